@@ -198,7 +198,7 @@ func (a *attachmentClient) Upload(ctx context.Context, authToken string, namespa
 	log.Debug().Msgf("finished uploading!")
 
 	var parsed types.CommonResponseTyped[entity.Attachment]
-	err = json.Unmarshal(body, &parsed)
+	err = unmarshalResponse(resp, body, &parsed)
 	if err != nil {
 		return nil, &types.CommonError{
 			Errors: []types.Error{
@@ -0,0 +1,125 @@
+package mycontentapiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// maximumBatchSize is the maximum items per batch accepted by the server
+const maximumBatchSize = 1000
+
+var _ mycontent.Batchable[mycontent.Data] = &client[mycontent.Data]{}
+
+// PostBatch to the "/batch" endpoint; the data must be of the same namespace
+func (c *client[T]) PostBatch(ctx context.Context, data []T, _ any) ([]mycontent.BatchResult[T], error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	namespace := data[0].Namespace()
+	for _, d := range data {
+		if d.Namespace() != namespace {
+			return nil, fmt.Errorf("post batch: %w: the data must be of the same namespace, got %v and %v",
+				mycontent.ErrValidation, namespace, d.Namespace())
+		}
+	}
+
+	// for client, meta is ignored
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("post batch: marshal data: %w", err)
+	}
+
+	return c.batch(ctx, http.MethodPost, namespace, payload)
+}
+
+// DeleteBatch to the "/batch" endpoint
+func (c *client[T]) DeleteBatch(ctx context.Context, namespace string, keys []mycontent.Key) ([]mycontent.BatchResult[T], error) {
+	params := make([]map[string]string, 0, len(keys))
+	for _, key := range keys {
+		param, err := toRefsParam(c.refsParam, key.RefIDs)
+		if err != nil {
+			return nil, fmt.Errorf("delete batch: %w", err)
+		}
+		param["id"] = key.ID
+		params = append(params, param)
+	}
+
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("delete batch: marshal keys: %w", err)
+	}
+
+	return c.batch(ctx, http.MethodDelete, namespace, payload)
+}
+
+func (c *client[T]) batch(ctx context.Context, method string, namespace string, payload []byte) ([]mycontent.BatchResult[T], error) {
+	wer, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("batch: invalid url: %w", err)
+	}
+	wer.Path = path.Join(wer.Path, "batch")
+
+	req, err := http.NewRequestWithContext(ctx, method, wer.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("batch: new request: %w", err)
+	}
+
+	if c.authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+c.authToken)
+	}
+	if namespace != "" {
+		req.Header.Add("X-Namespace", namespace)
+	}
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("batch: do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("batch: read body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotImplemented {
+		return nil, fmt.Errorf("batch: %w: %v", ErrBatchNotSupported, string(body))
+	}
+
+	var cr types.CommonResponseTyped[[]types.CommonResponseTyped[T]]
+	err = unmarshalResponse(resp, body, &cr)
+	if err != nil {
+		// the server without the endpoint
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+			return nil, fmt.Errorf("batch: %w: %v", ErrBatchNotSupported, string(body))
+		}
+		return nil, fmt.Errorf("batch: parse data from server: %w | %v", err, string(body))
+	}
+
+	var results []mycontent.BatchResult[T]
+	if len(cr.Success) > 0 {
+		results = make([]mycontent.BatchResult[T], len(cr.Success))
+		for idx, item := range cr.Success {
+			results[idx].Data = item.Success
+			if item.Error != nil {
+				results[idx].Err = parseError(item.Error)
+			}
+		}
+	}
+
+	if cr.Error != nil {
+		return results, fmt.Errorf("batch: from server: %w", parseError(cr.Error))
+	}
+
+	return results, nil
+}
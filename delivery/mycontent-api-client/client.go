@@ -9,13 +9,16 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	types "github.com/desain-gratis/common/types/http"
 )
 
 // TODO: follow this interface
 var _ mycontent.Usecase[mycontent.Data] = &client[mycontent.Data]{}
+var _ mycontent.Patchable[mycontent.Data] = &client[mycontent.Data]{}
 
 type client[T mycontent.Data] struct {
 	endpoint  string
@@ -55,16 +58,41 @@ func (c *client[T]) Get(ctx context.Context, namespace string, refIDs []string,
 	return c.get(ctx, c.authToken, namespace, params, ID)
 }
 
-// Stream response
+// List a page of your resource, filtered & ordered by the query
+func (c *client[T]) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	params, err := toRefsParamGet(c.refsParam, refIDs)
+	if err != nil {
+		return mycontent.Page[T]{}, fmt.Errorf("list: %w", err)
+	}
+
+	if q.Limit > 0 {
+		params["limit"] = strconv.Itoa(q.Limit)
+	}
+	if q.Cursor != "" {
+		params["cursor"] = q.Cursor
+	}
+	if q.OrderBy != content.OrderByKey {
+		params["order_by"] = string(q.OrderBy)
+	}
+	if q.Desc {
+		params["order"] = "desc"
+	}
+	for _, f := range q.Filters {
+		params["filter."+f.Field] = string(f.Value)
+	}
+
+	return c.list(ctx, c.authToken, namespace, params)
+}
+
+// Stream your resource as NDJSON, closed at the end of the stream or when the context is done.
+// The error that ends the stream early is reported with mycontent.ReportStreamError.
 func (c *client[T]) Stream(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan T, error) {
-	// params := map[string]string{}
-	// for idx := range refIDs {
-	// 	key := c.refsParam[idx]
-	// 	value := refIDs[idx]
-	// 	params[key] = value
-	// }
-	// TODO
-	return nil, errors.New("not implemented yet") // TODO
+	params, err := toRefsParamGet(c.refsParam, refIDs)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	return c.stream(ctx, c.authToken, namespace, params, ID)
 }
 
 // Delete your resource here
@@ -116,7 +144,7 @@ func (c *client[T]) delete(ctx context.Context, authToken string, namespace stri
 	}
 
 	var cr types.CommonResponseTyped[T]
-	err = json.Unmarshal(body, &cr)
+	err = unmarshalResponse(resp, body, &cr)
 	if err != nil {
 		return result, fmt.Errorf("delete: parse data from server: %w | %v", err, string(body))
 	}
@@ -170,7 +198,7 @@ func (c *client[T]) get(ctx context.Context, authToken string, namespace string,
 	}
 	if resp.StatusCode != 200 {
 		var commer types.CommonResponseTyped[error]
-		err = json.Unmarshal(body, &commer)
+		err = unmarshalResponse(resp, body, &commer)
 		if err != nil {
 			return result, fmt.Errorf("get: parse data from server: %w | %v", err, string(body))
 		}
@@ -180,7 +208,7 @@ func (c *client[T]) get(ctx context.Context, authToken string, namespace string,
 
 	var cr types.CommonResponseTyped[[]T]
 
-	err = json.Unmarshal(body, &cr)
+	err = unmarshalResponse(resp, body, &cr)
 	if err != nil {
 		return result, fmt.Errorf("get: parse server response: %w", err)
 	}
@@ -192,6 +220,218 @@ func (c *client[T]) get(ctx context.Context, authToken string, namespace string,
 	return cr.Success, nil
 }
 
+func (c *client[T]) list(ctx context.Context, authToken string, namespace string, params map[string]string) (result mycontent.Page[T], errUC error) {
+	wer, err := url.Parse(c.endpoint)
+	if err != nil {
+		return result, fmt.Errorf("list: %w", err)
+	}
+
+	v := wer.Query()
+	for param, value := range params {
+		v.Add(param, value)
+	}
+
+	wer.RawQuery = v.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wer.String(), nil)
+	if err != nil {
+		return result, fmt.Errorf("list: new request error: %w", err)
+	}
+
+	if authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Add("X-Namespace", namespace)
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("list: do error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("list: read body: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		var commer types.CommonResponseTyped[error]
+		err = unmarshalResponse(resp, body, &commer)
+		if err != nil {
+			return result, fmt.Errorf("list: parse data from server: %w | %v", err, string(body))
+		}
+
+		return result, parseError(commer.Error)
+	}
+
+	var cr types.CommonResponseTyped[[]T]
+
+	err = unmarshalResponse(resp, body, &cr)
+	if err != nil {
+		return result, fmt.Errorf("list: parse server response: %w", err)
+	}
+
+	if cr.Error != nil {
+		return result, fmt.Errorf("list: from server: %w", parseError(cr.Error))
+	}
+
+	return mycontent.Page[T]{
+		Data: cr.Success,
+		Next: resp.Header.Get("DG-Next-Cursor"),
+	}, nil
+}
+
+func (c *client[T]) stream(ctx context.Context, authToken string, namespace string, refIDs map[string]string, ID string) (<-chan T, error) {
+	wer, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	v := wer.Query()
+	if ID != "" {
+		v.Add("id", ID)
+	}
+
+	for param, value := range refIDs {
+		v.Add(param, value)
+	}
+
+	wer.RawQuery = v.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wer.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("stream: new request error: %w", err)
+	}
+
+	if authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Add("X-Namespace", namespace)
+	req.Header.Add("Accept", "application/x-ndjson")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("stream: do error: %w", err)
+	}
+
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("stream: read body: %w", err)
+		}
+
+		var commer types.CommonResponseTyped[error]
+		err = unmarshalResponse(resp, body, &commer)
+		if err != nil {
+			return nil, fmt.Errorf("stream: parse data from server: %w | %v", err, string(body))
+		}
+
+		return nil, parseError(commer.Error)
+	}
+
+	result := make(chan T)
+
+	go func() {
+		defer close(result)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var frame types.CommonResponseTyped[*T]
+			err := dec.Decode(&frame)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					mycontent.ReportStreamError(ctx, fmt.Errorf("stream: parse server response: %w", err))
+				}
+				return
+			}
+
+			if frame.Error != nil {
+				mycontent.ReportStreamError(ctx, fmt.Errorf("stream: from server: %w", parseError(frame.Error)))
+				return
+			}
+
+			if frame.Success == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case result <- *frame.Success:
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// Patch with a JSON merge patch (RFC 7396)
+func (c *client[T]) Patch(ctx context.Context, namespace string, refIDs []string, ID string, patch []byte, _ any) (T, error) {
+	// for client, meta is ignored
+	params, err := toRefsParam(c.refsParam, refIDs)
+	if err != nil {
+		var t T
+		return t, fmt.Errorf("patch: %w", err)
+	}
+
+	return c.patch(ctx, c.authToken, namespace, params, ID, patch)
+}
+
+func (c *client[T]) patch(ctx context.Context, authToken string, namespace string, refIDs map[string]string, ID string, patch []byte) (result T, errUC error) {
+	wer, err := url.Parse(c.endpoint)
+	if err != nil {
+		return result, fmt.Errorf("patch: invalid url: %w", err)
+	}
+
+	v := wer.Query()
+	v.Add("id", ID)
+
+	for param, value := range refIDs {
+		v.Add(param, value)
+	}
+
+	wer.RawQuery = v.Encode()
+
+	req, err := http.NewRequest(http.MethodPatch, wer.String(), bytes.NewReader(patch))
+	if err != nil {
+		return result, fmt.Errorf("patch: new request: %w", err)
+	}
+
+	req = req.WithContext(ctx)
+	if authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Add("X-Namespace", namespace)
+	req.Header.Add("Content-Type", "application/merge-patch+json")
+
+	resp, err := c.httpc.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("patch: do: %w", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("patch: read body: %w", err)
+	}
+
+	var cr types.CommonResponseTyped[T]
+	err = unmarshalResponse(resp, body, &cr)
+	if err != nil {
+		return result, fmt.Errorf("patch: parse data from server: %w | %v", err, string(body))
+	}
+
+	if cr.Error != nil {
+		return result, fmt.Errorf("patch: from server: %w", parseError(cr.Error))
+	}
+
+	return cr.Success, nil
+}
+
 func (c *client[T]) post(ctx context.Context, authToken string, data T) (result T, errUC error) {
 	payload, err := json.Marshal(data)
 	if err != nil {
@@ -222,7 +462,7 @@ func (c *client[T]) post(ctx context.Context, authToken string, data T) (result
 	}
 
 	var cr types.CommonResponseTyped[T]
-	err = json.Unmarshal(body, &cr)
+	err = unmarshalResponse(resp, body, &cr)
 	if err != nil {
 		return result, fmt.Errorf("post: parse data from server: %w | %v", err, string(body))
 	}
@@ -254,15 +494,3 @@ func toRefsParamGet(refsParam []string, refIDs []string) (map[string]string, err
 
 	return result, nil
 }
-
-func parseError(err *types.CommonError) error {
-	if err == nil || err.Errors == nil {
-		return nil
-	}
-	var errs error
-	for _, err := range err.Errors {
-		errs = errors.Join(errs, fmt.Errorf("%v: %v", err.Code, err.Message))
-	}
-
-	return errs
-}
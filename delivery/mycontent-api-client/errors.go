@@ -0,0 +1,98 @@
+package mycontentapiclient
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// The error from the server is matched by its code with errors.Is
+var (
+	ErrNotFound     = mycontent.ErrNotFound
+	ErrConflict     = content.ErrConflict
+	ErrValidation   = mycontent.ErrValidation
+	ErrUnauthorized = mycontent.ErrUnauthorized
+	ErrForbidden    = mycontent.ErrForbidden
+	ErrNotApplied   = mycontent.ErrNotApplied
+
+	// ErrBatchNotSupported by the server, without the "/batch" endpoint or with a storage that cannot write it all or nothing
+	ErrBatchNotSupported = errors.New("batch is not supported")
+)
+
+// ServerError is an error from the server, with its code and HTTP status
+type ServerError struct {
+	HTTPCode int
+	Code     string
+	Message  string
+	Field    string // JSON pointer of the invalid field, if any
+}
+
+func (e *ServerError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap to the sentinel of the code; a conflict is also the *content.ConflictError with the current version
+func (e *ServerError) Unwrap() []error {
+	switch e.Code {
+	case "NOT_FOUND":
+		return []error{ErrNotFound}
+	case "CONFLICT", "PRECONDITION_FAILED":
+		if conflict, ok := content.ParseConflictError(e.Message); ok {
+			return []error{conflict}
+		}
+		return []error{ErrConflict}
+	case "BAD_REQUEST", "INVALID_PARAMS", "EMPTY_NAMESPACE":
+		return []error{ErrValidation}
+	case "UNAUTHORIZED":
+		return []error{ErrUnauthorized}
+	case "FORBIDDEN":
+		return []error{ErrForbidden}
+	case "NOT_LEADER":
+		return []error{content.ErrNotLeader}
+	case "NOT_APPLIED":
+		return []error{ErrNotApplied}
+	}
+	return nil
+}
+
+func parseError(err *types.CommonError) error {
+	if err == nil || err.Errors == nil {
+		return nil
+	}
+	var errs error
+	for _, err := range err.Errors {
+		errs = errors.Join(errs, &ServerError{
+			HTTPCode: err.HTTPCode,
+			Code:     err.Code,
+			Message:  err.Message,
+			Field:    err.Field,
+		})
+	}
+
+	return errs
+}
+
+// unmarshalResponse of the server; problem details (application/problem+json) is the same as {"error": ...}
+func unmarshalResponse(resp *http.Response, body []byte, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" {
+		return json.Unmarshal(body, v)
+	}
+
+	var problem types.Problem
+	err := json.Unmarshal(body, &problem)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := json.Marshal(&types.CommonResponse{Error: problem.CommonError()})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(normalized, v)
+}
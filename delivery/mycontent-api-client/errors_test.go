@@ -0,0 +1,131 @@
+package mycontentapiclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+func TestServerErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrConflict, ErrValidation, ErrUnauthorized, ErrForbidden, ErrNotApplied, content.ErrNotLeader}
+
+	for _, tc := range []struct {
+		code string
+		want error
+	}{
+		{"NOT_FOUND", ErrNotFound},
+		{"CONFLICT", ErrConflict},
+		{"PRECONDITION_FAILED", ErrConflict},
+		{"BAD_REQUEST", ErrValidation},
+		{"INVALID_PARAMS", ErrValidation},
+		{"EMPTY_NAMESPACE", ErrValidation},
+		{"UNAUTHORIZED", ErrUnauthorized},
+		{"FORBIDDEN", ErrForbidden},
+		{"NOT_APPLIED", ErrNotApplied},
+		{"NOT_LEADER", content.ErrNotLeader},
+		{"SERVER_ERROR", nil},
+	} {
+		t.Run(tc.code, func(t *testing.T) {
+			err := error(&ServerError{Code: tc.code, Message: "message"})
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tc.want) {
+					t.Fatalf("expected errors.Is(%v) to be %v", sentinel, !got)
+				}
+			}
+		})
+	}
+}
+
+func TestServerErrorConflict(t *testing.T) {
+	for _, tc := range []struct {
+		code        string
+		conflict    *content.ConflictError
+		nextVersion bool
+	}{
+		{"CONFLICT", &content.ConflictError{Expected: 1, Current: 2, NextVersion: true}, true},
+		{"PRECONDITION_FAILED", &content.ConflictError{Expected: 1, Current: 2}, false},
+	} {
+		err := error(&ServerError{Code: tc.code, Message: tc.conflict.Error()})
+
+		var conflict *content.ConflictError
+		if !errors.As(err, &conflict) || conflict.Current != 2 || conflict.NextVersion != tc.nextVersion || !errors.Is(err, ErrConflict) {
+			t.Fatalf("%v: expected the conflict with the current version, got %+v", tc.code, conflict)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		status      int
+		body        string
+		want        error
+		field       string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			status:      http.StatusNotFound,
+			body:        `{"error":{"errors":[{"http_code":404,"code":"NOT_FOUND","message":"not found"}]}}`,
+			want:        ErrNotFound,
+		},
+		{
+			name:        "problem details",
+			contentType: "application/problem+json",
+			status:      http.StatusForbidden,
+			body:        `{"type":"about:blank","title":"Forbidden","status":403,"code":"FORBIDDEN","detail":"forbidden"}`,
+			want:        ErrForbidden,
+		},
+		{
+			name:        "problem details with field errors",
+			contentType: "application/problem+json; charset=utf-8",
+			status:      http.StatusBadRequest,
+			body: `{"type":"about:blank","title":"Bad Request","status":400,"code":"BAD_REQUEST","detail":"missing property",` +
+				`"errors":[{"http_code":400,"code":"BAD_REQUEST","message":"missing property","field":"/title"}]}`,
+			want:  ErrValidation,
+			field: "/title",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			c := New[*entity.Document](srv.Client(), srv.URL, []string{"owner"}, "")
+			_, err := c.Get(context.Background(), "ns", []string{"o"}, "d1")
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+
+			var serr *ServerError
+			if !errors.As(err, &serr) || serr.HTTPCode != tc.status || serr.Field != tc.field {
+				t.Fatalf("expected the server error of %v with the field %q, got %+v", tc.status, tc.field, serr)
+			}
+		})
+	}
+}
+
+func TestParseErrorJoin(t *testing.T) {
+	err := parseError(&types.CommonError{Errors: []types.Error{
+		{HTTPCode: 400, Code: "BAD_REQUEST", Message: "a", Field: "/a"},
+		{HTTPCode: 400, Code: "BAD_REQUEST", Message: "b", Field: "/b"},
+	}})
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 || !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected every error of the response, got %v", err)
+	}
+	if parseError(nil) != nil || parseError(&types.CommonError{}) != nil {
+		t.Fatal("expected no error without the errors")
+	}
+}
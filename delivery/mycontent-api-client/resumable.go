@@ -0,0 +1,160 @@
+package mycontentapiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// partRetries of each part of UploadResumable before it gives up; the upload can still be resumed later by its ID
+const partRetries = 3
+
+// UploadResumable the attachment in parts of the upload session; a failed part is retried.
+// An empty uploadID starts a new session, otherwise only the parts not uploaded yet are uploaded.
+// The upload ID is returned with the error, so it can be resumed later.
+func (a *attachmentClient) UploadResumable(ctx context.Context, authToken string, namespace string, metadata *entity.Attachment, file io.ReaderAt, size int64, uploadID string) (*entity.Attachment, string, error) {
+	var session *mycontent.UploadSession[*entity.Attachment]
+	var err error
+	if uploadID == "" {
+		metadata.ContentSize = uint64(size)
+		session, err = a.StartUpload(ctx, authToken, namespace, metadata)
+	} else {
+		session, err = a.GetUpload(ctx, authToken, namespace, uploadID)
+	}
+	if err != nil {
+		return nil, uploadID, err
+	}
+
+	for _, number := range session.Missing() {
+		partSize := mycontent.PartSizeOf(size, session.PartSize, number)
+		payload := make([]byte, partSize)
+		_, err := file.ReadAt(payload, int64(number-1)*session.PartSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, session.ID, fmt.Errorf("upload: read part %v: %w", number, err)
+		}
+
+		for attempt := 1; ; attempt++ {
+			_, err = a.UploadPart(ctx, authToken, namespace, session.ID, number, payload)
+			if err == nil {
+				break
+			}
+			if attempt == partRetries || errors.Is(err, ErrValidation) || errors.Is(err, ErrNotFound) {
+				return nil, session.ID, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, session.ID, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+
+	result, err := a.CompleteUpload(ctx, authToken, namespace, session.ID)
+	if err != nil {
+		return nil, session.ID, err
+	}
+
+	return result, session.ID, nil
+}
+
+// StartUpload session of the attachment, with its content size
+func (a *attachmentClient) StartUpload(ctx context.Context, authToken string, namespace string, metadata *entity.Attachment) (*mycontent.UploadSession[*entity.Attachment], error) {
+	if metadata.Namespace() == "" {
+		metadata.WithNamespace(namespace)
+	}
+
+	payload, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("start upload: marshal metadata: %w", err)
+	}
+
+	return doUpload[*mycontent.UploadSession[*entity.Attachment]](ctx, a, "start upload", http.MethodPost, "/uploads", authToken, namespace, nil, payload)
+}
+
+// GetUpload session with the parts uploaded so far
+func (a *attachmentClient) GetUpload(ctx context.Context, authToken string, namespace string, uploadID string) (*mycontent.UploadSession[*entity.Attachment], error) {
+	return doUpload[*mycontent.UploadSession[*entity.Attachment]](ctx, a, "get upload", http.MethodGet, "/uploads", authToken, namespace, url.Values{
+		"upload_id": {uploadID},
+	}, nil)
+}
+
+// UploadPart number (from 1) of the session
+func (a *attachmentClient) UploadPart(ctx context.Context, authToken string, namespace string, uploadID string, number int, payload []byte) (*mycontent.UploadPart, error) {
+	return doUpload[*mycontent.UploadPart](ctx, a, "upload part", http.MethodPut, "/uploads", authToken, namespace, url.Values{
+		"upload_id": {uploadID},
+		"part":      {strconv.Itoa(number)},
+	}, payload)
+}
+
+// CompleteUpload after every part is uploaded
+func (a *attachmentClient) CompleteUpload(ctx context.Context, authToken string, namespace string, uploadID string) (*entity.Attachment, error) {
+	return doUpload[*entity.Attachment](ctx, a, "complete upload", http.MethodPost, "/uploads/complete", authToken, namespace, url.Values{
+		"upload_id": {uploadID},
+	}, nil)
+}
+
+// AbortUpload and discard its uploaded parts
+func (a *attachmentClient) AbortUpload(ctx context.Context, authToken string, namespace string, uploadID string) error {
+	_, err := doUpload[map[string]string](ctx, a, "abort upload", http.MethodDelete, "/uploads", authToken, namespace, url.Values{
+		"upload_id": {uploadID},
+	}, nil)
+	return err
+}
+
+func doUpload[R any](ctx context.Context, a *attachmentClient, op string, method string, path string, authToken string, namespace string, params url.Values, body []byte) (result R, errUC error) {
+	wer, err := url.Parse(a.endpoint + path)
+	if err != nil {
+		return result, fmt.Errorf("%v: invalid url: %w", op, err)
+	}
+	wer.RawQuery = params.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, wer.String(), reader)
+	if err != nil {
+		return result, fmt.Errorf("%v: new request: %w", op, err)
+	}
+
+	if authToken != "" {
+		req.Header.Add("Authorization", "Bearer "+authToken)
+	}
+	req.Header.Add("X-Namespace", namespace)
+
+	resp, err := a.httpc.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("%v: do: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("%v: read body: %w", op, err)
+	}
+
+	var cr types.CommonResponseTyped[R]
+	err = unmarshalResponse(resp, respBody, &cr)
+	if err != nil {
+		return result, fmt.Errorf("%v: parse data from server: %w | %v", op, err, string(respBody))
+	}
+
+	if cr.Error != nil {
+		return result, fmt.Errorf("%v: from server: %w", op, parseError(cr.Error))
+	}
+
+	return cr.Success, nil
+}
@@ -2,6 +2,7 @@ package mycontentapiclient
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -126,19 +127,22 @@ func (s *sync[T]) Execute(ctx context.Context) error {
 	// 3. check if local project exist in server, if not create one, only for entity that doesn't have ID yet.
 	// ID is used if the local entity have file or image dependencies.
 
-	// TODO: using goroutine pool
+	var toCreate []T
 	for _, localEntity := range localEntities {
 		key := getKey2(localEntity)
 		if _, ok := remoteEntitiesMap[key]; !ok && localEntity.ID() == "" {
-			synced, err := s.client.Post(ctx, localEntity, nil)
-			if err != nil {
-				log.Error().Msgf("Failed to create entity of type %T with key %v: %v", localEntity, key, err)
-				continue
-			}
-			localEntity.WithID(synced.ID())
+			toCreate = append(toCreate, localEntity)
 		}
 	}
 
+	s.postBatch(ctx, toCreate, func(localEntity T, synced T, err error) {
+		if err != nil {
+			log.Error().Msgf("Failed to create entity of type %T with key %v: %v", localEntity, getKey2(localEntity), err)
+			return
+		}
+		localEntity.WithID(synced.ID())
+	})
+
 	localEntitiesMap := make(map[string]T)
 	for _, localEntity := range localEntities {
 		key := getKey2(localEntity)
@@ -146,17 +150,24 @@ func (s *sync[T]) Execute(ctx context.Context) error {
 	}
 
 	// 4. inversely, for all remote project that is not in local, delete them
+	//    (the batch delete is per namespace)
+	toDelete := make(map[string][]T)
 	for _, remoteEntity := range remoteEntities {
 		if !s.OptConfig.Hard {
 			break
 		}
 		remoteID := getKey2(remoteEntity)
 		if _, ok := localEntitiesMap[remoteID]; !ok {
-			_, err := s.client.Delete(ctx, remoteEntity.Namespace(), remoteEntity.RefIDs(), remoteEntity.ID())
+			toDelete[remoteEntity.Namespace()] = append(toDelete[remoteEntity.Namespace()], remoteEntity)
+		}
+	}
+
+	for namespace, remoteEntities := range toDelete {
+		s.deleteBatch(ctx, namespace, remoteEntities, func(remoteEntity T, err error) {
 			if err != nil {
-				log.Error().Msgf("Failed to delete remote entity with id: %v err: %v", remoteID, err)
+				log.Error().Msgf("Failed to delete remote entity with id: %v err: %v", getKey2(remoteEntity), err)
 			}
-		}
+		})
 	}
 
 	// 5. Now the entity are synced. But the dependencies are not yet.
@@ -198,17 +209,117 @@ func (s *sync[T]) Execute(ctx context.Context) error {
 	}
 
 	// Sync back the project since the data in localProjects have been already modified
-	for _, localEntity := range localEntities {
-		// TODO: calculate hash or compare directly to optimize upload
-		_, err = s.client.Post(ctx, localEntity, nil)
+	// TODO: calculate hash or compare directly to optimize upload
+	s.postBatch(ctx, localEntities, func(_ T, _ T, err error) {
 		if err != nil {
 			log.Error().Msgf("Failed to update project definition %+v", err)
 		}
-	}
+	})
 
 	return nil
 }
 
+// postBatch the entities per namespace, in chunks of the maximum batch size; done is called for each entity
+func (s *sync[T]) postBatch(ctx context.Context, entities []T, done func(local T, synced T, err error)) {
+	var namespaces []string
+	byNamespace := make(map[string][]T)
+	for _, entity := range entities {
+		if _, ok := byNamespace[entity.Namespace()]; !ok {
+			namespaces = append(namespaces, entity.Namespace())
+		}
+		byNamespace[entity.Namespace()] = append(byNamespace[entity.Namespace()], entity)
+	}
+
+	for _, namespace := range namespaces {
+		entities := byNamespace[namespace]
+		for start := 0; start < len(entities); start += maximumBatchSize {
+			chunk := entities[start:min(start+maximumBatchSize, len(entities))]
+
+			applyBatch(chunk,
+				func(items []T) ([]mycontent.BatchResult[T], error) {
+					return s.client.PostBatch(ctx, items, nil)
+				},
+				func(item T) (T, error) {
+					return s.client.Post(ctx, item, nil)
+				},
+				done,
+			)
+		}
+	}
+}
+
+// deleteBatch the entities of a namespace in chunks of the maximum batch size; done is called for each entity.
+// The entity already gone is deleted.
+func (s *sync[T]) deleteBatch(ctx context.Context, namespace string, entities []T, done func(remote T, err error)) {
+	for start := 0; start < len(entities); start += maximumBatchSize {
+		chunk := entities[start:min(start+maximumBatchSize, len(entities))]
+
+		applyBatch(chunk,
+			func(items []T) ([]mycontent.BatchResult[T], error) {
+				keys := make([]mycontent.Key, 0, len(items))
+				for _, remote := range items {
+					keys = append(keys, mycontent.Key{RefIDs: remote.RefIDs(), ID: remote.ID()})
+				}
+				return s.client.DeleteBatch(ctx, namespace, keys)
+			},
+			func(item T) (T, error) {
+				return s.client.Delete(ctx, namespace, item.RefIDs(), item.ID())
+			},
+			func(remote T, _ T, err error) {
+				if errors.Is(err, ErrNotFound) {
+					err = nil
+				}
+				done(remote, err)
+			},
+		)
+	}
+}
+
+// applyBatch of the items; done is called for each item.
+// When the batch fails, the items not applied because of a failing item are batched again without it.
+// The items are applied one by one if the server has no batch, or the batch fails without a failing item.
+// The batch failed without any result (eg. a network error) might be applied, so it is not applied again.
+func applyBatch[T any](items []T, batch func([]T) ([]mycontent.BatchResult[T], error), single func(T) (T, error), done func(item T, result T, err error)) {
+	oneByOne := func(items []T) {
+		for _, item := range items {
+			result, err := single(item)
+			done(item, result, err)
+		}
+	}
+
+	for len(items) > 0 {
+		results, err := batch(items)
+		if errors.Is(err, ErrBatchNotSupported) {
+			oneByOne(items)
+			return
+		}
+
+		var retry []T
+		for idx, item := range items {
+			var result T
+			errItem := err
+			if idx < len(results) {
+				result = results[idx].Data
+				if results[idx].Err != nil {
+					errItem = results[idx].Err
+				}
+			}
+
+			if err != nil && errors.Is(errItem, ErrNotApplied) {
+				retry = append(retry, item)
+				continue
+			}
+			done(item, result, errItem)
+		}
+
+		if len(retry) == len(items) {
+			oneByOne(retry)
+			return
+		}
+		items = retry
+	}
+}
+
 func attachmentToThumbnails(input map[string]*content.Attachment) map[string]*entity.Image {
 	result := make(map[string]*entity.Image)
 	for k, v := range input {
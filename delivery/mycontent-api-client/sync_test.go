@@ -0,0 +1,157 @@
+package mycontentapiclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+var errBad = errors.New("bad item")
+
+// transactional batch of a server: nothing is applied if any item is bad
+func transactional(bad ...string) func([]string) ([]mycontent.BatchResult[string], error) {
+	return func(items []string) ([]mycontent.BatchResult[string], error) {
+		results := make([]mycontent.BatchResult[string], len(items))
+		var failed bool
+		for idx, item := range items {
+			results[idx].Data = item
+			if slices.Contains(bad, item) {
+				results[idx].Err, failed = errBad, true
+			}
+		}
+		if !failed {
+			return results, nil
+		}
+		for idx := range results {
+			if results[idx].Err == nil {
+				results[idx].Err = ErrNotApplied
+			}
+		}
+		return results, fmt.Errorf("batch: from server: %w", errBad)
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		batch        func([]string) ([]mycontent.BatchResult[string], error)
+		wantOK       []string
+		wantErr      []string
+		wantOneByOne []string
+	}{
+		{
+			name:   "all applied",
+			batch:  transactional(),
+			wantOK: []string{"a", "b", "c"},
+		},
+		{
+			name:    "batched again without the bad item",
+			batch:   transactional("b"),
+			wantOK:  []string{"a", "c"},
+			wantErr: []string{"b"},
+		},
+		{
+			name: "server without batch",
+			batch: func([]string) ([]mycontent.BatchResult[string], error) {
+				return nil, fmt.Errorf("batch: %w", ErrBatchNotSupported)
+			},
+			wantOK:       []string{"a", "b", "c"},
+			wantOneByOne: []string{"a", "b", "c"},
+		},
+		{
+			name: "failed without a failing item",
+			batch: func(items []string) ([]mycontent.BatchResult[string], error) {
+				results := make([]mycontent.BatchResult[string], len(items))
+				for idx := range results {
+					results[idx].Err = ErrNotApplied
+				}
+				return results, errors.New("storage error")
+			},
+			wantOK:       []string{"a", "b", "c"},
+			wantOneByOne: []string{"a", "b", "c"},
+		},
+		{
+			// it might be applied, so it is not applied again
+			name: "failed without any result",
+			batch: func(items []string) ([]mycontent.BatchResult[string], error) {
+				return nil, fmt.Errorf("batch: do: %w", errBad)
+			},
+			wantErr: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ok, failed, single []string
+			applyBatch([]string{"a", "b", "c"}, tt.batch,
+				func(item string) (string, error) {
+					single = append(single, item)
+					return item, nil
+				},
+				func(item string, result string, err error) {
+					if err != nil {
+						if !errors.Is(err, errBad) {
+							t.Fatalf("item %v: unexpected error %v", item, err)
+						}
+						failed = append(failed, item)
+						return
+					}
+					if result != item {
+						t.Fatalf("item %v: got result %v", item, result)
+					}
+					ok = append(ok, item)
+				},
+			)
+
+			slices.Sort(ok)
+			if !slices.Equal(ok, tt.wantOK) || !slices.Equal(failed, tt.wantErr) || !slices.Equal(single, tt.wantOneByOne) {
+				t.Fatalf("got applied %v, failed %v, one by one %v; want %v, %v, %v",
+					ok, failed, single, tt.wantOK, tt.wantErr, tt.wantOneByOne)
+			}
+		})
+	}
+}
+
+func TestBatchNotSupported(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(status), status)
+		}))
+
+		c := New[*entity.Attachment](srv.Client(), srv.URL+"/attachment", nil, "")
+		_, err := c.DeleteBatch(t.Context(), "ns", []mycontent.Key{{ID: "a"}})
+		srv.Close()
+
+		if !errors.Is(err, ErrBatchNotSupported) {
+			t.Fatalf("status %v: expected batch not supported, got %v", status, err)
+		}
+	}
+}
+
+func TestPostBatchNamespace(t *testing.T) {
+	var namespaces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespaces = append(namespaces, r.Header.Get("X-Namespace"))
+		http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+	}))
+	defer srv.Close()
+
+	c := New[*entity.Attachment](srv.Client(), srv.URL+"/attachment", nil, "")
+
+	_, err := c.PostBatch(t.Context(), []*entity.Attachment{{OwnerId: "ns"}, {OwnerId: "ns"}}, nil)
+	if !errors.Is(err, ErrBatchNotSupported) || !slices.Equal(namespaces, []string{"ns"}) {
+		t.Fatalf("expected the batch under the X-Namespace of the data, got %v %v", namespaces, err)
+	}
+
+	// the server authorizes the batch by the header, so it cannot be of many namespaces
+	_, err = c.PostBatch(t.Context(), []*entity.Attachment{{OwnerId: "ns"}, {OwnerId: "other"}}, nil)
+	if !errors.Is(err, mycontent.ErrValidation) || len(namespaces) != 1 {
+		t.Fatalf("expected the batch rejected before it is sent, got %v %v", namespaces, err)
+	}
+}
@@ -1,6 +1,7 @@
 package mycontentapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -23,13 +25,24 @@ import (
 
 const maximumRequestLength = 1 << 20
 const maximumRequestLengthAttachment = 100 << 20
+const maximumListLimit = 1000
+
+// filterParamPrefix of the Get parameter to filter by a data field, eg. filter.status=active
+const filterParamPrefix = "filter."
 
 type service[T mycontent.Data] struct {
-	uc                   mycontent.Usecase[T]
-	refParams            []string
-	whitelistParams      map[string]struct{}
-	postProcess          []PostProcess[T]
-	enableOptimisticLock bool
+	uc                     mycontent.Usecase[T]
+	refParams              []string
+	whitelistParams        map[string]struct{}
+	whitelistListParams    map[string]struct{}
+	whitelistWatchParams   map[string]struct{}
+	whitelistHistoryParams map[string]struct{}
+	whitelistRestoreParams map[string]struct{}
+	whitelistTrashParams   map[string]struct{}
+	websocketOrigins       []string
+	postProcess            []PostProcess[T]
+	enableOptimisticLock   bool
+	policy                 mycontent.Policy
 }
 
 type PostProcess[T mycontent.Data] func(t T)
@@ -56,10 +69,52 @@ func New[T mycontent.Data](
 		whitelistParams[refParams] = struct{}{}
 	}
 
+	whitelistListParams := map[string]struct{}{
+		"limit":    {},
+		"cursor":   {},
+		"order_by": {},
+		"order":    {},
+	}
+	for param := range whitelistParams {
+		whitelistListParams[param] = struct{}{}
+	}
+
+	whitelistWatchParams := map[string]struct{}{
+		"from": {},
+	}
+	for _, param := range refParams {
+		whitelistWatchParams[param] = struct{}{}
+	}
+
+	whitelistHistoryParams := map[string]struct{}{
+		"limit":  {},
+		"cursor": {},
+	}
+	whitelistRestoreParams := map[string]struct{}{
+		"version": {},
+	}
+	whitelistTrashParams := map[string]struct{}{
+		"limit":  {},
+		"cursor": {},
+	}
+	for _, param := range refParams {
+		whitelistHistoryParams[param] = struct{}{}
+		whitelistRestoreParams[param] = struct{}{}
+		whitelistTrashParams[param] = struct{}{}
+	}
+
+	// a specific version of versioned data
+	whitelistListParams["version"] = struct{}{}
+
 	return &service[T]{
-		uc:              uc,
-		refParams:       refParams,
-		whitelistParams: whitelistParams,
+		uc:                     uc,
+		refParams:              refParams,
+		whitelistParams:        whitelistParams,
+		whitelistListParams:    whitelistListParams,
+		whitelistWatchParams:   whitelistWatchParams,
+		whitelistHistoryParams: whitelistHistoryParams,
+		whitelistRestoreParams: whitelistRestoreParams,
+		whitelistTrashParams:   whitelistTrashParams,
 		postProcess: []PostProcess[T]{
 			FormatURL[T](baseURL, refParams),
 		},
@@ -73,7 +128,25 @@ func (i *service[T]) WithPostProcess(t PostProcess[T]) *service[T] {
 	return i
 }
 
+// WithOptimisticLock requires the DG-Optimistic-Lock-Version header on Post, see capturePreconditions
+func (i *service[T]) WithOptimisticLock() *service[T] {
+	i.enableOptimisticLock = true
+	return i
+}
+
 func (i *service[T]) Post(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
 	// Read body parse entity and extract metadata
 
 	if len(r.URL.Query()) > 0 {
@@ -81,19 +154,19 @@ func (i *service[T]) Post(w http.ResponseWriter, r *http.Request, p httprouter.P
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, maximumRequestLength)
-	payload, err := io.ReadAll(r.Body)
-	if err != nil {
-		handleError(w, "SERVER_ERROR", "failed to read payload", http.StatusInternalServerError, err)
+	payload, ok := readBody(w, r, maximumRequestLength)
+	if !ok {
 		return
 	}
 
 	var resource T
-	err = json.Unmarshal(payload, &resource)
+	err := json.Unmarshal(payload, &resource)
 	if err != nil {
-		handleError(
-			w, "BAD_REQUEST", "failed to parse body. Make sure file size does not exceed 200Kb",
-			http.StatusBadRequest, nil)
+		handleError(w, "BAD_REQUEST", "failed to parse body: "+err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	if !sameNamespace(w, namespace, resource.Namespace()) {
 		return
 	}
 
@@ -112,7 +185,7 @@ func (i *service[T]) Post(w http.ResponseWriter, r *http.Request, p httprouter.P
 
 	// Forward optimistic lock information for any supported storage
 	// If storage not support this, can safely ignore the meta
-	err = captureOptimisticLock(meta, r.Header.Get("DG-Optimistic-Lock-Version"), i.enableOptimisticLock)
+	err = capturePreconditions(r, meta, i.enableOptimisticLock)
 	if err != nil {
 		handleError(
 			w, "BAD_REQUEST",
@@ -122,9 +195,16 @@ func (i *service[T]) Post(w http.ResponseWriter, r *http.Request, p httprouter.P
 		return
 	}
 
-	result, err := i.uc.Post(r.Context(), resource, meta)
+	// the usecase might read before write (eg. optimistic lock)
+	ctx, err := withReadConsistency(r)
 	if err != nil {
-		handlePostError(w, err)
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	result, err := i.uc.Post(ctx, resource, meta)
+	if err != nil {
+		handleWriteError(w, err, meta)
 		return
 	}
 
@@ -157,7 +237,11 @@ func (i *service[T]) Get(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		return
 	}
 
-	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistListParams, r.URL.Query(), filterParamPrefix)
 	if len(invalidParams) > 0 {
 		handleError(
 			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
@@ -171,13 +255,62 @@ func (i *service[T]) Get(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		refIDs = append(refIDs, r.URL.Query().Get(param))
 	}
 
+	// the versions of versioned data are their IDs
+	if version := r.URL.Query().Get("version"); version != "" {
+		if _, ok := i.uc.(mycontent.Historical[T]); !ok {
+			handleError(w, "BAD_REQUEST", "'version' is only for versioned data", http.StatusBadRequest, nil)
+			return
+		}
+		if ID != "" && ID != version {
+			handleError(w, "BAD_REQUEST", "'id' and 'version' must be the same, if both are specified", http.StatusBadRequest, nil)
+			return
+		}
+		ID = version
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	q, isList, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	if isList && ID != "" {
+		handleError(w, "BAD_REQUEST", "'id' cannot be combined with list parameters", http.StatusBadRequest, nil)
+		return
+	}
+
 	// Actually get the data
-	result, err := i.uc.Get(r.Context(), namespace, refIDs, ID)
+	var result []T
+	if isList {
+		var page mycontent.Page[T]
+		page, err = i.uc.List(ctx, namespace, refIDs, q)
+		if page.Next != "" {
+			w.Header().Set("DG-Next-Cursor", page.Next)
+		}
+		result = page.Data
+	} else {
+		result, err = i.uc.Get(ctx, namespace, refIDs, ID)
+	}
 	if err != nil {
-		handleGetError(w, err)
+		handleUsecaseError(w, err)
 		return
 	}
 
+	// the current version, to be used as the If-Match of the next write
+	var etag string
+	if versionable, ok := i.uc.(mycontent.Versionable); ok && ID != "" && len(result) == 1 {
+		version, err := versionable.Version(ctx, namespace, refIDs, ID)
+		if err == nil {
+			etag = versionETag(version)
+		}
+	}
+
 	for _, pp := range i.postProcess {
 		for idx := range result {
 			pp(result[idx])
@@ -192,7 +325,23 @@ func (i *service[T]) Get(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 		handleError(
 			w, "SERVER_ERROR", "server encounter an error",
 			http.StatusInternalServerError, err)
+		return
+	}
+
+	if etag == "" {
+		etag = payloadETag(payload)
 	}
+	w.Header().Set("ETag", etag)
+
+	if modified := lastModified(result); !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if !matchNoneOf(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	w.Write(payload)
 }
@@ -206,6 +355,10 @@ func (i *service[T]) Stream(w http.ResponseWriter, r *http.Request, p httprouter
 		return
 	}
 
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
+		return
+	}
+
 	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
 	if len(invalidParams) > 0 {
 		handleError(
@@ -220,20 +373,57 @@ func (i *service[T]) Stream(w http.ResponseWriter, r *http.Request, p httprouter
 		refIDs = append(refIDs, r.URL.Query().Get(param))
 	}
 
-	// Actually get the data
-	result, err := i.uc.Stream(r.Context(), namespace, refIDs, ID)
+	ctx, err := withReadConsistency(r)
 	if err != nil {
-		handleGetError(w, err)
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// stops the usecase once the client is gone, or we stop writing
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx, streamErr := mycontent.WithStreamError(ctx)
 
-	for res := range result {
-		for _, pp := range i.postProcess {
-			pp(res)
-			payload, _ := json.Marshal(res)
-			fmt.Fprintln(w, string(payload))
+	// Actually get the data
+	result, err := i.uc.Stream(ctx, namespace, refIDs, ID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	sw := newStreamWriter(w, r.Header.Get("Accept"))
+	sw.start()
+
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				sw.writeError(ctx.Err())
+			}
+			return
+		case <-ticker.C:
+			sw.flush()
+		case res, ok := <-result:
+			if !ok {
+				if err := streamErr.Err(); err != nil {
+					sw.writeError(err)
+				}
+				sw.flush()
+				return
+			}
+
+			for _, pp := range i.postProcess {
+				pp(res)
+			}
+
+			if err := sw.write(res); err != nil {
+				slog.Warn("stopped streaming", slog.String("error", err.Error()))
+				return
+			}
 		}
 	}
 }
@@ -247,6 +437,10 @@ func (i *service[T]) Delete(w http.ResponseWriter, r *http.Request, p httprouter
 		return
 	}
 
+	if !i.authorize(w, r, mycontent.ActionDelete, namespace) {
+		return
+	}
+
 	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
 	if len(invalidParams) > 0 {
 		handleError(
@@ -261,10 +455,27 @@ func (i *service[T]) Delete(w http.ResponseWriter, r *http.Request, p httprouter
 		refIDs = append(refIDs, r.URL.Query().Get(param))
 	}
 
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	// the If-Match of the stored resource, as a compare-and-set of the deletion
+	meta := &mycontent.Meta{}
+	meta.ExpectedVersion, err = parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+	if meta.ExpectedVersion != nil {
+		ctx = content.WithExpectedVersion(ctx, *meta.ExpectedVersion)
+	}
+
 	// Do the actual deletion
-	result, err := i.uc.Delete(r.Context(), namespace, refIDs, ID)
+	result, err := i.uc.Delete(ctx, namespace, refIDs, ID)
 	if err != nil {
-		handleDeleteError(w, err)
+		handleWriteError(w, err, meta)
 		return
 	}
 
@@ -274,13 +485,7 @@ func (i *service[T]) Delete(w http.ResponseWriter, r *http.Request, p httprouter
 
 	if err != nil {
 		log.Err(err).Msgf("Failed to parse payload")
-		errMessage := serializeError(&types.CommonError{
-			Errors: []types.Error{
-				{Message: "Failed to parse response", Code: "SERVER_ERROR"},
-			},
-		})
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(errMessage)
+		handleError(w, "SERVER_ERROR", "Failed to parse response", http.StatusInternalServerError, nil)
 		return
 	}
 
@@ -289,83 +494,110 @@ func (i *service[T]) Delete(w http.ResponseWriter, r *http.Request, p httprouter
 
 }
 
-func serializeError(err *types.CommonError) []byte {
-	d, errMarshal := json.Marshal(&types.CommonResponse{
-		Error: err,
-	})
-	if errMarshal != nil {
-		log.Err(errMarshal).Msgf("Failed to parse err")
-	}
-	return d
-}
-
-func validateParams(whitelisted map[string]struct{}, params url.Values) (invalidParams []string) {
+func validateParams(whitelisted map[string]struct{}, params url.Values, whitelistedPrefixes ...string) (invalidParams []string) {
 	for param := range params {
-		if _, ok := whitelisted[param]; !ok {
-			invalidParams = append(invalidParams, param)
+		if _, ok := whitelisted[param]; ok {
+			continue
 		}
+		if slices.ContainsFunc(whitelistedPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(param, prefix) && len(param) > len(prefix)
+		}) {
+			continue
+		}
+		invalidParams = append(invalidParams, param)
 	}
 	return invalidParams
 }
 
-func handlePostError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, mycontent.ErrValidation):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, mycontent.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
-	case errors.Is(err, content.ErrInvalidKey):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, content.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
-	default:
-		handleError(w, "SERVER_ERROR", "server error", http.StatusInternalServerError, err)
+// parseListQuery parses limit, cursor, order_by, order (asc or desc) & filter.<field> parameters.
+// The next cursor is returned in the DG-Next-Cursor header.
+func parseListQuery(params url.Values) (q content.Query, isList bool, err error) {
+	q, err = parsePageQuery(params)
+	if err != nil {
+		return q, false, err
 	}
-}
+	isList = params.Get("limit") != "" || q.Cursor != ""
 
-func handleGetError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, mycontent.ErrValidation):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, mycontent.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
-	case errors.Is(err, content.ErrInvalidKey):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, content.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
+	if orderBy := params.Get("order_by"); orderBy != "" {
+		q.OrderBy, err = content.ParseOrderBy(orderBy)
+		if err != nil {
+			return q, false, err
+		}
+		isList = true
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
 	default:
-		handleError(w, "SERVER_ERROR", "server error", http.StatusInternalServerError, err)
+		return q, false, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+	if params.Has("order") {
+		isList = true
+	}
+
+	for param, values := range params {
+		field, ok := strings.CutPrefix(param, filterParamPrefix)
+		if !ok {
+			continue
+		}
+		q.Filters = append(q.Filters, content.Filter{Field: field, Value: content.ParseFilterValue(values[0])})
+		isList = true
+	}
+	slices.SortFunc(q.Filters, func(a, b content.Filter) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+
+	if _, err := q.Validate(); err != nil {
+		return q, false, err
 	}
+
+	return q, isList, nil
 }
 
-func handleDeleteError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, mycontent.ErrValidation):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, mycontent.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
-	case errors.Is(err, content.ErrInvalidKey):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	case errors.Is(err, content.ErrNotFound):
-		handleError(w, "NOT_FOUND", err.Error(), http.StatusNotFound, nil)
-	default:
-		handleError(w, "SERVER_ERROR", "server error", http.StatusInternalServerError, err)
+// parsePageQuery parses only limit & cursor, of the list in the order of the usecase, eg. history.
+// The cursor is validated by the storage, once the order is set.
+func parsePageQuery(params url.Values) (q content.Query, err error) {
+	if limit := params.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit <= 0 || q.Limit > maximumListLimit {
+			return q, fmt.Errorf("limit must be between 1 and %v", maximumListLimit)
+		}
 	}
+
+	q.Cursor = params.Get("cursor")
+
+	return q, nil
 }
 
-func handleError(w http.ResponseWriter, code, msg string, httpStatus int, err error) {
+// readBody up to the limit; writes the error if it cannot be read, 413 if it is larger
+func readBody(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	payload, err := io.ReadAll(r.Body)
 	if err != nil {
-		slog.Error("failed to serve request", slog.String("error", err.Error()))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			handleError(
+				w, "PAYLOAD_TOO_LARGE", fmt.Sprintf("body must not exceed %vMb", limit>>20),
+				http.StatusRequestEntityTooLarge, nil)
+			return nil, false
+		}
+		handleError(w, "SERVER_ERROR", "failed to read payload", http.StatusInternalServerError, err)
+		return nil, false
 	}
+	return payload, true
+}
 
-	w.WriteHeader(http.StatusInternalServerError)
-	message := serializeError(&types.CommonError{
-		Errors: []types.Error{
-			{Message: msg, Code: code, HTTPCode: httpStatus},
-		},
-	})
+// withReadConsistency forwards the DG-Read-Consistency header (stale, linearizable, or leader)
+// to the storage. Storage that is not replicated ignores it.
+func withReadConsistency(r *http.Request) (context.Context, error) {
+	consistency, err := content.ParseReadConsistency(r.Header.Get("DG-Read-Consistency"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DG-Read-Consistency header: %w", err)
+	}
 
-	w.Write(message)
+	return content.WithReadConsistency(r.Context(), consistency), nil
 }
 
 func captureOptimisticLock(meta *mycontent.Meta, lockVersionStr string, required bool) error {
@@ -12,10 +12,8 @@ import (
 	"strings"
 
 	"github.com/julienschmidt/httprouter"
-	"github.com/rs/zerolog/log"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
-	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	entity "github.com/desain-gratis/common/types/entity"
 	types "github.com/desain-gratis/common/types/http"
 )
@@ -38,60 +36,45 @@ func NewAttachment(
 	refParams []string,
 	cacheControl string,
 ) *uploadService {
-	whitelistParams := map[string]struct{}{
-		"id":   {},
-		"data": {},
-	}
-	for _, refParams := range refParams {
-		whitelistParams[refParams] = struct{}{}
-	}
+	svc := New[*entity.Attachment](base, baseURL, refParams)
+
+	// the download of the attachment by id
+	svc.whitelistParams["data"] = struct{}{}
+	svc.whitelistListParams["data"] = struct{}{}
 
 	return &uploadService{
-		service: &service[*entity.Attachment]{
-			uc:              base,
-			refParams:       refParams,
-			whitelistParams: whitelistParams,
-			postProcess: []PostProcess[*entity.Attachment]{
-				FormatURL[*entity.Attachment](baseURL, refParams),
-			},
-		},
+		service:      svc,
 		uc:           base, // uc with advanced functionality
 		cacheControl: cacheControl,
 	}
 }
 
+// WithPolicy the same as service, for chaining
+func (i *uploadService) WithPolicy(policy mycontent.Policy) *uploadService {
+	i.service.WithPolicy(policy)
+	return i
+}
+
 func (i *uploadService) Get(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// the attachment meta, by id or listed, the same as the other API
+	if r.URL.Query().Get("data") != "true" {
+		i.service.Get(w, r, p)
+		return
+	}
+
 	namespace := r.Header.Get("X-Namespace")
 	if namespace == "" {
-		d := serializeError(&types.CommonError{
-			Errors: []types.Error{
-				{HTTPCode: http.StatusBadRequest, Code: "EMPTY_NAMESPACE", Message: "Please specify header 'X-Namespace'"},
-			},
-		})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(d)
+		handleError(w, "EMPTY_NAMESPACE", "Please specify header 'X-Namespace'", http.StatusBadRequest, nil)
 		return
 	}
 
-	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
-	if len(invalidParams) > 0 {
-		d := serializeError(&types.CommonError{
-			Errors: []types.Error{
-				{HTTPCode: http.StatusBadRequest, Code: "INVALID_PARAMS", Message: "Invalid parameter(s):" + strings.Join(invalidParams, ",")},
-			},
-		})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(d)
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
 		return
 	}
+
+	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
 	if len(invalidParams) > 0 {
-		d := serializeError(&types.CommonError{
-			Errors: []types.Error{
-				{HTTPCode: http.StatusBadRequest, Code: "INVALID_PARAMS", Message: "Invalid parameter(s):" + strings.Join(invalidParams, ",")},
-			},
-		})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(d)
+		handleError(w, "INVALID_PARAMS", "Invalid parameter(s):"+strings.Join(invalidParams, ","), http.StatusBadRequest, nil)
 		return
 	}
 
@@ -102,27 +85,14 @@ func (i *uploadService) Get(w http.ResponseWriter, r *http.Request, p httprouter
 		refIDs = append(refIDs, r.URL.Query().Get(param))
 	}
 
-	isData := r.URL.Query().Get("data")
-
-	if isData != "true" {
-		i.service.Get(w, r, p)
-		return
-	}
-
 	if ID == "" {
-		d := serializeError(&types.CommonError{
-			Errors: []types.Error{
-				{HTTPCode: http.StatusBadRequest, Code: "INVALID_PARAMS", Message: "You specify data=true but does not provide 'id' parameter"},
-			},
-		})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(d)
+		handleError(w, "INVALID_PARAMS", "You specify data=true but does not provide 'id' parameter", http.StatusBadRequest, nil)
 		return
 	}
 
 	payload, meta, err := i.uc.GetAttachment(r.Context(), namespace, refIDs, ID)
 	if err != nil {
-		handleGetError(w, err)
+		handleUsecaseError(w, err)
 		return
 	}
 	defer payload.Close()
@@ -147,6 +117,18 @@ func (i *uploadService) Get(w http.ResponseWriter, r *http.Request, p httprouter
 }
 
 func (i *uploadService) Upload(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
 	// Read body parse entity and extract metadata
 	r.Body = http.MaxBytesReader(w, r.Body, maximumRequestLengthAttachment)
 	defer r.Body.Close()
@@ -177,15 +159,12 @@ func (i *uploadService) Upload(w http.ResponseWriter, r *http.Request, p httprou
 	if err != nil {
 		// log.Warn().Msgf("msg: '%v'", string(_doc))
 		handleError(
-			w, "BAD_REQUEST", "failed to parse body. Make sure file size does not exceed 200Kb",
+			w, "BAD_REQUEST", "failed to parse 'document', it must be a JSON of at most 200Kb: "+err.Error(),
 			http.StatusBadRequest, nil)
 		return
 	}
 
-	if attachmentData.Namespace() == "" {
-		handleError(
-			w, "BAD_REQUEST", "namespace cannot be empty",
-			http.StatusBadRequest, nil)
+	if !sameNamespace(w, namespace, attachmentData.Namespace()) {
 		return
 	}
 
@@ -260,7 +239,7 @@ func (i *uploadService) Upload(w http.ResponseWriter, r *http.Request, p httprou
 
 	result, err := i.uc.Attach(r.Context(), attachmentData, multi)
 	if err != nil {
-		handleAttachError(w, err)
+		handleUsecaseError(w, err)
 		return
 	}
 
@@ -276,13 +255,3 @@ func (i *uploadService) Upload(w http.ResponseWriter, r *http.Request, p httprou
 	w.WriteHeader(http.StatusOK)
 	w.Write(payload)
 }
-
-func handleAttachError(w http.ResponseWriter, err error) {
-	switch {
-	case errors.Is(err, content.ErrInvalidKey):
-		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
-	default:
-		log.Error().Msgf("server error: %v", err)
-		handleError(w, "SERVER_ERROR", "server error", http.StatusInternalServerError, err)
-	}
-}
@@ -0,0 +1,80 @@
+package mycontentapi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+func newAttachmentAPI(t *testing.T) (*base.HandlerWithAttachment, http.Handler) {
+	t.Helper()
+
+	storage := newStorage(t, sqliteraft.TableConfig{TableName: "attachment", RefSize: 1})
+	uc := base.NewAttachment(storage["attachment"], newMemBlob(), false, "")
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/attachment", mycontentapi.NewAttachment(uc, "http://localhost/attachment", []string{"owner"}, ""))
+
+	return uc, router
+}
+
+func TestAttachmentGet(t *testing.T) {
+	uc, router := newAttachmentAPI(t)
+
+	var ids []string
+	for i := range 3 {
+		attachment, err := uc.Attach(context.Background(), &entity.Attachment{
+			OwnerId:     "ns",
+			RefIds:      []string{"o"},
+			CreatedAt:   time.Date(2026, 10, 18, 0, 0, i, 0, time.UTC).Format(time.RFC3339),
+			ContentType: "text/plain",
+		}, strings.NewReader(fmt.Sprint("content ", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, attachment.Id)
+	}
+
+	// by id & ref
+	rec := serve(t, router, http.MethodGet, "/attachment?owner=o&id="+ids[1], "")
+	if got := decode[[]*entity.Attachment](t, rec); len(got) != 1 || got[0].Id != ids[1] {
+		t.Fatalf("expected the attachment by id, got %+v", got)
+	}
+
+	// listed, page by page
+	rec = serve(t, router, http.MethodGet, "/attachment?owner=o&limit=2", "")
+	if got := decode[[]*entity.Attachment](t, rec); len(got) != 2 {
+		t.Fatalf("expected a page of 2 attachments, got %+v", got)
+	}
+	cursor := rec.Header().Get("DG-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("expected the cursor of the next page")
+	}
+	rec = serve(t, router, http.MethodGet, "/attachment?owner=o&limit=2&cursor="+cursor, "")
+	if got := decode[[]*entity.Attachment](t, rec); len(got) != 1 {
+		t.Fatalf("expected the last page of 1 attachment, got %+v", got)
+	}
+
+	// the download
+	rec = serve(t, router, http.MethodGet, "/attachment?owner=o&data=true&id="+ids[2], "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "content 2" {
+		t.Fatalf("expected the attachment content, got %v %q", rec.Code, rec.Body.String())
+	}
+
+	for _, query := range []string{"owner=o&bogus=1", "owner=o&data=true&id=" + ids[0] + "&limit=2"} {
+		rec = serve(t, router, http.MethodGet, "/attachment?"+query, "")
+		if status, _ := errorCode(t, rec); status != http.StatusBadRequest {
+			t.Fatalf("%v: expected bad request, got %v", query, status)
+		}
+	}
+}
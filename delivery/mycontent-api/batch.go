@@ -0,0 +1,197 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+const maximumBatchRequestLength = 10 << 20
+const maximumBatchSize = 1000
+
+// PostBatch takes an array of resources under the X-Namespace. Every one of them is validated before any is written.
+// The response has the result of each resource in order, as {"success": ...} or {"error": ...}.
+func (i *service[T]) PostBatch(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
+	if len(r.URL.Query()) > 0 {
+		handleError(w, "BAD_REQUEST", "URL Parameter should not be specified", http.StatusBadRequest, nil)
+		return
+	}
+
+	batchable, ok := i.uc.(mycontent.Batchable[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "batch is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	payload, ok := readBody(w, r, maximumBatchRequestLength)
+	if !ok {
+		return
+	}
+
+	var resources []T
+	err := json.Unmarshal(payload, &resources)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", "failed to parse body, it must be an array: "+err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	if len(resources) == 0 || len(resources) > maximumBatchSize {
+		handleError(
+			w, "BAD_REQUEST", fmt.Sprintf("batch must have 1 to %v items", maximumBatchSize),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	for _, resource := range resources {
+		if !sameNamespace(w, namespace, resource.Namespace()) {
+			return
+		}
+	}
+
+	meta := &mycontent.Meta{
+		CreatedAt: time.Now(),
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	results, err := batchable.PostBatch(ctx, resources, meta)
+	i.writeBatchResults(w, results, err)
+}
+
+// DeleteBatch takes an array of keys under the X-Namespace, eg. [{"<ref param>": "...", "id": "..."}],
+// the same as the URL parameters of Delete. The response is the same as PostBatch.
+func (i *service[T]) DeleteBatch(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionDelete, namespace) {
+		return
+	}
+
+	if len(r.URL.Query()) > 0 {
+		handleError(w, "BAD_REQUEST", "URL Parameter should not be specified", http.StatusBadRequest, nil)
+		return
+	}
+
+	batchable, ok := i.uc.(mycontent.Batchable[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "batch is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	payload, ok := readBody(w, r, maximumRequestLength)
+	if !ok {
+		return
+	}
+
+	var params []map[string]string
+	err := json.Unmarshal(payload, &params)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", "failed to parse body, it must be an array of keys: "+err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	if len(params) == 0 || len(params) > maximumBatchSize {
+		handleError(
+			w, "BAD_REQUEST", fmt.Sprintf("batch must have 1 to %v items", maximumBatchSize),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	keys := make([]mycontent.Key, 0, len(params))
+	for idx, param := range params {
+		for name := range param {
+			if _, ok := i.whitelistParams[name]; !ok {
+				handleError(
+					w, "BAD_REQUEST", fmt.Sprintf("invalid key '%v' of item %v", name, idx),
+					http.StatusBadRequest, nil)
+				return
+			}
+		}
+
+		if param["id"] == "" {
+			handleError(
+				w, "BAD_REQUEST", fmt.Sprintf("'id' of item %v is empty", idx),
+				http.StatusBadRequest, nil)
+			return
+		}
+
+		refIDs := make([]string, 0, len(i.refParams))
+		for _, refParam := range i.refParams {
+			refIDs = append(refIDs, param[refParam])
+		}
+
+		keys = append(keys, mycontent.Key{RefIDs: refIDs, ID: param["id"]})
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	results, err := batchable.DeleteBatch(ctx, namespace, keys)
+	i.writeBatchResults(w, results, err)
+}
+
+// writeBatchResults with 200 if all succeed, 207 if only some of them, or the status of the batch error
+func (i *service[T]) writeBatchResults(w http.ResponseWriter, results []mycontent.BatchResult[T], err error) {
+	items := make([]types.CommonResponseTyped[T], len(results))
+
+	status := http.StatusOK
+	for idx, result := range results {
+		if result.Err != nil {
+			items[idx].Error, _ = toCommonError(result.Err)
+			status = http.StatusMultiStatus
+			continue
+		}
+
+		for _, pp := range i.postProcess {
+			pp(result.Data)
+		}
+		items[idx].Success = result.Data
+	}
+
+	response := types.CommonResponse{Success: items}
+	if err != nil {
+		response.Error, status = toCommonError(err)
+	}
+
+	payload, errMarshal := json.Marshal(&response)
+	if errMarshal != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, errMarshal)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(payload)
+}
@@ -0,0 +1,103 @@
+package mycontentapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// singleRepo hides the batch of the repository
+type singleRepo struct {
+	content.Repository
+}
+
+func TestBatch(t *testing.T) {
+	storage := newStorage(t,
+		sqliteraft.TableConfig{TableName: "doc", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "single", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "trashed", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "trashed_trash", RefSize: 1},
+	)
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).
+		Resource("/doc", mycontentapi.New[*entity.Document](
+			base.New[*entity.Document](storage["doc"]), "http://localhost/doc", []string{"owner"})).
+		Resource("/single", mycontentapi.New[*entity.Document](
+			base.New[*entity.Document](&singleRepo{storage["single"]}), "http://localhost/single", []string{"owner"})).
+		Resource("/trashed", mycontentapi.New[*entity.Document](
+			base.New[*entity.Document](storage["trashed"]).WithSoftDelete(storage["trashed_trash"], time.Hour), "http://localhost/trashed", []string{"owner"}))
+
+	docs := `[{"id":"d1","namespace":"ns","ref_ids":["o"]},{"id":"d2","namespace":"ns","ref_ids":["o"]}]`
+	keys := `[{"owner":"o","id":"d1"},{"owner":"o","id":"d2"}]`
+
+	t.Run("all or nothing", func(t *testing.T) {
+		rec := serve(t, router, http.MethodPost, "/doc/batch", docs)
+		if results := decode[[]types.CommonResponseTyped[entity.Document]](t, rec); len(results) != 2 || results[1].Success["id"] != "d2" {
+			t.Fatalf("expected both posted, got %s", rec.Body.String())
+		}
+
+		// d3 does not exist, so none is deleted
+		rec = serve(t, router, http.MethodDelete, "/doc/batch", `[{"owner":"o","id":"d1"},{"owner":"o","id":"d3"}]`)
+		if status, code := errorCode(t, rec); status != http.StatusNotFound || code != "NOT_FOUND" {
+			t.Fatalf("expected not found, got %v %v", status, code)
+		}
+
+		var resp types.CommonResponseTyped[[]types.CommonResponse]
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if msg := resp.Error.Errors[0].Message; !strings.HasPrefix(msg, "storage: ") {
+			t.Fatalf("expected the storage error, got %v", msg)
+		}
+		if len(resp.Success) != 2 || resp.Success[0].Error.Errors[0].Code != "NOT_APPLIED" || resp.Success[1].Error.Errors[0].Code != "NOT_FOUND" {
+			t.Fatalf("expected the failing item, got %s", rec.Body.String())
+		}
+
+		rec = serve(t, router, http.MethodGet, "/doc?owner=o", "")
+		if got := decode[[]entity.Document](t, rec); len(got) != 2 {
+			t.Fatalf("expected nothing deleted, got %v", got)
+		}
+
+		rec = serve(t, router, http.MethodDelete, "/doc/batch", keys)
+		if results := decode[[]types.CommonResponseTyped[entity.Document]](t, rec); len(results) != 2 {
+			t.Fatalf("expected both deleted, got %s", rec.Body.String())
+		}
+	})
+
+	// the client writes each of them instead
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+	}{
+		{name: "post without batch storage", method: http.MethodPost, target: "/single/batch", body: docs},
+		{name: "delete without batch storage", method: http.MethodDelete, target: "/single/batch", body: keys},
+		{name: "delete with soft delete", method: http.MethodDelete, target: "/trashed/batch", body: keys},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, router, tc.method, tc.target, tc.body)
+			if status, code := errorCode(t, rec); status != http.StatusNotImplemented || code != "NOT_IMPLEMENTED" {
+				t.Fatalf("expected not implemented, got %v %v", status, code)
+			}
+		})
+	}
+
+	// nothing is written one by one
+	rec := serve(t, router, http.MethodGet, "/single?owner=o", "")
+	if got := decode[[]entity.Document](t, rec); len(got) != 0 {
+		t.Fatalf("expected nothing posted, got %v", got)
+	}
+}
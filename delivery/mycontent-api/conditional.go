@@ -0,0 +1,107 @@
+package mycontentapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+// versionETag is the ETag of Get by ID, and the If-Match of Post, Patch & Delete:
+// the event ID for versioned data, the content version otherwise
+func versionETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// payloadETag of the response that has no single version, eg. list.
+// Truncated SHA-256, so a changed response does not get a colliding ETag (and a wrong 304).
+func payloadETag(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// parseIfMatch as the optimistic lock version; nil if not specified
+func parseIfMatch(ifMatch string) (*uint64, error) {
+	if ifMatch == "" {
+		return nil, nil
+	}
+
+	tag := strings.TrimSpace(ifMatch)
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return nil, fmt.Errorf("If-Match only supports a single strong entity tag, got %v", ifMatch)
+	}
+
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("If-Match is not an entity tag of this resource: %v", ifMatch)
+	}
+
+	return &version, nil
+}
+
+// matchNoneOf the If-None-Match header; weak comparison as it is for GET
+func matchNoneOf(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return true
+	}
+
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lastModified of the resources, from the latest created time
+func lastModified[T mycontent.Data](result []T) time.Time {
+	var latest time.Time
+	for _, d := range result {
+		if d.CreatedTime().After(latest) {
+			latest = d.CreatedTime()
+		}
+	}
+	return latest
+}
+
+// capturePreconditions of the write to the meta. They are two different versions:
+//   - If-Match is the ETag of the stored resource (see versionETag), for Post by id, Patch & Delete; 412 on conflict.
+//   - DG-Optimistic-Lock-Version is the versions count of versioned data, for Post of its next version; 409 on conflict.
+func capturePreconditions(r *http.Request, meta *mycontent.Meta, lockRequired bool) error {
+	var err error
+	meta.ExpectedVersion, err = parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		return err
+	}
+
+	return captureOptimisticLock(meta, r.Header.Get("DG-Optimistic-Lock-Version"), lockRequired)
+}
+
+// handleWriteError of the write with the preconditions of the meta
+func handleWriteError(w http.ResponseWriter, err error, meta *mycontent.Meta) {
+	var conflict *content.ConflictError
+	if meta.ExpectedVersion != nil && errors.As(err, &conflict) && !conflict.NextVersion {
+		handlePreconditionFailed(w, err)
+		return
+	}
+
+	handleUsecaseError(w, err)
+}
+
+// handlePreconditionFailed when the If-Match conflicts with the current version
+func handlePreconditionFailed(w http.ResponseWriter, err error) {
+	var conflict *content.ConflictError
+	if errors.As(err, &conflict) {
+		w.Header().Set("ETag", versionETag(conflict.Current))
+	}
+	handleError(w, "PRECONDITION_FAILED", err.Error(), http.StatusPreconditionFailed, nil)
+}
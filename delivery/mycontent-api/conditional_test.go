@@ -0,0 +1,119 @@
+package mycontentapi_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/desain-gratis/common/types/entity"
+
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+)
+
+func TestConditionalGet(t *testing.T) {
+	_, router := newDocumentAPI(t)
+
+	for _, target := range []string{"/doc?owner=o&id=d1", "/doc?owner=o"} {
+		rec := serve(t, router, http.MethodGet, target, "")
+		decode[[]entity.Document](t, rec)
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%v: expected the ETag, got %v", target, rec.Header())
+		}
+
+		rec = serve(t, router, http.MethodGet, target, "", "If-None-Match", `"other", W/`+etag)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Fatalf("%v: expected not modified without body, got %v %q", target, rec.Code, rec.Body.String())
+		}
+
+		rec = serve(t, router, http.MethodGet, target, "", "If-None-Match", `"other"`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%v: expected the resources of another ETag, got %v", target, rec.Code)
+		}
+	}
+
+	// the list is not modified any more after a write
+	rec := serve(t, router, http.MethodGet, "/doc?owner=o", "")
+	listETag := rec.Header().Get("ETag")
+	if len(listETag) != 34 {
+		t.Fatalf("expected the truncated SHA-256 of the payload, got %v", listETag)
+	}
+
+	serve(t, router, http.MethodPost, "/doc", `{"id":"d2","namespace":"ns","ref_ids":["o"],"title":"b"}`)
+
+	rec = serve(t, router, http.MethodGet, "/doc?owner=o", "", "If-None-Match", listETag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == listETag {
+		t.Fatalf("expected the changed list with a new ETag, got %v %v", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{})
+
+	serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"a","created_at":"2026-01-02T03:04:05Z"}`)
+	serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"b","created_at":"2026-03-02T03:04:05Z"}`)
+
+	rec := serve(t, router, http.MethodGet, "/post?owner=o&id=0", "")
+	if got := rec.Header().Get("Last-Modified"); got != "Fri, 02 Jan 2026 03:04:05 GMT" {
+		t.Fatalf("expected the created time of the resource, got %q", got)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/post?owner=o", "")
+	if got := rec.Header().Get("Last-Modified"); got != "Mon, 02 Mar 2026 03:04:05 GMT" {
+		t.Fatalf("expected the latest created time, got %q", got)
+	}
+}
+
+// the ETag of versioned data is its event ID, so posting the same content again is still a new ETag
+func TestVersionedIfMatch(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{})
+
+	const body = `{"id":"0","namespace":"ns","ref_ids":["o"],"title":"a"}`
+
+	// the resource does not exist yet
+	rec := serve(t, router, http.MethodPost, "/post", body, "If-Match", `"5"`)
+	if status, code := errorCode(t, rec); status != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+		t.Fatalf("expected precondition failed, got %v %v", status, code)
+	}
+
+	serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"a"}`)
+
+	get := func() (versionedDoc, string) {
+		t.Helper()
+		rec := serve(t, router, http.MethodGet, "/post?owner=o&id=0", "")
+		docs := decode[[]versionedDoc](t, rec)
+		if len(docs) != 1 {
+			t.Fatalf("expected the version, got %+v", docs)
+		}
+		return docs[0], rec.Header().Get("ETag")
+	}
+
+	doc, etag := get()
+	if etag != `"`+strconv.FormatUint(doc.EventID, 10)+`"` {
+		t.Fatalf("expected the event ID %v as the ETag, got %v", doc.EventID, etag)
+	}
+
+	decode[versionedDoc](t, serve(t, router, http.MethodPost, "/post", body, "If-Match", etag))
+
+	doc, current := get()
+	if current == etag || current != `"`+strconv.FormatUint(doc.EventID, 10)+`"` {
+		t.Fatalf("expected the new event ID as the ETag, got %v after %v", current, etag)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		target := "/post"
+		if method == http.MethodDelete {
+			target = "/post?owner=o&id=0"
+		}
+		rec := serve(t, router, method, target, body, "If-Match", etag)
+		if status, code := errorCode(t, rec); status != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+			t.Fatalf("%v: expected precondition failed, got %v %v", method, status, code)
+		}
+		if got := rec.Header().Get("ETag"); got != current {
+			t.Fatalf("%v: expected the current ETag %v, got %v", method, current, got)
+		}
+	}
+
+	rec = serve(t, router, http.MethodDelete, "/post?owner=o&id=0", "", "If-Match", current)
+	decode[versionedDoc](t, rec)
+}
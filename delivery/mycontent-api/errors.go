@@ -0,0 +1,176 @@
+package mycontentapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// ContentTypeProblem of the error written by the ProblemDetails middleware
+const ContentTypeProblem = "application/problem+json"
+
+// toCommonError maps the error of the usecase to its code & HTTP status; unknown errors are logged and hidden
+func toCommonError(err error) (*types.CommonError, int) {
+	code, msg, status := "SERVER_ERROR", "server error", http.StatusInternalServerError
+
+	var verr *mycontent.ValidationError
+	if errors.As(err, &verr) {
+		return &types.CommonError{Errors: fieldErrors(verr)}, http.StatusBadRequest
+	}
+
+	switch {
+	case errors.Is(err, mycontent.ErrNotApplied):
+		code, msg, status = "NOT_APPLIED", err.Error(), http.StatusFailedDependency
+	case errors.Is(err, content.ErrConflict):
+		code, msg, status = "CONFLICT", err.Error(), http.StatusConflict
+	case errors.Is(err, mycontent.ErrValidation), errors.Is(err, content.ErrInvalidKey):
+		code, msg, status = "BAD_REQUEST", err.Error(), http.StatusBadRequest
+	case errors.Is(err, mycontent.ErrNotFound), errors.Is(err, content.ErrNotFound):
+		code, msg, status = "NOT_FOUND", err.Error(), http.StatusNotFound
+	case errors.Is(err, mycontent.ErrUnauthorized):
+		code, msg, status = "UNAUTHORIZED", err.Error(), http.StatusUnauthorized
+	case errors.Is(err, mycontent.ErrForbidden):
+		code, msg, status = "FORBIDDEN", err.Error(), http.StatusForbidden
+	case errors.Is(err, mycontent.ErrSoftDeleteNotEnabled), errors.Is(err, mycontent.ErrResumableUploadNotEnabled),
+		errors.Is(err, mycontent.ErrBatchNotSupported), errors.Is(err, content.ErrWatchNotSupported), errors.Is(err, content.ErrCompareAndSetNotSupported):
+		code, msg, status = "NOT_IMPLEMENTED", err.Error(), http.StatusNotImplemented
+	case errors.Is(err, content.ErrWatchExpired):
+		code, msg, status = "GONE", err.Error(), http.StatusGone
+	case errors.Is(err, content.ErrNotLeader):
+		code, msg, status = "NOT_LEADER", err.Error(), http.StatusServiceUnavailable
+	default:
+		slog.Error("failed to serve request", slog.String("error", err.Error()))
+	}
+
+	return &types.CommonError{
+		Errors: []types.Error{
+			{Message: msg, Code: code, HTTPCode: status},
+		},
+	}, status
+}
+
+// handleUsecaseError writes the error of the usecase; a conflict has the current version in the header of its precondition
+func handleUsecaseError(w http.ResponseWriter, err error) {
+	var conflict *content.ConflictError
+	if errors.As(err, &conflict) {
+		if conflict.NextVersion {
+			w.Header().Set("DG-Optimistic-Lock-Version", strconv.FormatUint(conflict.Current, 10))
+		} else {
+			w.Header().Set("ETag", versionETag(conflict.Current))
+		}
+	}
+
+	commonErr, status := toCommonError(err)
+	writeError(w, status, commonErr)
+}
+
+func handleError(w http.ResponseWriter, code, msg string, httpStatus int, err error) {
+	if err != nil {
+		slog.Error("failed to serve request", slog.String("error", err.Error()))
+	}
+
+	writeError(w, httpStatus, &types.CommonError{
+		Errors: []types.Error{
+			{Message: msg, Code: code, HTTPCode: httpStatus},
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, commonErr *types.CommonError) {
+	if wantsProblem(w) {
+		w.Header().Set("Content-Type", ContentTypeProblem)
+		w.WriteHeader(status)
+		w.Write(serializeProblem(status, commonErr))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(serializeError(commonErr))
+}
+
+func serializeError(err *types.CommonError) []byte {
+	d, errMarshal := json.Marshal(&types.CommonResponse{
+		Error: err,
+	})
+	if errMarshal != nil {
+		log.Err(errMarshal).Msgf("Failed to parse err")
+	}
+	return d
+}
+
+// serializeProblem with the first error as its detail, and every error as its extension
+func serializeProblem(status int, err *types.CommonError) []byte {
+	problem := types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Errors: err.Errors,
+	}
+	if len(err.Errors) > 0 {
+		problem.Code = err.Errors[0].Code
+		problem.Detail = err.Errors[0].Message
+	}
+
+	d, errMarshal := json.Marshal(&problem)
+	if errMarshal != nil {
+		log.Err(errMarshal).Msgf("Failed to parse problem")
+	}
+	return d
+}
+
+// ProblemDetails writes every error of the route as RFC 7807 problem details (application/problem+json),
+// instead of {"error": {"errors": [...]}}. The success response is not changed.
+func ProblemDetails(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		handle(&problemWriter{ResponseWriter: w}, r, p)
+	}
+}
+
+// wantsProblem if the writer is, or wraps, the one of ProblemDetails
+func wantsProblem(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(*problemWriter); ok {
+			return true
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// problemWriter marks the route of ProblemDetails; it is still a flusher & hijacker for stream & watch
+type problemWriter struct {
+	http.ResponseWriter
+}
+
+func (p *problemWriter) Flush() {
+	if f, ok := p.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (p *problemWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := p.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack is not supported")
+	}
+	return h.Hijack()
+}
+
+func (p *problemWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
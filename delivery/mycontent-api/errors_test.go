@@ -0,0 +1,154 @@
+package mycontentapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// failingUsecase fails every Get with its error
+type failingUsecase struct {
+	mycontent.Usecase[*entity.Document]
+	err error
+}
+
+func (f *failingUsecase) Get(ctx context.Context, namespace string, refIDs []string, ID string) ([]*entity.Document, error) {
+	return nil, f.err
+}
+
+func TestErrorStatus(t *testing.T) {
+	uc := &failingUsecase{}
+
+	router := httprouter.New()
+	svc := mycontentapi.New[*entity.Document](uc, "http://localhost/doc", []string{"owner"})
+	mycontentapi.NewRegistrar(router).Resource("/doc", svc)
+
+	// the logging is the outermost, then the problem details
+	problemRouter := httprouter.New()
+	mycontentapi.NewRegistrar(problemRouter).Use(mycontentapi.Logging, mycontentapi.ProblemDetails).Resource("/doc", svc)
+
+	// the problem details is the outermost, then the logging
+	problemInnerRouter := httprouter.New()
+	mycontentapi.NewRegistrar(problemInnerRouter).Use(mycontentapi.ProblemDetails, mycontentapi.Logging).Resource("/doc", svc)
+
+	for _, tc := range []struct {
+		err     error
+		status  int
+		code    string
+		message string
+		header  [2]string
+	}{
+		{err: fmt.Errorf("wrapped: %w", mycontent.ErrNotFound), status: http.StatusNotFound, code: "NOT_FOUND"},
+		{err: content.ErrNotFound, status: http.StatusNotFound, code: "NOT_FOUND"},
+		{err: mycontent.ErrValidation, status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{err: content.ErrInvalidKey, status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{err: mycontent.ErrUnauthorized, status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{err: mycontent.ErrForbidden, status: http.StatusForbidden, code: "FORBIDDEN"},
+		{err: mycontent.ErrNotApplied, status: http.StatusFailedDependency, code: "NOT_APPLIED"},
+		{err: mycontent.ErrSoftDeleteNotEnabled, status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED"},
+		{err: mycontent.ErrResumableUploadNotEnabled, status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED"},
+		{err: content.ErrWatchNotSupported, status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED"},
+		{err: content.ErrCompareAndSetNotSupported, status: http.StatusNotImplemented, code: "NOT_IMPLEMENTED"},
+		{err: content.ErrWatchExpired, status: http.StatusGone, code: "GONE"},
+		{err: content.ErrNotLeader, status: http.StatusServiceUnavailable, code: "NOT_LEADER"},
+		{
+			err:    &content.ConflictError{Expected: 1, Current: 2},
+			status: http.StatusConflict, code: "CONFLICT",
+			header: [2]string{"ETag", `"2"`},
+		},
+		{
+			err:    fmt.Errorf("storage: %w", &content.ConflictError{Expected: 1, Current: 3, NextVersion: true}),
+			status: http.StatusConflict, code: "CONFLICT",
+			header: [2]string{"DG-Optimistic-Lock-Version", "3"},
+		},
+
+		// the unknown error is hidden
+		{err: errors.New("secret of the storage"), status: http.StatusInternalServerError, code: "SERVER_ERROR", message: "server error"},
+	} {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			uc.err = tc.err
+
+			message := tc.message
+			if message == "" {
+				message = tc.err.Error()
+			}
+
+			rec := serve(t, router, http.MethodGet, "/doc?owner=o", "")
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("expected JSON, got %v", rec.Header().Get("Content-Type"))
+			}
+			var resp types.CommonResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == nil || len(resp.Error.Errors) != 1 {
+				t.Fatalf("expected a single error, got %q", rec.Body.String())
+			}
+			if got := resp.Error.Errors[0]; rec.Code != tc.status || got.HTTPCode != tc.status || got.Code != tc.code || got.Message != message {
+				t.Fatalf("expected %v %v %q, got %v %+v", tc.status, tc.code, message, rec.Code, got)
+			}
+			if tc.header[0] != "" && rec.Header().Get(tc.header[0]) != tc.header[1] {
+				t.Fatalf("expected the header %v: %v, got %v", tc.header[0], tc.header[1], rec.Header())
+			}
+
+			for _, handler := range []http.Handler{problemRouter, problemInnerRouter} {
+				rec := serve(t, handler, http.MethodGet, "/doc?owner=o", "")
+				if rec.Header().Get("Content-Type") != mycontentapi.ContentTypeProblem {
+					t.Fatalf("expected problem details, got %v", rec.Header().Get("Content-Type"))
+				}
+
+				var problem types.Problem
+				if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+					t.Fatalf("expected problem details, got %q", rec.Body.String())
+				}
+				if rec.Code != tc.status || problem.Status != tc.status || problem.Type != "about:blank" || problem.Title != http.StatusText(tc.status) ||
+					problem.Code != tc.code || problem.Detail != message || len(problem.Errors) != 1 {
+					t.Fatalf("expected the problem of %v %v %q, got %v %+v", tc.status, tc.code, message, rec.Code, problem)
+				}
+				if tc.header[0] != "" && rec.Header().Get(tc.header[0]) != tc.header[1] {
+					t.Fatalf("expected the header %v: %v with the problem, got %v", tc.header[0], tc.header[1], rec.Header())
+				}
+			}
+		})
+	}
+}
+
+func TestProblemDetailsFieldErrors(t *testing.T) {
+	uc := &failingUsecase{err: &mycontent.ValidationError{Fields: []mycontent.FieldError{
+		{Path: "/tags/x", Message: "got number, want string"},
+		{Path: "/title", Message: "missing property"},
+	}}}
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Use(mycontentapi.ProblemDetails).
+		Resource("/doc", mycontentapi.New[*entity.Document](uc, "http://localhost/doc", []string{"owner"}))
+
+	rec := serve(t, router, http.MethodGet, "/doc?owner=o", "")
+
+	var problem types.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("expected problem details, got %q", rec.Body.String())
+	}
+	if rec.Code != http.StatusBadRequest || problem.Code != "BAD_REQUEST" || problem.Detail != "got number, want string" || len(problem.Errors) != 2 {
+		t.Fatalf("expected the first field as the detail, got %v %+v", rec.Code, problem)
+	}
+	if problem.Errors[0].Field != "/tags/x" || problem.Errors[1].Field != "/title" {
+		t.Fatalf("expected every field in the errors, got %+v", problem.Errors)
+	}
+
+	// the success is not changed
+	uc.err = nil
+	rec = serve(t, router, http.MethodGet, "/doc?owner=o", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") == mycontentapi.ContentTypeProblem {
+		t.Fatalf("expected the success response, got %v %v", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	decode[[]entity.Document](t, rec)
+}
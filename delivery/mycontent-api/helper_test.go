@@ -0,0 +1,158 @@
+package mycontentapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/blob"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// newStorage starts a single node sqlite-raft with the tables, and returns the storage of each table
+func newStorage(t *testing.T, tables ...sqliteraft.TableConfig) map[string]content.Repository {
+	t.Helper()
+
+	c := raftest.NewCluster(t, 1, func(uint64) raft.Application {
+		return sqliteraft.New(tables...)
+	})
+
+	result := make(map[string]content.Repository)
+	for _, table := range tables {
+		result[table.TableName] = sqliteraft.NewStorageClient(c.Node(1).Context(), table.TableName)
+	}
+	return result
+}
+
+// versionedDoc is a resource of versioned storage; its id is the version
+type versionedDoc struct {
+	Id        string   `json:"id"`
+	Ns        string   `json:"namespace"`
+	Refs      []string `json:"ref_ids"`
+	Title     string   `json:"title"`
+	CreatedAt string   `json:"created_at,omitempty"`
+	EventID   uint64   `json:"event_id"`
+}
+
+func (d *versionedDoc) WithID(id string) mycontent.Data               { d.Id = id; return d }
+func (d *versionedDoc) ID() string                                    { return d.Id }
+func (d *versionedDoc) WithNamespace(ns string) mycontent.Data        { d.Ns = ns; return d }
+func (d *versionedDoc) Namespace() string                             { return d.Ns }
+func (d *versionedDoc) WithURL(string) mycontent.Data                 { return d }
+func (d *versionedDoc) URL() string                                   { return "" }
+func (d *versionedDoc) RefIDs() []string                              { return d.Refs }
+func (d *versionedDoc) Validate() error                               { return nil }
+func (d *versionedDoc) WithEventID(id uint64) mycontent.VersionedData { d.EventID = id; return d }
+
+func (d *versionedDoc) WithCreatedTime(t time.Time) mycontent.Data {
+	d.CreatedAt = t.Format(time.RFC3339)
+	return d
+}
+
+func (d *versionedDoc) CreatedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, d.CreatedAt)
+	return t
+}
+
+// memBlob keeps the attachment in memory
+type memBlob struct {
+	lock sync.Mutex
+	data map[string][]byte
+}
+
+func newMemBlob() *memBlob {
+	return &memBlob{data: make(map[string][]byte)}
+}
+
+func (m *memBlob) Upload(ctx context.Context, path string, attachment *entity.Attachment, payload io.Reader) (*blob.Data, error) {
+	b, err := io.ReadAll(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data[path] = b
+
+	return &blob.Data{Path: path, ContentSize: int64(len(b)), ContentType: attachment.ContentType}, nil
+}
+
+func (m *memBlob) Delete(ctx context.Context, path string) (*blob.Data, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.data, path)
+
+	return &blob.Data{Path: path}, nil
+}
+
+func (m *memBlob) Get(ctx context.Context, path string) (io.ReadCloser, *blob.Data, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, ok := m.data[path]
+	if !ok {
+		return nil, nil, fmt.Errorf("blob %v not found", path)
+	}
+	return io.NopCloser(bytes.NewReader(b)), &blob.Data{Path: path, ContentSize: int64(len(b))}, nil
+}
+
+// serve the request in the namespace "ns"; header is pairs of key & value
+func serve(t *testing.T, handler http.Handler, method string, target string, body string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = bytes.NewBufferString(body)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("X-Namespace", "ns")
+	for idx := 0; idx+1 < len(header); idx += 2 {
+		req.Header.Set(header[idx], header[idx+1])
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode the success of the response; fails the test if the response is an error
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var resp types.CommonResponseTyped[T]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response %q: %v", rec.Body.String(), err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error response (%v): %s", rec.Code, rec.Body.String())
+	}
+	return resp.Success
+}
+
+// errorCode of the response, with its status
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) (int, string) {
+	t.Helper()
+
+	var resp types.CommonResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response %q: %v", rec.Body.String(), err)
+	}
+	if resp.Error == nil || len(resp.Error.Errors) == 0 {
+		t.Fatalf("expected error response, got (%v): %s", rec.Code, rec.Body.String())
+	}
+	return rec.Code, resp.Error.Errors[0].Code
+}
@@ -0,0 +1,163 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// History lists every version under the complete ref params, latest written first.
+// It is paginated the same as Get, with "limit" & "cursor".
+func (i *service[T]) History(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistHistoryParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	historical, ok := i.uc.(mycontent.Historical[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "history is only for versioned data", http.StatusNotImplemented, nil)
+		return
+	}
+
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	q, err := parsePageQuery(r.URL.Query())
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	page, err := historical.History(ctx, namespace, refIDs, q)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	if page.Next != "" {
+		w.Header().Set("DG-Next-Cursor", page.Next)
+	}
+
+	for _, pp := range i.postProcess {
+		for idx := range page.Data {
+			pp(page.Data[idx])
+		}
+	}
+
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: page.Data,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+// Restore the "version" under the complete ref params, by writing it back as the new version.
+// The optimistic lock is the same as Post of new version.
+func (i *service[T]) Restore(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistRestoreParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	historical, ok := i.uc.(mycontent.Historical[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "restore is only for versioned data", http.StatusNotImplemented, nil)
+		return
+	}
+
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	meta := &mycontent.Meta{
+		CreatedAt: time.Now(),
+	}
+
+	err := captureOptimisticLock(meta, r.Header.Get("DG-Optimistic-Lock-Version"), i.enableOptimisticLock)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	result, err := historical.Restore(ctx, namespace, refIDs, r.URL.Query().Get("version"), meta)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	for _, pp := range i.postProcess {
+		pp(result)
+	}
+
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: &result,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
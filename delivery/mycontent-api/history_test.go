@@ -0,0 +1,113 @@
+package mycontentapi_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+)
+
+func titles(docs []versionedDoc) []string {
+	result := make([]string, 0, len(docs))
+	for _, d := range docs {
+		result = append(result, d.Title)
+	}
+	return result
+}
+
+func TestHistoryAndRestore(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{})
+
+	for _, title := range []string{"a", "b", "c"} {
+		decode[versionedDoc](t, serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"`+title+`"}`))
+	}
+
+	rec := serve(t, router, http.MethodGet, "/post/history?owner=o&limit=2", "")
+	if got := titles(decode[[]versionedDoc](t, rec)); len(got) != 2 || got[0] != "c" || got[1] != "b" {
+		t.Fatalf("expected the latest versions first, got %v", got)
+	}
+	cursor := rec.Header().Get("DG-Next-Cursor")
+	if cursor == "" {
+		t.Fatal("expected the cursor of the next page")
+	}
+
+	rec = serve(t, router, http.MethodGet, "/post/history?owner=o&limit=2&cursor="+cursor, "")
+	if got := titles(decode[[]versionedDoc](t, rec)); len(got) != 1 || got[0] != "a" || rec.Header().Get("DG-Next-Cursor") != "" {
+		t.Fatalf("expected the first version in the last page, got %v", got)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/post/restore?owner=o&version=0", "")
+	restored := decode[versionedDoc](t, rec)
+	if restored.Id != "3" || restored.Title != "a" || restored.EventID == 0 {
+		t.Fatalf("expected the first version written as the new version, got %+v", restored)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/post/history?owner=o", "")
+	if got := titles(decode[[]versionedDoc](t, rec)); len(got) != 4 || got[0] != "a" || got[1] != "c" {
+		t.Fatalf("expected the restored version as the latest, got %v", got)
+	}
+
+	// the restored version is kept as it is
+	rec = serve(t, router, http.MethodGet, "/post?owner=o&id=0", "")
+	if got := decode[[]versionedDoc](t, rec); len(got) != 1 || got[0].Title != "a" {
+		t.Fatalf("expected the first version unchanged, got %+v", got)
+	}
+}
+
+func TestHistoryAndRestoreErrors(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{})
+	decode[versionedDoc](t, serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"a"}`))
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		status int
+		code   string
+	}{
+		{"history without the complete refs", http.MethodGet, "/post/history", http.StatusBadRequest, "BAD_REQUEST"},
+		{"history with unknown param", http.MethodGet, "/post/history?owner=o&id=0", http.StatusBadRequest, "BAD_REQUEST"},
+		{"history with invalid cursor", http.MethodGet, "/post/history?owner=o&cursor=x", http.StatusBadRequest, "BAD_REQUEST"},
+		{"restore without version", http.MethodPost, "/post/restore?owner=o", http.StatusBadRequest, "BAD_REQUEST"},
+		{"restore of missing version", http.MethodPost, "/post/restore?owner=o&version=9", http.StatusNotFound, "NOT_FOUND"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, router, tc.method, tc.target, "")
+			if status, code := errorCode(t, rec); status != tc.status || code != tc.code {
+				t.Fatalf("expected %v %v, got %v %v", tc.status, tc.code, status, code)
+			}
+		})
+	}
+
+	// history & restore are only mounted for versioned data
+	_, documents := newDocumentAPI(t)
+	for _, target := range []string{"/doc/history?owner=o", "/doc/restore?owner=o&version=d1"} {
+		if rec := serve(t, documents, http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected %v not mounted, got %v", target, rec.Code)
+		}
+	}
+}
+
+// restore is the same as Post of a new version for the optimistic lock
+func TestRestoreOptimisticLock(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{VersionedUseOptimisticLock: true})
+
+	for idx, title := range []string{"a", "b"} {
+		decode[versionedDoc](t, serve(t, router, http.MethodPost, "/post", `{"namespace":"ns","ref_ids":["o"],"title":"`+title+`"}`,
+			"DG-Optimistic-Lock-Version", strconv.Itoa(idx)))
+	}
+
+	rec := serve(t, router, http.MethodPost, "/post/restore?owner=o&version=0", "", "DG-Optimistic-Lock-Version", "1")
+	if status, code := errorCode(t, rec); status != http.StatusConflict || code != "CONFLICT" {
+		t.Fatalf("expected conflict, got %v %v", status, code)
+	}
+	if got := rec.Header().Get("DG-Optimistic-Lock-Version"); got != "2" {
+		t.Fatalf("expected the versions count, got %q", got)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/post/restore?owner=o&version=0", "", "DG-Optimistic-Lock-Version", "2")
+	if got := decode[versionedDoc](t, rec); got.Id != "2" || got.Title != "a" {
+		t.Fatalf("expected the restored version, got %+v", got)
+	}
+}
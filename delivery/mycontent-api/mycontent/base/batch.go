@@ -0,0 +1,116 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+var _ mycontent.Batchable[mycontent.Data] = &Handler[mycontent.Data]{}
+
+// PostBatch in a single transaction; mycontent.ErrBatchNotSupported if the repository is not a content.BatchRepository
+func (c *Handler[T]) PostBatch(ctx context.Context, data []T, meta any) ([]mycontent.BatchResult[T], error) {
+	batchRepo, ok := c.repo.(content.BatchRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: the storage cannot write a batch in a single transaction", mycontent.ErrBatchNotSupported)
+	}
+
+	results := make([]mycontent.BatchResult[T], len(data))
+
+	var invalid bool
+	for idx, d := range data {
+		if err := validatePost(d, c.schema); err != nil {
+			results[idx].Err = err
+			invalid = true
+		}
+	}
+	if invalid {
+		return notApplied(results), fmt.Errorf("%w: invalid batch item(s)", mycontent.ErrValidation)
+	}
+
+	items := make([]content.Data, 0, len(data))
+	for idx, d := range data {
+		item, err := toContent(d, meta)
+		if err != nil {
+			results[idx].Err = err
+			return notApplied(results), err
+		}
+		items = append(items, item)
+	}
+
+	posted, err := batchRepo.PostBatch(ctx, items)
+	if err != nil {
+		return batchFailed(results, err), fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	for idx, p := range posted {
+		c.publish(content.EventPost, items[idx], p)
+		results[idx].Data, results[idx].Err = parsePosted[T](p)
+	}
+
+	return results, nil
+}
+
+// DeleteBatch in a single transaction, the same way as PostBatch.
+// Soft delete moves each of them to the trash in its own write, so it is not supported either.
+func (c *Handler[T]) DeleteBatch(ctx context.Context, namespace string, keys []mycontent.Key) ([]mycontent.BatchResult[T], error) {
+	batchRepo, ok := c.repo.(content.BatchRepository)
+	if !ok {
+		return nil, fmt.Errorf("%w: the storage cannot delete a batch in a single transaction", mycontent.ErrBatchNotSupported)
+	}
+	if c.trash != nil {
+		return nil, fmt.Errorf("%w: soft delete cannot delete a batch in a single transaction", mycontent.ErrBatchNotSupported)
+	}
+
+	results := make([]mycontent.BatchResult[T], len(keys))
+
+	var invalid bool
+	for idx, k := range keys {
+		if err := validateDelete(namespace, k.RefIDs); err != nil {
+			results[idx].Err = err
+			invalid = true
+		}
+	}
+	if invalid {
+		return notApplied(results), fmt.Errorf("%w: invalid batch item(s)", mycontent.ErrValidation)
+	}
+
+	items := make([]content.Data, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, content.Data{Namespace: namespace, RefIDs: k.RefIDs, ID: k.ID})
+	}
+
+	deleted, err := batchRepo.DeleteBatch(ctx, items)
+	if err != nil {
+		return batchFailed(results, err), fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	for idx, d := range deleted {
+		c.publish(content.EventDelete, items[idx], d)
+		results[idx].Data, results[idx].Err = parsePosted[T](d)
+	}
+
+	return results, nil
+}
+
+// batchFailed puts the error to the failing item, if known
+func batchFailed[T any](results []mycontent.BatchResult[T], err error) []mycontent.BatchResult[T] {
+	var batchErr *content.BatchError
+	if errors.As(err, &batchErr) && batchErr.Index >= 0 && batchErr.Index < len(results) {
+		results[batchErr.Index].Err = batchErr.Err
+	}
+
+	return notApplied(results)
+}
+
+func notApplied[T any](results []mycontent.BatchResult[T]) []mycontent.BatchResult[T] {
+	for idx := range results {
+		if results[idx].Err == nil {
+			results[idx].Err = mycontent.ErrNotApplied
+		}
+	}
+	return results
+}
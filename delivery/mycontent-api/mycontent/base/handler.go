@@ -3,18 +3,36 @@ package base
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/notifier"
+	notifier_impl "github.com/desain-gratis/common/lib/notifier/impl"
 )
 
 var _ mycontent.Usecase[mycontent.Data] = &Handler[mycontent.Data]{}
+var _ mycontent.Watchable[mycontent.Data] = &Handler[mycontent.Data]{}
+var _ mycontent.Versionable = &Handler[mycontent.Data]{}
+var _ mycontent.Schematic = &Handler[mycontent.Data]{}
 
 type Handler[T mycontent.Data] struct {
-	repo content.Repository
+	repo   content.Repository
+	schema *mycontent.Schema
+
+	// soft delete, if the trash is specified
+	trash     content.Repository
+	retention time.Duration
+
+	// the version of versioned data is its event ID, since the same content can be posted again as another version
+	eventVersion bool
+
+	// the writes through this handler, watched if the repository is not a content.Watcher
+	topic notifier.Topic
 }
 
 func New[T mycontent.Data](
@@ -22,46 +40,106 @@ func New[T mycontent.Data](
 ) *Handler[T] {
 	// TODO: add validation
 	return &Handler[T]{
-		repo: repo,
+		repo:  repo,
+		topic: notifier_impl.NewStandardTopic(),
 	}
 }
 
+// WithSchema validates every posted resource with the JSON Schema, before its Validate method
+func (c *Handler[T]) WithSchema(schema *mycontent.Schema) *Handler[T] {
+	c.schema = schema
+	return c
+}
+
+// Schema of the resource; nil if there is none
+func (c *Handler[T]) Schema() *mycontent.Schema {
+	return c.schema
+}
+
 // Post (create new or overwrite) resource here
 func (c *Handler[T]) Post(ctx context.Context, data T, meta any) (T, error) {
 	var t T
+	err := validatePost(data, c.schema)
+	if err != nil {
+		return t, err
+	}
+
+	d, err := toContent(data, meta)
+	if err != nil {
+		return t, err
+	}
+
+	expected := d.ExpectedVersion
+	d.ExpectedVersion, err = c.storageVersion(ctx, d.Namespace, d.RefIDs, d.ID, expected)
+	if err != nil {
+		return t, err
+	}
+
+	result, err := c.repo.Post(ctx, data.Namespace(), data.RefIDs(), data.ID(), d)
+	if err != nil {
+		err = c.eventConflict(ctx, d.Namespace, d.RefIDs, d.ID, expected, err)
+		return t, fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	c.publish(content.EventPost, d, result)
+
+	return parsePosted[T](result)
+}
+
+func validatePost[T mycontent.Data](data T, schema *mycontent.Schema) error {
+	if schema != nil {
+		if err := schema.Validate(data); err != nil {
+			return err
+		}
+	}
+
 	err := data.Validate()
 	if err != nil {
-		return t, fmt.Errorf("error %w: %w", mycontent.ErrValidation, err)
+		return fmt.Errorf("error %w: %w", mycontent.ErrValidation, err)
 	}
 
 	if data.Namespace() == "" {
-		return t, fmt.Errorf("error %w: namespace cannot be empty", mycontent.ErrValidation)
+		return fmt.Errorf("error %w: namespace cannot be empty", mycontent.ErrValidation)
 	}
 
-	payload, errMarshal := json.Marshal(data)
-	if errMarshal != nil {
-		return t, err
+	return nil
+}
+
+func toContent[T mycontent.Data](data T, meta any) (content.Data, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return content.Data{}, err
 	}
 
 	metaPayload := []byte("{}")
 	if meta != nil {
-		metaPayload, errMarshal = json.Marshal(meta)
-		if errMarshal != nil {
-			return t, errMarshal
+		metaPayload, err = json.Marshal(meta)
+		if err != nil {
+			return content.Data{}, err
 		}
 	}
 
-	result, err := c.repo.Post(ctx, data.Namespace(), data.RefIDs(), data.ID(), content.Data{
-		Data: payload,
-		Meta: metaPayload,
-	})
-	if err != nil {
-		return t, fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	result := content.Data{
+		Namespace: data.Namespace(),
+		RefIDs:    data.RefIDs(),
+		ID:        data.ID(),
+		Data:      payload,
+		Meta:      metaPayload,
+	}
+
+	// the expected version of the existing data is a compare-and-set in the storage;
+	// the optimistic lock of a new version is checked by the storage from the meta
+	if m, ok := meta.(*mycontent.Meta); ok && m.ExpectedVersion != nil && data.ID() != "" {
+		result.ExpectedVersion = m.ExpectedVersion
 	}
 
+	return result, nil
+}
+
+func parsePosted[T mycontent.Data](result content.Data) (T, error) {
 	parsedResult, err := Parse[T](result.Data)
 	if err != nil {
-		return t, fmt.Errorf("err unmarshal: %w data: %v", err, string(result.Data))
+		return parsedResult, fmt.Errorf("err unmarshal: %w data: %v", err, string(result.Data))
 	}
 
 	parsedResult.WithID(result.ID)
@@ -69,6 +147,79 @@ func (c *Handler[T]) Post(ctx context.Context, data T, meta any) (T, error) {
 	return parsedResult, nil
 }
 
+// Version of the stored resource, for the optimistic lock
+func (c *Handler[T]) Version(ctx context.Context, namespace string, refIDs []string, ID string) (uint64, error) {
+	if ID == "" {
+		return 0, fmt.Errorf("%w: id must be specified", mycontent.ErrValidation)
+	}
+
+	ds, err := c.get(ctx, namespace, refIDs, ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.version(ds[0]), nil
+}
+
+// version of the stored data: the event ID for versioned data, the content version otherwise
+func (c *Handler[T]) version(d content.Data) uint64 {
+	if c.eventVersion {
+		return d.EventID
+	}
+	return d.Version()
+}
+
+// expectVersion checks the stored data against the expected version,
+// then expects its content version for the compare-and-set of the storage
+func (c *Handler[T]) expectVersion(stored content.Data, expected *uint64) (*uint64, error) {
+	if expected != nil {
+		if current := c.version(stored); current != *expected {
+			return nil, &content.ConflictError{Expected: *expected, Current: current}
+		}
+	}
+
+	version := stored.Version()
+	return &version, nil
+}
+
+// storageVersion translates the expected event ID of versioned data to the content version, the only one the storage knows.
+// The storage still rejects the write if the data is changed after it is read here.
+func (c *Handler[T]) storageVersion(ctx context.Context, namespace string, refIDs []string, ID string, expected *uint64) (*uint64, error) {
+	if !c.eventVersion || expected == nil {
+		return expected, nil
+	}
+
+	ds, err := c.repo.Get(ctx, namespace, refIDs, ID)
+	if err != nil && !errors.Is(err, content.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", mycontent.ErrStorage, err)
+	}
+
+	if len(ds) == 0 {
+		// 0 expects the data to not exist yet
+		if *expected == 0 {
+			return expected, nil
+		}
+		return nil, &content.ConflictError{Expected: *expected}
+	}
+
+	return c.expectVersion(ds[0], expected)
+}
+
+// eventConflict reports the conflict of the storage with the current event ID of versioned data, same as the expected one
+func (c *Handler[T]) eventConflict(ctx context.Context, namespace string, refIDs []string, ID string, expected *uint64, err error) error {
+	var conflict *content.ConflictError
+	if !c.eventVersion || expected == nil || !errors.As(err, &conflict) || conflict.NextVersion {
+		return err
+	}
+
+	result := &content.ConflictError{Expected: *expected}
+	if ds, gerr := c.repo.Get(ctx, namespace, refIDs, ID); gerr == nil && len(ds) > 0 {
+		result.Current = ds[0].EventID
+	}
+
+	return result
+}
+
 // raw data
 func (c *Handler[T]) get(ctx context.Context, namespace string, refIDs []string, ID string) ([]content.Data, error) {
 	// 1. check if there is ID
@@ -134,6 +285,42 @@ func (c *Handler[T]) Get(ctx context.Context, namespace string, refIDs []string,
 	return result, nil
 }
 
+// List the resources under the namespace & the (partial) ref IDs
+func (c *Handler[T]) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	page, err := c.list(ctx, namespace, refIDs, q)
+	if err != nil {
+		return mycontent.Page[T]{}, err
+	}
+
+	result := mycontent.Page[T]{
+		Data: make([]T, 0, len(page.Data)),
+		Next: page.Next,
+	}
+	for _, d := range page.Data {
+		parsedResult, err := Parse[T](d.Data)
+		if err != nil {
+			log.Error().Msgf("Should not happend")
+			continue
+		}
+
+		parsedResult.WithID(d.ID)
+
+		result.Data = append(result.Data, parsedResult)
+	}
+
+	return result, nil
+}
+
+// raw page
+func (c *Handler[T]) list(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	if !isValid(refIDs) {
+		return content.Page{}, fmt.Errorf(
+			"%w: references must be specified from the first one. found: %+v", mycontent.ErrValidation, refIDs)
+	}
+
+	return c.repo.List(ctx, namespace, filterEmpty(refIDs), q)
+}
+
 // TODO: move to interface
 type Pair[T mycontent.Data] struct {
 	Data T
@@ -167,7 +354,8 @@ func (c *Handler[T]) GetWithMeta(ctx context.Context, namespace string, refIDs [
 	return result, nil
 }
 
-// TODO: implement properly
+// Stream the resource; stops when the context is done.
+// Data that cannot be parsed ends the stream, reported with mycontent.ReportStreamError.
 func (c *Handler[T]) Stream(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan T, error) {
 	// 1. check if there is ID
 	if ID != "" {
@@ -195,76 +383,94 @@ func (c *Handler[T]) Stream(ctx context.Context, namespace string, refIDs []stri
 
 		go func() {
 			defer close(result)
-			result <- parsedResult
+			select {
+			case <-ctx.Done():
+			case result <- parsedResult:
+			}
 		}()
 
 		return result, nil
 	}
 
-	// 2. check if there is main ref ID (without ID)
+	// 2. check if there is main ref ID (without ID), otherwise 3. get by namespace
+	mainRefIDs := []string{}
 	if isValid(refIDs) {
-		ds, err := c.repo.Stream(ctx, namespace, filterEmpty(refIDs), "")
-		if err != nil {
-			return nil, err
-		}
-
-		result := make(chan T)
-		go func() {
-			defer close(result)
-
-			for d := range ds {
-				parsedResult, err := Parse[T](d.Data)
-				if err != nil {
-					log.Error().Msgf("Should not happend")
-					continue
-				}
-				result <- parsedResult
-			}
-		}()
-		return result, nil
+		mainRefIDs = filterEmpty(refIDs)
 	}
 
-	// 3. get by namespace
-	ds, err := c.repo.Stream(ctx, namespace, []string{}, "")
+	ds, err := c.repo.Stream(ctx, namespace, mainRefIDs, "")
 	if err != nil {
 		return nil, err
 	}
 
+	return parseStream[T](ctx, ds), nil
+}
+
+func parseStream[T mycontent.Data](ctx context.Context, ds <-chan content.Data) <-chan T {
 	result := make(chan T)
+
+	// the storage may not stop sending on ctx (eg. raft lookup has its own context),
+	// so let it finish sending when the stream ends early, instead of blocking it forever
+	drain := func() {
+		go func() {
+			for range ds {
+			}
+		}()
+	}
+
 	go func() {
 		defer close(result)
 
 		for d := range ds {
 			parsedResult, err := Parse[T](d.Data)
 			if err != nil {
-				log.Error().Msgf("Should not happend")
-				continue
+				log.Error().Msgf("failed to parse streamed data %v: %v", d.ID, err)
+				mycontent.ReportStreamError(ctx, fmt.Errorf("%w: failed to parse data %v", mycontent.ErrStorage, d.ID))
+				drain()
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				drain()
+				return
+			case result <- parsedResult:
 			}
-			result <- parsedResult
 		}
 	}()
 
-	return result, nil
+	return result
 }
 
 // Delete your resource here
 // the implementation can check whether there are linked resource or not
 func (c *Handler[T]) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (t T, err error) {
-	if !isValid(refIDs) {
-		return t, fmt.Errorf("%w: complete reference must be provided during delete", mycontent.ErrValidation)
+	if err := validateDelete(namespace, refIDs); err != nil {
+		return t, err
 	}
 
-	if namespace == "" || namespace == "*" {
-		return t, fmt.Errorf("%w: namespace cannot be empty or '*' during delete", mycontent.ErrValidation)
+	if c.trash != nil {
+		return c.softDelete(ctx, namespace, refIDs, ID)
+	}
+
+	expected := content.GetExpectedVersion(ctx)
+	if expected != nil {
+		version, err := c.storageVersion(ctx, namespace, refIDs, ID, expected)
+		if err != nil {
+			return t, err
+		}
+		ctx = content.WithExpectedVersion(ctx, *version)
 	}
 
 	// TODO user ID validation
 	d, err := c.repo.Delete(ctx, namespace, refIDs, ID)
 	if err != nil {
-		var t T
+		err = c.eventConflict(ctx, namespace, refIDs, ID, expected, err)
 		return t, fmt.Errorf("repository error: %w", err)
 	}
 
+	c.publish(content.EventDelete, content.Data{Namespace: namespace, RefIDs: refIDs, ID: ID}, d)
+
 	parsedResult, err := Parse[T](d.Data)
 	if err != nil {
 		return t, err
@@ -275,6 +481,18 @@ func (c *Handler[T]) Delete(ctx context.Context, namespace string, refIDs []stri
 	return parsedResult, nil
 }
 
+func validateDelete(namespace string, refIDs []string) error {
+	if !isValid(refIDs) {
+		return fmt.Errorf("%w: complete reference must be provided during delete", mycontent.ErrValidation)
+	}
+
+	if namespace == "" || namespace == "*" {
+		return fmt.Errorf("%w: namespace cannot be empty or '*' during delete", mycontent.ErrValidation)
+	}
+
+	return nil
+}
+
 func Parse[T any](in []byte) (T, error) {
 	var t T
 	err := json.Unmarshal(in, &t)
@@ -308,3 +526,140 @@ func isValid(arr []string) bool {
 	}
 	return true
 }
+
+// Watch the changes. The repository that is a content.Watcher publishes them, and can resume from the event ID.
+// Otherwise, only the live changes written through this handler are watched; not those of another instance.
+func (c *Handler[T]) Watch(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan mycontent.Event[T], error) {
+	return c.watch(ctx, namespace, refIDs, fromEventID, func(t T, _ content.Data) T { return t })
+}
+
+func (c *Handler[T]) watch(
+	ctx context.Context,
+	namespace string,
+	refIDs []string,
+	fromEventID *uint64,
+	transform func(t T, d content.Data) T,
+) (<-chan mycontent.Event[T], error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("%w: namespace cannot be empty", mycontent.ErrValidation)
+	}
+
+	if !isValid(refIDs) {
+		return nil, fmt.Errorf("%w: reference must be specified in order. found: %+v", mycontent.ErrValidation, refIDs)
+	}
+
+	var events <-chan content.Event
+	var err error
+	if watcher, ok := c.repo.(content.Watcher); ok {
+		events, err = watcher.Watch(ctx, namespace, filterEmpty(refIDs), fromEventID)
+		// eg. clickhouse-raft without the topic of the table
+		if errors.Is(err, content.ErrWatchNotSupported) && fromEventID == nil {
+			events, err = c.watchPublished(ctx, namespace, filterEmpty(refIDs), fromEventID)
+		}
+	} else {
+		events, err = c.watchPublished(ctx, namespace, filterEmpty(refIDs), fromEventID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(chan mycontent.Event[T])
+
+	go func() {
+		defer close(result)
+
+		for e := range events {
+			event := mycontent.Event[T]{
+				EventID:   e.Data.EventID,
+				Type:      e.Type,
+				Namespace: e.Data.Namespace,
+				RefIDs:    e.Data.RefIDs,
+				ID:        e.Data.ID,
+			}
+
+			if e.Type == content.EventPost {
+				parsedResult, err := Parse[T](e.Data.Data)
+				if err != nil {
+					log.Error().Msgf("failed to parse watched data %v: %v", e.Data.ID, err)
+					continue
+				}
+				event.Data = transform(parsedResult, e.Data)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case result <- event:
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+// watchPublished watches the writes through this handler; they are not kept, so it cannot resume from an event ID
+func (c *Handler[T]) watchPublished(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan content.Event, error) {
+	if fromEventID != nil {
+		return nil, fmt.Errorf("%w: the storage keeps no event to resume from; get the content again, then watch without it", content.ErrWatchExpired)
+	}
+
+	if c.topic == nil {
+		return nil, content.ErrWatchNotSupported
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	subs, err := c.topic.Subscribe(ctx, notifier_impl.NewStandardSubscriber(func(msg any) bool {
+		event, ok := msg.(content.Event)
+		return !ok || !event.Match(namespace, refIDs)
+	}))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	subs.Start()
+	live := subs.Listen()
+
+	out := make(chan content.Event)
+
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		for msg := range live {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- msg.(content.Event):
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// publish the successful write to the watchers of this handler. The key is of the request,
+// since not every repository returns it; a delete event has only the key & the event ID.
+func (c *Handler[T]) publish(eventType content.EventType, key content.Data, result content.Data) {
+	if c.topic == nil {
+		return
+	}
+
+	data := content.Data{
+		Namespace: key.Namespace,
+		RefIDs:    key.RefIDs,
+		ID:        result.ID,
+		EventID:   result.EventID,
+	}
+	if data.ID == "" {
+		data.ID = key.ID
+	}
+	if eventType == content.EventPost {
+		data.Data = result.Data
+	}
+
+	err := c.topic.Broadcast(context.Background(), content.Event{Type: eventType, Data: data})
+	if err != nil {
+		log.Error().Msgf("failed to publish the %v event of %v: %v", eventType, data.ID, err)
+	}
+}
@@ -2,13 +2,17 @@ package base
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	notifier_impl "github.com/desain-gratis/common/lib/notifier/impl"
 	"github.com/rs/zerolog/log"
 )
 
 var _ mycontent.Usecase[mycontent.VersionedData] = &VersionedHandler[mycontent.VersionedData]{}
+var _ mycontent.Historical[mycontent.VersionedData] = &VersionedHandler[mycontent.VersionedData]{}
 
 type VersionedHandler[T mycontent.VersionedData] struct {
 	*Handler[T] // extends
@@ -20,7 +24,9 @@ func NewVersioned[T mycontent.VersionedData](
 ) *VersionedHandler[T] {
 	return &VersionedHandler[T]{
 		Handler: &Handler[T]{
-			repo: repo,
+			repo:         repo,
+			eventVersion: true,
+			topic:        notifier_impl.NewStandardTopic(),
 		},
 	}
 }
@@ -48,3 +54,109 @@ func (c *VersionedHandler[T]) Get(ctx context.Context, namespace string, refIDs
 
 	return result, nil
 }
+
+// Extend the List
+func (c *VersionedHandler[T]) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	page, err := c.list(ctx, namespace, refIDs, q)
+	if err != nil {
+		return mycontent.Page[T]{}, err
+	}
+
+	result := mycontent.Page[T]{
+		Data: make([]T, 0, len(page.Data)),
+		Next: page.Next,
+	}
+	for _, d := range page.Data {
+		parsedResult, err := Parse[T](d.Data)
+		if err != nil {
+			log.Error().Msgf("Should not happend")
+			continue
+		}
+
+		parsedResult.WithEventID(d.EventID)
+
+		result.Data = append(result.Data, parsedResult)
+	}
+
+	return result, nil
+}
+
+// Extend the Watch
+func (c *VersionedHandler[T]) Watch(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan mycontent.Event[T], error) {
+	return c.watch(ctx, namespace, refIDs, fromEventID, func(t T, d content.Data) T {
+		t.WithEventID(d.EventID)
+		return t
+	})
+}
+
+// History lists every version in write order, latest first
+func (c *VersionedHandler[T]) History(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	if len(filterEmpty(refIDs)) != len(refIDs) {
+		return mycontent.Page[T]{}, fmt.Errorf(
+			"%w: all reference must be specified for the history. found: %+v", mycontent.ErrValidation, refIDs)
+	}
+
+	q.OrderBy = content.OrderByEventID
+	q.Desc = true
+
+	return c.List(ctx, namespace, refIDs, q)
+}
+
+// Restore the version by posting it again without ID, so it is written as the new version
+func (c *VersionedHandler[T]) Restore(ctx context.Context, namespace string, refIDs []string, version string, meta any) (T, error) {
+	var t T
+	if version == "" {
+		return t, fmt.Errorf("%w: version must be specified", mycontent.ErrValidation)
+	}
+
+	ds, err := c.get(ctx, namespace, refIDs, version)
+	if err != nil {
+		return t, err
+	}
+
+	data, err := Parse[T](ds[0].Data)
+	if err != nil {
+		return t, fmt.Errorf("%w: stored version cannot be parsed: %w", mycontent.ErrStorage, err)
+	}
+
+	data.WithID("")
+	data.WithCreatedTime(time.Now())
+
+	err = validatePost(data, c.schema)
+	if err != nil {
+		return t, err
+	}
+
+	d, err := toContent(data, meta)
+	if err != nil {
+		return t, err
+	}
+
+	result, err := c.repo.Post(ctx, namespace, refIDs, "", d)
+	if err != nil {
+		return t, fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	c.publish(content.EventPost, d, result)
+
+	restored, err := parsePosted[T](result)
+	if err != nil {
+		return t, err
+	}
+
+	restored.WithEventID(result.EventID)
+
+	return restored, nil
+}
+
+// WithSoftDelete the same as Handler, for chaining
+func (c *VersionedHandler[T]) WithSoftDelete(trash content.Repository, retention time.Duration) *VersionedHandler[T] {
+	c.Handler.WithSoftDelete(trash, retention)
+	return c
+}
+
+// WithSchema the same as Handler, for chaining
+func (c *VersionedHandler[T]) WithSchema(schema *mycontent.Schema) *VersionedHandler[T] {
+	c.Handler.WithSchema(schema)
+	return c
+}
@@ -19,12 +19,20 @@ import (
 
 var _ mycontent.Usecase[*entity.Attachment] = &HandlerWithAttachment{}
 var _ mycontent.Attachable[*entity.Attachment] = &HandlerWithAttachment{}
+var _ mycontent.Trashable[*entity.Attachment] = &HandlerWithAttachment{}
+var _ mycontent.Resumable[*entity.Attachment] = &HandlerWithAttachment{}
+var _ Purger = &HandlerWithAttachment{}
 
 type HandlerWithAttachment struct {
 	*Handler[*entity.Attachment]
 	blobRepo  blob.Repository
 	hideUrl   bool // if data are quite sensitive
 	namespace string
+
+	// resumable upload, if the sessions is specified
+	sessions     content.Repository
+	partSize     int64
+	uploadExpiry time.Duration
 }
 
 // NewWithAttachment creates the basic CRUD handle, but enables attachment
@@ -59,6 +67,80 @@ func (c *HandlerWithAttachment) Get(ctx context.Context, userID string, refIDs [
 	return result, nil
 }
 
+// Overwrite for censoring
+func (c *HandlerWithAttachment) List(ctx context.Context, userID string, refIDs []string, q content.Query) (mycontent.Page[*entity.Attachment], error) {
+	result, err := c.Handler.List(ctx, userID, refIDs, q)
+	if err != nil {
+		return result, err
+	}
+	if c.hideUrl {
+		for _, d := range result.Data {
+			d.Url = ""
+			d.Path = ""
+		}
+	}
+	return result, nil
+}
+
+// WithSoftDelete keeps the blob of the deleted attachment until it is purged from the trash
+func (c *HandlerWithAttachment) WithSoftDelete(trash content.Repository, retention time.Duration) *HandlerWithAttachment {
+	c.Handler.WithSoftDelete(trash, retention)
+	return c
+}
+
+// Overwrite for censoring
+func (c *HandlerWithAttachment) Trash(ctx context.Context, userID string, refIDs []string, q content.Query) (mycontent.Page[*entity.Attachment], error) {
+	result, err := c.Handler.Trash(ctx, userID, refIDs, q)
+	if err != nil {
+		return result, err
+	}
+	if c.hideUrl {
+		for _, d := range result.Data {
+			d.Url = ""
+			d.Path = ""
+		}
+	}
+	return result, nil
+}
+
+// Overwrite for censoring
+func (c *HandlerWithAttachment) Undelete(ctx context.Context, userID string, refIDs []string, ID string) (*entity.Attachment, error) {
+	at, err := c.Handler.Undelete(ctx, userID, refIDs, ID)
+	if err != nil {
+		return nil, err
+	}
+	if c.hideUrl {
+		at.Url = ""
+		at.DataUrl = ""
+		at.Path = ""
+	}
+	return at, nil
+}
+
+// Purge the expired attachments in the trash together with their blob
+func (c *HandlerWithAttachment) Purge(ctx context.Context) (int, error) {
+	return c.purge(ctx, func(d content.Data) error {
+		at, err := Parse[*entity.Attachment](d.Data)
+		if err != nil {
+			return fmt.Errorf("%w: invalid attachment %v in the trash: %w", mycontent.ErrStorage, d.ID, err)
+		}
+
+		_, err = c.blobRepo.Delete(ctx, at.Path)
+		return err
+	})
+}
+
+// Overwrite for censoring
+func (c *HandlerWithAttachment) Watch(ctx context.Context, userID string, refIDs []string, fromEventID *uint64) (<-chan mycontent.Event[*entity.Attachment], error) {
+	return c.watch(ctx, userID, refIDs, fromEventID, func(d *entity.Attachment, _ content.Data) *entity.Attachment {
+		if c.hideUrl {
+			d.Url = ""
+			d.Path = ""
+		}
+		return d
+	})
+}
+
 // BETA
 func (c *HandlerWithAttachment) GetAttachment(ctx context.Context, userID string, refIDs []string, ID string) (payload io.ReadCloser, meta *entity.Attachment, err error) {
 	result, err := c.Handler.Get(ctx, userID, refIDs, ID)
@@ -85,6 +167,12 @@ func (c *HandlerWithAttachment) Put(ctx context.Context, content *entity.Attachm
 	return nil, errors.New("not supported")
 }
 
+// Patch
+// disables the default patch behaviour, the attachment is only written by Attach
+func (c *HandlerWithAttachment) Patch(ctx context.Context, namespace string, refIDs []string, ID string, patch []byte, meta any) (*entity.Attachment, error) {
+	return nil, fmt.Errorf("%w: attachment cannot be patched", mycontent.ErrValidation)
+}
+
 func (c *HandlerWithAttachment) Attach(ctx context.Context, meta *entity.Attachment, payload io.Reader) (*entity.Attachment, error) {
 	// TODO: Get all existing data based on user ID, calculate the total size to do validation
 
@@ -134,22 +222,9 @@ func (c *HandlerWithAttachment) Attach(ctx context.Context, meta *entity.Attachm
 
 	// If it's a new object, generate random ID
 	if len(existing) != 1 { // can paranoid check id
-		// make random name
-		uid, err := uuid.NewRandom()
+		result.Path, err = c.newPath(result.CreatedAt)
 		if err != nil {
-			return nil, fmt.Errorf("%w: failed to generate uuid", err)
-		}
-
-		// overwrite name with random (so cannot be guessed); todo: since the actual path is using random uuid we good.
-		// result.Name = uid.String()
-		t, err := time.Parse(time.RFC3339, result.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("%w: invalid created at value, must be RFC3339", err)
-		}
-
-		result.Path = strconv.Itoa(t.Year()) + "/" + strconv.Itoa(int(t.Month())) + "/" + uid.String()
-		if c.namespace != "" {
-			result.Path = c.namespace + "/" + result.Path
+			return nil, err
 		}
 	}
 
@@ -176,11 +251,36 @@ func (c *HandlerWithAttachment) Attach(ctx context.Context, meta *entity.Attachm
 
 // DeleteAttachment generic binary at path
 func (c *HandlerWithAttachment) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (*entity.Attachment, error) {
+	// the blob is deleted when it is purged from the trash instead
+	if c.trash != nil {
+		at, err := c.Handler.Delete(ctx, namespace, refIDs, ID)
+		if err != nil {
+			return nil, err
+		}
+		if c.hideUrl {
+			at.Url = ""
+			at.DataUrl = ""
+			at.Path = ""
+		}
+		return at, nil
+	}
+
 	result, err := c.Handler.Get(ctx, namespace, refIDs, ID)
 	if err != nil {
 		return nil, err
 	}
 
+	// the blob cannot be restored, so the compare-and-set is checked before it is deleted
+	if expectedVersion := content.GetExpectedVersion(ctx); expectedVersion != nil {
+		ds, err := c.get(ctx, namespace, refIDs, ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := content.CheckVersion(&ds[0], content.Data{ExpectedVersion: expectedVersion}); err != nil {
+			return nil, err
+		}
+	}
+
 	_, err = c.blobRepo.Delete(ctx, result[0].Path)
 	if err != nil {
 		return nil, err
@@ -198,3 +298,27 @@ func (c *HandlerWithAttachment) Delete(ctx context.Context, namespace string, re
 	}
 	return at, nil
 }
+
+// Overwrite, since the blob cannot be deleted in the same transaction
+func (c *HandlerWithAttachment) DeleteBatch(ctx context.Context, namespace string, keys []mycontent.Key) ([]mycontent.BatchResult[*entity.Attachment], error) {
+	return nil, fmt.Errorf("%w: the blob of the attachment cannot be deleted in the same transaction", mycontent.ErrBatchNotSupported)
+}
+
+// newPath of the blob of the new attachment, random so it cannot be guessed
+func (c *HandlerWithAttachment) newPath(createdAt string) (string, error) {
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to generate uuid", err)
+	}
+
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid created at value, must be RFC3339", err)
+	}
+
+	path := strconv.Itoa(t.Year()) + "/" + strconv.Itoa(int(t.Month())) + "/" + uid.String()
+	if c.namespace != "" {
+		path = c.namespace + "/" + path
+	}
+	return path, nil
+}
@@ -0,0 +1,113 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+var _ mycontent.Patchable[mycontent.Data] = &Handler[mycontent.Data]{}
+
+// Patch the stored resource with a JSON merge patch (RFC 7396), then post it the same way as Post.
+// The write is a compare-and-set against the version that is patched.
+func (c *Handler[T]) Patch(ctx context.Context, namespace string, refIDs []string, ID string, patch []byte, meta any) (T, error) {
+	var t T
+	if ID == "" {
+		return t, fmt.Errorf("%w: id must be specified during patch", mycontent.ErrValidation)
+	}
+
+	if namespace == "" || namespace == "*" {
+		return t, fmt.Errorf("%w: namespace cannot be empty or '*' during patch", mycontent.ErrValidation)
+	}
+
+	ds, err := c.get(ctx, namespace, refIDs, ID)
+	if err != nil {
+		return t, err
+	}
+
+	patched, err := mergePatch(ds[0].Data, patch)
+	if err != nil {
+		return t, fmt.Errorf("%w: %w", mycontent.ErrValidation, err)
+	}
+
+	data, err := Parse[T](patched)
+	if err != nil {
+		return t, fmt.Errorf("%w: patched data cannot be parsed: %w", mycontent.ErrValidation, err)
+	}
+
+	// the location of the resource is not patchable
+	data.WithID(ds[0].ID)
+	if data.Namespace() != namespace || !slices.Equal(data.RefIDs(), refIDs) {
+		return t, fmt.Errorf("%w: namespace and references cannot be patched", mycontent.ErrValidation)
+	}
+
+	err = validatePost(data, c.schema)
+	if err != nil {
+		return t, err
+	}
+
+	d, err := toContent(data, meta)
+	if err != nil {
+		return t, err
+	}
+
+	// without the optimistic lock, it is still patched only if the data is not changed since it is read
+	expected := d.ExpectedVersion
+	d.ExpectedVersion, err = c.expectVersion(ds[0], expected)
+	if err != nil {
+		return t, err
+	}
+
+	result, err := c.repo.Post(ctx, namespace, refIDs, d.ID, d)
+	if err != nil {
+		err = c.eventConflict(ctx, namespace, refIDs, d.ID, expected, err)
+		return t, fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	c.publish(content.EventPost, d, result)
+
+	return parsePosted[T](result)
+}
+
+// mergePatch the JSON document as specified in RFC 7396
+func mergePatch(doc []byte, patch []byte) ([]byte, error) {
+	var target any
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &target); err != nil {
+			return nil, fmt.Errorf("stored data is not a valid JSON: %w", err)
+		}
+	}
+
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("patch is not a valid JSON: %w", err)
+	}
+
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target any, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any)
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergeValue(targetObj[k], v)
+	}
+
+	return targetObj
+}
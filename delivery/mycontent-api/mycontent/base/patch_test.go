@@ -0,0 +1,94 @@
+package base
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{
+			name:  "replace & add",
+			doc:   `{"a":"b","c":"d"}`,
+			patch: `{"a":"z","e":"f"}`,
+			want:  `{"a":"z","c":"d","e":"f"}`,
+		},
+		{
+			name:  "null deletes",
+			doc:   `{"a":"b","c":"d"}`,
+			patch: `{"a":null,"x":null}`,
+			want:  `{"c":"d"}`,
+		},
+		{
+			name:  "nested objects merge",
+			doc:   `{"a":{"b":"c","d":{"e":"f","g":"h"}}}`,
+			patch: `{"a":{"b":"z","d":{"e":null,"i":"j"}}}`,
+			want:  `{"a":{"b":"z","d":{"g":"h","i":"j"}}}`,
+		},
+		{
+			name:  "array replaced",
+			doc:   `{"a":[1,2,3]}`,
+			patch: `{"a":[4]}`,
+			want:  `{"a":[4]}`,
+		},
+		{
+			name:  "object replaces a value",
+			doc:   `{"a":"b"}`,
+			patch: `{"a":{"c":"d","e":null}}`,
+			want:  `{"a":{"c":"d"}}`,
+		},
+		{
+			name:  "non-object patch replaces the document",
+			doc:   `{"a":"b"}`,
+			patch: `["c"]`,
+			want:  `["c"]`,
+		},
+		{
+			name:  "object patch of a non-object document",
+			doc:   `"a"`,
+			patch: `{"b":"c"}`,
+			want:  `{"b":"c"}`,
+		},
+		{
+			name:  "empty document",
+			doc:   ``,
+			patch: `{"a":"b"}`,
+			want:  `{"a":"b"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := mergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`)); err == nil {
+		t.Fatal("expected error of the invalid patch")
+	}
+	if _, err := mergePatch([]byte(`{"a":`), []byte(`{"a":"b"}`)); err == nil {
+		t.Fatal("expected error of the invalid stored data")
+	}
+}
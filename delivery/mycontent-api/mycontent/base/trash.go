@@ -0,0 +1,275 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+var _ mycontent.Trashable[mycontent.Data] = &Handler[mycontent.Data]{}
+var _ Purger = &Handler[mycontent.Data]{}
+
+// purgePageSize of the trash listing during purge
+const purgePageSize = 100
+
+// Purger hard deletes the expired soft deleted resources
+type Purger interface {
+	Purge(ctx context.Context) (int, error)
+}
+
+// PurgerFunc is the function as a Purger, eg. HandlerWithAttachment.PurgeUploads
+type PurgerFunc func(ctx context.Context) (int, error)
+
+func (f PurgerFunc) Purge(ctx context.Context) (int, error) {
+	return f(ctx)
+}
+
+// tombstone is the meta of the trashed data. The "created_at" is the deletion time, so the trash can be ordered by it.
+type tombstone struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Meta      json.RawMessage `json:"meta,omitempty"`
+}
+
+// WithSoftDelete moves the deleted resources to the trash repository instead, until the retention is over.
+// The trash is a non-versioned table with the same ref size.
+func (c *Handler[T]) WithSoftDelete(trash content.Repository, retention time.Duration) *Handler[T] {
+	c.trash = trash
+	c.retention = retention
+	return c
+}
+
+// softDelete writes the tombstone to the trash first, so the data is never lost in between
+func (c *Handler[T]) softDelete(ctx context.Context, namespace string, refIDs []string, ID string) (t T, err error) {
+	if ID == "" {
+		return t, fmt.Errorf("%w: id must be specified during delete", mycontent.ErrValidation)
+	}
+
+	ds, err := c.get(ctx, namespace, refIDs, ID)
+	if err != nil {
+		return t, err
+	}
+
+	// fail early, the delete below is still a compare-and-set of the content version
+	expected := content.GetExpectedVersion(ctx)
+	if expected != nil {
+		version, err := c.expectVersion(ds[0], expected)
+		if err != nil {
+			return t, err
+		}
+		ctx = content.WithExpectedVersion(ctx, *version)
+	}
+
+	meta, err := json.Marshal(tombstone{
+		CreatedAt: time.Now(),
+		Meta:      ds[0].Meta,
+	})
+	if err != nil {
+		return t, err
+	}
+
+	_, err = c.trash.Post(ctx, namespace, refIDs, ID, content.Data{
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+		Data:      ds[0].Data,
+		Meta:      meta,
+	})
+	if err != nil {
+		return t, fmt.Errorf("%w: %w during trash storage", mycontent.ErrStorage, err)
+	}
+
+	d, err := c.repo.Delete(ctx, namespace, refIDs, ID)
+	if err != nil {
+		if _, terr := c.trash.Delete(content.WithoutExpectedVersion(context.WithoutCancel(ctx)), namespace, refIDs, ID); terr != nil {
+			log.Error().Msgf("failed to remove the tombstone of %v after a failed delete: %v", ID, terr)
+		}
+		err = c.eventConflict(ctx, namespace, refIDs, ID, expected, err)
+		return t, fmt.Errorf("repository error: %w", err)
+	}
+
+	c.publish(content.EventDelete, content.Data{Namespace: namespace, RefIDs: refIDs, ID: ID}, d)
+
+	return parsePosted[T](d)
+}
+
+// Trash lists the soft deleted resources, latest deleted first
+func (c *Handler[T]) Trash(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	if c.trash == nil {
+		return mycontent.Page[T]{}, mycontent.ErrSoftDeleteNotEnabled
+	}
+
+	if !isValid(refIDs) {
+		return mycontent.Page[T]{}, fmt.Errorf(
+			"%w: references must be specified from the first one. found: %+v", mycontent.ErrValidation, refIDs)
+	}
+
+	q.OrderBy = content.OrderByCreatedAt
+	q.Desc = true
+
+	page, err := c.trash.List(ctx, namespace, filterEmpty(refIDs), q)
+	if err != nil {
+		return mycontent.Page[T]{}, err
+	}
+
+	result := mycontent.Page[T]{
+		Data: make([]T, 0, len(page.Data)),
+		Next: page.Next,
+	}
+	for _, d := range page.Data {
+		parsedResult, err := Parse[T](d.Data)
+		if err != nil {
+			log.Error().Msgf("Should not happend")
+			continue
+		}
+
+		parsedResult.WithID(d.ID)
+
+		result.Data = append(result.Data, parsedResult)
+	}
+
+	return result, nil
+}
+
+// Undelete the soft deleted resource back to its place, with its original meta.
+// Conflict if the place has been written since.
+func (c *Handler[T]) Undelete(ctx context.Context, namespace string, refIDs []string, ID string) (t T, err error) {
+	if c.trash == nil {
+		return t, mycontent.ErrSoftDeleteNotEnabled
+	}
+
+	if err := validateDelete(namespace, refIDs); err != nil {
+		return t, err
+	}
+
+	if ID == "" {
+		return t, fmt.Errorf("%w: id must be specified during undelete", mycontent.ErrValidation)
+	}
+
+	ds, err := c.trash.Get(ctx, namespace, refIDs, ID)
+	if err != nil {
+		return t, err
+	}
+
+	if len(ds) == 0 {
+		return t, fmt.Errorf("%w: id specified, but not found in the trash", mycontent.ErrNotFound)
+	}
+
+	var tomb tombstone
+	if err := json.Unmarshal(ds[0].Meta, &tomb); err != nil {
+		return t, fmt.Errorf("%w: invalid tombstone: %w", mycontent.ErrStorage, err)
+	}
+
+	meta := []byte(tomb.Meta)
+	if len(meta) == 0 {
+		meta = []byte("{}")
+	}
+
+	notExist := uint64(0)
+	result, err := c.repo.Post(ctx, namespace, refIDs, ID, content.Data{
+		Namespace:       namespace,
+		RefIDs:          refIDs,
+		ID:              ID,
+		Data:            ds[0].Data,
+		Meta:            meta,
+		ExpectedVersion: &notExist,
+	})
+	if err != nil {
+		return t, fmt.Errorf("%w: %w during data storage", mycontent.ErrStorage, err)
+	}
+
+	_, err = c.trash.Delete(content.WithoutExpectedVersion(ctx), namespace, refIDs, ID)
+	if err != nil {
+		log.Error().Msgf("failed to remove the tombstone of undeleted %v: %v", ID, err)
+	}
+
+	c.publish(content.EventPost, content.Data{Namespace: namespace, RefIDs: refIDs, ID: ID}, result)
+
+	return parsePosted[T](result)
+}
+
+// Purge hard deletes the resources in the trash longer than the retention
+func (c *Handler[T]) Purge(ctx context.Context) (int, error) {
+	return c.purge(ctx, func(content.Data) error { return nil })
+}
+
+// purge the expired tombstones page by page; beforeDelete is for the other resource linked to the data, eg. blob.
+// A tombstone that fails is logged & kept for the next purge, the rest is still purged.
+func (c *Handler[T]) purge(ctx context.Context, beforeDelete func(d content.Data) error) (int, error) {
+	if c.trash == nil {
+		return 0, mycontent.ErrSoftDeleteNotEnabled
+	}
+
+	expiry := time.Now().Add(-c.retention)
+
+	var purged, failed int
+	q := content.Query{Limit: purgePageSize}
+	for {
+		// the cursor is the key of the last listed, so the deletion does not shift the next page
+		page, err := c.trash.List(ctx, "*", []string{}, q)
+		if err != nil {
+			return purged, fmt.Errorf("%w: %w during trash listing", mycontent.ErrStorage, err)
+		}
+
+		for _, d := range page.Data {
+			var tomb tombstone
+			if err := json.Unmarshal(d.Meta, &tomb); err != nil {
+				log.Error().Msgf("invalid tombstone of %v in namespace %v: %v", d.ID, d.Namespace, err)
+				continue
+			}
+			if !tomb.CreatedAt.Before(expiry) {
+				continue
+			}
+
+			if err := beforeDelete(d); err != nil {
+				log.Error().Msgf("failed to purge %v in namespace %v: %v", d.ID, d.Namespace, err)
+				failed++
+				continue
+			}
+
+			if _, err := c.trash.Delete(ctx, d.Namespace, d.RefIDs, d.ID); err != nil {
+				log.Error().Msgf("failed to delete the tombstone of %v in namespace %v: %v", d.ID, d.Namespace, err)
+				failed++
+				continue
+			}
+			purged++
+		}
+
+		if page.Next == "" {
+			break
+		}
+		q.Cursor = page.Next
+	}
+
+	if failed > 0 {
+		return purged, fmt.Errorf("%w: failed to purge %v of the expired tombstones", mycontent.ErrStorage, failed)
+	}
+
+	return purged, nil
+}
+
+// RunPurger purges every interval until the context is done
+func RunPurger(ctx context.Context, purger Purger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := purger.Purge(ctx)
+			if err != nil {
+				log.Error().Msgf("failed to purge: %v", err)
+			}
+			if purged > 0 {
+				log.Info().Msgf("purged %v item(s)", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/blob"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+// uploadSession is the data of the session repository, keyed by the namespace & the upload ID
+type uploadSession struct {
+	Attachment   *entity.Attachment `json:"attachment"`
+	BlobUploadID string             `json:"blob_upload_id"`
+	PartSize     int64              `json:"part_size"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+}
+
+type uploadMeta struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WithResumableUpload keeps the sessions of the resumable upload in the repository, a non-versioned table without ref.
+// The blob repository must be blob.Multipart. The part size is at least blob.MinPartSize;
+// the unfinished session is aborted by PurgeUploads after the expiry.
+func (c *HandlerWithAttachment) WithResumableUpload(sessions content.Repository, partSize int64, expiry time.Duration) *HandlerWithAttachment {
+	c.sessions = sessions
+	c.partSize = max(partSize, blob.MinPartSize)
+	c.uploadExpiry = expiry
+	return c
+}
+
+// StartUpload of the attachment to the same path as Attach; the attachment with the same ID is overwritten when it is completed
+func (c *HandlerWithAttachment) StartUpload(ctx context.Context, meta *entity.Attachment) (*mycontent.UploadSession[*entity.Attachment], error) {
+	multipart, err := c.multipart()
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.Namespace() == "" {
+		return nil, fmt.Errorf("%w: namespace must be specified", mycontent.ErrValidation)
+	}
+	if meta.ContentSize == 0 {
+		return nil, fmt.Errorf("%w: content size must be specified", mycontent.ErrValidation)
+	}
+	if err := validatePost(meta, c.schema); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if meta.CreatedAt == "" {
+		meta.CreatedAt = now.Format(time.RFC3339)
+	}
+	if meta.ContentType == "" {
+		meta.ContentType = "application/octet-stream"
+	}
+
+	var existing *entity.Attachment
+	if meta.Id != "" {
+		result, err := c.Handler.Get(ctx, meta.Namespace(), meta.RefIds, meta.Id)
+		if err != nil && !errors.Is(err, content.ErrNotFound) && !errors.Is(err, mycontent.ErrNotFound) {
+			return nil, err
+		}
+		if len(result) == 1 {
+			existing = result[0]
+		}
+	}
+
+	if existing != nil {
+		// Server overwritten properties, the same as Attach
+		meta.RefIds = existing.RefIds
+		meta.Url = existing.Url
+		meta.OwnerId = existing.OwnerId
+		meta.CreatedAt = existing.CreatedAt
+		meta.Name = existing.Name
+		meta.Path = existing.Path
+	} else {
+		meta.Path, err = c.newPath(meta.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blobUploadID, err := multipart.StartUpload(ctx, meta.Path, meta)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w during upload start", mycontent.ErrStorage, err)
+	}
+
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to generate uuid", err)
+	}
+
+	session := uploadSession{
+		Attachment:   meta,
+		BlobUploadID: blobUploadID,
+		PartSize:     c.partSize,
+		ExpiresAt:    now.Add(c.uploadExpiry),
+	}
+
+	if err := c.saveUpload(ctx, meta.Namespace(), uid.String(), session, now); err != nil {
+		if aerr := multipart.AbortUpload(context.WithoutCancel(ctx), meta.Path, blobUploadID); aerr != nil {
+			log.Error().Msgf("failed to abort the upload of %v after a failed start: %v", meta.Path, aerr)
+		}
+		return nil, err
+	}
+
+	return c.uploadView(uid.String(), session, nil), nil
+}
+
+// GetUpload session with the parts uploaded so far
+func (c *HandlerWithAttachment) GetUpload(ctx context.Context, namespace string, uploadID string) (*mycontent.UploadSession[*entity.Attachment], error) {
+	session, multipart, err := c.loadUpload(ctx, namespace, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := multipart.ListParts(ctx, session.Attachment.Path, session.BlobUploadID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w during part listing", mycontent.ErrStorage, err)
+	}
+
+	return c.uploadView(uploadID, *session, parts), nil
+}
+
+// UploadPart of the session; every part must have the part size, except the last one
+func (c *HandlerWithAttachment) UploadPart(ctx context.Context, namespace string, uploadID string, number int, payload io.Reader, size int64) (*mycontent.UploadPart, error) {
+	session, multipart, err := c.loadUpload(ctx, namespace, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	partCount := session.partCount()
+	if number < 1 || number > partCount {
+		return nil, fmt.Errorf("%w: part number must be between 1 and %v", mycontent.ErrValidation, partCount)
+	}
+
+	expected := mycontent.PartSizeOf(int64(session.Attachment.ContentSize), session.PartSize, number)
+	if size != expected {
+		return nil, fmt.Errorf("%w: part %v must be %v bytes, got %v", mycontent.ErrValidation, number, expected, size)
+	}
+
+	part, err := multipart.UploadPart(ctx, session.Attachment.Path, session.BlobUploadID, number, payload, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w during part upload", mycontent.ErrStorage, err)
+	}
+
+	return &mycontent.UploadPart{
+		Number: part.Number,
+		Size:   part.Size,
+	}, nil
+}
+
+// CompleteUpload after every part is uploaded, then the attachment is written
+func (c *HandlerWithAttachment) CompleteUpload(ctx context.Context, namespace string, uploadID string) (*entity.Attachment, error) {
+	session, multipart, err := c.loadUpload(ctx, namespace, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := multipart.ListParts(ctx, session.Attachment.Path, session.BlobUploadID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w during part listing", mycontent.ErrStorage, err)
+	}
+
+	view := c.uploadView(uploadID, *session, parts)
+	if missing := view.Missing(); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: part(s) %v are not uploaded yet", mycontent.ErrValidation, missing)
+	}
+
+	data, err := multipart.CompleteUpload(ctx, session.Attachment.Path, session.BlobUploadID, session.Attachment, parts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w during upload completion", mycontent.ErrStorage, err)
+	}
+
+	// Another server protected field
+	at := session.Attachment
+	at.ContentSize = uint64(data.ContentSize)
+	at.Url = data.PublicURL // will be overwritten..
+	at.DataUrl = data.PublicURL
+
+	createdAt := map[string]string{
+		"created_at": time.Now().Format(time.RFC3339),
+	}
+	result, err := c.Handler.Post(ctx, at, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.sessions.Delete(content.WithoutExpectedVersion(ctx), namespace, []string{}, uploadID)
+	if err != nil {
+		log.Error().Msgf("failed to remove the completed upload session %v: %v", uploadID, err)
+	}
+
+	if c.hideUrl {
+		result.Url = ""
+		result.DataUrl = ""
+		result.Path = ""
+	}
+	return result, nil
+}
+
+// AbortUpload and discard its uploaded parts
+func (c *HandlerWithAttachment) AbortUpload(ctx context.Context, namespace string, uploadID string) error {
+	session, multipart, err := c.loadUpload(ctx, namespace, uploadID)
+	if err != nil {
+		return err
+	}
+
+	err = multipart.AbortUpload(ctx, session.Attachment.Path, session.BlobUploadID)
+	if err != nil {
+		return fmt.Errorf("%w: %w during upload abort", mycontent.ErrStorage, err)
+	}
+
+	_, err = c.sessions.Delete(content.WithoutExpectedVersion(ctx), namespace, []string{}, uploadID)
+	if err != nil {
+		return fmt.Errorf("%w: %w during session deletion", mycontent.ErrStorage, err)
+	}
+
+	return nil
+}
+
+// PurgeUploads aborts the expired upload sessions page by page; run it with RunPurger(ctx, PurgerFunc(h.PurgeUploads), interval).
+// A session that fails is logged & kept for the next purge, the rest is still purged.
+func (c *HandlerWithAttachment) PurgeUploads(ctx context.Context) (int, error) {
+	multipart, err := c.multipart()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+
+	var purged, failed int
+	q := content.Query{Limit: purgePageSize}
+	for {
+		// the cursor is the key of the last listed, so the deletion does not shift the next page
+		page, err := c.sessions.List(ctx, "*", []string{}, q)
+		if err != nil {
+			return purged, fmt.Errorf("%w: %w during session listing", mycontent.ErrStorage, err)
+		}
+
+		for _, d := range page.Data {
+			var session uploadSession
+			if err := json.Unmarshal(d.Data, &session); err != nil {
+				log.Error().Msgf("invalid upload session %v in namespace %v: %v", d.ID, d.Namespace, err)
+				continue
+			}
+			if !session.ExpiresAt.Before(now) {
+				continue
+			}
+
+			if err := multipart.AbortUpload(ctx, session.Attachment.Path, session.BlobUploadID); err != nil {
+				log.Error().Msgf("failed to abort the upload %v in namespace %v: %v", d.ID, d.Namespace, err)
+				failed++
+				continue
+			}
+
+			if _, err := c.sessions.Delete(ctx, d.Namespace, d.RefIDs, d.ID); err != nil {
+				log.Error().Msgf("failed to delete the upload session %v in namespace %v: %v", d.ID, d.Namespace, err)
+				failed++
+				continue
+			}
+			purged++
+		}
+
+		if page.Next == "" {
+			break
+		}
+		q.Cursor = page.Next
+	}
+
+	if failed > 0 {
+		return purged, fmt.Errorf("%w: failed to purge %v of the expired upload sessions", mycontent.ErrStorage, failed)
+	}
+
+	return purged, nil
+}
+
+func (c *HandlerWithAttachment) multipart() (blob.Multipart, error) {
+	if c.sessions == nil {
+		return nil, mycontent.ErrResumableUploadNotEnabled
+	}
+
+	multipart, ok := c.blobRepo.(blob.Multipart)
+	if !ok {
+		return nil, fmt.Errorf("%w: the blob repository cannot upload in parts", mycontent.ErrResumableUploadNotEnabled)
+	}
+
+	return multipart, nil
+}
+
+// loadUpload session that is not expired yet
+func (c *HandlerWithAttachment) loadUpload(ctx context.Context, namespace string, uploadID string) (*uploadSession, blob.Multipart, error) {
+	multipart, err := c.multipart()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if namespace == "" || uploadID == "" {
+		return nil, nil, fmt.Errorf("%w: namespace & upload ID must be specified", mycontent.ErrValidation)
+	}
+
+	ds, err := c.sessions.Get(ctx, namespace, []string{}, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ds) == 0 {
+		return nil, nil, fmt.Errorf("%w: upload session not found", mycontent.ErrNotFound)
+	}
+
+	var session uploadSession
+	if err := json.Unmarshal(ds[0].Data, &session); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid upload session: %w", mycontent.ErrStorage, err)
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		return nil, nil, fmt.Errorf("%w: upload session is expired", mycontent.ErrNotFound)
+	}
+
+	return &session, multipart, nil
+}
+
+func (c *HandlerWithAttachment) saveUpload(ctx context.Context, namespace string, uploadID string, session uploadSession, now time.Time) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(uploadMeta{CreatedAt: now})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sessions.Post(ctx, namespace, []string{}, uploadID, content.Data{
+		Namespace: namespace,
+		RefIDs:    []string{},
+		ID:        uploadID,
+		Data:      data,
+		Meta:      meta,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w during session storage", mycontent.ErrStorage, err)
+	}
+
+	return nil
+}
+
+// uploadView of the session, censored the same as the attachment
+func (c *HandlerWithAttachment) uploadView(uploadID string, session uploadSession, parts []blob.Part) *mycontent.UploadSession[*entity.Attachment] {
+	at := *session.Attachment
+	if c.hideUrl {
+		at.Url = ""
+		at.DataUrl = ""
+		at.Path = ""
+	}
+
+	result := &mycontent.UploadSession[*entity.Attachment]{
+		ID:         uploadID,
+		Attachment: &at,
+		PartSize:   session.PartSize,
+		PartCount:  session.partCount(),
+		Parts:      make([]mycontent.UploadPart, 0, len(parts)),
+		ExpiresAt:  session.ExpiresAt,
+	}
+	for _, part := range parts {
+		result.Parts = append(result.Parts, mycontent.UploadPart{
+			Number: part.Number,
+			Size:   part.Size,
+		})
+	}
+
+	return result
+}
+
+func (s uploadSession) partCount() int {
+	size := int64(s.Attachment.ContentSize)
+	return int((size + s.PartSize - 1) / s.PartSize)
+}
@@ -0,0 +1,29 @@
+package mycontent
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnauthorized when there is no verified session to authorize
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrForbidden when the session is not allowed to do the action on the namespace
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Action of a request on a namespace
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionWrite  Action = "write"
+	ActionDelete Action = "delete"
+)
+
+// Policy authorizes the action on the namespace, with the verified session in the context.
+// It returns ErrUnauthorized or ErrForbidden if it is not allowed.
+type Policy interface {
+	Authorize(ctx context.Context, action Action, namespace string) error
+}
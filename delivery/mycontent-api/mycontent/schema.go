@@ -0,0 +1,134 @@
+package mycontent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+// schemaURL is the location of the schema to compile; it is not resolved
+const schemaURL = "mycontent://schema.json"
+
+// pointerEscaper of a reference token of JSON pointer (RFC 6901)
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Schema is the JSON Schema of a content, published to the non-Go client
+type Schema struct {
+	raw      json.RawMessage
+	compiled *jsonschema.Schema
+}
+
+// CompileSchema of the content; draft 2020-12 unless the "$schema" says otherwise
+func CompileSchema(raw []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	compiled, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return &Schema{
+		raw:      raw,
+		compiled: compiled,
+	}, nil
+}
+
+// Raw schema, as it is compiled
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Validate the JSON of the data; *ValidationError with the failing fields, in path order, if it is invalid
+func (s *Schema) Validate(data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	err = s.compiled.Validate(doc)
+	if err == nil {
+		return nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	result := &ValidationError{}
+	for _, unit := range verr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+
+		// only the causes; the groups of them are reported by their fields
+		switch unit.Error.Kind.(type) {
+		case *kind.Group, *kind.Schema, *kind.Reference:
+			continue
+		}
+
+		// the missing property is located at itself, rather than at its parent
+		if required, ok := unit.Error.Kind.(*kind.Required); ok {
+			for _, property := range required.Missing {
+				result.Fields = append(result.Fields, FieldError{
+					Path:    unit.InstanceLocation + "/" + pointerEscaper.Replace(property),
+					Message: "missing property",
+				})
+			}
+			continue
+		}
+
+		result.Fields = append(result.Fields, FieldError{
+			Path:    unit.InstanceLocation,
+			Message: unit.Error.String(),
+		})
+	}
+
+	slices.SortStableFunc(result.Fields, func(a, b FieldError) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return result
+}
+
+// ValidationError of the fields of the content; it is ErrValidation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// FieldError of a field, located by its JSON pointer (RFC 6901); empty for the whole content
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (v *ValidationError) Error() string {
+	fields := make([]string, 0, len(v.Fields))
+	for _, f := range v.Fields {
+		fields = append(fields, "'"+f.Path+"' "+f.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(fields, "; ")
+}
+
+func (v *ValidationError) Unwrap() error {
+	return ErrValidation
+}
@@ -0,0 +1,92 @@
+package mycontent_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["title", "a/b"],
+	"properties": {
+		"title": {"type": "string", "minLength": 1},
+		"tags": {"type": "object", "additionalProperties": {"type": "string"}},
+		"items": {"type": "array", "items": {"type": "integer"}},
+		"author": {"type": "object", "required": ["name"]}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := mycontent.CompileSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		data  map[string]any
+		paths []string
+	}{
+		{
+			name: "valid",
+			data: map[string]any{"title": "a", "a/b": 1, "tags": map[string]any{"x": "1"}, "items": []any{1, 2}},
+		},
+		{
+			name:  "missing properties at themselves, escaped",
+			data:  map[string]any{},
+			paths: []string{"/a~1b", "/title"},
+		},
+		{
+			name:  "missing nested property",
+			data:  map[string]any{"title": "a", "a/b": 1, "author": map[string]any{}},
+			paths: []string{"/author/name"},
+		},
+		{
+			name:  "every invalid field",
+			data:  map[string]any{"title": 5, "a/b": 1, "tags": map[string]any{"x y": 1}, "items": []any{1, "x"}},
+			paths: []string{"/items/1", "/tags/x y", "/title"},
+		},
+		{
+			name:  "keyword other than type",
+			data:  map[string]any{"title": "", "a/b": 1},
+			paths: []string{"/title"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.Validate(tc.data)
+			if len(tc.paths) == 0 {
+				if err != nil {
+					t.Fatalf("expected valid, got %v", err)
+				}
+				return
+			}
+
+			var verr *mycontent.ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, mycontent.ErrValidation) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+
+			paths := make([]string, 0, len(verr.Fields))
+			for _, f := range verr.Fields {
+				if f.Message == "" {
+					t.Fatalf("expected the message of %v", f.Path)
+				}
+				paths = append(paths, f.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.paths) {
+				t.Fatalf("expected the fields %v, got %v", tc.paths, paths)
+			}
+		})
+	}
+}
+
+func TestCompileSchemaInvalid(t *testing.T) {
+	for _, raw := range []string{`{`, `{"type": 5}`} {
+		if _, err := mycontent.CompileSchema([]byte(raw)); err == nil {
+			t.Fatalf("expected %v to be invalid", raw)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mycontent
+
+import (
+	"context"
+	"sync"
+)
+
+type streamErrorKey struct{}
+
+// StreamError is the error that ends a stream early, since the channel of Stream cannot carry it
+type StreamError struct {
+	mu  sync.Mutex
+	err error
+}
+
+// WithStreamError lets the Stream implementation report its error to the caller
+func WithStreamError(ctx context.Context) (context.Context, *StreamError) {
+	s := &StreamError{}
+	return context.WithValue(ctx, streamErrorKey{}, s), s
+}
+
+// ReportStreamError records the first error of the stream; no-op if the caller does not track it
+func ReportStreamError(ctx context.Context, err error) {
+	s, ok := ctx.Value(streamErrorKey{}).(*StreamError)
+	if !ok || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Err reported by the stream, if any. Only final after the channel is closed.
+func (s *StreamError) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,67 @@
+package mycontent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrResumableUploadNotEnabled for the resumable upload of a usecase without its session repository
+var ErrResumableUploadNotEnabled = errors.New("resumable upload is not enabled")
+
+// Resumable upload of the attachment in parts, so a large one can be resumed from its last uploaded part.
+// The attachment is written only when the upload is completed.
+type Resumable[T any] interface {
+	// StartUpload of the attachment; its content size must be specified
+	StartUpload(ctx context.Context, meta T) (*UploadSession[T], error)
+
+	// GetUpload session with the parts uploaded so far, eg. to resume it
+	GetUpload(ctx context.Context, namespace string, uploadID string) (*UploadSession[T], error)
+
+	// UploadPart number (from 1) of the session; the part uploaded again replaces the previous one
+	UploadPart(ctx context.Context, namespace string, uploadID string, number int, payload io.Reader, size int64) (*UploadPart, error)
+
+	// CompleteUpload after every part is uploaded, then the attachment is written
+	CompleteUpload(ctx context.Context, namespace string, uploadID string) (T, error)
+
+	// AbortUpload and discard its uploaded parts
+	AbortUpload(ctx context.Context, namespace string, uploadID string) error
+}
+
+// UploadSession of a resumable upload. Every part has the part size, except the last one.
+type UploadSession[T any] struct {
+	ID         string       `json:"id"`
+	Attachment T            `json:"attachment"`
+	PartSize   int64        `json:"part_size"`
+	PartCount  int          `json:"part_count"`
+	Parts      []UploadPart `json:"parts"` // uploaded so far
+	ExpiresAt  time.Time    `json:"expires_at"`
+}
+
+type UploadPart struct {
+	Number int   `json:"number"`
+	Size   int64 `json:"size"`
+}
+
+// PartSizeOf the part number (from 1) of the content size
+func PartSizeOf(contentSize int64, partSize int64, number int) int64 {
+	offset := int64(number-1) * partSize
+	return min(partSize, contentSize-offset)
+}
+
+// Missing part numbers of the session, to be uploaded before it is completed
+func (s *UploadSession[T]) Missing() []int {
+	uploaded := make(map[int]bool, len(s.Parts))
+	for _, part := range s.Parts {
+		uploaded[part.Number] = true
+	}
+
+	var result []int
+	for number := 1; number <= s.PartCount; number++ {
+		if !uploaded[number] {
+			result = append(result, number)
+		}
+	}
+	return result
+}
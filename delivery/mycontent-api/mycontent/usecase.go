@@ -5,6 +5,8 @@ import (
 	"errors"
 	"io"
 	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 )
 
 var (
@@ -16,11 +18,25 @@ var (
 
 	// ErrNotFound when content is not found during Post, Delete, and Get (by ID)
 	ErrNotFound = errors.New("not found")
+
+	// ErrNotApplied to the batch item, because the batch fails as a whole
+	ErrNotApplied = errors.New("not applied")
+
+	// ErrSoftDeleteNotEnabled for the trash operations of a usecase without soft delete
+	ErrSoftDeleteNotEnabled = errors.New("soft delete is not enabled")
+
+	// ErrBatchNotSupported if the batch cannot be written all or nothing; write each of them instead
+	ErrBatchNotSupported = errors.New("batch is not supported")
 )
 
 type Meta struct {
-	CreatedAt             time.Time `json:"created_at"` // server time
-	OptimisticLockVersion *uint64   `json:"optimistic_lock_version,omitempty"`
+	CreatedAt time.Time `json:"created_at"` // server time
+
+	// OptimisticLockVersion of a new version of versioned data: the versions count so far (DG-Optimistic-Lock-Version)
+	OptimisticLockVersion *uint64 `json:"optimistic_lock_version,omitempty"`
+
+	// ExpectedVersion of the existing data (If-Match): its event ID for versioned data, content.Data.Version otherwise; not stored
+	ExpectedVersion *uint64 `json:"-"`
 
 	// Can add more here later
 }
@@ -43,6 +59,90 @@ type Usecase[T any] interface {
 	// Delete your resource here
 	// the implementation can check whether there are linked resource or not
 	Delete(ctx context.Context, namespace string, refIDs []string, ID string) (T, error)
+
+	// List resources page by page; filtered & ordered by the storage
+	List(ctx context.Context, namespace string, refIDs []string, q content.Query) (Page[T], error)
+}
+
+type Page[T any] struct {
+	Data []T `json:"data"`
+
+	// Next cursor; empty for the last page
+	Next string `json:"next,omitempty"`
+}
+
+// Versionable usecase exposes the version of the stored resource (the event ID for versioned data), to be used as the expected version of Post
+type Versionable interface {
+	Version(ctx context.Context, namespace string, refIDs []string, ID string) (uint64, error)
+}
+
+// Schematic usecase validates the resources with a JSON Schema
+type Schematic interface {
+	// Schema of the resource; nil if there is none
+	Schema() *Schema
+}
+
+// Patchable usecase partially updates a resource
+type Patchable[T any] interface {
+	// Patch the stored resource with a JSON merge patch (RFC 7396); the result is validated & written the same way as Post
+	Patch(ctx context.Context, namespace string, refIDs []string, ID string, patch []byte, meta any) (T, error)
+}
+
+// Historical usecase keeps every version of a resource. The versions are the IDs under the same ref IDs (see VersionedData).
+type Historical[T any] interface {
+	// History of the versions under the complete ref IDs, latest written first
+	History(ctx context.Context, namespace string, refIDs []string, q content.Query) (Page[T], error)
+
+	// Restore an older version by writing it back as the new version
+	Restore(ctx context.Context, namespace string, refIDs []string, version string, meta any) (T, error)
+}
+
+// Trashable usecase soft deletes: the deleted resources are kept in the trash until purged
+type Trashable[T any] interface {
+	// Trash lists the soft deleted resources, latest deleted first
+	Trash(ctx context.Context, namespace string, refIDs []string, q content.Query) (Page[T], error)
+
+	// Undelete the resource from the trash; content.ErrConflict if the resource has been written again since
+	Undelete(ctx context.Context, namespace string, refIDs []string, ID string) (T, error)
+}
+
+// Batchable usecase writes many resources at once
+type Batchable[T any] interface {
+	// PostBatch validates every resource first; nothing is written if any of them is invalid.
+	// It is all or nothing, or ErrBatchNotSupported if the storage cannot do it.
+	PostBatch(ctx context.Context, data []T, meta any) ([]BatchResult[T], error)
+
+	// DeleteBatch the keys under the namespace, the same way as PostBatch
+	DeleteBatch(ctx context.Context, namespace string, keys []Key) ([]BatchResult[T], error)
+}
+
+// BatchResult of each item, in the order of the batch
+type BatchResult[T any] struct {
+	Data T
+	Err  error
+}
+
+// Key of a resource under a namespace
+type Key struct {
+	RefIDs []string
+	ID     string
+}
+
+// Watchable usecase publishes the changes of its content
+type Watchable[T any] interface {
+	// Watch the changes until the context is done; backfilled since the event ID first, if specified
+	Watch(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan Event[T], error)
+}
+
+type Event[T any] struct {
+	EventID   uint64            `json:"event_id"`
+	Type      content.EventType `json:"type"`
+	Namespace string            `json:"namespace"`
+	RefIDs    []string          `json:"ref_ids,omitempty"`
+	ID        string            `json:"id"`
+
+	// Data of the post; empty for delete
+	Data T `json:"data,omitempty"`
 }
 
 type Attachable[T any] interface {
@@ -0,0 +1,532 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	entity "github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// OpenAPI 3.1 document of the services, eg. to generate the client
+type OpenAPI struct {
+	title   string
+	version string
+
+	paths     map[string]map[string]any
+	schemas   map[string]any
+	typeNames map[reflect.Type]string
+
+	once    sync.Once
+	payload []byte
+	err     error
+}
+
+// Documented service describes its endpoints to the OpenAPI document
+type Documented interface {
+	describe(o *OpenAPI, path string)
+}
+
+func NewOpenAPI(title string, version string) *OpenAPI {
+	o := &OpenAPI{
+		title:     title,
+		version:   version,
+		paths:     make(map[string]map[string]any),
+		schemas:   make(map[string]any),
+		typeNames: make(map[reflect.Type]string),
+	}
+
+	// referenced by every error response
+	o.ref(reflect.TypeFor[types.CommonError]())
+	o.ref(reflect.TypeFor[types.Problem]())
+
+	return o
+}
+
+// Add the endpoints of the service, mounted at the path with the conventional sub path of each endpoint,
+// eg. "/batch" for PostBatch & DeleteBatch
+func (o *OpenAPI) Add(path string, svc Documented) *OpenAPI {
+	svc.describe(o, path)
+	return o
+}
+
+// Serve the document as JSON; the services must be added before it is first served
+func (o *OpenAPI) Serve(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	o.once.Do(func() {
+		o.payload, o.err = json.Marshal(map[string]any{
+			"openapi": "3.1.0",
+			"info": map[string]any{
+				"title":   o.title,
+				"version": o.version,
+			},
+			"paths": o.paths,
+			"components": map[string]any{
+				"schemas": o.schemas,
+			},
+		})
+	})
+
+	if o.err != nil {
+		handleError(w, "SERVER_ERROR", "server encounter an error", http.StatusInternalServerError, o.err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(o.payload)
+}
+
+func (o *OpenAPI) operation(path string, method string, op map[string]any) {
+	if _, ok := o.paths[path]; !ok {
+		o.paths[path] = make(map[string]any)
+	}
+
+	op["operationId"] = strings.ToLower(method) + operationName(path)
+	op["tags"] = []string{strings.Trim(path, "/")}
+	if _, ok := op["responses"]; !ok {
+		op["responses"] = map[string]any{}
+	}
+	errorContent := jsonContent(envelope(nil))
+	errorContent[ContentTypeProblem] = map[string]any{
+		"schema": map[string]any{"$ref": "#/components/schemas/Problem"},
+	}
+	op["responses"].(map[string]any)["default"] = map[string]any{
+		"description": "error; problem details with the ProblemDetails middleware",
+		"content":     errorContent,
+	}
+
+	o.paths[path][strings.ToLower(method)] = op
+}
+
+func (i *service[T]) describe(o *OpenAPI, path string) {
+	resource := i.resourceSchema(o, path)
+	list := map[string]any{"type": "array", "items": resource}
+
+	o.operation(path, http.MethodGet, map[string]any{
+		"summary": "Get by id, or list under the namespace & the (partial) references",
+		"parameters": append(i.keyParameters(false),
+			queryParameter("version", "a version of the versioned data; the same as id", false, nil),
+			queryParameter("limit", "page size; paginated only if limit or cursor is specified", false, map[string]any{"type": "integer", "minimum": 0}),
+			queryParameter("cursor", "DG-Next-Cursor of the previous page", false, nil),
+			queryParameter("order_by", "order of the list, tie-broken by the key; by the key if not specified", false, map[string]any{"type": "string", "enum": []string{"created_at", "event_id"}}),
+			queryParameter("order", "ascending by default", false, map[string]any{"type": "string", "enum": []string{"asc", "desc"}}),
+			headerParameter("If-None-Match", "ETag of the cached response", false),
+			headerParameter("DG-Read-Consistency", "stale, linearizable, or leader", false),
+		),
+		"responses": map[string]any{
+			"200": response("the resources", list, "DG-Next-Cursor", "ETag", "Last-Modified"),
+			"304": map[string]any{"description": "not modified"},
+		},
+	})
+
+	o.operation(path, http.MethodPost, map[string]any{
+		"summary": "Create, or overwrite the resource with the id",
+		"parameters": []any{
+			namespaceParameter(),
+			headerParameter("DG-Optimistic-Lock-Version", "the versions count of the versioned data, for its next version", i.enableOptimisticLock),
+			headerParameter("If-Match", "ETag of the version that is overwritten", false),
+		},
+		"requestBody": map[string]any{"required": true, "content": jsonContent(resource)},
+		"responses": map[string]any{
+			"200": response("the posted resource", resource),
+		},
+	})
+
+	o.operation(path, http.MethodDelete, map[string]any{
+		"summary": "Delete the resource",
+		"parameters": append(i.keyParameters(true),
+			headerParameter("If-Match", "ETag of the version that is deleted", false),
+		),
+		"responses": map[string]any{
+			"200": response("the deleted resource", resource),
+		},
+	})
+
+	o.operation(path+"/stream", http.MethodGet, map[string]any{
+		"summary":    "Stream the resources",
+		"parameters": i.keyParameters(false),
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "a resource per line or per event",
+				"content": map[string]any{
+					ContentTypeNDJSON: map[string]any{"schema": resource},
+					ContentTypeSSE:    map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+			},
+		},
+	})
+
+	if _, ok := i.uc.(mycontent.Patchable[T]); ok {
+		o.operation(path, http.MethodPatch, map[string]any{
+			"summary": "Patch the resource with a JSON merge patch",
+			"parameters": append(i.keyParameters(true),
+				headerParameter("If-Match", "ETag of the version that is patched", false),
+			),
+			"requestBody": map[string]any{
+				"required": true,
+				"content":  map[string]any{ContentTypeMergePatch: map[string]any{"schema": map[string]any{"type": "object"}}},
+			},
+			"responses": map[string]any{
+				"200": response("the patched resource", resource),
+			},
+		})
+	}
+
+	if _, ok := i.uc.(mycontent.Batchable[T]); ok {
+		results := map[string]any{"type": "array", "items": envelope(resource)}
+		o.operation(path+"/batch", http.MethodPost, map[string]any{
+			"summary":     "Post many resources under the namespace, all or nothing",
+			"parameters":  []any{namespaceParameter()},
+			"requestBody": map[string]any{"required": true, "content": jsonContent(list)},
+			"responses": map[string]any{
+				"200": response("the result of each resource, in order", results),
+			},
+		})
+
+		key := map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string"}}}
+		for _, param := range i.refParams {
+			key["properties"].(map[string]any)[param] = map[string]any{"type": "string"}
+		}
+		o.operation(path+"/batch", http.MethodDelete, map[string]any{
+			"summary":     "Delete many resources under the namespace",
+			"parameters":  []any{namespaceParameter()},
+			"requestBody": map[string]any{"required": true, "content": jsonContent(map[string]any{"type": "array", "items": key})},
+			"responses": map[string]any{
+				"200": response("the result of each resource, in order", results),
+			},
+		})
+	}
+
+	if _, ok := i.uc.(mycontent.Watchable[T]); ok {
+		event := map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"event_id":  map[string]any{"type": "integer", "minimum": 0},
+				"type":      map[string]any{"type": "string"},
+				"namespace": map[string]any{"type": "string"},
+				"ref_ids":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"id":        map[string]any{"type": "string"},
+				"data":      resource,
+			},
+		}
+		o.operation(path+"/watch", http.MethodGet, map[string]any{
+			"summary":     "Watch the changes, as server-sent events or websocket",
+			"description": "Only clickhouse-raft with the event log of the table can resume from the event ID; the other storages answer 410 Gone, and send the live changes written through this instance only.",
+			"parameters": append(i.refParameters(false),
+				namespaceParameter(),
+				queryParameter("from", "resume after the event ID", false, map[string]any{"type": "integer", "minimum": 0}),
+				headerParameter("Last-Event-ID", "resume after the event ID", false),
+			),
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "the events",
+					"content":     map[string]any{ContentTypeSSE: map[string]any{"schema": event}},
+				},
+			},
+		})
+	}
+
+	if _, ok := i.uc.(mycontent.Historical[T]); ok {
+		o.operation(path+"/history", http.MethodGet, map[string]any{
+			"summary": "Every version under the references, latest first",
+			"parameters": append(i.refParameters(true),
+				namespaceParameter(),
+				queryParameter("limit", "page size", false, map[string]any{"type": "integer", "minimum": 0}),
+				queryParameter("cursor", "DG-Next-Cursor of the previous page", false, nil),
+			),
+			"responses": map[string]any{
+				"200": response("the versions", list, "DG-Next-Cursor"),
+			},
+		})
+		o.operation(path+"/restore", http.MethodPost, map[string]any{
+			"summary": "Write the version back as the new version",
+			"parameters": append(i.refParameters(true),
+				namespaceParameter(),
+				queryParameter("version", "the version to restore, its id", true, nil),
+				headerParameter("DG-Optimistic-Lock-Version", "the versions count of the versioned data, for its next version", i.enableOptimisticLock),
+			),
+			"responses": map[string]any{
+				"200": response("the new version", resource),
+			},
+		})
+	}
+
+	if _, ok := i.uc.(mycontent.Trashable[T]); ok {
+		o.operation(path+"/trash", http.MethodGet, map[string]any{
+			"summary": "The soft deleted resources, latest deleted first",
+			"parameters": append(i.refParameters(false),
+				namespaceParameter(),
+				queryParameter("limit", "page size", false, map[string]any{"type": "integer", "minimum": 0}),
+				queryParameter("cursor", "DG-Next-Cursor of the previous page", false, nil),
+			),
+			"responses": map[string]any{
+				"200": response("the deleted resources", list, "DG-Next-Cursor"),
+			},
+		})
+		o.operation(path+"/undelete", http.MethodPost, map[string]any{
+			"summary":    "Restore the soft deleted resource",
+			"parameters": i.keyParameters(true),
+			"responses": map[string]any{
+				"200": response("the restored resource", resource),
+			},
+		})
+	}
+
+	if schematic, ok := i.uc.(mycontent.Schematic); ok && schematic.Schema() != nil {
+		o.operation(path+"/schema", http.MethodGet, map[string]any{
+			"summary": "JSON Schema of the resource",
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "the schema",
+					"content":     map[string]any{ContentTypeSchema: map[string]any{"schema": map[string]any{"type": "object"}}},
+				},
+			},
+		})
+	}
+}
+
+func (i *uploadService) describe(o *OpenAPI, path string) {
+	resource := i.resourceSchema(o, path)
+
+	o.operation(path, http.MethodGet, map[string]any{
+		"summary": "Get the attachment meta by id, list them, or download the attachment with data=true",
+		"parameters": append(i.keyParameters(false),
+			queryParameter("data", "download the attachment, by id", false, map[string]any{"type": "boolean"}),
+		),
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "the attachment meta, or the attachment itself",
+				"content": map[string]any{
+					"application/json":         map[string]any{"schema": envelope(map[string]any{"type": "array", "items": resource})},
+					"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"}},
+				},
+			},
+		},
+	})
+
+	o.operation(path, http.MethodPost, map[string]any{
+		"summary":    "Upload the attachment",
+		"parameters": []any{namespaceParameter()},
+		"requestBody": map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{
+					"schema": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"document":   resource,
+							"attachment": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"},
+						},
+						"required": []string{"document", "attachment"},
+					},
+					"encoding": map[string]any{
+						"document": map[string]any{"contentType": "application/json"},
+					},
+				},
+			},
+		},
+		"responses": map[string]any{
+			"200": response("the attachment meta", resource),
+		},
+	})
+
+	o.operation(path, http.MethodDelete, map[string]any{
+		"summary":    "Delete the attachment",
+		"parameters": i.keyParameters(true),
+		"responses": map[string]any{
+			"200": response("the deleted attachment meta", resource),
+		},
+	})
+
+	if _, ok := i.uc.(mycontent.Resumable[*entity.Attachment]); ok {
+		i.describeUploads(o, path, resource)
+	}
+}
+
+// describeUploads of the resumable upload
+func (i *uploadService) describeUploads(o *OpenAPI, path string, resource map[string]any) {
+	part := o.schemaOf(reflect.TypeFor[mycontent.UploadPart]())
+	session := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":         map[string]any{"type": "string"},
+			"attachment": resource,
+			"part_size":  map[string]any{"type": "integer"},
+			"part_count": map[string]any{"type": "integer"},
+			"parts":      map[string]any{"type": "array", "items": part},
+			"expires_at": map[string]any{"type": "string", "format": "date-time"},
+		},
+	}
+	sessionParameters := []any{
+		namespaceParameter(),
+		queryParameter("upload_id", "the upload session, returned when it is started", true, nil),
+	}
+
+	o.operation(path+"/uploads", http.MethodPost, map[string]any{
+		"summary":    "Start the resumable upload of the attachment, with its content size",
+		"parameters": []any{namespaceParameter()},
+		"requestBody": map[string]any{
+			"required": true,
+			"content":  jsonContent(resource),
+		},
+		"responses": map[string]any{
+			"200": response("the upload session", session),
+		},
+	})
+	o.operation(path+"/uploads", http.MethodGet, map[string]any{
+		"summary":    "The upload session with the parts uploaded so far",
+		"parameters": sessionParameters,
+		"responses": map[string]any{
+			"200": response("the upload session", session),
+		},
+	})
+	o.operation(path+"/uploads", http.MethodPut, map[string]any{
+		"summary": "Upload the part; every part has the part size, except the last one",
+		"parameters": append(sessionParameters,
+			queryParameter("part", "part number, from 1", true, map[string]any{"type": "integer", "minimum": 1}),
+		),
+		"requestBody": map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "contentMediaType": "application/octet-stream"}},
+			},
+		},
+		"responses": map[string]any{
+			"200": response("the uploaded part", part),
+		},
+	})
+	o.operation(path+"/uploads", http.MethodDelete, map[string]any{
+		"summary":    "Abort the upload, and discard its parts",
+		"parameters": sessionParameters,
+		"responses": map[string]any{
+			"200": response("the aborted upload ID", map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"id": map[string]any{"type": "string"}},
+			}),
+		},
+	})
+	o.operation(path+"/uploads/complete", http.MethodPost, map[string]any{
+		"summary":    "Complete the upload after every part is uploaded",
+		"parameters": sessionParameters,
+		"responses": map[string]any{
+			"200": response("the attachment meta", resource),
+		},
+	})
+}
+
+// resourceSchema is the JSON Schema of the resource if any, otherwise reflected from T
+func (i *service[T]) resourceSchema(o *OpenAPI, path string) map[string]any {
+	if schematic, ok := i.uc.(mycontent.Schematic); ok && schematic.Schema() != nil {
+		name := operationName(path)
+		o.schemas[name] = schematic.Schema().Raw()
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	return o.schemaOf(reflect.TypeFor[T]())
+}
+
+// keyParameters locate the resource(s): namespace, the ref params, & id
+func (i *service[T]) keyParameters(required bool) []any {
+	return append(i.refParameters(required),
+		namespaceParameter(),
+		queryParameter("id", "id of the resource", required, nil),
+	)
+}
+
+func (i *service[T]) refParameters(required bool) []any {
+	result := make([]any, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		result = append(result, queryParameter(param, "reference; specified from the first one", required, nil))
+	}
+	return result
+}
+
+func namespaceParameter() map[string]any {
+	return headerParameter("X-Namespace", "namespace of the resource", true)
+}
+
+func queryParameter(name string, description string, required bool, schema map[string]any) map[string]any {
+	if schema == nil {
+		schema = map[string]any{"type": "string"}
+	}
+	return map[string]any{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+}
+
+func headerParameter(name string, description string, required bool) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          "header",
+		"description": description,
+		"required":    required,
+		"schema":      map[string]any{"type": "string"},
+	}
+}
+
+// envelope of types.CommonResponse
+func envelope(success map[string]any) map[string]any {
+	properties := map[string]any{
+		"error": map[string]any{"$ref": "#/components/schemas/CommonError"},
+	}
+	if success != nil {
+		properties["success"] = success
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func response(description string, success map[string]any, headers ...string) map[string]any {
+	result := map[string]any{
+		"description": description,
+		"content":     jsonContent(envelope(success)),
+	}
+
+	if len(headers) > 0 {
+		h := make(map[string]any, len(headers))
+		for _, header := range headers {
+			h[header] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		result["headers"] = h
+	}
+
+	return result
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": schema},
+	}
+}
+
+// operationName from the path, eg. "/auth/user-thumbnail" is "AuthUserThumbnail"
+func operationName(path string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range path {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package mycontentapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+	componentNameInvalid = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemaOf the Go type as it is marshalled by encoding/json.
+// Named structs are put to the components, and referenced.
+func (o *OpenAPI) schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// cannot be known without marshalling it
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": o.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": o.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return o.structSchema(t)
+		}
+		return o.ref(t)
+	}
+
+	// interface, etc.
+	return map[string]any{}
+}
+
+// ref to the component of the named struct, added if it is not yet
+func (o *OpenAPI) ref(t reflect.Type) map[string]any {
+	name, ok := o.typeNames[t]
+	if !ok {
+		name = componentNameInvalid.ReplaceAllString(t.Name(), "_")
+		if _, taken := o.schemas[name]; taken {
+			name = componentNameInvalid.ReplaceAllString(t.PkgPath(), "_") + "." + name
+		}
+
+		// registered first, for the recursive type
+		o.typeNames[t] = name
+		o.schemas[name] = nil
+		o.schemas[name] = o.structSchema(t)
+	}
+
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (o *OpenAPI) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	o.addFields(t, properties)
+
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// addFields the same as encoding/json: exported fields with their json tag; untagged embedded structs are flattened
+func (o *OpenAPI) addFields(t reflect.Type, properties map[string]any) {
+	var embedded []reflect.Type
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = o.schemaOf(field.Type)
+	}
+
+	// the shallower field wins, as in encoding/json
+	for _, ft := range embedded {
+		embeddedProperties := make(map[string]any)
+		o.addFields(ft, embeddedProperties)
+		for name, schema := range embeddedProperties {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package mycontentapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// the document only depends on the services, their storage is never called
+func newOpenAPIRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	schema, err := mycontent.CompileSchema([]byte(documentSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents := base.New[*entity.Document](nil).WithSchema(schema).WithSoftDelete(nil, time.Hour)
+	attachments := base.NewAttachment(nil, newMemBlob(), false, "")
+
+	router := httprouter.New()
+	openAPI := mycontentapi.NewOpenAPI("test", "1.0.0")
+	mycontentapi.NewRegistrar(router).WithOpenAPI(openAPI).
+		Resource("/doc", mycontentapi.New[*entity.Document](documents, "http://localhost/doc", []string{"owner"})).
+		Resource("/post", mycontentapi.NewFromStorageVersioned[*versionedDoc]("http://localhost/post", []string{"owner"}, nil, 1).WithOptimisticLock()).
+		Resource("/attachment", mycontentapi.NewAttachment(attachments, "http://localhost/attachment", []string{"owner"}, "")).
+		Handle(http.MethodGet, "/openapi.json", openAPI.Serve)
+
+	return router
+}
+
+func TestOpenAPIGolden(t *testing.T) {
+	rec := serve(t, newOpenAPIRouter(t), http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the document, got %v %s", rec.Code, rec.Body.String())
+	}
+
+	var got bytes.Buffer
+	if err := json.Indent(&got, rec.Body.Bytes(), "", "  "); err != nil {
+		t.Fatalf("expected a JSON document: %v", err)
+	}
+	got.WriteByte('\n')
+
+	golden := filepath.Join("testdata", "openapi.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v; run the test with -update to write it", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("the document differs from %v; review the change, then run the test with -update:\n%s", golden, got.String())
+	}
+}
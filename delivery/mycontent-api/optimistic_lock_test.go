@@ -0,0 +1,71 @@
+package mycontentapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+)
+
+func newVersionedAPI(t *testing.T, table sqliteraft.TableConfig) http.Handler {
+	t.Helper()
+
+	table.TableName, table.RefSize, table.Versioned = "post", 1, true
+	storage := newStorage(t, table)
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/post",
+		mycontentapi.NewFromStorageVersioned[*versionedDoc]("http://localhost/post", []string{"owner"}, storage["post"], 1))
+
+	return router
+}
+
+// DG-Optimistic-Lock-Version is only the versions count of the next version; If-Match is only the stored version
+func TestOptimisticLockAndIfMatch(t *testing.T) {
+	router := newVersionedAPI(t, sqliteraft.TableConfig{VersionedUseOptimisticLock: true})
+
+	const body = `{"namespace":"ns","ref_ids":["o"],"title":"a"}`
+
+	rec := serve(t, router, http.MethodPost, "/post", body, "DG-Optimistic-Lock-Version", "0")
+	if got := decode[versionedDoc](t, rec); got.Id != "0" {
+		t.Fatalf("expected the first version, got %+v", got)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/post", body, "DG-Optimistic-Lock-Version", "0")
+	if status, code := errorCode(t, rec); status != http.StatusConflict || code != "CONFLICT" {
+		t.Fatalf("expected conflict of the next version, got %v %v", status, code)
+	}
+	if lock, etag := rec.Header().Get("DG-Optimistic-Lock-Version"), rec.Header().Get("ETag"); lock != "1" || etag != "" {
+		t.Fatalf("expected the versions count, not the ETag, got %q %q", lock, etag)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/post", body, "DG-Optimistic-Lock-Version", "1")
+	if got := decode[versionedDoc](t, rec); got.Id != "1" {
+		t.Fatalf("expected the second version, got %+v", got)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/post?owner=o&id=0", "")
+	decode[[]versionedDoc](t, rec)
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("DG-Optimistic-Lock-Version") != "" {
+		t.Fatalf("expected only the ETag of the stored version, got %v", rec.Header())
+	}
+
+	// the versions count does not apply to the write of the stored version
+	rec = serve(t, router, http.MethodPost, "/post", `{"id":"0","namespace":"ns","ref_ids":["o"],"title":"b"}`,
+		"If-Match", etag, "DG-Optimistic-Lock-Version", "100")
+	if got := decode[versionedDoc](t, rec); got.Title != "b" {
+		t.Fatalf("expected the stored version overwritten, got %+v", got)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/post", `{"id":"0","namespace":"ns","ref_ids":["o"],"title":"c"}`, "If-Match", etag)
+	if status, code := errorCode(t, rec); status != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+		t.Fatalf("expected precondition failed, got %v %v", status, code)
+	}
+	if current := rec.Header().Get("ETag"); current == "" || current == etag || rec.Header().Get("DG-Optimistic-Lock-Version") != "" {
+		t.Fatalf("expected only the current ETag, got %v", rec.Header())
+	}
+}
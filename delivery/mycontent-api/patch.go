@@ -0,0 +1,114 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// ContentTypeMergePatch is the JSON merge patch (RFC 7396) content type
+const ContentTypeMergePatch = "application/merge-patch+json"
+
+// Patch the resource located by the URL parameters (the same as Delete) with a JSON merge patch body.
+// The patched resource is validated again, and written only if it is not changed since it is read, or since the If-Match.
+func (i *service[T]) Patch(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != ContentTypeMergePatch && mediaType != "application/json" {
+			handleError(
+				w, "UNSUPPORTED_MEDIA_TYPE", "only '"+ContentTypeMergePatch+"' is supported",
+				http.StatusUnsupportedMediaType, nil)
+			return
+		}
+	}
+
+	patchable, ok := i.uc.(mycontent.Patchable[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "patch is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	patch, ok := readBody(w, r, maximumRequestLength)
+	if !ok {
+		return
+	}
+
+	if !json.Valid(patch) {
+		handleError(w, "BAD_REQUEST", "failed to parse body. Make sure it is a valid JSON", http.StatusBadRequest, nil)
+		return
+	}
+
+	ID := r.URL.Query().Get("id")
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	meta := &mycontent.Meta{
+		CreatedAt: time.Now(),
+	}
+
+	var err error
+	meta.ExpectedVersion, err = parseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	// patch always reads before write
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	result, err := patchable.Patch(ctx, namespace, refIDs, ID, patch, meta)
+	if err != nil {
+		handleWriteError(w, err, meta)
+		return
+	}
+
+	for _, pp := range i.postProcess {
+		pp(result)
+	}
+
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: &result,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
@@ -0,0 +1,121 @@
+package mycontentapi_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+// racingRepo writes the data once more right before the next Post, as another writer would
+type racingRepo struct {
+	content.Repository
+	race atomic.Bool
+}
+
+func (r *racingRepo) Post(ctx context.Context, namespace string, refIDs []string, ID string, data content.Data) (content.Data, error) {
+	if r.race.CompareAndSwap(true, false) {
+		other := data
+		other.Data, other.ExpectedVersion = []byte(`{"id":"d1","namespace":"ns","ref_ids":["o"],"title":"other writer"}`), nil
+		if _, err := r.Repository.Post(ctx, namespace, refIDs, ID, other); err != nil {
+			return content.Data{}, err
+		}
+	}
+	return r.Repository.Post(ctx, namespace, refIDs, ID, data)
+}
+
+func newDocumentAPI(t *testing.T) (*racingRepo, http.Handler) {
+	t.Helper()
+
+	storage := newStorage(t, sqliteraft.TableConfig{TableName: "doc", RefSize: 1})
+	repo := &racingRepo{Repository: storage["doc"]}
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/doc", mycontentapi.New[*entity.Document](base.New[*entity.Document](repo), "http://localhost/doc", []string{"owner"}))
+
+	rec := serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o"],"title":"a","tags":{"x":"1","y":"2"}}`)
+	decode[entity.Document](t, rec)
+
+	return repo, router
+}
+
+func TestPatch(t *testing.T) {
+	_, router := newDocumentAPI(t)
+
+	rec := serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", `{"title":"b","tags":{"x":null,"z":"3"}}`,
+		"Content-Type", mycontentapi.ContentTypeMergePatch)
+	got := decode[entity.Document](t, rec)
+	if got["title"] != "b" || got["namespace"] != "ns" {
+		t.Fatalf("expected the patched document, got %v", got)
+	}
+	if tags, _ := got["tags"].(map[string]any); len(tags) != 2 || tags["y"] != "2" || tags["z"] != "3" {
+		t.Fatalf("expected the tags merged, got %v", got["tags"])
+	}
+
+	rec = serve(t, router, http.MethodGet, "/doc?owner=o&id=d1", "")
+	if docs := decode[[]entity.Document](t, rec); len(docs) != 1 || docs[0]["title"] != "b" {
+		t.Fatalf("expected the patched document stored, got %v", docs)
+	}
+}
+
+func TestPatchLocation(t *testing.T) {
+	_, router := newDocumentAPI(t)
+
+	for _, patch := range []string{`{"namespace":"other"}`, `{"ref_ids":["p"]}`, `{"ref_ids":null}`} {
+		rec := serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", patch)
+		if status, code := errorCode(t, rec); status != http.StatusBadRequest {
+			t.Fatalf("%v: expected bad request, got %v %v", patch, status, code)
+		}
+	}
+
+	for _, query := range []string{"owner=o&id=missing", "owner=p&id=d1"} {
+		rec := serve(t, router, http.MethodPatch, "/doc?"+query, `{"title":"b"}`)
+		if status, code := errorCode(t, rec); status != http.StatusNotFound {
+			t.Fatalf("%v: expected not found, got %v %v", query, status, code)
+		}
+	}
+
+	rec := serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", `{"title":"b"}`, "Content-Type", "text/plain")
+	if status, _ := errorCode(t, rec); status != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected unsupported media type, got %v", status)
+	}
+}
+
+func TestPatchConcurrentWrite(t *testing.T) {
+	repo, router := newDocumentAPI(t)
+
+	// written by another between the read & the write of the patch
+	repo.race.Store(true)
+	rec := serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", `{"title":"b"}`)
+	if status, code := errorCode(t, rec); status != http.StatusConflict || code != "CONFLICT" {
+		t.Fatalf("expected conflict, got %v %v", status, code)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/doc?owner=o&id=d1", "")
+	if docs := decode[[]entity.Document](t, rec); docs[0]["title"] != "other writer" {
+		t.Fatalf("expected the write of the other kept, got %v", docs)
+	}
+	etag := rec.Header().Get("ETag")
+
+	// written by another since the read of the client
+	rec = serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o"],"title":"c"}`)
+	decode[entity.Document](t, rec)
+
+	rec = serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", `{"title":"b"}`, "If-Match", etag)
+	if status, code := errorCode(t, rec); status != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+		t.Fatalf("expected precondition failed, got %v %v", status, code)
+	}
+
+	rec = serve(t, router, http.MethodPatch, "/doc?owner=o&id=d1", `{"title":"b"}`, "If-Match", rec.Header().Get("ETag"))
+	if got := decode[entity.Document](t, rec); got["title"] != "b" {
+		t.Fatalf("expected the patch with the current ETag, got %v", got)
+	}
+}
@@ -0,0 +1,44 @@
+package mycontentapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+)
+
+// WithPolicy authorizes every request by its action & namespace.
+// The namespace is the X-Namespace header; the posted resource must be of the same namespace.
+func (i *service[T]) WithPolicy(policy mycontent.Policy) *service[T] {
+	i.policy = policy
+	return i
+}
+
+// authorize the request; writes the error if it is not allowed
+func (i *service[T]) authorize(w http.ResponseWriter, r *http.Request, action mycontent.Action, namespace string) bool {
+	if i.policy == nil {
+		return true
+	}
+
+	err := i.policy.Authorize(r.Context(), action, namespace)
+	if err == nil {
+		return true
+	}
+
+	handleUsecaseError(w, err)
+	return false
+}
+
+// sameNamespace of the posted resource as the X-Namespace header, which the request is authorized against;
+// writes the error if it is not
+func sameNamespace(w http.ResponseWriter, namespace string, resourceNamespace string) bool {
+	if resourceNamespace == namespace {
+		return true
+	}
+
+	handleError(
+		w, "BAD_REQUEST",
+		fmt.Sprintf("the namespace of the resource (%v) is not the 'X-Namespace' header (%v)", resourceNamespace, namespace),
+		http.StatusBadRequest, nil)
+	return false
+}
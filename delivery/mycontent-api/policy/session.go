@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/types/protobuf/session"
+)
+
+var _ mycontent.Policy = &sessionPolicy{}
+
+// Table declares the "ui_and_api_permission" of the grant needed for each action on the table.
+// Empty needs only the grant to the namespace.
+type Table struct {
+	Read   string
+	Write  string
+	Delete string
+}
+
+type sessionPolicy struct {
+	authKey any
+	table   Table
+}
+
+// NewSession authorizes with the grants of the *session.SessionData in the context under the authKey,
+// eg. as put by idtokenverifier.AppAuth. The namespace is the key of the grants; super admin is allowed everything.
+func NewSession(authKey any, table Table) *sessionPolicy {
+	return &sessionPolicy{
+		authKey: authKey,
+		table:   table,
+	}
+}
+
+func (s *sessionPolicy) Authorize(ctx context.Context, action mycontent.Action, namespace string) error {
+	auth, _ := ctx.Value(s.authKey).(*session.SessionData)
+	if auth == nil {
+		return fmt.Errorf("%w: no verified session", mycontent.ErrUnauthorized)
+	}
+
+	if auth.IsSuperAdmin {
+		return nil
+	}
+
+	if namespace == "*" {
+		return fmt.Errorf("%w: only super admin can %v every namespace", mycontent.ErrForbidden, action)
+	}
+
+	grant, ok := auth.Grants[namespace]
+	if !ok || grant == nil {
+		return fmt.Errorf("%w: no grant to namespace '%v'", mycontent.ErrForbidden, namespace)
+	}
+
+	permission := s.table.permission(action)
+	if permission != "" && !grant.UiAndApiPermission[permission] {
+		return fmt.Errorf("%w: '%v' permission is needed to %v in namespace '%v'", mycontent.ErrForbidden, permission, action, namespace)
+	}
+
+	return nil
+}
+
+func (t Table) permission(action mycontent.Action) string {
+	switch action {
+	case mycontent.ActionRead:
+		return t.Read
+	case mycontent.ActionWrite:
+		return t.Write
+	case mycontent.ActionDelete:
+		return t.Delete
+	}
+	return ""
+}
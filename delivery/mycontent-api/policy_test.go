@@ -0,0 +1,214 @@
+package mycontentapi_test
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+type policyCall struct {
+	action    mycontent.Action
+	namespace string
+}
+
+// actionPolicy denies the actions with its error, and records every call
+type actionPolicy struct {
+	denied map[mycontent.Action]bool
+	err    error
+	calls  []policyCall
+}
+
+func (p *actionPolicy) Authorize(ctx context.Context, action mycontent.Action, namespace string) error {
+	p.calls = append(p.calls, policyCall{action, namespace})
+	if p.denied[action] {
+		return p.err
+	}
+	return nil
+}
+
+func newPolicyAPI(t *testing.T, policy mycontent.Policy) http.Handler {
+	t.Helper()
+
+	storage := newStorage(t,
+		sqliteraft.TableConfig{TableName: "doc", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "doc_trash", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "post", RefSize: 1, Versioned: true},
+		sqliteraft.TableConfig{TableName: "attachment", RefSize: 1},
+	)
+
+	documents := base.New[*entity.Document](storage["doc"]).WithSoftDelete(storage["doc_trash"], time.Hour)
+	attachments := base.NewAttachment(storage["attachment"], newMemBlob(), false, "")
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).
+		Resource("/doc", mycontentapi.New[*entity.Document](documents, "http://localhost/doc", []string{"owner"}).WithPolicy(policy)).
+		Resource("/post", mycontentapi.NewFromStorageVersioned[*versionedDoc]("http://localhost/post", []string{"owner"}, storage["post"], 1).WithPolicy(policy)).
+		Resource("/attachment", mycontentapi.NewAttachment(attachments, "http://localhost/attachment", []string{"owner"}, "").WithPolicy(policy))
+
+	return router
+}
+
+// multipartUpload of the attachment document & its content
+func multipartUpload(t *testing.T, document string) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("document", document); err != nil {
+		t.Fatal(err)
+	}
+	part, err := mw.CreateFormFile("attachment", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("content"))
+	mw.Close()
+
+	return buf.String(), mw.FormDataContentType()
+}
+
+func TestPolicyPerAction(t *testing.T) {
+	upload, uploadType := multipartUpload(t, `{"owner_id":"ns","ref_ids":["o"],"content_type":"text/plain"}`)
+
+	policy := &actionPolicy{err: mycontent.ErrForbidden}
+	router := newPolicyAPI(t, policy)
+
+	for _, tc := range []struct {
+		name      string
+		method    string
+		target    string
+		body      string
+		header    []string
+		action    mycontent.Action
+		namespace string
+
+		// the request does not return while it is allowed
+		blocking bool
+	}{
+		{name: "get", method: http.MethodGet, target: "/doc?owner=o", action: mycontent.ActionRead},
+		{name: "stream", method: http.MethodGet, target: "/doc/stream?owner=o", action: mycontent.ActionRead},
+		{name: "watch", method: http.MethodGet, target: "/doc/watch?owner=o", action: mycontent.ActionRead, blocking: true},
+		{name: "trash", method: http.MethodGet, target: "/doc/trash?owner=o", action: mycontent.ActionRead},
+		{name: "history", method: http.MethodGet, target: "/post/history?owner=o", action: mycontent.ActionRead},
+		{name: "download", method: http.MethodGet, target: "/attachment?owner=o&id=a&data=true", action: mycontent.ActionRead},
+		{name: "post", method: http.MethodPost, target: "/doc", body: `{"id":"d1","namespace":"ns","ref_ids":["o"]}`, action: mycontent.ActionWrite},
+		{name: "post to the namespace of the header", method: http.MethodPost, target: "/doc", body: `{"id":"d1","namespace":"other","ref_ids":["o"]}`, header: []string{"X-Namespace", "other"}, action: mycontent.ActionWrite, namespace: "other"},
+		{name: "post batch", method: http.MethodPost, target: "/doc/batch", body: `[{"id":"d2","namespace":"ns","ref_ids":["o"]}]`, action: mycontent.ActionWrite},
+		{name: "patch", method: http.MethodPatch, target: "/doc?owner=o&id=d1", body: `{"title":"b"}`, header: []string{"Content-Type", "application/merge-patch+json"}, action: mycontent.ActionWrite},
+		{name: "undelete", method: http.MethodPost, target: "/doc/undelete?owner=o&id=d1", action: mycontent.ActionWrite},
+		{name: "restore", method: http.MethodPost, target: "/post/restore?owner=o&version=0", action: mycontent.ActionWrite},
+		{name: "upload", method: http.MethodPost, target: "/attachment", body: upload, header: []string{"Content-Type", uploadType}, action: mycontent.ActionWrite},
+		{name: "start upload", method: http.MethodPost, target: "/attachment/uploads", body: `{"owner_id":"ns","ref_ids":["o"]}`, action: mycontent.ActionWrite},
+		{name: "upload part", method: http.MethodPut, target: "/attachment/uploads?upload_id=u&part=1", body: "part", action: mycontent.ActionWrite},
+		{name: "abort upload", method: http.MethodDelete, target: "/attachment/uploads?upload_id=u", action: mycontent.ActionWrite},
+		{name: "delete", method: http.MethodDelete, target: "/doc?owner=o&id=d1", action: mycontent.ActionDelete},
+		{name: "delete batch", method: http.MethodDelete, target: "/doc/batch", body: `[{"namespace":"ns","ref_ids":["o"],"id":"d2"}]`, action: mycontent.ActionDelete},
+		{name: "delete attachment", method: http.MethodDelete, target: "/attachment?owner=o&id=a", action: mycontent.ActionDelete},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			namespace := tc.namespace
+			if namespace == "" {
+				namespace = "ns"
+			}
+
+			// denied by the policy of its action
+			policy.denied, policy.calls = map[mycontent.Action]bool{tc.action: true}, nil
+
+			rec := serve(t, router, tc.method, tc.target, tc.body, tc.header...)
+			if status, code := errorCode(t, rec); status != http.StatusForbidden || code != "FORBIDDEN" {
+				t.Fatalf("expected forbidden, got %v %v", status, code)
+			}
+			if len(policy.calls) == 0 || policy.calls[0] != (policyCall{tc.action, namespace}) {
+				t.Fatalf("expected the policy asked for %v on %v, got %+v", tc.action, namespace, policy.calls)
+			}
+
+			if tc.blocking {
+				return
+			}
+
+			// allowed, the other actions are not asked
+			policy.denied = map[mycontent.Action]bool{
+				mycontent.ActionRead: true, mycontent.ActionWrite: true, mycontent.ActionDelete: true,
+			}
+			delete(policy.denied, tc.action)
+			policy.calls = nil
+
+			rec = serve(t, router, tc.method, tc.target, tc.body, tc.header...)
+			if rec.Code == http.StatusForbidden || rec.Code == http.StatusUnauthorized {
+				t.Fatalf("expected allowed, got %v %s", rec.Code, rec.Body.String())
+			}
+			for _, call := range policy.calls {
+				if call != (policyCall{tc.action, namespace}) {
+					t.Fatalf("expected only %v on %v asked, got %+v", tc.action, namespace, policy.calls)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyUnauthorized(t *testing.T) {
+	policy := &actionPolicy{
+		denied: map[mycontent.Action]bool{mycontent.ActionRead: true, mycontent.ActionWrite: true, mycontent.ActionDelete: true},
+		err:    mycontent.ErrUnauthorized,
+	}
+	router := newPolicyAPI(t, policy)
+
+	rec := serve(t, router, http.MethodGet, "/doc?owner=o", "")
+	if status, code := errorCode(t, rec); status != http.StatusUnauthorized || code != "UNAUTHORIZED" {
+		t.Fatalf("expected unauthorized, got %v %v", status, code)
+	}
+}
+
+// every action is authorized against the X-Namespace header, so the posted resource cannot be of another namespace
+func TestPolicyNamespaceMismatch(t *testing.T) {
+	policy := &actionPolicy{}
+	router := newPolicyAPI(t, policy)
+
+	upload, uploadType := multipartUpload(t, `{"owner_id":"other","ref_ids":["o"],"content_type":"text/plain"}`)
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		body   string
+		header []string
+	}{
+		{name: "post", target: "/doc", body: `{"id":"d1","namespace":"other","ref_ids":["o"]}`},
+		{name: "post batch", target: "/doc/batch", body: `[{"id":"d1","namespace":"ns","ref_ids":["o"]},{"id":"d2","namespace":"other","ref_ids":["o"]}]`},
+		{name: "upload", target: "/attachment", body: upload, header: []string{"Content-Type", uploadType}},
+		{name: "start upload", target: "/attachment/uploads", body: `{"owner_id":"other","ref_ids":["o"]}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			policy.calls = nil
+
+			rec := serve(t, router, http.MethodPost, tc.target, tc.body, tc.header...)
+			if status, code := errorCode(t, rec); status != http.StatusBadRequest || code != "BAD_REQUEST" {
+				t.Fatalf("expected bad request, got %v %v", status, code)
+			}
+			if len(policy.calls) != 1 || policy.calls[0] != (policyCall{mycontent.ActionWrite, "ns"}) {
+				t.Fatalf("expected the policy asked for the header namespace only, got %+v", policy.calls)
+			}
+		})
+	}
+
+	// without the header
+	rec := serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o"]}`, "X-Namespace", "")
+	if status, code := errorCode(t, rec); status != http.StatusBadRequest || code != "BAD_REQUEST" {
+		t.Fatalf("expected bad request without the header, got %v %v", status, code)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/doc?owner=o", "")
+	if got := decode[[]entity.Document](t, rec); len(got) != 0 {
+		t.Fatalf("expected nothing posted, got %v", got)
+	}
+}
@@ -0,0 +1,265 @@
+package mycontentapi
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	entity "github.com/desain-gratis/common/types/entity"
+)
+
+// Middleware of a route, eg. idtokenverifier.AppAuth
+type Middleware func(httprouter.Handle) httprouter.Handle
+
+// Route mounted by the Registrar
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// Resource is the base path of the resource of the route; empty for the other route
+	Resource string `json:"resource,omitempty"`
+}
+
+// Resource is a service that mounts its endpoints; service[T] & uploadService
+type Resource interface {
+	Documented
+	mount(g *Registrar, path string, middleware []Middleware)
+}
+
+// Registrar mounts the resources to the router with the shared middleware
+type Registrar struct {
+	router     *httprouter.Router
+	middleware []Middleware
+	cors       []string
+	openAPI    *OpenAPI
+	routes     []Route
+}
+
+func NewRegistrar(router *httprouter.Router) *Registrar {
+	return &Registrar{
+		router: router,
+	}
+}
+
+// Use the middleware for every route mounted after; the first one is the outermost
+func (g *Registrar) Use(middleware ...Middleware) *Registrar {
+	g.middleware = append(g.middleware, middleware...)
+	return g
+}
+
+// WithCORS allows the origins (or "*") for every route mounted after, including its preflight
+func (g *Registrar) WithCORS(allowedOrigins ...string) *Registrar {
+	g.cors = allowedOrigins
+	return g
+}
+
+// WithOpenAPI adds every resource mounted after to the document
+func (g *Registrar) WithOpenAPI(o *OpenAPI) *Registrar {
+	g.openAPI = o
+	return g
+}
+
+// Resource mounts every endpoint of the service under the path, with the conventional sub path (see OpenAPI.Add).
+// The middleware is after the shared one.
+func (g *Registrar) Resource(path string, svc Resource, middleware ...Middleware) *Registrar {
+	svc.mount(g, path, middleware)
+	if g.openAPI != nil {
+		g.openAPI.Add(path, svc)
+	}
+	return g
+}
+
+// Handle mounts the other route with the shared middleware
+func (g *Registrar) Handle(method string, path string, handle httprouter.Handle, middleware ...Middleware) *Registrar {
+	g.handle("", method, path, handle, middleware)
+	return g
+}
+
+// Routes mounted so far
+func (g *Registrar) Routes() []Route {
+	result := make([]Route, len(g.routes))
+	copy(result, g.routes)
+	return result
+}
+
+func (g *Registrar) handle(resource string, method string, path string, handle httprouter.Handle, middleware []Middleware) {
+	// the preflight has no credential, so it has no middleware other than CORS
+	if !g.mounted(http.MethodOptions, path) {
+		g.router.Handle(http.MethodOptions, path, g.withCORS(preflight))
+		g.routes = append(g.routes, Route{Method: http.MethodOptions, Path: path, Resource: resource})
+	}
+
+	for idx := len(middleware) - 1; idx >= 0; idx-- {
+		handle = middleware[idx](handle)
+	}
+	for idx := len(g.middleware) - 1; idx >= 0; idx-- {
+		handle = g.middleware[idx](handle)
+	}
+
+	g.router.Handle(method, path, g.withCORS(handle))
+	g.routes = append(g.routes, Route{Method: method, Path: path, Resource: resource})
+}
+
+func (g *Registrar) mounted(method string, path string) bool {
+	for _, route := range g.routes {
+		if route.Method == method && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Registrar) withCORS(handle httprouter.Handle) httprouter.Handle {
+	if len(g.cors) == 0 {
+		return handle
+	}
+
+	origins := g.cors
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		origin := r.Header.Get("Origin")
+		for _, allowed := range origins {
+			if allowed == "*" || allowed == origin {
+				header := w.Header()
+				header.Set("Access-Control-Allow-Origin", allowed)
+				header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				header.Set("Access-Control-Allow-Headers", "*")
+				header.Set("Access-Control-Expose-Headers", "*")
+				if allowed != "*" {
+					header.Add("Vary", "Origin")
+				}
+				break
+			}
+		}
+		handle(w, r, p)
+	}
+}
+
+func preflight(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *service[T]) mount(g *Registrar, path string, middleware []Middleware) {
+	g.handle(path, http.MethodGet, path, i.Get, middleware)
+	g.handle(path, http.MethodPost, path, i.Post, middleware)
+	g.handle(path, http.MethodDelete, path, i.Delete, middleware)
+	g.handle(path, http.MethodGet, path+"/stream", i.Stream, middleware)
+
+	if _, ok := i.uc.(mycontent.Patchable[T]); ok {
+		g.handle(path, http.MethodPatch, path, i.Patch, middleware)
+	}
+
+	if _, ok := i.uc.(mycontent.Batchable[T]); ok {
+		g.handle(path, http.MethodPost, path+"/batch", i.PostBatch, middleware)
+		g.handle(path, http.MethodDelete, path+"/batch", i.DeleteBatch, middleware)
+	}
+
+	if _, ok := i.uc.(mycontent.Watchable[T]); ok {
+		g.handle(path, http.MethodGet, path+"/watch", i.Watch, middleware)
+	}
+
+	if _, ok := i.uc.(mycontent.Historical[T]); ok {
+		g.handle(path, http.MethodGet, path+"/history", i.History, middleware)
+		g.handle(path, http.MethodPost, path+"/restore", i.Restore, middleware)
+	}
+
+	if _, ok := i.uc.(mycontent.Trashable[T]); ok {
+		g.handle(path, http.MethodGet, path+"/trash", i.Trash, middleware)
+		g.handle(path, http.MethodPost, path+"/undelete", i.Undelete, middleware)
+	}
+
+	if schematic, ok := i.uc.(mycontent.Schematic); ok && schematic.Schema() != nil {
+		g.handle(path, http.MethodGet, path+"/schema", i.Schema, middleware)
+	}
+}
+
+// mount the attachment: the download is Get with data=true
+func (i *uploadService) mount(g *Registrar, path string, middleware []Middleware) {
+	g.handle(path, http.MethodGet, path, i.Get, middleware)
+	g.handle(path, http.MethodPost, path, i.Upload, middleware)
+	g.handle(path, http.MethodDelete, path, i.Delete, middleware)
+
+	if _, ok := i.uc.(mycontent.Resumable[*entity.Attachment]); ok {
+		g.handle(path, http.MethodPost, path+"/uploads", i.StartUpload, middleware)
+		g.handle(path, http.MethodGet, path+"/uploads", i.GetUpload, middleware)
+		g.handle(path, http.MethodPut, path+"/uploads", i.UploadPart, middleware)
+		g.handle(path, http.MethodDelete, path+"/uploads", i.AbortUpload, middleware)
+		g.handle(path, http.MethodPost, path+"/uploads/complete", i.CompleteUpload, middleware)
+	}
+}
+
+// Logging of every request, with its status & duration
+func Logging(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		handle(sw, r, p)
+
+		slog.Info("served request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("namespace", r.Header.Get("X-Namespace")),
+			slog.Int("status", sw.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+}
+
+// MaxInFlight limits the requests being served at once, shared by every route it is used for; the rest is rejected with 503
+func MaxInFlight(limit int) Middleware {
+	sem := make(chan struct{}, limit)
+	return func(handle httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				handle(w, r, p)
+			default:
+				w.Header().Set("Retry-After", "1")
+				handleError(w, "TOO_MANY_REQUESTS", "server is busy, please retry", http.StatusServiceUnavailable, nil)
+			}
+		}
+	}
+}
+
+// statusWriter records the status; it is still a flusher & hijacker for stream & watch
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack is not supported")
+	}
+	s.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// String of the route, eg. "GET /org/user"
+func (r Route) String() string {
+	return strings.Join([]string{r.Method, r.Path}, " ")
+}
@@ -0,0 +1,152 @@
+package mycontentapi_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+func resourceRoutes(resource string, routes ...string) []mycontentapi.Route {
+	result := make([]mycontentapi.Route, 0, len(routes))
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		result = append(result, mycontentapi.Route{Method: method, Path: resource + path, Resource: resource})
+	}
+	return result
+}
+
+func TestRegistrarRoutes(t *testing.T) {
+	schema, err := mycontent.CompileSchema([]byte(documentSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := httprouter.New()
+	registrar := mycontentapi.NewRegistrar(router).
+		Resource("/doc", mycontentapi.New[*entity.Document](base.New[*entity.Document](nil), "http://localhost/doc", []string{"owner"})).
+		Resource("/schematic", mycontentapi.New[*entity.Document](base.New[*entity.Document](nil).WithSchema(schema), "http://localhost/schematic", []string{"owner"})).
+		Resource("/post", mycontentapi.NewFromStorageVersioned[*versionedDoc]("http://localhost/post", []string{"owner"}, nil, 1)).
+		Resource("/attachment", mycontentapi.NewAttachment(base.NewAttachment(nil, newMemBlob(), false, ""), "http://localhost/attachment", []string{"owner"}, "")).
+		Handle(http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {})
+
+	common := []string{
+		"OPTIONS ", "GET ", "POST ", "DELETE ",
+		"OPTIONS /stream", "GET /stream",
+		"PATCH ",
+		"OPTIONS /batch", "POST /batch", "DELETE /batch",
+		"OPTIONS /watch", "GET /watch",
+	}
+	trash := []string{
+		"OPTIONS /trash", "GET /trash",
+		"OPTIONS /undelete", "POST /undelete",
+	}
+
+	var want []mycontentapi.Route
+	want = append(want, resourceRoutes("/doc", append(common, trash...)...)...)
+	want = append(want, resourceRoutes("/schematic", append(append(common, trash...), "OPTIONS /schema", "GET /schema")...)...)
+	want = append(want, resourceRoutes("/post", append(append(common, "OPTIONS /history", "GET /history", "OPTIONS /restore", "POST /restore"), trash...)...)...)
+	want = append(want, resourceRoutes("/attachment",
+		"OPTIONS ", "GET ", "POST ", "DELETE ",
+		"OPTIONS /uploads", "POST /uploads", "GET /uploads", "PUT /uploads", "DELETE /uploads",
+		"OPTIONS /uploads/complete", "POST /uploads/complete",
+	)...)
+	want = append(want,
+		mycontentapi.Route{Method: http.MethodOptions, Path: "/healthz"},
+		mycontentapi.Route{Method: http.MethodGet, Path: "/healthz"},
+	)
+
+	got := registrar.Routes()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected routes\n got: %v\nwant: %v", got, want)
+	}
+
+	for _, route := range got {
+		if handle, _, _ := router.Lookup(route.Method, route.Path); handle == nil {
+			t.Fatalf("expected %v %v mounted to the router", route.Method, route.Path)
+		}
+	}
+
+	// a copy
+	got[0].Path = "/changed"
+	if registrar.Routes()[0].Path == "/changed" {
+		t.Fatal("expected the routes to be a copy")
+	}
+}
+
+func TestRegistrarMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) mycontentapi.Middleware {
+		return func(next httprouter.Handle) httprouter.Handle {
+			return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+				calls = append(calls, name)
+				next(w, r, p)
+			}
+		}
+	}
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).
+		Use(record("shared 1"), record("shared 2")).
+		Resource("/doc", mycontentapi.New[*entity.Document](base.New[*entity.Document](nil), "http://localhost/doc", []string{"owner"}), record("resource")).
+		Handle(http.MethodGet, "/healthz", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+			calls = append(calls, "handle")
+		}, record("route"))
+
+	for _, tc := range []struct {
+		method string
+		target string
+		want   []string
+	}{
+		{http.MethodGet, "/doc", []string{"shared 1", "shared 2", "resource"}},
+		{http.MethodGet, "/healthz", []string{"shared 1", "shared 2", "route", "handle"}},
+
+		// the preflight has no credential
+		{http.MethodOptions, "/doc", nil},
+	} {
+		calls = nil
+		serve(t, router, tc.method, tc.target, "", "X-Namespace", "")
+		if !reflect.DeepEqual(calls, tc.want) {
+			t.Fatalf("%v %v: expected the middleware %v, got %v", tc.method, tc.target, tc.want, calls)
+		}
+	}
+}
+
+func TestRegistrarCORS(t *testing.T) {
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).
+		Handle(http.MethodGet, "/before", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {}).
+		WithCORS("https://a.example").
+		Handle(http.MethodGet, "/after", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {})
+
+	for _, tc := range []struct {
+		method string
+		target string
+		origin string
+		status int
+		allow  string
+	}{
+		{http.MethodOptions, "/after", "https://a.example", http.StatusNoContent, "https://a.example"},
+		{http.MethodGet, "/after", "https://a.example", http.StatusOK, "https://a.example"},
+		{http.MethodGet, "/after", "https://b.example", http.StatusOK, ""},
+
+		// only for the routes mounted after
+		{http.MethodGet, "/before", "https://a.example", http.StatusOK, ""},
+	} {
+		rec := serve(t, router, tc.method, tc.target, "", "Origin", tc.origin)
+		if rec.Code != tc.status || rec.Header().Get("Access-Control-Allow-Origin") != tc.allow {
+			t.Fatalf("%v %v from %v: expected %v %q, got %v %q", tc.method, tc.target, tc.origin,
+				tc.status, tc.allow, rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if tc.allow != "" && rec.Header().Get("Vary") != "Origin" {
+			t.Fatalf("%v %v: expected to vary by origin", tc.method, tc.target)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	entity "github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+var whitelistUploadParams = map[string]struct{}{
+	"upload_id": {},
+	"part":      {},
+}
+
+// StartUpload of the attachment in parts; the body is the attachment meta, the same as the 'document' of Upload,
+// with its content size. The response is the session, with its ID, part size & part count.
+func (i *uploadService) StartUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
+	if len(r.URL.Query()) > 0 {
+		handleError(w, "BAD_REQUEST", "URL Parameter should not be specified", http.StatusBadRequest, nil)
+		return
+	}
+
+	resumable, ok := i.uc.(mycontent.Resumable[*entity.Attachment])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "resumable upload is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	payload, ok := readBody(w, r, maximumRequestLength)
+	if !ok {
+		return
+	}
+
+	attachmentData := &entity.Attachment{}
+	err := json.Unmarshal(payload, attachmentData)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", "failed to parse body: "+err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	if !sameNamespace(w, namespace, attachmentData.Namespace()) {
+		return
+	}
+
+	session, err := resumable.StartUpload(r.Context(), attachmentData)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	writeSuccess(w, session)
+}
+
+// GetUpload session by the upload_id, with the parts uploaded so far to resume it
+func (i *uploadService) GetUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	resumable, namespace, uploadID, ok := i.uploadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := resumable.GetUpload(r.Context(), namespace, uploadID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	writeSuccess(w, session)
+}
+
+// UploadPart number "part" (from 1) of the upload_id; the body is the part, with its Content-Length.
+// Every part has the part size of the session, except the last one.
+func (i *uploadService) UploadPart(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	resumable, namespace, uploadID, ok := i.uploadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(r.URL.Query().Get("part"))
+	if err != nil {
+		handleError(w, "BAD_REQUEST", "'part' parameter must be the part number", http.StatusBadRequest, nil)
+		return
+	}
+
+	if r.ContentLength < 0 {
+		handleError(w, "LENGTH_REQUIRED", "'Content-Length' header must be specified", http.StatusLengthRequired, nil)
+		return
+	}
+	defer r.Body.Close()
+
+	part, err := resumable.UploadPart(r.Context(), namespace, uploadID, number, r.Body, r.ContentLength)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	writeSuccess(w, part)
+}
+
+// CompleteUpload of the upload_id after every part is uploaded; the response is the attachment meta, the same as Upload
+func (i *uploadService) CompleteUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	resumable, namespace, uploadID, ok := i.uploadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := resumable.CompleteUpload(r.Context(), namespace, uploadID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	for _, pp := range i.postProcess {
+		pp(result)
+	}
+
+	writeSuccess(w, result)
+}
+
+// AbortUpload of the upload_id, and discard its uploaded parts
+func (i *uploadService) AbortUpload(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	resumable, namespace, uploadID, ok := i.uploadRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := resumable.AbortUpload(r.Context(), namespace, uploadID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	writeSuccess(w, map[string]string{"id": uploadID})
+}
+
+// uploadRequest of the session by the X-Namespace header & the upload_id parameter; writes the error if it is invalid
+func (i *uploadService) uploadRequest(w http.ResponseWriter, r *http.Request) (resumable mycontent.Resumable[*entity.Attachment], namespace string, uploadID string, ok bool) {
+	namespace = r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return nil, "", "", false
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return nil, "", "", false
+	}
+
+	invalidParams := validateParams(whitelistUploadParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return nil, "", "", false
+	}
+
+	resumable, ok = i.uc.(mycontent.Resumable[*entity.Attachment])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "resumable upload is not supported", http.StatusNotImplemented, nil)
+		return nil, "", "", false
+	}
+
+	uploadID = r.URL.Query().Get("upload_id")
+	if uploadID == "" {
+		handleError(w, "BAD_REQUEST", "'upload_id' parameter must be specified", http.StatusBadRequest, nil)
+		return nil, "", "", false
+	}
+
+	return resumable, namespace, uploadID, true
+}
+
+func writeSuccess(w http.ResponseWriter, success any) {
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: success,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
@@ -0,0 +1,297 @@
+package mycontentapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/blob"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+// multipartBlob keeps the parts of the upload in memory until it is completed; the abort fails while failAbort is set
+type multipartBlob struct {
+	*memBlob
+
+	lock      sync.Mutex
+	uploads   map[string]map[int][]byte
+	aborted   []string
+	failAbort bool
+}
+
+func newMultipartBlob() *multipartBlob {
+	return &multipartBlob{memBlob: newMemBlob(), uploads: make(map[string]map[int][]byte)}
+}
+
+func (m *multipartBlob) StartUpload(ctx context.Context, path string, attachment *entity.Attachment) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	uploadID := fmt.Sprint(path, "#", len(m.uploads))
+	m.uploads[uploadID] = make(map[int][]byte)
+	return uploadID, nil
+}
+
+func (m *multipartBlob) UploadPart(ctx context.Context, path string, uploadID string, number int, payload io.Reader, size int64) (*blob.Part, error) {
+	b, err := io.ReadAll(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	parts, ok := m.uploads[uploadID]
+	if !ok {
+		return nil, fmt.Errorf("upload %v not found", uploadID)
+	}
+	parts[number] = b
+
+	return &blob.Part{Number: number, ETag: fmt.Sprint(number), Size: int64(len(b))}, nil
+}
+
+func (m *multipartBlob) ListParts(ctx context.Context, path string, uploadID string) ([]blob.Part, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	parts, ok := m.uploads[uploadID]
+	if !ok {
+		return nil, fmt.Errorf("upload %v not found", uploadID)
+	}
+
+	result := make([]blob.Part, 0, len(parts))
+	for number, b := range parts {
+		result = append(result, blob.Part{Number: number, ETag: fmt.Sprint(number), Size: int64(len(b))})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+	return result, nil
+}
+
+func (m *multipartBlob) CompleteUpload(ctx context.Context, path string, uploadID string, attachment *entity.Attachment, parts []blob.Part) (*blob.Data, error) {
+	m.lock.Lock()
+	uploaded, ok := m.uploads[uploadID]
+	delete(m.uploads, uploadID)
+	m.lock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("upload %v not found", uploadID)
+	}
+
+	var buf bytes.Buffer
+	for _, part := range parts {
+		buf.Write(uploaded[part.Number])
+	}
+	return m.memBlob.Upload(ctx, path, attachment, &buf)
+}
+
+func (m *multipartBlob) AbortUpload(ctx context.Context, path string, uploadID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.failAbort {
+		return errors.New("injected failure")
+	}
+	delete(m.uploads, uploadID)
+	m.aborted = append(m.aborted, uploadID)
+	return nil
+}
+
+// newResumableAPI with the sessions that expire after the expiry, and the other with the same storage that are already expired
+func newResumableAPI(t *testing.T) (live, expired *base.HandlerWithAttachment, blobs *multipartBlob, router http.Handler) {
+	t.Helper()
+
+	storage := newStorage(t,
+		sqliteraft.TableConfig{TableName: "attachment", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "upload", RefSize: 0},
+	)
+	blobs = newMultipartBlob()
+	live = base.NewAttachment(storage["attachment"], blobs, false, "").WithResumableUpload(storage["upload"], 0, time.Hour)
+	expired = base.NewAttachment(storage["attachment"], blobs, false, "").WithResumableUpload(storage["upload"], 0, -time.Minute)
+
+	r := httprouter.New()
+	mycontentapi.NewRegistrar(r).Resource("/attachment", mycontentapi.NewAttachment(live, "http://localhost/attachment", []string{"owner"}, ""))
+
+	return live, expired, blobs, r
+}
+
+func partNumbers(parts []mycontent.UploadPart) []int {
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		result = append(result, part.Number)
+	}
+	return result
+}
+
+func TestResumableUpload(t *testing.T) {
+	_, _, _, router := newResumableAPI(t)
+
+	// 2 full parts & the last one of 3 bytes
+	first := strings.Repeat("a", blob.MinPartSize)
+	second := strings.Repeat("b", blob.MinPartSize)
+	last := "end"
+	size := 2*blob.MinPartSize + len(last)
+
+	rec := serve(t, router, http.MethodPost, "/attachment/uploads",
+		fmt.Sprintf(`{"owner_id":"ns","ref_ids":["o"],"content_type":"text/plain","content_size":%v}`, size))
+	session := decode[mycontent.UploadSession[*entity.Attachment]](t, rec)
+	if session.ID == "" || session.PartSize != blob.MinPartSize || session.PartCount != 3 || len(session.Parts) != 0 {
+		t.Fatalf("expected a session of 3 parts, got %+v", session)
+	}
+	target := "/attachment/uploads?upload_id=" + session.ID
+
+	// out of order, the part uploaded again replaces the previous one
+	decode[mycontent.UploadPart](t, serve(t, router, http.MethodPut, target+"&part=3", "xyz"))
+	decode[mycontent.UploadPart](t, serve(t, router, http.MethodPut, target+"&part=3", last))
+	part := decode[mycontent.UploadPart](t, serve(t, router, http.MethodPut, target+"&part=1", first))
+	if part.Number != 1 || part.Size != blob.MinPartSize {
+		t.Fatalf("expected the first part, got %+v", part)
+	}
+
+	// resumed from the uploaded parts
+	session = decode[mycontent.UploadSession[*entity.Attachment]](t, serve(t, router, http.MethodGet, target, ""))
+	if got := partNumbers(session.Parts); !reflect.DeepEqual(got, []int{1, 3}) || !reflect.DeepEqual(session.Missing(), []int{2}) {
+		t.Fatalf("expected the part 2 missing, got %v", got)
+	}
+
+	// not completed with a missing part
+	if status, code := errorCode(t, serve(t, router, http.MethodPost, "/attachment/uploads/complete?upload_id="+session.ID, "")); status != http.StatusBadRequest || code != "BAD_REQUEST" {
+		t.Fatalf("expected the missing part rejected, got %v %v", status, code)
+	}
+
+	decode[mycontent.UploadPart](t, serve(t, router, http.MethodPut, target+"&part=2", second))
+
+	attachment := decode[*entity.Attachment](t, serve(t, router, http.MethodPost, "/attachment/uploads/complete?upload_id="+session.ID, ""))
+	if attachment.Id == "" || attachment.ContentSize != uint64(size) {
+		t.Fatalf("expected the attachment of %v bytes, got %+v", size, attachment)
+	}
+
+	rec = serve(t, router, http.MethodGet, "/attachment?owner=o&data=true&id="+attachment.Id, "")
+	if rec.Code != http.StatusOK || rec.Body.String() != first+second+last {
+		t.Fatalf("expected the parts in order, got %v of %v bytes", rec.Code, rec.Body.Len())
+	}
+
+	// the completed session is removed
+	if status, code := errorCode(t, serve(t, router, http.MethodGet, target, "")); status != http.StatusNotFound || code != "NOT_FOUND" {
+		t.Fatalf("expected the completed session not found, got %v %v", status, code)
+	}
+}
+
+func TestResumableUploadErrors(t *testing.T) {
+	_, _, blobs, router := newResumableAPI(t)
+
+	rec := serve(t, router, http.MethodPost, "/attachment/uploads", fmt.Sprintf(`{"owner_id":"ns","ref_ids":["o"],"content_size":%v}`, blob.MinPartSize+3))
+	session := decode[mycontent.UploadSession[*entity.Attachment]](t, rec)
+	target := "/attachment/uploads?upload_id=" + session.ID
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{"start without size", http.MethodPost, "/attachment/uploads", `{"owner_id":"ns","ref_ids":["o"]}`, http.StatusBadRequest, "BAD_REQUEST"},
+		{"start without namespace", http.MethodPost, "/attachment/uploads", `{"ref_ids":["o"],"content_size":3}`, http.StatusBadRequest, "BAD_REQUEST"},
+		{"part number", http.MethodPut, target + "&part=x", "abc", http.StatusBadRequest, "BAD_REQUEST"},
+		{"part out of range", http.MethodPut, target + "&part=3", "abc", http.StatusBadRequest, "BAD_REQUEST"},
+		{"part size", http.MethodPut, target + "&part=1", "abc", http.StatusBadRequest, "BAD_REQUEST"},
+		{"last part size", http.MethodPut, target + "&part=2", "ab", http.StatusBadRequest, "BAD_REQUEST"},
+		{"unknown session", http.MethodGet, "/attachment/uploads?upload_id=unknown", "", http.StatusNotFound, "NOT_FOUND"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if status, code := errorCode(t, serve(t, router, tc.method, tc.target, tc.body)); status != tc.status || code != tc.code {
+				t.Fatalf("expected %v %v, got %v %v", tc.status, tc.code, status, code)
+			}
+		})
+	}
+
+	// aborted, then not found
+	if got := decode[map[string]string](t, serve(t, router, http.MethodDelete, target, "")); got["id"] != session.ID {
+		t.Fatalf("expected the aborted session, got %v", got)
+	}
+	if len(blobs.aborted) != 1 {
+		t.Fatalf("expected the blob upload aborted, got %v", blobs.aborted)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		if status, code := errorCode(t, serve(t, router, method, target+"&part=2", "abc")); status != http.StatusNotFound || code != "NOT_FOUND" {
+			t.Fatalf("%v: expected the aborted session not found, got %v %v", method, status, code)
+		}
+	}
+	if status, code := errorCode(t, serve(t, router, http.MethodPost, "/attachment/uploads/complete?upload_id="+session.ID, "")); status != http.StatusNotFound || code != "NOT_FOUND" {
+		t.Fatalf("expected the aborted session not completed, got %v %v", status, code)
+	}
+}
+
+func TestResumableUploadExpiry(t *testing.T) {
+	live, expired, blobs, router := newResumableAPI(t)
+	ctx := context.Background()
+
+	var expiredIDs []string
+	for range 2 {
+		session, err := expired.StartUpload(ctx, &entity.Attachment{OwnerId: "ns", RefIds: []string{"o"}, ContentSize: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expiredIDs = append(expiredIDs, session.ID)
+	}
+	session, err := live.StartUpload(ctx, &entity.Attachment{OwnerId: "ns", RefIds: []string{"o"}, ContentSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the expired session is not found, even before it is purged
+	for _, ID := range expiredIDs {
+		if status, code := errorCode(t, serve(t, router, http.MethodPut, "/attachment/uploads?part=1&upload_id="+ID, "abc")); status != http.StatusNotFound || code != "NOT_FOUND" {
+			t.Fatalf("expected the expired session not found, got %v %v", status, code)
+		}
+	}
+
+	// the failed ones are kept for the next purge
+	blobs.failAbort = true
+	purged, err := live.PurgeUploads(ctx)
+	if purged != 0 || !errors.Is(err, mycontent.ErrStorage) {
+		t.Fatalf("expected the failures reported, got %v %v", purged, err)
+	}
+
+	blobs.failAbort = false
+	purged, err = live.PurgeUploads(ctx)
+	if err != nil || purged != 2 || len(blobs.aborted) != 2 {
+		t.Fatalf("expected the expired sessions purged, got %v %v %v", purged, err, blobs.aborted)
+	}
+
+	if purged, err = live.PurgeUploads(ctx); err != nil || purged != 0 {
+		t.Fatalf("expected nothing left to purge, got %v %v", purged, err)
+	}
+
+	// the session before its expiry is resumable
+	decode[mycontent.UploadPart](t, serve(t, router, http.MethodPut, "/attachment/uploads?part=1&upload_id="+session.ID, "abc"))
+	decode[*entity.Attachment](t, serve(t, router, http.MethodPost, "/attachment/uploads/complete?upload_id="+session.ID, ""))
+}
+
+func TestResumableUploadNotEnabled(t *testing.T) {
+	_, router := newAttachmentAPI(t)
+
+	if status, code := errorCode(t, serve(t, router, http.MethodPost, "/attachment/uploads", `{"owner_id":"ns","ref_ids":["o"],"content_size":3}`)); status != http.StatusNotImplemented || code != "NOT_IMPLEMENTED" {
+		t.Fatalf("expected not implemented, got %v %v", status, code)
+	}
+
+	if _, err := base.NewAttachment(nil, newMemBlob(), false, "").PurgeUploads(context.Background()); !errors.Is(err, mycontent.ErrResumableUploadNotEnabled) {
+		t.Fatalf("expected resumable upload not enabled, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package mycontentapi
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// ContentTypeSchema is the JSON Schema content type
+const ContentTypeSchema = "application/schema+json"
+
+// Schema publishes the JSON Schema of the resource, so non-Go clients can validate before posting
+func (i *service[T]) Schema(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	schematic, ok := i.uc.(mycontent.Schematic)
+	if !ok || schematic.Schema() == nil {
+		handleError(w, "NOT_FOUND", "the resource has no schema", http.StatusNotFound, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeSchema)
+	w.WriteHeader(http.StatusOK)
+	w.Write(schematic.Schema().Raw())
+}
+
+// fieldErrors of the validation, one for each invalid field
+func fieldErrors(verr *mycontent.ValidationError) []types.Error {
+	result := make([]types.Error, 0, len(verr.Fields))
+	for _, f := range verr.Fields {
+		result = append(result, types.Error{
+			HTTPCode: http.StatusBadRequest,
+			Code:     "BAD_REQUEST",
+			Message:  f.Message,
+			Field:    f.Path,
+		})
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package mycontentapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+const documentSchema = `{"type":"object","required":["title"],"properties":{"title":{"type":"string"},"tags":{"type":"object","additionalProperties":{"type":"string"}}}}`
+
+func TestSchemaFieldErrors(t *testing.T) {
+	schema, err := mycontent.CompileSchema([]byte(documentSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storage := newStorage(t, sqliteraft.TableConfig{TableName: "doc", RefSize: 1})
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/doc",
+		mycontentapi.New[*entity.Document](base.New[*entity.Document](storage["doc"]).WithSchema(schema), "http://localhost/doc", []string{"owner"}))
+
+	rec := serve(t, router, http.MethodGet, "/doc/schema", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != mycontentapi.ContentTypeSchema || rec.Body.String() != documentSchema {
+		t.Fatalf("expected the schema, got %v %v %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o"],"tags":{"x":1,"y":"2","z":true}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %v", rec.Code)
+	}
+
+	var resp types.CommonResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Error == nil {
+		t.Fatalf("expected the error response, got %q", rec.Body.String())
+	}
+
+	fields := make([]string, 0, len(resp.Error.Errors))
+	for _, e := range resp.Error.Errors {
+		if e.Code != "BAD_REQUEST" || e.Message == "" {
+			t.Fatalf("expected a bad request with the message, got %+v", e)
+		}
+		fields = append(fields, e.Field)
+	}
+	if want := []string{"/tags/x", "/tags/z", "/title"}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected the error of each field %v, got %v", want, fields)
+	}
+
+	decode[entity.Document](t, serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o"],"title":"a"}`))
+
+	// the schema is only published if there is one
+	_, documents := newDocumentAPI(t)
+	if rec := serve(t, documents, http.MethodGet, "/doc/schema", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected no schema, got %v", rec.Code)
+	}
+}
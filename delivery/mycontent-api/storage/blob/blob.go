@@ -28,3 +28,33 @@ type Data struct {
 	ContentType string
 	ContentSize int64
 }
+
+// MinPartSize of the multipart upload, except its last part; the limit of S3
+const MinPartSize = 5 << 20
+
+// Multipart is the optional capability of the repository to upload a binary in parts,
+// so an interrupted upload can be resumed from its last uploaded part.
+// The binary is at the path only after the upload is completed.
+type Multipart interface {
+	// StartUpload of the binary to the path; returns the ID of the upload
+	StartUpload(ctx context.Context, path string, attachment *entity.Attachment) (string, error)
+
+	// UploadPart number (from 1) of the upload; the part uploaded again replaces the previous one
+	UploadPart(ctx context.Context, path string, uploadID string, number int, payload io.Reader, size int64) (*Part, error)
+
+	// ListParts uploaded so far, ordered by their number
+	ListParts(ctx context.Context, path string, uploadID string) ([]Part, error)
+
+	// CompleteUpload with the parts ordered by their number
+	CompleteUpload(ctx context.Context, path string, uploadID string, attachment *entity.Attachment, parts []Part) (*Data, error)
+
+	// AbortUpload and discard its uploaded parts
+	AbortUpload(ctx context.Context, path string, uploadID string) error
+}
+
+// Part of a multipart upload
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
@@ -0,0 +1,169 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/blob"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+var _ blob.Multipart = &handler{}
+
+// maxComposeSources of a GCS compose request
+const maxComposeSources = 32
+
+// StartUpload in parts. Every part is a temporary object next to the path, so the upload can be resumed
+// by any server; they are composed to the path when it is completed.
+func (h *handler) StartUpload(ctx context.Context, objectPath string, attachment *entity.Attachment) (string, error) {
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to generate upload ID", err)
+	}
+	return uid.String(), nil
+}
+
+func (h *handler) UploadPart(ctx context.Context, objectPath string, uploadID string, number int, payload io.Reader, size int64) (*blob.Part, error) {
+	bucket := h.gcsClient.Bucket(h.bucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("empty bucket")
+	}
+
+	objWriter := bucket.Object(partPath(objectPath, uploadID, number)).NewWriter(ctx)
+
+	length, err := io.Copy(objWriter, io.LimitReader(payload, size))
+	if err != nil {
+		objWriter.Close()
+		return nil, fmt.Errorf("%w: failed to upload part %v", err, number)
+	}
+	if length != size {
+		objWriter.Close()
+		return nil, fmt.Errorf("part %v is %v bytes, expected %v", number, length, size)
+	}
+
+	err = objWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: error when closing obj writer", err)
+	}
+
+	return &blob.Part{
+		Number: number,
+		ETag:   objWriter.Attrs().Etag,
+		Size:   length,
+	}, nil
+}
+
+func (h *handler) ListParts(ctx context.Context, objectPath string, uploadID string) ([]blob.Part, error) {
+	bucket := h.gcsClient.Bucket(h.bucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("empty bucket")
+	}
+
+	var result []blob.Part
+	it := bucket.Objects(ctx, &storage.Query{Prefix: partPrefix(objectPath, uploadID)})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to list parts", err)
+		}
+
+		number, err := strconv.Atoi(path.Base(attrs.Name))
+		if err != nil {
+			log.Warn().Msgf("unknown object %v in the parts of upload %v", attrs.Name, uploadID)
+			continue
+		}
+
+		result = append(result, blob.Part{
+			Number: number,
+			ETag:   attrs.Etag,
+			Size:   attrs.Size,
+		})
+	}
+
+	slices.SortFunc(result, func(a, b blob.Part) int {
+		return a.Number - b.Number
+	})
+
+	return result, nil
+}
+
+// CompleteUpload composes the parts to the path, at most 32 at a time, then deletes them
+func (h *handler) CompleteUpload(ctx context.Context, objectPath string, uploadID string, attachment *entity.Attachment, parts []blob.Part) (*blob.Data, error) {
+	bucket := h.gcsClient.Bucket(h.bucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("empty bucket")
+	}
+
+	object := bucket.Object(objectPath)
+
+	var size int64
+	for idx := 0; idx < len(parts); {
+		var srcs []*storage.ObjectHandle
+		if idx > 0 {
+			// the composed so far
+			srcs = append(srcs, object)
+		}
+		for ; idx < len(parts) && len(srcs) < maxComposeSources; idx++ {
+			srcs = append(srcs, bucket.Object(partPath(objectPath, uploadID, parts[idx].Number)))
+		}
+
+		composer := object.ComposerFrom(srcs...)
+		composer.ContentType = attachment.ContentType
+		attrs, err := composer.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to compose parts", err)
+		}
+		size = attrs.Size
+	}
+
+	if err := h.AbortUpload(ctx, objectPath, uploadID); err != nil {
+		log.Error().Msgf("failed to delete the parts of completed upload %v: %v", uploadID, err)
+	}
+
+	return &blob.Data{
+		PublicURL:   h.basePublicUrl + "/" + objectPath,
+		Path:        objectPath,
+		ContentType: attachment.ContentType,
+		ContentSize: size,
+	}, nil
+}
+
+// AbortUpload deletes the uploaded parts
+func (h *handler) AbortUpload(ctx context.Context, objectPath string, uploadID string) error {
+	parts, err := h.ListParts(ctx, objectPath, uploadID)
+	if err != nil {
+		return err
+	}
+
+	bucket := h.gcsClient.Bucket(h.bucketName)
+	for _, part := range parts {
+		err := bucket.Object(partPath(objectPath, uploadID, part.Number)).Delete(ctx)
+		if err != nil && err != storage.ErrObjectNotExist {
+			return fmt.Errorf("%w: failed to delete part %v", err, part.Number)
+		}
+	}
+
+	return nil
+}
+
+func partPrefix(objectPath string, uploadID string) string {
+	return strings.Join([]string{objectPath + ".parts", uploadID, ""}, "/")
+}
+
+// partPath of the temporary object of the part
+func partPath(objectPath string, uploadID string, number int) string {
+	return partPrefix(objectPath, uploadID) + fmt.Sprintf("%05d", number)
+}
@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+
+	blob "github.com/desain-gratis/common/delivery/mycontent-api/storage/blob"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+var _ blob.Multipart = &handler{}
+
+// listPartsPageSize is the maximum of S3
+const listPartsPageSize = 1000
+
+// StartUpload the S3 multipart upload
+func (h *handler) StartUpload(ctx context.Context, objectPath string, attachment *entity.Attachment) (string, error) {
+	exists, err := h.client.BucketExists(ctx, h.bucketName)
+	if !exists || err != nil {
+		return "", fmt.Errorf("%w: failure when accessing storage or bucket not exist %v", err, !exists)
+	}
+
+	uploadID, err := h.core().NewMultipartUpload(ctx, h.bucketName, objectPath, minio.PutObjectOptions{
+		ContentType: attachment.ContentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to start multipart upload", err)
+	}
+
+	return uploadID, nil
+}
+
+func (h *handler) UploadPart(ctx context.Context, objectPath string, uploadID string, number int, payload io.Reader, size int64) (*blob.Part, error) {
+	part, err := h.core().PutObjectPart(ctx, h.bucketName, objectPath, uploadID, number, payload, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to put part %v %v", err, number, ctx.Err())
+	}
+
+	return &blob.Part{
+		Number: part.PartNumber,
+		ETag:   part.ETag,
+		Size:   part.Size,
+	}, nil
+}
+
+func (h *handler) ListParts(ctx context.Context, objectPath string, uploadID string) ([]blob.Part, error) {
+	var result []blob.Part
+	marker := 0
+	for {
+		page, err := h.core().ListObjectParts(ctx, h.bucketName, objectPath, uploadID, marker, listPartsPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to list parts", err)
+		}
+
+		for _, part := range page.ObjectParts {
+			result = append(result, blob.Part{
+				Number: part.PartNumber,
+				ETag:   part.ETag,
+				Size:   part.Size,
+			})
+		}
+
+		if !page.IsTruncated {
+			break
+		}
+		marker = page.NextPartNumberMarker
+	}
+
+	return result, nil
+}
+
+func (h *handler) CompleteUpload(ctx context.Context, objectPath string, uploadID string, attachment *entity.Attachment, parts []blob.Part) (*blob.Data, error) {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	var size int64
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{
+			PartNumber: part.Number,
+			ETag:       part.ETag,
+		})
+		size += part.Size
+	}
+
+	_, err := h.core().CompleteMultipartUpload(ctx, h.bucketName, objectPath, uploadID, completeParts, minio.PutObjectOptions{
+		ContentType: attachment.ContentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to complete multipart upload", err)
+	}
+
+	return &blob.Data{
+		PublicURL:   h.basePublicUrl + "/" + objectPath,
+		Path:        objectPath,
+		ContentType: attachment.ContentType,
+		ContentSize: size,
+	}, nil
+}
+
+func (h *handler) AbortUpload(ctx context.Context, objectPath string, uploadID string) error {
+	err := h.core().AbortMultipartUpload(ctx, h.bucketName, objectPath, uploadID)
+	if err != nil {
+		return fmt.Errorf("%w: failed to abort multipart upload", err)
+	}
+	return nil
+}
+
+// core for the low level multipart API
+func (h *handler) core() minio.Core {
+	return minio.Core{Client: h.client}
+}
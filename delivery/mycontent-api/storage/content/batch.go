@@ -0,0 +1,29 @@
+package content
+
+import (
+	"context"
+	"fmt"
+)
+
+// BatchRepository is implemented by the repository that writes a batch in a single transaction: all or nothing
+type BatchRepository interface {
+	// PostBatch the data; the key (namespace, ref IDs, ID) is in each data
+	PostBatch(ctx context.Context, data []Data) ([]Data, error)
+
+	// DeleteBatch the keys (namespace, ref IDs, ID); each of them must exist
+	DeleteBatch(ctx context.Context, keys []Data) ([]Data, error)
+}
+
+// BatchError is the item that fails the whole batch
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch item %v: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/notifier"
 	"github.com/desain-gratis/common/lib/raft"
 	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
@@ -27,6 +29,7 @@ const (
 )
 
 var _ raft.Application = &ContentApp{}
+var _ raft.TableOwner = &ContentApp{}
 
 type QueryMyContent struct {
 	Table     string   `json:"table"`
@@ -47,6 +50,9 @@ type DataWrapper struct {
 	EventID   uint64          `json:"event_id"`
 	Data      json.RawMessage `json:"data,omitempty"` // todo use ref omitempty
 	Meta      json.RawMessage `json:"meta,omitempty"` // omitempty
+
+	// compare-and-set of post, see content.Data
+	ExpectedVersion *uint64 `json:"expected_version,omitempty"`
 }
 
 // happySM to isolate all business logic from the state machine technicality
@@ -55,6 +61,9 @@ type DataWrapper struct {
 type ContentApp struct {
 	state       *state
 	tableConfig map[string]TableConfig
+
+	// guards the state swapped by RecoverFromSnapshot, for Watch
+	mu sync.RWMutex
 }
 
 type TableConfig struct {
@@ -63,6 +72,13 @@ type TableConfig struct {
 	Versioned                  bool
 	VersionedGetLimit          uint32
 	VersionedUseOptimisticLock bool
+
+	// Topic to publish Post & Delete as content.Event, after they are applied. Optional.
+	Topic notifier.Topic
+
+	// EventLog also appends every Post & Delete to an append-only "<name>_event_log" table,
+	// so Watch can resume from an event ID. It is never compacted; same on every replica.
+	EventLog bool
 }
 
 func New(tableConfig ...TableConfig) *ContentApp {
@@ -101,6 +117,16 @@ func (s *ContentApp) Init(ctx context.Context) error {
 		if err != nil {
 			log.Panic().Msgf("failed to execute DDL for table %v (%v): %v", table.Name, table.RefSize, err)
 		}
+
+		if !table.EventLog {
+			continue
+		}
+
+		ddl = getDDLLogType(eventLogTable(table.Name), table.RefSize, table.Versioned)
+		err = conn.Exec(ctx, ddl)
+		if err != nil {
+			log.Panic().Msgf("failed to execute DDL for the event log of table %v (%v): %v", table.Name, table.RefSize, err)
+		}
 	}
 
 	// get metadata
@@ -128,9 +154,23 @@ func (s *ContentApp) Init(ctx context.Context) error {
 		s.state.VersionIndexes = make(map[string]*uint64)
 	}
 
+	s.initEventLogStarts(s.state)
+
 	return nil
 }
 
+// Tables owned by the app, to be included in the raft snapshot
+func (s *ContentApp) Tables(_ context.Context) []string {
+	tables := make([]string, 0, len(s.tableConfig))
+	for name, table := range s.tableConfig {
+		tables = append(tables, name)
+		if table.EventLog {
+			tables = append(tables, eventLogTable(name))
+		}
+	}
+	return tables
+}
+
 func (s *ContentApp) Lookup(ctx context.Context, query interface{}) (interface{}, error) {
 	if query == nil {
 		return nil, fmt.Errorf("empty query")
@@ -140,6 +180,10 @@ func (s *ContentApp) Lookup(ctx context.Context, query interface{}) (interface{}
 	case QueryMyContent:
 		// todo can accept limit (but later)
 		return s.queryMyContent(ctx, q, 0)
+	case ListMyContent:
+		return s.listMyContent(ctx, q)
+	case WatchMyContent:
+		return s.watchMyContent(ctx, q)
 	}
 
 	return nil, errors.New("unsupported query")
@@ -182,6 +226,7 @@ func (s *ContentApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterAp
 		}
 
 		return func() (raft.Result, error) {
+			s.publish(content.EventPost, *result)
 			return raft.Result{Value: 0, Data: encResult}, nil
 		}, nil
 
@@ -198,7 +243,13 @@ func (s *ContentApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterAp
 			}, nil
 		}
 
+		// the event of the deletion itself, rather than of the deleted data
+		event := *result
+		event.EventID = *s.state.EventIndexes[result.Table] - 1
+		event.Data, event.Meta = nil, nil
+
 		return func() (raft.Result, error) {
+			s.publish(content.EventDelete, event)
 			return raft.Result{Value: 0, Data: encResult}, nil
 		}, nil
 
@@ -220,6 +271,30 @@ func (s *ContentApp) Apply(ctx context.Context) error {
 	return raft_runner.SetMetadata(ctx, appName, payload)
 }
 
+// publish the event to the topic of the table, if any
+func (s *ContentApp) publish(eventType content.EventType, payload DataWrapper) {
+	tableCfg, ok := s.tableConfig[payload.Table]
+	if !ok || tableCfg.Topic == nil {
+		return
+	}
+
+	err := tableCfg.Topic.Broadcast(context.Background(), content.Event{
+		Table: payload.Table,
+		Type:  eventType,
+		Data: content.Data{
+			EventID:   payload.EventID,
+			Namespace: payload.Namespace,
+			RefIDs:    payload.RefIDs,
+			ID:        payload.ID,
+			Data:      payload.Data,
+			Meta:      payload.Meta,
+		},
+	})
+	if err != nil {
+		log.Err(err).Msgf("failed to publish %v event of table %v", eventType, payload.Table)
+	}
+}
+
 func (s *ContentApp) subscribe(_ context.Context, payload DataWrapper) (raft.OnAfterApply, error) {
 	tableCfg, ok := s.tableConfig[payload.Table]
 	if !ok {
@@ -350,7 +425,30 @@ func (s *ContentApp) post(ctx context.Context, payload DataWrapper) (*DataWrappe
 		}
 
 		if *versionIdx != *meta.OptimisticLockVersion {
-			return nil, fmt.Errorf("optimistic lock version mismatch, expected %v got %v", *versionIdx, *meta.OptimisticLockVersion)
+			return nil, &content.ConflictError{Expected: *meta.OptimisticLockVersion, Current: *versionIdx, NextVersion: true}
+		}
+	}
+
+	// For the existing data, compare-and-set with the version of the current data
+	if payload.ExpectedVersion != nil {
+		var current *content.Data
+		if payload.ID != "" {
+			prevData, err := s.queryMyContent(ctx, QueryMyContent{
+				Table:     tableCfg.Name,
+				Namespace: payload.Namespace,
+				RefIDs:    payload.RefIDs,
+				ID:        payload.ID,
+			}, 1)
+			if err != nil {
+				return nil, err
+			}
+			for d := range prevData {
+				current = d
+			}
+		}
+
+		if err := content.CheckVersion(current, content.Data{ExpectedVersion: payload.ExpectedVersion}); err != nil {
+			return nil, err
 		}
 	}
 
@@ -400,6 +498,13 @@ func (s *ContentApp) post(ctx context.Context, payload DataWrapper) (*DataWrappe
 		return nil, fmt.Errorf("error writing to table: %v", err)
 	}
 
+	err = s.appendEventLog(ctx, tableCfg, cols, args, tmplt)
+	if err != nil {
+		return nil, err
+	}
+
+	// the event ID as written, same as Get
+	writtenEventID := *eventIdx
 	*s.state.EventIndexes[tableCfg.Name]++
 
 	if increment { // after exec todo: refactormaxxing
@@ -417,7 +522,8 @@ func (s *ContentApp) post(ctx context.Context, payload DataWrapper) (*DataWrappe
 	}
 
 	finalResult.ID = fID
-	finalResult.EventID = *eventIdx
+	finalResult.EventID = writtenEventID
+	finalResult.ExpectedVersion = nil
 
 	return &finalResult, nil
 }
@@ -465,6 +571,10 @@ func (s *ContentApp) delete(ctx context.Context, payload DataWrapper) (*DataWrap
 		return nil, errors.New("not found")
 	}
 
+	if err := content.CheckVersion(toDelete, content.Data{ExpectedVersion: payload.ExpectedVersion}); err != nil {
+		return nil, err
+	}
+
 	cols, args, tmplt := s.preparePost(
 		tableCfg,
 		*eventIdx,
@@ -484,50 +594,53 @@ func (s *ContentApp) delete(ctx context.Context, payload DataWrapper) (*DataWrap
 		return nil, fmt.Errorf("error writing to table: %v %w", tableCfg.Name, err)
 	}
 
+	err = s.appendEventLog(ctx, tableCfg, cols, args, tmplt)
+	if err != nil {
+		return nil, err
+	}
+
 	*s.state.EventIndexes[tableCfg.Name]++
 	// *s.state.VersionIndexes[versionKey]++
 
 	// which is the same..
 	payload.ID = toDelete.ID
 	payload.EventID = toDelete.EventID
+	payload.ExpectedVersion = nil
 
 	return &payload, nil
 }
 
-// getDDLLogType sort key only "event_id"
-func getDDLLogType(tableName string, refSize int) string {
+// getDDLLogType sort key only "event_id"; the same columns as getDDL, but nothing is merged away
+func getDDLLogType(tableName string, refSize int, incrementalID bool) string {
 	buf := bytes.NewBuffer(make([]byte, 0, 100))
 
 	_, err := buf.WriteString(`CREATE TABLE IF NOT EXISTS ` + tableName + ` (
 		event_id UInt64,
+		namespace String,
 	`)
 	if err != nil {
-		log.Panic().Msgf("error write string buffer in getDDL: %v", err)
+		log.Panic().Msgf("error write string buffer in getDDLLogType: %v", err)
 	}
 
-	keys := []string{"namespace String"}
-
 	for i := 0; i < refSize; i++ {
-		refID := `ref_id_` + strconv.Itoa(i+1)
-		buf.WriteString(refID + ` String, ` + "\n")
-		keys = append(keys, refID)
+		buf.WriteString(`		ref_id_` + strconv.Itoa(i+1) + " String,\n")
 	}
 
-	keys = append(keys, "id")
+	if !incrementalID {
+		buf.WriteString(`		id String,`)
+	} else {
+		buf.WriteString(`		id UInt64,`)
+	}
 
 	_, err = buf.WriteString(`
-		` + strings.Join(keys, ", \n") + `
 		data String,
 		meta String,
 		server_time DateTime,
-		is_deleted UInt8,
-		) ENGINE = ReplacingMergeTree ORDER BY (` + strings.Join(keys, ",") + `);
-	`) // -- consider deletion as a business event.
-	// -- consider also using ordinary merge tree, but uses  namespace, ref IDs + ref for KV access
-	// OR, we can create separate table just for the head of the KV.
-	// because right now I focused on the events log
+		is_deleted UInt8
+		) ENGINE = MergeTree ORDER BY event_id;
+	`)
 	if err != nil {
-		log.Panic().Msgf("error write string buffer in getDDL: %v", err)
+		log.Panic().Msgf("error write string buffer in getDDLLogType: %v", err)
 	}
 
 	return buf.String()
@@ -642,8 +755,37 @@ func (s *ContentApp) preparePost(tableConfig TableConfig, eventID uint64, namesp
 // )
 // WHERE t.3 = 0;
 func (s *ContentApp) prepareGet(tableConfig TableConfig, _ QueryMyContent, namespace string, refIDs []string, ID any, limit int) (string, []any, func() (*scanResult, []any), error) {
+	buf, whereArgs, _, scanFn, err := s.prepareLatest(tableConfig, namespace, refIDs, ID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	// Technically, this is still Key-Value store, so this is "like" a "hack" (not necessarily actual hack)
+	// Querying without a full ref ID will not be defined clearly.
+	if tableConfig.Versioned {
+		_, err = buf.WriteString(` ORDER BY id DESC `) // (previously was event_id, just to make it consistent now is ID)
+
+		finalLimit := 20
+
+		if tableConfig.VersionedGetLimit > 0 {
+			finalLimit = int(tableConfig.VersionedGetLimit)
+		}
+
+		if limit > 0 {
+			finalLimit = int(limit)
+		}
+
+		_, err = fmt.Fprintf(buf, `	LIMIT %v`, finalLimit)
+	}
+
+	return buf.String(), whereArgs, scanFn, nil
+}
+
+// prepareLatest selects the latest, not deleted, version of each key; the outer query can be extended further.
+// It returns the key columns as well.
+func (s *ContentApp) prepareLatest(tableConfig TableConfig, namespace string, refIDs []string, ID any) (*bytes.Buffer, []any, []string, func() (*scanResult, []any), error) {
 	if len(refIDs) > tableConfig.RefSize {
-		return "", nil, nil, fmt.Errorf(
+		return nil, nil, nil, nil, fmt.Errorf(
 			"%w: ref size is greater than expected (got %v, expected %v)", content.ErrInvalidKey, len(refIDs), tableConfig.RefSize)
 	}
 
@@ -659,7 +801,7 @@ func (s *ContentApp) prepareGet(tableConfig TableConfig, _ QueryMyContent, names
 
 	whereQ, whereArgs, err := s.prepareWhereQuery(tableConfig.RefSize, namespace, refIDs, ID)
 	if err != nil {
-		return "", nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	keyCols = append(keyCols, "namespace") // event id is no longer a key
@@ -686,28 +828,10 @@ func (s *ContentApp) prepareGet(tableConfig TableConfig, _ QueryMyContent, names
 			`WHERE t.3 = 0`,
 	)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to build query: %w", err)
-	}
-
-	// Technically, this is still Key-Value store, so this is "like" a "hack" (not necessarily actual hack)
-	// Querying without a full ref ID will not be defined clearly.
-	if tableConfig.Versioned {
-		_, err = buf.WriteString(` ORDER BY id DESC `) // (previously was event_id, just to make it consistent now is ID)
-
-		finalLimit := 20
-
-		if tableConfig.VersionedGetLimit > 0 {
-			finalLimit = int(tableConfig.VersionedGetLimit)
-		}
-
-		if limit > 0 {
-			finalLimit = int(limit)
-		}
-
-		_, err = fmt.Fprintf(buf, `	LIMIT %v`, finalLimit)
+		return nil, nil, nil, nil, fmt.Errorf("failed to build query: %w", err)
 	}
 
-	return buf.String(), whereArgs, func() (*scanResult, []any) {
+	return buf, whereArgs, keyCols, func() (*scanResult, []any) {
 		sr := &scanResult{
 			keys: make([]string, len(keyCols)-1), // keyCols without ID
 		}
@@ -0,0 +1,146 @@
+package clickhouseraft
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+// ListMyContent is the Lookup query of List
+type ListMyContent struct {
+	Table     string        `json:"table"`
+	Namespace string        `json:"namespace"`
+	RefIDs    []string      `json:"ref_ids"`
+	Query     content.Query `json:"query"`
+}
+
+func (s *ContentApp) listMyContent(ctx context.Context, query ListMyContent) (content.Page, error) {
+	tableCfg, ok := s.tableConfig[query.Table]
+	if !ok {
+		return content.Page{}, fmt.Errorf("table not found: %v", query.Table)
+	}
+
+	q, args, scanFn, err := s.prepareList(tableCfg, query.Namespace, query.RefIDs, query.Query)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	conn := raft_runner.GetClickhouseConnection(ctx)
+	rows, err := conn.Query(ctx, q, args...)
+	if err != nil {
+		return content.Page{}, err
+	}
+	defer rows.Close()
+
+	result := make([]content.Data, 0)
+	for rows.Next() {
+		scanResult, scanReceiver := scanFn()
+
+		err := rows.Scan(scanReceiver...)
+		if err != nil {
+			slog.Error(
+				"failed to scan row", slog.String("err", err.Error()),
+				slog.String("components", "mycontent.storage.clickhouse-raft.list"))
+			continue
+		}
+
+		result = append(result, *s.convertScanResult(scanResult, tableCfg.Versioned))
+	}
+
+	if err := rows.Err(); err != nil {
+		return content.Page{}, err
+	}
+
+	return query.Query.NewPage(result), nil
+}
+
+// prepareList filters, orders & paginates the latest version of each key
+func (s *ContentApp) prepareList(tableConfig TableConfig, namespace string, refIDs []string, q content.Query) (string, []any, func() (*scanResult, []any), error) {
+	cursor, err := q.Validate()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	buf, args, keyCols, scanFn, err := s.prepareLatest(tableConfig, namespace, refIDs, "")
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	// compares the raw JSON, so "5" does not match 5
+	for _, f := range q.Filters {
+		buf.WriteString(` AND JSONExtractRaw(t.1, ?) = ?`)
+		args = append(args, f.Field, string(f.Value))
+	}
+
+	orderCols := keyCols
+	switch q.OrderBy {
+	case content.OrderByCreatedAt:
+		orderCols = append([]string{`JSONExtractString(t.2, 'created_at')`}, keyCols...)
+	case content.OrderByEventID:
+		orderCols = append([]string{`t.4`}, keyCols...)
+	}
+
+	if cursor != nil {
+		if len(cursor.Key) != len(keyCols) {
+			return "", nil, nil, fmt.Errorf("%w: cursor is for a different table", content.ErrInvalidKey)
+		}
+
+		var bound []any
+		switch q.OrderBy {
+		case content.OrderByCreatedAt:
+			bound = append(bound, cursor.Sort)
+		case content.OrderByEventID:
+			eventID, _ := strconv.ParseUint(cursor.Sort, 10, 64)
+			bound = append(bound, eventID)
+		}
+		for _, key := range cursor.Key[:len(cursor.Key)-1] {
+			bound = append(bound, key)
+		}
+
+		id := cursor.Key[len(cursor.Key)-1]
+		if tableConfig.Versioned {
+			version, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("%w: invalid cursor", content.ErrInvalidKey)
+			}
+			bound = append(bound, version)
+		} else {
+			bound = append(bound, id)
+		}
+
+		op := ` > `
+		if q.Desc {
+			op = ` < `
+		}
+
+		tmplt := make([]string, len(bound))
+		for i := range tmplt {
+			tmplt[i] = `?`
+		}
+
+		buf.WriteString(` AND (` + strings.Join(orderCols, ", ") + `)` + op + `(` + strings.Join(tmplt, ", ") + `)`)
+		args = append(args, bound...)
+	}
+
+	direction := ` ASC`
+	if q.Desc {
+		direction = ` DESC`
+	}
+
+	order := make([]string, len(orderCols))
+	for i, col := range orderCols {
+		order[i] = col + direction
+	}
+	buf.WriteString(` ORDER BY ` + strings.Join(order, ", "))
+
+	if q.Limit > 0 {
+		fmt.Fprintf(buf, ` LIMIT %v`, q.Limit+1)
+	}
+
+	return buf.String(), args, scanFn, nil
+}
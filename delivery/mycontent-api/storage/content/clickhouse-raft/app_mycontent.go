@@ -23,6 +23,8 @@ func (r *repository) Post(ctx context.Context, namespace string, refIDs []string
 		ID:        ID,
 		Data:      data.Data, // raw json
 		Meta:      data.Meta,
+
+		ExpectedVersion: data.ExpectedVersion,
 	})
 	if err != nil {
 		return content.Data{}, err
@@ -70,6 +72,8 @@ func (r *repository) Delete(ctx context.Context, namespace string, refIDs []stri
 		ID:        ID,
 		Data:      placeholder,
 		Meta:      placeholder,
+
+		ExpectedVersion: content.GetExpectedVersion(ctx),
 	})
 	if err != nil {
 		return content.Data{}, err
@@ -109,3 +113,12 @@ func (r *repository) Stream(ctx context.Context, namespace string, refIDs []stri
 
 	return result, nil
 }
+
+func (r *repository) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	return r.base.listMyContent(ctx, ListMyContent{
+		Table:     r.tableConfig.Name,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		Query:     q,
+	})
+}
@@ -0,0 +1,77 @@
+package clickhouseraft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+var _ raft.Snapshotter = &ContentApp{}
+
+type contentSnapshot struct {
+	state  json.RawMessage
+	tables raft_runner.FrozenTables
+}
+
+// PrepareSnapshot captures the event & version indexes and freeze all the mycontent tables
+func (s *ContentApp) PrepareSnapshot(ctx context.Context) (any, error) {
+	state, err := json.Marshal(s.state)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := raft_runner.FreezeTables(ctx, s.Tables(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentSnapshot{
+		state:  state,
+		tables: tables,
+	}, nil
+}
+
+func (s *ContentApp) SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error {
+	snap, ok := snapshot.(*contentSnapshot)
+	if !ok {
+		return fmt.Errorf("unexpected snapshot context: %T", snapshot)
+	}
+	defer snap.tables.Drop(ctx)
+
+	if err := raft_runner.WriteValue(w, snap.state); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return raft_runner.WriteTables(ctx, w, snap.tables, done)
+}
+
+func (s *ContentApp) RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error {
+	recovered := &state{}
+	if err := raft_runner.ReadValue(r, recovered); err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+
+	if recovered.EventIndexes == nil {
+		recovered.EventIndexes = make(map[string]*uint64)
+	}
+
+	if recovered.VersionIndexes == nil {
+		recovered.VersionIndexes = make(map[string]*uint64)
+	}
+
+	s.initEventLogStarts(recovered)
+
+	if _, err := raft_runner.ReadTables(ctx, r, done); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.state = recovered
+	s.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package clickhouseraft
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+// WatchMyContent is the Lookup query of the Watch backfill
+type WatchMyContent struct {
+	Table       string   `json:"table"`
+	Namespace   string   `json:"namespace"`
+	RefIDs      []string `json:"ref_ids"`
+	FromEventID uint64   `json:"from_event_id"`
+}
+
+type WatchMyContentResponse <-chan content.Event
+
+func (s *ContentApp) watchMyContent(ctx context.Context, query WatchMyContent) (WatchMyContentResponse, error) {
+	tableCfg, ok := s.tableConfig[query.Table]
+	if !ok {
+		return nil, fmt.Errorf("table not found: %v", query.Table)
+	}
+
+	if !tableCfg.EventLog {
+		return nil, fmt.Errorf("%w: table %v has no event log to resume from", content.ErrWatchNotSupported, tableCfg.Name)
+	}
+
+	// the events before the log started were never logged
+	if start := s.eventLogStart(tableCfg.Name); query.FromEventID < start {
+		return nil, fmt.Errorf("%w: the event log of table %v starts at %v, got %v",
+			content.ErrWatchExpired, tableCfg.Name, start, query.FromEventID)
+	}
+
+	q, args, err := s.prepareWatch(tableCfg, query.Namespace, query.RefIDs, query.FromEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := raft_runner.GetClickhouseConnection(ctx)
+	rows, err := conn.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan content.Event)
+
+	go func() {
+		defer close(out)
+		defer rows.Close()
+
+		for rows.Next() {
+			sr := &scanResult{keys: make([]string, 1+tableCfg.RefSize)}
+
+			scanAny := make([]any, 0, len(sr.keys)+5)
+			scanAny = append(scanAny, &sr.eventID)
+			for i := range sr.keys {
+				scanAny = append(scanAny, &sr.keys[i])
+			}
+			if tableCfg.Versioned {
+				scanAny = append(scanAny, &sr.idVersioned)
+			} else {
+				scanAny = append(scanAny, &sr.id)
+			}
+
+			var isDeleted uint8
+			scanAny = append(scanAny, &sr.data, &sr.meta, &isDeleted)
+
+			err := rows.Scan(scanAny...)
+			if err != nil {
+				slog.Error(
+					"failed to scan row", slog.String("err", err.Error()),
+					slog.String("components", "mycontent.storage.clickhouse-raft.watch"))
+				continue
+			}
+
+			event := content.Event{
+				Table: tableCfg.Name,
+				Type:  content.EventPost,
+				Data:  *s.convertScanResult(sr, tableCfg.Versioned),
+			}
+			if isDeleted > 0 {
+				event.Type = content.EventDelete
+				event.Data.Data, event.Data.Meta = nil, nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- event:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// prepareWatch selects the events logged since the event ID, in write order
+func (s *ContentApp) prepareWatch(tableConfig TableConfig, namespace string, refIDs []string, fromEventID uint64) (string, []any, error) {
+	if len(refIDs) > tableConfig.RefSize {
+		return "", nil, fmt.Errorf(
+			"%w: ref size is greater than expected (got %v, expected %v)", content.ErrInvalidKey, len(refIDs), tableConfig.RefSize)
+	}
+
+	whereQ, args, err := s.prepareWhereQuery(tableConfig.RefSize, namespace, refIDs, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cols := []string{"event_id", "namespace"}
+	for i := range tableConfig.RefSize {
+		cols = append(cols, `ref_id_`+strconv.Itoa(i+1))
+	}
+	cols = append(cols, "id", "data", "meta", "is_deleted")
+
+	q := `SELECT ` + strings.Join(cols, ", ") +
+		` FROM "` + eventLogTable(tableConfig.Name) + `"` +
+		whereQ + ` AND event_id >= ?` +
+		` ORDER BY event_id ASC`
+
+	return q, append(args, fromEventID), nil
+}
+
+func eventLogTable(tableName string) string {
+	return tableName + "_event_log"
+}
+
+// appendEventLog writes the same row as the table to its event log, if enabled
+func (s *ContentApp) appendEventLog(ctx context.Context, tableConfig TableConfig, cols []string, args []any, tmplt []string) error {
+	if !tableConfig.EventLog {
+		return nil
+	}
+
+	q := `INSERT INTO ` + eventLogTable(tableConfig.Name) + `(` + strings.Join(cols, ",") + `) 
+	VALUES (` + strings.Join(tmplt, ",") + `);`
+
+	err := raft_runner.GetClickhouseConnection(ctx).Exec(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("error writing to the event log of table: %v %w", tableConfig.Name, err)
+	}
+
+	return nil
+}
+
+// initEventLogStarts of the tables whose log is enabled just now, at their next event
+func (s *ContentApp) initEventLogStarts(st *state) {
+	if st.EventLogStarts == nil {
+		st.EventLogStarts = make(map[string]uint64)
+	}
+
+	for name, table := range s.tableConfig {
+		if _, ok := st.EventLogStarts[name]; ok || !table.EventLog {
+			continue
+		}
+
+		var start uint64
+		if eventIdx, ok := st.EventIndexes[name]; ok {
+			start = *eventIdx
+		}
+		st.EventLogStarts[name] = start
+	}
+}
+
+// eventLogStart is the first event ID in the event log of the table
+func (s *ContentApp) eventLogStart(tableName string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.state.EventLogStarts[tableName]
+}
@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/desain-gratis/common/lib/notifier"
+	notifier_impl "github.com/desain-gratis/common/lib/notifier/impl"
+	"github.com/desain-gratis/common/lib/raft"
 	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 	"github.com/lni/dragonboat/v4"
 	"github.com/lni/dragonboat/v4/client"
@@ -16,6 +19,7 @@ import (
 )
 
 var _ content.Repository = &mycontentClient{}
+var _ content.Watcher = &mycontentClient{}
 
 var (
 	ErrNotReady = errors.New("raft not ready")
@@ -26,6 +30,11 @@ type mycontentClient struct {
 	DHost     *dragonboat.NodeHost
 	Sess      *client.Session
 	ReplicaID uint64
+	encoding  raft_runner.CommandEncoding
+
+	consistency content.ReadConsistency
+	sessions    *raft_runner.SessionPool
+	topic       notifier.Topic
 }
 
 func NewStorageClient(ctx context.Context, tableName string) *mycontentClient {
@@ -35,9 +44,32 @@ func NewStorageClient(ctx context.Context, tableName string) *mycontentClient {
 		DHost:     raftCtx.DHost,
 		Sess:      raftCtx.DHost.GetNoOPSession(raftCtx.ShardID),
 		ReplicaID: raftCtx.ReplicaID,
+		encoding:  raftCtx.CommandEncoding,
+
+		consistency: content.ReadLinearizable,
 	}
 }
 
+// WithReadConsistency sets the default read consistency of Get & Stream.
+// It can be overridden per request with content.WithReadConsistency.
+func (c *mycontentClient) WithReadConsistency(consistency content.ReadConsistency) *mycontentClient {
+	c.consistency = consistency
+	return c
+}
+
+// WithSessionPool proposes Post & Delete with client sessions, so they can be retried safely.
+// The pool can be shared by the clients of the same replica; closing it is up to the caller.
+func (c *mycontentClient) WithSessionPool(sessions *raft_runner.SessionPool) *mycontentClient {
+	c.sessions = sessions
+	return c
+}
+
+// WithTopic enables Watch; it is the Topic of the table config in this replica.
+func (c *mycontentClient) WithTopic(topic notifier.Topic) *mycontentClient {
+	c.topic = topic
+	return c
+}
+
 func (c *mycontentClient) Post(ctx context.Context, namespace string, refIDs []string, ID string, data content.Data) (content.Data, error) {
 	var validate map[string]any
 	err := json.Unmarshal(data.Data, &validate)
@@ -53,20 +85,16 @@ func (c *mycontentClient) Post(ctx context.Context, namespace string, refIDs []s
 	}
 
 	// forward one more layer to raft
-	wrap := map[string]any{
-		"command": "gratis.desain.mycontent.post",
-		"value": DataWrapper{
-			Table:     c.tableName,
-			Namespace: namespace,
-			RefIDs:    refIDs,
-			ID:        ID,
-			Data:      data.Data, // raw json
-			Meta:      data.Meta,
-		},
-		"replica_id": c.ReplicaID,
-	}
-
-	resp, err := c.publishToRaft(ctx, wrap)
+	resp, err := c.publishToRaft(ctx, "gratis.desain.mycontent.post", DataWrapper{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+		Data:      data.Data, // raw json
+		Meta:      data.Meta,
+
+		ExpectedVersion: data.ExpectedVersion,
+	})
 	if err != nil {
 		return content.Data{}, err
 	}
@@ -117,19 +145,16 @@ func (c *mycontentClient) Get(ctx context.Context, namespace string, refIDs []st
 func (c *mycontentClient) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (content.Data, error) {
 	placeholder := json.RawMessage("{}")
 
-	wrap := map[string]any{
-		"command": "gratis.desain.mycontent.delete",
-		"value": DataWrapper{
-			Table:     c.tableName,
-			Namespace: namespace,
-			RefIDs:    refIDs,
-			ID:        ID,
-			Data:      placeholder,
-			Meta:      placeholder,
-		},
-	}
+	resp, err := c.publishToRaft(ctx, "gratis.desain.mycontent.delete", DataWrapper{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+		Data:      placeholder,
+		Meta:      placeholder,
 
-	resp, err := c.publishToRaft(ctx, wrap)
+		ExpectedVersion: content.GetExpectedVersion(ctx),
+	})
 	// TODO: find better way to parse data back and forth & error handling between client and raft app
 	if err != nil {
 		return content.Data{}, fmt.Errorf("failed to publish delete message to raft: %w", err)
@@ -180,8 +205,144 @@ func (c *mycontentClient) Stream(ctx context.Context, namespace string, refIDs [
 	return result, nil
 }
 
-func (c *mycontentClient) publishToRaft(ctx context.Context, msg any) ([]byte, error) {
-	data, err := json.Marshal(msg)
+// List data, filtered & paginated by the replica
+func (c *mycontentClient) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	resp, err := c.queryLocal(ctx, ListMyContent{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		Query:     q,
+	})
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	page, ok := resp.(content.Page)
+	if !ok {
+		return content.Page{}, fmt.Errorf("server error: not a page: %T %v", resp, resp)
+	}
+
+	return page, nil
+}
+
+// Watch subscribes to the topic of this replica first, so nothing is missed between the backfill & the live events
+func (c *mycontentClient) Watch(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan content.Event, error) {
+	if c.topic == nil {
+		return nil, fmt.Errorf("%w: no topic for table %v", content.ErrWatchNotSupported, c.tableName)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	subs, err := c.topic.Subscribe(ctx, notifier_impl.NewStandardSubscriber(func(msg any) bool {
+		event, ok := msg.(content.Event)
+		return !ok || event.Table != c.tableName || !event.Match(namespace, refIDs)
+	}))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	subs.Start()
+	live := subs.Listen()
+
+	var backfill WatchMyContentResponse
+	if fromEventID != nil {
+		resp, err := c.queryLocal(ctx, WatchMyContent{
+			Table:       c.tableName,
+			Namespace:   namespace,
+			RefIDs:      refIDs,
+			FromEventID: *fromEventID,
+		})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		var ok bool
+		backfill, ok = resp.(WatchMyContentResponse)
+		if !ok {
+			cancel()
+			return nil, fmt.Errorf("server error: not watch my content response: %T %v", resp, resp)
+		}
+	}
+
+	out := make(chan content.Event)
+
+	go func() {
+		defer cancel()
+		defer close(out)
+
+		handOff(ctx, fromEventID, backfill, live, out)
+	}()
+
+	return out, nil
+}
+
+// handOff sends the backfill then the live events, skipping the live events already backfilled.
+// The rest of the backfill is drained, so its query is not left blocked.
+func handOff(ctx context.Context, fromEventID *uint64, backfill <-chan content.Event, live <-chan any, out chan<- content.Event) {
+	// the live events can overlap with the backfill
+	var next uint64
+	if fromEventID != nil {
+		next = *fromEventID
+	}
+	hasNext := fromEventID != nil
+
+	send := func(event content.Event) bool {
+		if hasNext && event.Data.EventID < next {
+			return true
+		}
+		next, hasNext = event.Data.EventID+1, true
+
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- event:
+			return true
+		}
+	}
+
+	if backfill != nil {
+		defer func() {
+			for range backfill {
+			}
+		}()
+
+		for event := range backfill {
+			if !send(event) {
+				return
+			}
+		}
+	}
+
+	for msg := range live {
+		if !send(msg.(content.Event)) {
+			return
+		}
+	}
+}
+
+func (c *mycontentClient) publishToRaft(ctx context.Context, command raft.Command, value DataWrapper) ([]byte, error) {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal msg to raft: %w", err)
+	}
+
+	if c.sessions != nil {
+		res, err := c.sessions.Propose(ctx, command, payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w (%w)", err, ErrNotReady)
+		}
+		if res.Value > 0 {
+			return nil, parseRaftError(res.Data)
+		}
+		return res.Data, nil
+	}
+
+	data, err := raft_runner.EncodeCommand(c.encoding, raft_runner.Command{
+		Command:   command,
+		Value:     payload,
+		ReplicaID: &c.ReplicaID,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal msg to raft: %w (%v)", err, string(data))
 	}
@@ -210,32 +371,21 @@ func (c *mycontentClient) publishToRaft(ctx context.Context, msg any) ([]byte, e
 	}
 
 	if res.Value > 0 {
-		return nil, fmt.Errorf("got error from raft: '%v'", string(res.Data))
+		return nil, parseRaftError(res.Data)
 	}
 
 	return res.Data, nil
 }
 
-func (c *mycontentClient) queryLocal(ctx context.Context, msg any) (any, error) {
-	var res any
-	var errg error
-	for range 3 {
-		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		tmpRes, err := c.DHost.SyncRead(ctx, c.Sess.ShardID, msg)
-		if err == nil {
-			res = tmpRes
-			errg = nil
-			cancel()
-			break
-		}
-		errg = err
-		cancel()
-		if errors.Is(err, content.ErrInvalidKey) || errors.Is(err, content.ErrNotFound) {
-			return nil, err
-		}
-
-		time.Sleep(500 * time.Millisecond)
+// parseRaftError brings back the conflict error, since only the message goes through raft
+func parseRaftError(msg []byte) error {
+	if conflict, ok := content.ParseConflictError(string(msg)); ok {
+		return fmt.Errorf("got error from raft: %w", conflict)
 	}
 
-	return res, errg
+	return fmt.Errorf("got error from raft: '%v'", string(msg))
+}
+
+func (c *mycontentClient) queryLocal(ctx context.Context, msg any) (any, error) {
+	return raft_runner.ConsistentRead(ctx, c.DHost, c.Sess.ShardID, c.ReplicaID, c.consistency, msg)
 }
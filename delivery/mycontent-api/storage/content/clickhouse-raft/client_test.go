@@ -0,0 +1,119 @@
+package clickhouseraft
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+func events(ids ...uint64) []content.Event {
+	result := make([]content.Event, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, content.Event{Type: content.EventPost, Data: content.Data{EventID: id}})
+	}
+	return result
+}
+
+func eventIDs(events <-chan content.Event) []uint64 {
+	var ids []uint64
+	for event := range events {
+		ids = append(ids, event.Data.EventID)
+	}
+	return ids
+}
+
+func TestHandOff(t *testing.T) {
+	from := uint64(5)
+
+	tests := []struct {
+		name        string
+		fromEventID *uint64
+		backfill    []content.Event
+		live        []content.Event
+		want        []uint64
+	}{
+		{
+			name:        "backfill then live, overlap skipped",
+			fromEventID: &from,
+			backfill:    events(5, 6, 7),
+			live:        events(6, 7, 8, 9),
+			want:        []uint64{5, 6, 7, 8, 9},
+		},
+		{
+			name:        "live events older than from",
+			fromEventID: &from,
+			live:        events(3, 4, 5, 6),
+			want:        []uint64{5, 6},
+		},
+		{
+			name: "live only",
+			live: events(1, 2),
+			want: []uint64{1, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backfill chan content.Event
+			if tt.fromEventID != nil {
+				backfill = make(chan content.Event)
+				go func() {
+					defer close(backfill)
+					for _, event := range tt.backfill {
+						backfill <- event
+					}
+				}()
+			}
+
+			live := make(chan any, len(tt.live))
+			for _, event := range tt.live {
+				live <- event
+			}
+			close(live)
+
+			out := make(chan content.Event)
+			go func() {
+				defer close(out)
+				handOff(context.Background(), tt.fromEventID, backfill, live, out)
+			}()
+
+			if got := eventIDs(out); !slices.Equal(got, tt.want) {
+				t.Fatalf("got events %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandOffCancel(t *testing.T) {
+	from := uint64(0)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	backfill := make(chan content.Event)
+	backfillDone := make(chan struct{})
+	go func() {
+		defer close(backfillDone)
+		defer close(backfill)
+		for _, event := range events(0, 1, 2, 3) {
+			backfill <- event
+		}
+	}()
+
+	out := make(chan content.Event)
+	go handOff(ctx, &from, backfill, make(chan any), out)
+
+	if event := <-out; event.Data.EventID != 0 {
+		t.Fatalf("expected the first event, got %v", event.Data.EventID)
+	}
+
+	// the watcher is gone before the end of the backfill
+	cancel()
+
+	select {
+	case <-backfillDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the backfill is left blocked after the watch is cancelled")
+	}
+}
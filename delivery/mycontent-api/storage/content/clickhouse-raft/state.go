@@ -3,4 +3,7 @@ package clickhouseraft
 type state struct {
 	EventIndexes   map[string]*uint64 `json:"event_indexes"`
 	VersionIndexes map[string]*uint64 `json:"version_indexes"`
+
+	// EventLogStarts is the first event ID in the event log of the table, set when the log is enabled
+	EventLogStarts map[string]uint64 `json:"event_log_starts,omitempty"`
 }
@@ -111,6 +111,11 @@ func (h *handler) Post(ctx context.Context, namespace string, refIDs []string, I
 		input.Meta = []byte(`{}`)
 	}
 
+	// clickhouse has no transaction, so a concurrent write can pass the same check
+	if input.ExpectedVersion != nil {
+		return content.Data{}, fmt.Errorf("%w by clickhouse", content.ErrCompareAndSetNotSupported)
+	}
+
 	id, cols, args, tmplt := h.preparePost(namespace, refIDs, ID, string(input.Data), string(input.Meta))
 
 	q := `INSERT INTO ` + h.tableName + `(` + strings.Join(cols, ",") + `) 
@@ -133,6 +138,11 @@ func (h *handler) Delete(ctx context.Context, namespace string, refIDs []string,
 		return content.Data{}, fmt.Errorf("%w: incomplete reference", content.ErrInvalidKey)
 	}
 
+	// the same as Post
+	if content.GetExpectedVersion(ctx) != nil {
+		return content.Data{}, fmt.Errorf("%w by clickhouse", content.ErrCompareAndSetNotSupported)
+	}
+
 	q, args, err := h.prepareGet(namespace, refIDs, ID)
 	if err != nil {
 		return content.Data{}, err
@@ -246,6 +256,43 @@ func (h *handler) Stream(ctx context.Context, namespace string, refIDs []string,
 	return output, nil
 }
 
+// List filters, orders & paginates in clickhouse. The table has no event ID, so OrderByEventID is not supported.
+func (h *handler) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	query, args, err := h.prepareList(namespace, refIDs, q)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	rows, err := h.db.Query(ctx, query, args...)
+	if err != nil {
+		slog.Error(
+			"failed to do query", slog.String("err", err.Error()),
+			slog.String("components", "mycontent.storage.clickhouse.list"))
+		return content.Page{}, err
+	}
+	defer rows.Close()
+
+	resp := make([]content.Data, 0)
+	for rows.Next() {
+		result, dest := h.allocateResultDst(true, true)
+		err := rows.Scan(dest...)
+		if err != nil {
+			slog.Error(
+				"failed to scan row", slog.String("err", err.Error()),
+				slog.String("components", "mycontent.storage.clickhouse.list"))
+			continue
+		}
+
+		resp = append(resp, *h.convertGetData(result))
+	}
+
+	if err := rows.Err(); err != nil {
+		return content.Page{}, err
+	}
+
+	return q.NewPage(resp), nil
+}
+
 func (h *handler) allocateResultDst(withData, withMeta bool) ([]string, []any) {
 	c := 0
 	if withData {
@@ -324,6 +371,85 @@ func (h *handler) prepareGet(namespace string, refIDs []string, ID string) (stri
 	return buf.String(), whereArgs, nil
 }
 
+func (h *handler) prepareList(namespace string, refIDs []string, q content.Query) (string, []any, error) {
+	cursor, err := q.Validate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if q.OrderBy == content.OrderByEventID {
+		return "", nil, fmt.Errorf("%w: order by event_id is not supported", content.ErrInvalidKey)
+	}
+
+	if len(refIDs) > h.refSize {
+		return "", nil, fmt.Errorf(
+			"%w: ref size is greater than expected (got %v, expected %v)", content.ErrInvalidKey, len(refIDs), h.refSize)
+	}
+
+	whereQ, args, err := h.prepareWhereQuery(namespace, refIDs, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 100))
+	buf.WriteString(`SELECT ` + strings.Join(append(h.keyCols, "data", "meta"), ",") + ` FROM "` + h.tableName + `" FINAL ` + whereQ)
+
+	// compares the raw JSON, so "5" does not match 5
+	for _, f := range q.Filters {
+		buf.WriteString(` AND JSONExtractRaw(data, ?) = ?`)
+		args = append(args, f.Field, string(f.Value))
+	}
+
+	orderCols := h.keyCols
+	if q.OrderBy == content.OrderByCreatedAt {
+		orderCols = append([]string{`JSONExtractString(meta, 'created_at')`}, h.keyCols...)
+	}
+
+	if cursor != nil {
+		if len(cursor.Key) != len(h.keyCols) {
+			return "", nil, fmt.Errorf("%w: cursor is for a different table", content.ErrInvalidKey)
+		}
+
+		var bound []any
+		if q.OrderBy == content.OrderByCreatedAt {
+			bound = append(bound, cursor.Sort)
+		}
+		for _, key := range cursor.Key {
+			bound = append(bound, key)
+		}
+
+		op := ` > `
+		if q.Desc {
+			op = ` < `
+		}
+
+		tmplt := make([]string, len(bound))
+		for i := range tmplt {
+			tmplt[i] = `?`
+		}
+
+		buf.WriteString(` AND (` + strings.Join(orderCols, ", ") + `)` + op + `(` + strings.Join(tmplt, ", ") + `)`)
+		args = append(args, bound...)
+	}
+
+	direction := ` ASC`
+	if q.Desc {
+		direction = ` DESC`
+	}
+
+	order := make([]string, len(orderCols))
+	for i, col := range orderCols {
+		order[i] = col + direction
+	}
+	buf.WriteString(` ORDER BY ` + strings.Join(order, ", "))
+
+	if q.Limit > 0 {
+		fmt.Fprintf(buf, ` LIMIT %v`, q.Limit+1)
+	}
+
+	return buf.String(), args, nil
+}
+
 func (h *handler) prepareWhereQuery(namespace string, refIDs []string, ID string) (string, []any, error) {
 	// keys consist of (namespace, ...refIDs, ID)
 	keySize := len(refIDs) + 2
@@ -386,13 +512,15 @@ func (h *handler) prepareWhereQuery(namespace string, refIDs []string, ID string
 func (h *handler) convertGetData(result []string) *content.Data {
 	refIDs := make([]string, 0, h.refSize)
 
-	for i := 1; i < len(result)-2; i++ {
+	// namespace, ref IDs, ID, data, meta
+	for i := 1; i < len(result)-3; i++ {
 		refIDs = append(refIDs, result[i])
 	}
 
 	return &content.Data{
 		Namespace: result[0],
 		RefIDs:    refIDs,
+		ID:        result[len(result)-3],
 		Data:      []byte(result[len(result)-2]),
 		Meta:      []byte(result[len(result)-1]),
 	}
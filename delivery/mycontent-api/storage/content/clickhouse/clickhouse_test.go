@@ -0,0 +1,34 @@
+package clickhouse
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// a row is scanned in the order of the selected columns: namespace, ref IDs, ID, data, meta.
+// The ID used to be read as the last ref ID, and never set.
+func TestConvertGetData(t *testing.T) {
+	h := &handler{tableName: "content", refSize: 2, keyCols: []string{"namespace", "ref_id_1", "ref_id_2", "id"}}
+
+	q, _, err := h.prepareGet("ns", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(q, `SELECT namespace,ref_id_1,ref_id_2,id,data,meta FROM`) {
+		t.Fatalf("unexpected selected columns: %v", q)
+	}
+
+	got := h.convertGetData([]string{"ns", "user-1", "album-1", "photo-1", `{"a":1}`, `{"b":2}`})
+	if got.Namespace != "ns" || !slices.Equal(got.RefIDs, []string{"user-1", "album-1"}) || got.ID != "photo-1" ||
+		string(got.Data) != `{"a":1}` || string(got.Meta) != `{"b":2}` {
+		t.Fatalf("unexpected data: %+v", got)
+	}
+
+	// without ref IDs
+	h = &handler{tableName: "content", refSize: 0, keyCols: []string{"namespace", "id"}}
+	got = h.convertGetData([]string{"ns", "photo-1", `{}`, `{}`})
+	if len(got.RefIDs) != 0 || got.ID != "photo-1" {
+		t.Fatalf("unexpected data: %+v", got)
+	}
+}
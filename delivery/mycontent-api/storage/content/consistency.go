@@ -0,0 +1,47 @@
+package content
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/desain-gratis/common/lib/raft"
+)
+
+// ReadConsistency of Get & Stream for replicated repository.
+// Repository that is not replicated can ignore it.
+type ReadConsistency = raft.ReadConsistency
+
+const (
+	// ReadDefault uses the default of the repository
+	ReadDefault = raft.ReadDefault
+
+	// ReadStale reads the local replica as it is; might not see the latest writes
+	ReadStale = raft.ReadStale
+
+	// ReadLinearizable sees every write completed before the read started, in any replica
+	ReadLinearizable = raft.ReadLinearizable
+
+	// ReadLeader is linearizable, but only served by the leader. Fails with ErrNotLeader otherwise.
+	ReadLeader = raft.ReadLeader
+)
+
+var ErrNotLeader = raft.ErrNotLeader
+
+func ParseReadConsistency(s string) (ReadConsistency, error) {
+	switch c := ReadConsistency(s); c {
+	case ReadDefault, ReadStale, ReadLinearizable, ReadLeader:
+		return c, nil
+	}
+
+	return ReadDefault, fmt.Errorf("%w: unknown read consistency '%v'", ErrInvalidKey, s)
+}
+
+// WithReadConsistency overrides the read consistency of the repository for the request
+func WithReadConsistency(ctx context.Context, c ReadConsistency) context.Context {
+	return raft.WithReadConsistency(ctx, c)
+}
+
+// GetReadConsistency returns the read consistency of the request, or def if not specified
+func GetReadConsistency(ctx context.Context, def ReadConsistency) ReadConsistency {
+	return raft.GetReadConsistency(ctx, def)
+}
@@ -2,7 +2,10 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	types "github.com/desain-gratis/common/types/http"
@@ -12,6 +15,12 @@ import (
 )
 
 var _ content.Repository = &handler{}
+var _ content.BatchRepository = &handler{}
+
+// queryer is either the database, or the transaction of a batch
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
 
 type handler struct {
 	db        *sqlx.DB
@@ -90,6 +99,30 @@ func (h *handler) Get(ctx context.Context, namespace string, refIDs []string, ID
 }
 
 func (h *handler) Post(ctx context.Context, namespace string, refIDs []string, ID string, input content.Data) (out content.Data, err error) {
+	if input.ExpectedVersion == nil {
+		return h.post(ctx, h.db, namespace, refIDs, ID, input)
+	}
+
+	// compare-and-set in a transaction; the current data is locked until it is overwritten
+	tx, err := h.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return input, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	out, err = h.post(ctx, tx, namespace, refIDs, ID, input)
+	if err != nil {
+		return out, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return input, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return out, nil
+}
+
+func (h *handler) post(ctx context.Context, db queryer, namespace string, refIDs []string, ID string, input content.Data) (out content.Data, err error) {
 	pKey := PrimaryKey{
 		Namespace: namespace,
 		ID:        ID,
@@ -112,8 +145,23 @@ func (h *handler) Post(ctx context.Context, namespace string, refIDs []string, I
 		input.Meta = []byte(`{}`)
 	}
 
-	q, args := generateQuery(h.tableName, "INSERT", pKey, UpsertData{Data: input.Data, Meta: input.Meta})
-	rows, errExec := h.db.QueryContext(ctx, q, args...)
+	queryType := "INSERT"
+	if input.ExpectedVersion != nil {
+		current, err := h.current(ctx, db, pKey)
+		if err != nil {
+			return input, err
+		}
+		if err := content.CheckVersion(current, input); err != nil {
+			return input, err
+		}
+		if current == nil {
+			// expected to not exist yet; do not overwrite the one inserted concurrently
+			queryType = "CREATE"
+		}
+	}
+
+	q, args := generateQuery(h.tableName, queryType, pKey, UpsertData{Data: input.Data, Meta: input.Meta})
+	rows, errExec := db.QueryContext(ctx, q, args...)
 	if errExec != nil {
 		err = &types.CommonError{
 			Errors: []types.Error{
@@ -139,12 +187,104 @@ func (h *handler) Post(ctx context.Context, namespace string, refIDs []string, I
 
 	// idstr := strconv.FormatInt(id, 10)
 
+	if queryType == "CREATE" && idstr == "" {
+		current, err := h.current(ctx, db, pKey)
+		if err != nil {
+			return input, err
+		}
+
+		var version uint64
+		if current != nil {
+			version = current.Version()
+		}
+		return input, &content.ConflictError{Expected: *input.ExpectedVersion, Current: version}
+	}
+
 	input.ID = idstr
 
 	return input, nil
 }
 
+// current data of the key, locked for update if it is inside a transaction; nil if it does not exist
+func (h *handler) current(ctx context.Context, db queryer, pKey PrimaryKey) (*content.Data, error) {
+	if pKey.ID == "" {
+		return nil, nil
+	}
+
+	q, _ := generateQuery(h.tableName, "SELECT", pKey, UpsertData{})
+	q = strings.TrimSuffix(q, ";") + " FOR UPDATE;"
+
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the current data: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, fmt.Errorf("failed to scan the current data: %w", err)
+	}
+
+	current, err := mergeColumnValue(columns, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &current, nil
+}
+
 func (h *handler) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (out content.Data, err error) {
+	expectedVersion := content.GetExpectedVersion(ctx)
+	if expectedVersion == nil {
+		return h.delete(ctx, h.db, namespace, refIDs, ID)
+	}
+
+	// compare-and-set in a transaction; the current data is locked until it is deleted
+	tx, err := h.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return content.Data{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := h.current(ctx, tx, PrimaryKey{Namespace: namespace, RefIDs: refIDs, ID: ID})
+	if err != nil {
+		return content.Data{}, err
+	}
+
+	// not found is reported by the delete itself
+	if current != nil {
+		if err := content.CheckVersion(current, content.Data{ExpectedVersion: expectedVersion}); err != nil {
+			return content.Data{}, err
+		}
+	}
+
+	out, err = h.delete(ctx, tx, namespace, refIDs, ID)
+	if err != nil {
+		return out, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return content.Data{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return out, nil
+}
+
+func (h *handler) delete(ctx context.Context, db queryer, namespace string, refIDs []string, ID string) (out content.Data, err error) {
 	pKey := PrimaryKey{
 		Namespace: namespace,
 		RefIDs:    refIDs,
@@ -153,7 +293,7 @@ func (h *handler) Delete(ctx context.Context, namespace string, refIDs []string,
 
 	q, _ := generateQuery(h.tableName, "DELETE", pKey, UpsertData{})
 
-	rows, errExec := h.db.QueryContext(ctx, q)
+	rows, errExec := db.QueryContext(ctx, q)
 	if errExec != nil {
 		err = &types.CommonError{
 			Errors: []types.Error{
@@ -204,6 +344,96 @@ func (h *handler) Delete(ctx context.Context, namespace string, refIDs []string,
 	return out, nil
 }
 
+// PostBatch in a single transaction
+func (h *handler) PostBatch(ctx context.Context, data []content.Data) ([]content.Data, error) {
+	return h.inTx(ctx, data, func(tx *sqlx.Tx, d content.Data) (content.Data, error) {
+		out, err := h.post(ctx, tx, d.Namespace, d.RefIDs, d.ID, d)
+		out.Namespace, out.RefIDs = d.Namespace, d.RefIDs
+		return out, err
+	})
+}
+
+// DeleteBatch in a single transaction
+func (h *handler) DeleteBatch(ctx context.Context, keys []content.Data) ([]content.Data, error) {
+	return h.inTx(ctx, keys, func(tx *sqlx.Tx, d content.Data) (content.Data, error) {
+		out, err := h.delete(ctx, tx, d.Namespace, d.RefIDs, d.ID)
+		out.RefIDs = d.RefIDs
+		return out, err
+	})
+}
+
+func (h *handler) inTx(ctx context.Context, items []content.Data, fn func(tx *sqlx.Tx, d content.Data) (content.Data, error)) ([]content.Data, error) {
+	tx, err := h.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := make([]content.Data, 0, len(items))
+	for idx, d := range items {
+		out, err := fn(tx, d)
+		if err != nil {
+			return nil, &content.BatchError{Index: idx, Err: err}
+		}
+		result = append(result, out)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// List filters, orders & paginates in postgres. OrderByEventID uses the "gid" serial column of the table.
+func (h *handler) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	query, args, err := generateListQuery(h.tableName, h.refSize, namespace, refIDs, q)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	rows, err := h.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return content.Page{}, fmt.Errorf("failed list query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return content.Page{}, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+
+	resp := make([]content.Data, 0)
+	for rows.Next() {
+		for i := range columns {
+			valuePtrs[i] = &values[i]
+		}
+
+		errScan := rows.Scan(valuePtrs...)
+		if errScan != nil {
+			log.Err(errScan).Msgf("Failed scan row")
+			continue
+		}
+
+		rowValue, errMerge := mergeColumnValue(columns, values)
+		if errMerge != nil {
+			log.Err(errMerge).Msgf("Failed merge column & value")
+			continue
+		}
+
+		resp = append(resp, rowValue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return content.Page{}, err
+	}
+
+	return q.NewPage(resp), nil
+}
+
 func (h *handler) Stream(ctx context.Context, namespace string, refIDs []string, ID string) (result <-chan content.Data, err error) {
 	return nil, nil
 }
@@ -13,6 +13,7 @@ const (
 	COLUMN_NAME_DATA      = "data"
 	COLUMN_NAME_META      = "meta"
 	COLUMN_NAME_NAMESPACE = "namespace"
+	COLUMN_NAME_GID       = "gid"
 )
 
 func generateQuery(tableName, queryType string, primaryKey PrimaryKey, upsertData UpsertData) (query string, args []any) {
@@ -65,6 +66,13 @@ func generateQuery(tableName, queryType string, primaryKey PrimaryKey, upsertDat
 		values = append(values, `$1`, `$2`)
 		query = `INSERT INTO ` + tableName + `(` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(values, ", ") + `)` +
 			` ON CONFLICT (` + strings.Join(pkColumns, ",") + `) DO UPDATE SET (` + strings.Join(columns, ", ") + `) = ` + `(` + strings.Join(values, ", ") + `) RETURNING id`
+	case "CREATE":
+		// the same as INSERT, but the existing one is kept; no row is returned
+		columns = append(columns, COLUMN_NAME_DATA, COLUMN_NAME_META)
+		args = append(args, string(upsertData.Data), string(upsertData.Meta))
+		values = append(values, `$1`, `$2`)
+		query = `INSERT INTO ` + tableName + `(` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(values, ", ") + `)` +
+			` ON CONFLICT (` + strings.Join(pkColumns, ",") + `) DO NOTHING RETURNING id`
 	case "DELETE":
 		query = `DELETE FROM ` + tableName + ` WHERE ` + strings.Join(primaryKeys, " AND ") + ` RETURNING ` + COLUMN_NAME_ID + `, ` + COLUMN_NAME_NAMESPACE + `, ` + COLUMN_NAME_DATA
 	}
@@ -73,6 +81,98 @@ func generateQuery(tableName, queryType string, primaryKey PrimaryKey, upsertDat
 	return
 }
 
+// generateListQuery is parameterized, unlike generateQuery, since the filter values come from the user
+func generateListQuery(tableName string, refSize int, namespace string, refIDs []string, q content.Query) (string, []any, error) {
+	cursor, err := q.Validate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if namespace == "" || len(refIDs) > refSize {
+		return "", nil, fmt.Errorf("%w: namespace must be specified, with at most %v ref IDs", content.ErrInvalidKey, refSize)
+	}
+
+	var where []string
+	var args []any
+	param := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if namespace != "*" {
+		where = append(where, COLUMN_NAME_NAMESPACE+" = "+param(namespace))
+	}
+
+	for i, refID := range refIDs {
+		where = append(where, "ref_id_"+strconv.Itoa(i+1)+" = "+param(refID))
+	}
+
+	// compares the JSON representation, so "5" does not match 5
+	for _, f := range q.Filters {
+		where = append(where, "("+COLUMN_NAME_DATA+"->("+param(f.Field)+"::text))::text = "+param(string(f.Value)))
+	}
+
+	keyCols := []string{COLUMN_NAME_NAMESPACE}
+	for i := range refSize {
+		keyCols = append(keyCols, "ref_id_"+strconv.Itoa(i+1))
+	}
+	keyCols = append(keyCols, COLUMN_NAME_ID)
+
+	orderCols := keyCols
+	switch q.OrderBy {
+	case content.OrderByCreatedAt:
+		orderCols = append([]string{"COALESCE(" + COLUMN_NAME_META + "->>'created_at', '')"}, keyCols...)
+	case content.OrderByEventID:
+		orderCols = append([]string{COLUMN_NAME_GID}, keyCols...)
+	}
+
+	if cursor != nil {
+		if len(cursor.Key) != len(keyCols) {
+			return "", nil, fmt.Errorf("%w: cursor is for a different table", content.ErrInvalidKey)
+		}
+
+		var bound []string
+		switch q.OrderBy {
+		case content.OrderByCreatedAt:
+			bound = append(bound, param(cursor.Sort))
+		case content.OrderByEventID:
+			gid, _ := strconv.ParseInt(cursor.Sort, 10, 64)
+			bound = append(bound, param(gid))
+		}
+		for _, key := range cursor.Key {
+			bound = append(bound, param(key))
+		}
+
+		op := " > "
+		if q.Desc {
+			op = " < "
+		}
+		where = append(where, "("+strings.Join(orderCols, ", ")+")"+op+"("+strings.Join(bound, ", ")+")")
+	}
+
+	var whereClause string
+	if len(where) > 0 {
+		whereClause = ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	direction := " ASC"
+	if q.Desc {
+		direction = " DESC"
+	}
+
+	order := make([]string, len(orderCols))
+	for i, col := range orderCols {
+		order[i] = col + direction
+	}
+
+	query := `SELECT * FROM ` + tableName + whereClause + ` ORDER BY ` + strings.Join(order, ", ")
+	if q.Limit > 0 {
+		query += ` LIMIT ` + strconv.Itoa(q.Limit+1)
+	}
+
+	return query + `;`, args, nil
+}
+
 func mergeColumnValue(columns []string, values []interface{}) (resp content.Data, err error) {
 	if len(columns) != len(values) {
 		err = fmt.Errorf("column length & value length are not same")
@@ -90,6 +190,10 @@ func mergeColumnValue(columns []string, values []interface{}) (resp content.Data
 		}
 
 		switch {
+		case column == COLUMN_NAME_GID:
+			if gid, ok := tempValue.(int64); ok {
+				resp.EventID = uint64(gid)
+			}
 		case column == COLUMN_NAME_NAMESPACE:
 			resp.Namespace = value
 		case column == COLUMN_NAME_ID:
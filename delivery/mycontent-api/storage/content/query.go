@@ -0,0 +1,185 @@
+package content
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// OrderBy of the listing. All of them are tie-broken by the key (namespace, ref IDs, ID).
+type OrderBy string
+
+const (
+	// OrderByKey orders by namespace, ref IDs, then ID
+	OrderByKey OrderBy = ""
+
+	// OrderByCreatedAt orders by the "created_at" of the meta
+	OrderByCreatedAt OrderBy = "created_at"
+
+	// OrderByEventID orders by the event ID assigned by the repository, ie. write order
+	OrderByEventID OrderBy = "event_id"
+)
+
+var filterField = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Query for List. Zero value lists everything in key order.
+type Query struct {
+	// Limit of a page; zero is no limit
+	Limit int
+
+	// Cursor is Page.Next of the previous page
+	Cursor string
+
+	OrderBy OrderBy
+	Desc    bool
+
+	// Filters all must match
+	Filters []Filter
+}
+
+// Filter matches a top level field of the data with a JSON scalar, eg. `"active"`, `5` or `true`
+type Filter struct {
+	Field string
+	Value json.RawMessage
+}
+
+type Page struct {
+	Data []Data
+
+	// Next cursor; empty for the last page
+	Next string
+}
+
+// Cursor is the position of the last data of a page
+type Cursor struct {
+	OrderBy OrderBy `json:"o,omitempty"`
+	Desc    bool    `json:"d,omitempty"`
+
+	// Sort value of the order, if any
+	Sort string `json:"s,omitempty"`
+
+	// Key is namespace, ref IDs, then ID
+	Key []string `json:"k"`
+}
+
+func ParseOrderBy(s string) (OrderBy, error) {
+	switch o := OrderBy(s); o {
+	case OrderByKey, OrderByCreatedAt, OrderByEventID:
+		return o, nil
+	}
+
+	return OrderByKey, fmt.Errorf("%w: unknown order by '%v'", ErrInvalidKey, s)
+}
+
+// ParseFilterValue accepts a JSON scalar, or takes it as a string otherwise
+func ParseFilterValue(s string) json.RawMessage {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		switch v.(type) {
+		case string, float64, bool, nil:
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, []byte(s)); err == nil {
+				return compact.Bytes()
+			}
+		}
+	}
+
+	quoted, _ := json.Marshal(s)
+	return quoted
+}
+
+// Validate the query, and returns the decoded cursor, if any
+func (q Query) Validate() (*Cursor, error) {
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidKey)
+	}
+
+	if _, err := ParseOrderBy(string(q.OrderBy)); err != nil {
+		return nil, err
+	}
+
+	for _, f := range q.Filters {
+		if !filterField.MatchString(f.Field) {
+			return nil, fmt.Errorf("%w: invalid filter field '%v'", ErrInvalidKey, f.Field)
+		}
+		var v any
+		if err := json.Unmarshal(f.Value, &v); err != nil {
+			return nil, fmt.Errorf("%w: invalid filter value for '%v'", ErrInvalidKey, f.Field)
+		}
+		switch v.(type) {
+		case string, float64, bool, nil:
+		default:
+			return nil, fmt.Errorf("%w: filter value for '%v' must be a scalar", ErrInvalidKey, f.Field)
+		}
+	}
+
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidKey)
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(payload, &cursor); err != nil || len(cursor.Key) < 2 {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidKey)
+	}
+
+	if cursor.OrderBy != q.OrderBy || cursor.Desc != q.Desc {
+		return nil, fmt.Errorf("%w: cursor is for a different order", ErrInvalidKey)
+	}
+
+	if cursor.OrderBy == OrderByEventID {
+		if _, err := strconv.ParseUint(cursor.Sort, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidKey)
+		}
+	}
+
+	return &cursor, nil
+}
+
+// NewPage makes the page out of at most Limit+1 data, queried in the order of the query.
+// The extra data only tells that there is a next page.
+func (q Query) NewPage(data []Data) Page {
+	if q.Limit <= 0 || len(data) <= q.Limit {
+		return Page{Data: data}
+	}
+
+	data = data[:q.Limit]
+	last := data[len(data)-1]
+
+	cursor := Cursor{
+		OrderBy: q.OrderBy,
+		Desc:    q.Desc,
+		Key:     append(append([]string{last.Namespace}, last.RefIDs...), last.ID),
+	}
+
+	switch q.OrderBy {
+	case OrderByCreatedAt:
+		cursor.Sort = CreatedAt(last.Meta)
+	case OrderByEventID:
+		cursor.Sort = strconv.FormatUint(last.EventID, 10)
+	}
+
+	payload, _ := json.Marshal(cursor)
+
+	return Page{
+		Data: data,
+		Next: base64.RawURLEncoding.EncodeToString(payload),
+	}
+}
+
+// CreatedAt of the meta as it is stored, for OrderByCreatedAt. Empty if not available.
+func CreatedAt(meta []byte) string {
+	var m struct {
+		CreatedAt string `json:"created_at"`
+	}
+	_ = json.Unmarshal(meta, &m)
+
+	return m.CreatedAt
+}
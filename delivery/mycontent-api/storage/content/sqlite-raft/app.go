@@ -1,11 +1,57 @@
 package sqliteraft
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 
-	_ "modernc.org/sqlite"
+	"github.com/rs/zerolog/log"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const (
+	appName = "sqlite_content_app"
+
+	// TODO: add separator validation on post / make this configurable
+	separator = "\\"
+)
+
+const (
+	CommandPost   raft.Command = "gratis.desain.mycontent.post"
+	CommandDelete raft.Command = "gratis.desain.mycontent.delete"
+)
+
+var (
+	_ raft.Application = &ContentApp{}
+	_ raft.Savepointer = &ContentApp{}
 )
 
+type QueryMyContent struct {
+	Table     string   `json:"table"`
+	Namespace string   `json:"namespace"`
+	RefIDs    []string `json:"ref_ids"`
+	ID        string   `json:"id"`
+}
+
+type QueryMyContentResponse <-chan content.Data
+
+type DataWrapper struct {
+	Table     string          `json:"table"`
+	Namespace string          `json:"namespace"`
+	RefIDs    []string        `json:"ref_ids"`
+	ID        string          `json:"id"`
+	EventID   uint64          `json:"event_id"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Meta      json.RawMessage `json:"meta,omitempty"`
+
+	// compare-and-set of post, see content.Data
+	ExpectedVersion *uint64 `json:"expected_version,omitempty"`
+}
+
 type TableConfig struct {
 	TableName                  string
 	RefSize                    int
@@ -14,70 +60,156 @@ type TableConfig struct {
 	VersionedUseOptimisticLock bool
 }
 
+// ContentApp is the mycontent raft.Application backed by the replica SQLite database.
+// Run it with raft_runner.WithSQLiteStorage; every replica applies the same
+// Post & Delete to its own database file.
 type ContentApp struct {
-	db *sql.DB
+	state       *state
+	tableConfig map[string]TableConfig
+}
 
-	tableConfig TableConfig
+func New(tableConfig ...TableConfig) *ContentApp {
+	tableConfigMap := make(map[string]TableConfig)
+	for _, c := range tableConfig {
+		tableConfigMap[c.TableName] = c
+		if c.RefSize < 0 || c.RefSize > 20 {
+			log.Panic().Msgf("invalid refSize: %v", c.RefSize)
+		}
+	}
+
+	return &ContentApp{
+		tableConfig: tableConfigMap,
+	}
 }
 
-func NewStorageClient(
-	filename string,
-	tableConfig TableConfig,
-) (*ContentApp, error) {
+// GetStorage offers the usual content.Repository interface for code *inside* raft.Application
+func (a *ContentApp) GetStorage(tableName string) (content.Repository, error) {
+	tableCfg, ok := a.tableConfig[tableName]
+	if !ok {
+		return nil, errors.New("table not found")
+	}
+
+	return &repository{
+		base:        a,
+		tableConfig: tableCfg,
+	}, nil
+}
 
-	db, err := sql.Open("sqlite", filename)
+func (a *ContentApp) Init(ctx context.Context) error {
+	conn := raft_runner.GetSQLiteConnection(ctx)
+
+	for _, table := range a.tableConfig {
+		if err := initSchema(ctx, conn, table); err != nil {
+			return fmt.Errorf("failed to init schema for table %v: %w", table.TableName, err)
+		}
+	}
+
+	meta, err := raft_runner.GetMetadata(ctx, appName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// SQLite tuning.
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
+	a.state = &state{}
 
-	app := &ContentApp{
-		db:          db,
-		tableConfig: tableConfig,
+	if len(meta) > 0 {
+		err = json.Unmarshal(meta, a.state)
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := app.initSQLite(); err != nil {
-		_ = db.Close()
-		return nil, err
+	if a.state.EventIndexes == nil {
+		a.state.EventIndexes = make(map[string]*uint64)
 	}
 
-	if err := app.initSchema(); err != nil {
-		_ = db.Close()
-		return nil, err
+	if a.state.VersionIndexes == nil {
+		a.state.VersionIndexes = make(map[string]*uint64)
 	}
 
-	return app, nil
+	return nil
 }
 
-func (a *ContentApp) Close() error {
-	if a.db == nil {
-		return nil
+func (a *ContentApp) Lookup(ctx context.Context, query interface{}) (interface{}, error) {
+	if query == nil {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	switch q := query.(type) {
+	case QueryMyContent:
+		return a.queryMyContent(ctx, q)
+	case ListMyContent:
+		table, ok := a.tableConfig[q.Table]
+		if !ok {
+			return nil, fmt.Errorf("table not found: %v", q.Table)
+		}
+		return a.list(ctx, table, q.Namespace, q.RefIDs, q.Query)
 	}
-	return a.db.Close()
+
+	return nil, errors.New("unsupported query")
 }
 
-func (a *ContentApp) Repository() *repository {
-	return &repository{
-		app: a,
+// PrepareUpdate has nothing to prepare; the runner already opens the update transaction
+func (a *ContentApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	return ctx, func() {}, nil
+}
+
+// OnUpdate updates the object using the specified committed raft entry.
+func (a *ContentApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command == CommandPostBatch || e.Command == CommandDeleteBatch {
+		return a.applyBatch(ctx, e.Command, e.Value)
+	}
+
+	payload, err := parseAs[DataWrapper](e.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse command as JSON (%v)", err, string(e.Value))
+	}
+
+	var result *DataWrapper
+	switch e.Command {
+	case CommandPost:
+		result, err = a.post(ctx, payload)
+	case CommandDelete:
+		result, err = a.delete(ctx, payload)
+	default:
+		return nil, fmt.Errorf("raft update %w: %v", raft.ErrUnsupported, e.Command)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	encResult, err := json.Marshal(*result)
+	if err != nil {
+		return func() (raft.Result, error) {
+			return raft.Result{Value: 1, Data: []byte(fmt.Sprintf("error marshal: %v", err))}, nil
+		}, nil
+	}
+
+	return func() (raft.Result, error) {
+		return raft.Result{Value: 0, Data: encResult}, nil
+	}, nil
 }
 
-func (a *ContentApp) initSQLite() error {
-	pragmas := []string{
-		"PRAGMA journal_mode=WAL;",
-		"PRAGMA synchronous=NORMAL;",
-		"PRAGMA foreign_keys=ON;",
-		"PRAGMA busy_timeout=5000;",
+// Apply saves the event & version indexes, in the same transaction as the update
+func (a *ContentApp) Apply(ctx context.Context) error {
+	payload, err := json.Marshal(a.state)
+	if err != nil {
+		return err
 	}
 
-	for _, pragma := range pragmas {
-		if _, err := a.db.Exec(pragma); err != nil {
-			return err
-		}
+	return raft_runner.SetMetadata(ctx, appName, payload)
+}
+
+func (a *ContentApp) queryMyContent(ctx context.Context, query QueryMyContent) (QueryMyContentResponse, error) {
+	table, ok := a.tableConfig[query.Table]
+	if !ok {
+		return nil, fmt.Errorf("table not found: %v", query.Table)
 	}
 
-	return nil
+	return a.stream(ctx, table, query.Namespace, query.RefIDs, query.ID)
+}
+
+func parseAs[T any](payload []byte) (T, error) {
+	var t T
+	err := json.Unmarshal(payload, &t)
+	return t, err
 }
@@ -6,8 +6,10 @@ import (
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 )
 
+// TO BE USED INSIDE RAFT APPLICATION
 type repository struct {
-	app *ContentApp
+	base        *ContentApp
+	tableConfig TableConfig
 }
 
 var _ content.Repository = (*repository)(nil)
@@ -19,7 +21,21 @@ func (r *repository) Post(
 	ID string,
 	data content.Data,
 ) (content.Data, error) {
-	return r.post(ctx, namespace, refIDs, ID, data)
+	result, err := r.base.post(ctx, DataWrapper{
+		Table:     r.tableConfig.TableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+		Data:      data.Data,
+		Meta:      data.Meta,
+
+		ExpectedVersion: data.ExpectedVersion,
+	})
+	if err != nil {
+		return content.Data{}, err
+	}
+
+	return toContentData(*result), nil
 }
 
 func (r *repository) Get(
@@ -28,7 +44,7 @@ func (r *repository) Get(
 	refIDs []string,
 	ID string,
 ) ([]content.Data, error) {
-	return r.get(ctx, namespace, refIDs, ID)
+	return r.base.get(ctx, r.tableConfig, namespace, refIDs, ID)
 }
 
 func (r *repository) Delete(
@@ -37,7 +53,19 @@ func (r *repository) Delete(
 	refIDs []string,
 	ID string,
 ) (content.Data, error) {
-	return r.delete(ctx, namespace, refIDs, ID)
+	result, err := r.base.delete(ctx, DataWrapper{
+		Table:     r.tableConfig.TableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+
+		ExpectedVersion: content.GetExpectedVersion(ctx),
+	})
+	if err != nil {
+		return content.Data{}, err
+	}
+
+	return toContentData(*result), nil
 }
 
 func (r *repository) Stream(
@@ -46,5 +74,25 @@ func (r *repository) Stream(
 	refIDs []string,
 	ID string,
 ) (<-chan content.Data, error) {
-	return r.stream(ctx, namespace, refIDs, ID)
+	return r.base.stream(ctx, r.tableConfig, namespace, refIDs, ID)
+}
+
+func (r *repository) List(
+	ctx context.Context,
+	namespace string,
+	refIDs []string,
+	q content.Query,
+) (content.Page, error) {
+	return r.base.list(ctx, r.tableConfig, namespace, refIDs, q)
+}
+
+func toContentData(d DataWrapper) content.Data {
+	return content.Data{
+		EventID:   d.EventID,
+		Namespace: d.Namespace,
+		RefIDs:    d.RefIDs,
+		ID:        d.ID,
+		Data:      d.Data,
+		Meta:      d.Meta,
+	}
 }
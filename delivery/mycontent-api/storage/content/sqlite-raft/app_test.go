@@ -0,0 +1,381 @@
+package sqliteraft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+var testTables = []TableConfig{
+	{TableName: "article", RefSize: 1},
+	{TableName: "revision", RefSize: 1, Versioned: true},
+}
+
+func newTestApp(_ uint64) raft.Application {
+	return New(testTables...)
+}
+
+func readAll(table string) func(node *raftest.Node) (any, error) {
+	return func(node *raftest.Node) (any, error) {
+		return NewStorageClient(node.Context(), table).Get(context.Background(), "ns", []string{"owner"}, "")
+	}
+}
+
+func postN(t *testing.T, repo content.Repository, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		_, err := repo.Post(context.Background(), "ns", []string{"owner"}, fmt.Sprintf("id-%d", i), content.Data{
+			Data: []byte(fmt.Sprintf(`{"i":%d}`, i)),
+		})
+		if err != nil {
+			t.Fatalf("post %v: %v", i, err)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	articles := NewStorageClient(c.Node(1).Context(), "article")
+	postN(t, articles, 0, 5)
+
+	// generated ID must be the same in every replica
+	if _, err := articles.Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := articles.Delete(context.Background(), "ns", []string{"owner"}, "id-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.ID != "id-0" {
+		t.Fatalf("deleted the wrong data: %+v", deleted)
+	}
+
+	_, err = articles.Delete(context.Background(), "ns", []string{"owner"}, "id-0")
+	if !errors.Is(err, content.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	revisions := NewStorageClient(c.Node(2).Context(), "revision")
+	for range 3 {
+		if _, err := revisions.Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.RequireConverged(readAll("article"))
+	c.RequireConverged(readAll("revision"))
+
+	got, err := NewStorageClient(c.Node(3).Context(), "revision").Get(context.Background(), "ns", []string{"owner"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].ID != "2" {
+		t.Fatalf("expected latest version first, got %+v", got)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	articles := NewStorageClient(c.Node(1).Context(), "article")
+	postN(t, articles, 0, 3)
+	c.WaitApplied()
+
+	c.Stop(3)
+	postN(t, articles, 3, 6)
+
+	c.Restart(3)
+	c.RequireConverged(readAll("article"))
+}
+
+func TestRecoverFromSnapshot(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp, raftest.WithSnapshotEntries(5, 1))
+
+	articles := NewStorageClient(c.Node(1).Context(), "article")
+	postN(t, articles, 0, 3)
+	c.WaitApplied()
+
+	c.Stop(3)
+
+	// the log is compacted, node 3 can only catch up from a snapshot
+	postN(t, articles, 3, 30)
+
+	revisions := NewStorageClient(c.Node(1).Context(), "revision")
+	for range 3 {
+		if _, err := revisions.Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.Restart(3)
+	c.RequireConverged(readAll("article"))
+	c.RequireConverged(readAll("revision"))
+
+	// the event index is part of the snapshot too
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return *node.App.(*ContentApp).state.EventIndexes["article"], nil
+	})
+
+	// so the next version, proposed from the recovered node, continues from it
+	next, err := NewStorageClient(c.Node(3).Context(), "revision").
+		Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != "3" {
+		t.Fatalf("expected version 3 after recovery, got %+v", next)
+	}
+	c.RequireConverged(readAll("revision"))
+}
+
+func TestReadConsistency(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	leaderID := c.WaitLeader()
+	followerID := leaderID%3 + 1
+
+	postN(t, NewStorageClient(c.Node(leaderID).Context(), "article"), 0, 1)
+
+	// linearizable read in a follower sees the write without waiting for apply
+	got, err := NewStorageClient(c.Node(followerID).Context(), "article").
+		Get(context.Background(), "ns", []string{"owner"}, "id-0")
+	if err != nil || len(got) != 1 {
+		t.Fatalf("expected to read own write, got %+v %v", got, err)
+	}
+
+	leaderOnly := content.WithReadConsistency(context.Background(), content.ReadLeader)
+	if _, err := NewStorageClient(c.Node(followerID).Context(), "article").
+		Get(leaderOnly, "ns", []string{"owner"}, ""); !errors.Is(err, content.ErrNotLeader) {
+		t.Fatalf("expected not leader, got %v", err)
+	}
+	if _, err := NewStorageClient(c.Node(leaderID).Context(), "article").
+		Get(leaderOnly, "ns", []string{"owner"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	c.WaitApplied()
+	stale := NewStorageClient(c.Node(followerID).Context(), "article").WithReadConsistency(content.ReadStale)
+	got, err = stale.Get(context.Background(), "ns", []string{"owner"}, "")
+	if err != nil || len(got) != 1 {
+		t.Fatalf("expected stale read after apply, got %+v %v", got, err)
+	}
+}
+
+func TestSessionClient(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	pool, err := raft_runner.NewSessionPool(c.Node(1).Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(context.Background())
+
+	revisions := NewStorageClient(c.Node(1).Context(), "revision").WithSessionPool(pool)
+	for i := range 3 {
+		got, err := revisions.Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != fmt.Sprint(i) {
+			t.Fatalf("expected version %v, got %+v", i, got)
+		}
+	}
+
+	c.RequireConverged(readAll("revision"))
+}
+
+func TestBatchClient(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	batcher, err := raft_runner.NewBatcher(c.Node(1).Context(), raft_runner.BatchConfig{MaxDelay: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batcher.Close()
+
+	const n = 20
+	revisions := NewStorageClient(c.Node(1).Context(), "revision").WithBatcher(batcher)
+
+	var wg sync.WaitGroup
+	ids := make([]string, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := revisions.Post(context.Background(), "ns", []string{"owner"}, "", content.Data{Data: []byte(`{}`)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = got.ID
+		}()
+	}
+	wg.Wait()
+
+	// each post in the batch got its own version
+	slices.Sort(ids)
+	if ids = slices.Compact(ids); len(ids) != n {
+		t.Fatalf("expected %v distinct versions, got %v", n, ids)
+	}
+
+	c.RequireConverged(readAll("revision"))
+}
+
+func TestList(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	articles := NewStorageClient(c.Node(1).Context(), "article")
+	postN(t, articles, 0, 10)
+
+	list := func(q content.Query) []string {
+		t.Helper()
+		var ids []string
+		for {
+			page, err := articles.List(context.Background(), "ns", []string{"owner"}, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Limit > 0 && len(page.Data) > q.Limit {
+				t.Fatalf("expected at most %v data, got %v", q.Limit, len(page.Data))
+			}
+			for _, d := range page.Data {
+				ids = append(ids, d.ID)
+			}
+			if page.Next == "" {
+				return ids
+			}
+			q.Cursor = page.Next
+		}
+	}
+
+	got := list(content.Query{Limit: 3, OrderBy: content.OrderByEventID, Desc: true})
+	expected := []string{"id-9", "id-8", "id-7", "id-6", "id-5", "id-4", "id-3", "id-2", "id-1", "id-0"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = list(content.Query{Limit: 2, Filters: []content.Filter{{Field: "i", Value: content.ParseFilterValue("4")}}})
+	if !slices.Equal(got, []string{"id-4"}) {
+		t.Fatalf("expected only id-4, got %v", got)
+	}
+
+	_, err := articles.List(context.Background(), "ns", nil, content.Query{Cursor: "garbage"})
+	if !errors.Is(err, content.ErrInvalidKey) {
+		t.Fatalf("expected invalid key for a bad cursor, got %v", err)
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	revisions := NewStorageClient(c.Node(1).Context(), "revision")
+	item := content.Data{Namespace: "ns", RefIDs: []string{"owner"}, Data: []byte(`{}`)}
+
+	posted, err := revisions.PostBatch(context.Background(), []content.Data{item, item, item})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 3 || posted[2].ID != "2" {
+		t.Fatalf("expected 3 versions, got %+v", posted)
+	}
+
+	// the last item fails, so the first is not written either, and its version is not taken
+	invalid := content.Data{Namespace: "ns", RefIDs: []string{"owner", "extra"}, Data: []byte(`{}`)}
+	_, err = revisions.PostBatch(context.Background(), []content.Data{item, invalid})
+	var batchErr *content.BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("expected batch error at item 1, got %v", err)
+	}
+
+	deleted, err := revisions.DeleteBatch(context.Background(), []content.Data{
+		{Namespace: "ns", RefIDs: []string{"owner"}, ID: "0"},
+		{Namespace: "ns", RefIDs: []string{"owner"}, ID: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("expected 2 deleted, got %+v", deleted)
+	}
+
+	_, err = revisions.DeleteBatch(context.Background(), []content.Data{
+		{Namespace: "ns", RefIDs: []string{"owner"}, ID: "2"},
+		{Namespace: "ns", RefIDs: []string{"owner"}, ID: "0"},
+	})
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, content.ErrNotFound) {
+		t.Fatalf("expected not found at item 1, got %v", err)
+	}
+
+	posted, err = revisions.PostBatch(context.Background(), []content.Data{item})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted[0].ID != "3" {
+		t.Fatalf("expected the failed batch not to take a version, got %v", posted[0].ID)
+	}
+
+	c.RequireConverged(readAll("revision"))
+
+	got, err := NewStorageClient(c.Node(2).Context(), "revision").Get(context.Background(), "ns", []string{"owner"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected versions 2 & 3 to remain, got %+v", got)
+	}
+}
+
+func TestPostCompareAndSet(t *testing.T) {
+	c := raftest.NewCluster(t, 3, newTestApp)
+
+	articles := NewStorageClient(c.Node(1).Context(), "article")
+	post := func(expected uint64, data string) (content.Data, error) {
+		return articles.Post(context.Background(), "ns", []string{"owner"}, "a", content.Data{
+			Data:            []byte(data),
+			ExpectedVersion: &expected,
+		})
+	}
+
+	// 0 expects it to not exist yet
+	posted, err := post(0, `{"title":"first"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := articles.Get(context.Background(), "ns", []string{"owner"}, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Version() != posted.Version() {
+		t.Fatalf("expected the stored version to be %v, got %+v", posted.Version(), got)
+	}
+
+	_, err = post(0, `{"title":"again"}`)
+	var conflict *content.ConflictError
+	if !errors.As(err, &conflict) || conflict.Current != posted.Version() {
+		t.Fatalf("expected conflict with the current version %v, got %v", posted.Version(), err)
+	}
+
+	updated, err := post(posted.Version(), `{"title":"second"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = post(posted.Version(), `{"title":"stale"}`)
+	if !errors.As(err, &conflict) || conflict.Current != updated.Version() {
+		t.Fatalf("expected conflict with the current version %v, got %v", updated.Version(), err)
+	}
+
+	c.RequireConverged(readAll("article"))
+}
@@ -0,0 +1,149 @@
+package sqliteraft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const (
+	CommandPostBatch   raft.Command = "gratis.desain.mycontent.post_batch"
+	CommandDeleteBatch raft.Command = "gratis.desain.mycontent.delete_batch"
+)
+
+// batchFailure is the raft result of a failed batch
+type batchFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// Savepoint of the event & version indexes, restored when the batch of the runner (raft_runner.CommandBatch) is rolled back
+func (a *ContentApp) Savepoint(ctx context.Context) (func(), error) {
+	prevState := a.state.clone()
+	return func() { a.state = prevState }, nil
+}
+
+// applyBatch all the items, or none of them: the writes are rolled back to a savepoint, and the indexes are restored
+func (a *ContentApp) applyBatch(ctx context.Context, command raft.Command, value []byte) (raft.OnAfterApply, error) {
+	items, err := parseAs[[]DataWrapper](value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse batch command as JSON (%v)", err, string(value))
+	}
+
+	apply := a.post
+	if command == CommandDeleteBatch {
+		apply = a.delete
+	}
+
+	conn := raft_runner.GetSQLiteConnection(ctx)
+	if _, err := conn.ExecContext(ctx, `SAVEPOINT mycontent_batch`); err != nil {
+		return nil, fmt.Errorf("failed to start batch: %w", err)
+	}
+
+	prevState := a.state.clone()
+
+	results := make([]DataWrapper, 0, len(items))
+	for idx, item := range items {
+		result, err := apply(ctx, item)
+		if err != nil {
+			a.state = prevState
+			if _, errRollback := conn.ExecContext(ctx, `ROLLBACK TO mycontent_batch`); errRollback != nil {
+				return nil, fmt.Errorf("failed to rollback batch: %w", errRollback)
+			}
+			if _, errRelease := conn.ExecContext(ctx, `RELEASE mycontent_batch`); errRelease != nil {
+				return nil, fmt.Errorf("failed to release batch: %w", errRelease)
+			}
+
+			failure, _ := json.Marshal(batchFailure{Index: idx, Error: err.Error()})
+			return func() (raft.Result, error) {
+				return raft.Result{Value: 1, Data: failure}, nil
+			}, nil
+		}
+		results = append(results, *result)
+	}
+
+	if _, err := conn.ExecContext(ctx, `RELEASE mycontent_batch`); err != nil {
+		return nil, fmt.Errorf("failed to release batch: %w", err)
+	}
+
+	encResult, err := json.Marshal(results)
+	if err != nil {
+		return func() (raft.Result, error) {
+			return raft.Result{Value: 1, Data: []byte(fmt.Sprintf("error marshal: %v", err))}, nil
+		}, nil
+	}
+
+	return func() (raft.Result, error) {
+		return raft.Result{Value: 0, Data: encResult}, nil
+	}, nil
+}
+
+// PostBatch proposes the batch as a single raft entry
+func (c *mycontentClient) PostBatch(ctx context.Context, data []content.Data) ([]content.Data, error) {
+	items := make([]DataWrapper, 0, len(data))
+	for _, d := range data {
+		items = append(items, DataWrapper{
+			Table:     c.tableName,
+			Namespace: d.Namespace,
+			RefIDs:    d.RefIDs,
+			ID:        d.ID,
+			Data:      d.Data,
+			Meta:      d.Meta,
+
+			ExpectedVersion: d.ExpectedVersion,
+		})
+	}
+
+	return c.proposeBatch(ctx, CommandPostBatch, items)
+}
+
+// DeleteBatch proposes the batch as a single raft entry
+func (c *mycontentClient) DeleteBatch(ctx context.Context, keys []content.Data) ([]content.Data, error) {
+	items := make([]DataWrapper, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, DataWrapper{
+			Table:     c.tableName,
+			Namespace: k.Namespace,
+			RefIDs:    k.RefIDs,
+			ID:        k.ID,
+		})
+	}
+
+	return c.proposeBatch(ctx, CommandDeleteBatch, items)
+}
+
+func (c *mycontentClient) proposeBatch(ctx context.Context, command raft.Command, items []DataWrapper) ([]content.Data, error) {
+	value, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.proposeRaw(ctx, command, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose %v to raft: %w (%w)", command, err, ErrNotReady)
+	}
+
+	if res.Value > 0 {
+		var failure batchFailure
+		if err := json.Unmarshal(res.Data, &failure); err != nil {
+			return nil, parseRaftError(res.Data)
+		}
+		return nil, &content.BatchError{Index: failure.Index, Err: parseRaftError([]byte(failure.Error))}
+	}
+
+	var parsedRaft []DataWrapper
+	if err := json.Unmarshal(res.Data, &parsedRaft); err != nil {
+		return nil, fmt.Errorf("failed to parse raft response: %w '%v'", err, string(res.Data))
+	}
+
+	result := make([]content.Data, 0, len(parsedRaft))
+	for _, d := range parsedRaft {
+		result = append(result, toContentData(d))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,234 @@
+package sqliteraft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lni/dragonboat/v4"
+	"github.com/lni/dragonboat/v4/client"
+	"github.com/lni/dragonboat/v4/statemachine"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+var _ content.Repository = &mycontentClient{}
+var _ content.BatchRepository = &mycontentClient{}
+
+var (
+	ErrNotReady = errors.New("raft not ready")
+)
+
+// mycontentClient proposes Post & Delete to raft, and reads from the local replica
+type mycontentClient struct {
+	tableName string
+	DHost     *dragonboat.NodeHost
+	Sess      *client.Session
+	ReplicaID uint64
+	encoding  raft_runner.CommandEncoding
+
+	consistency content.ReadConsistency
+	sessions    *raft_runner.SessionPool
+	batcher     *raft_runner.Batcher
+}
+
+func NewStorageClient(ctx context.Context, tableName string) *mycontentClient {
+	raftCtx, _ := raft_runner.GetRaftContext(ctx)
+	return &mycontentClient{
+		tableName: tableName,
+		DHost:     raftCtx.DHost,
+		Sess:      raftCtx.DHost.GetNoOPSession(raftCtx.ShardID),
+		ReplicaID: raftCtx.ReplicaID,
+		encoding:  raftCtx.CommandEncoding,
+
+		consistency: content.ReadLinearizable,
+	}
+}
+
+// WithReadConsistency sets the default read consistency of Get & Stream.
+// It can be overridden per request with content.WithReadConsistency.
+func (c *mycontentClient) WithReadConsistency(consistency content.ReadConsistency) *mycontentClient {
+	c.consistency = consistency
+	return c
+}
+
+// WithSessionPool proposes Post & Delete with client sessions, so they can be retried safely.
+// The pool can be shared by the clients of the same replica; closing it is up to the caller.
+func (c *mycontentClient) WithSessionPool(sessions *raft_runner.SessionPool) *mycontentClient {
+	c.sessions = sessions
+	return c
+}
+
+// WithBatcher proposes Post & Delete through the batcher, grouping concurrent writes into a single raft entry.
+// It takes precedence over the session pool; give the pool to the batcher instead.
+func (c *mycontentClient) WithBatcher(batcher *raft_runner.Batcher) *mycontentClient {
+	c.batcher = batcher
+	return c
+}
+
+func (c *mycontentClient) Post(ctx context.Context, namespace string, refIDs []string, ID string, data content.Data) (content.Data, error) {
+	var validate any
+	if len(data.Data) > 0 {
+		if err := json.Unmarshal(data.Data, &validate); err != nil {
+			return content.Data{}, fmt.Errorf("expected json mycontent data payload: %v", string(data.Data))
+		}
+	}
+
+	if len(data.Meta) > 0 {
+		if err := json.Unmarshal(data.Meta, &validate); err != nil {
+			return content.Data{}, fmt.Errorf("expected json mycontent meta payload: %v", string(data.Meta))
+		}
+	}
+
+	return c.propose(ctx, CommandPost, DataWrapper{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+		Data:      data.Data,
+		Meta:      data.Meta,
+
+		ExpectedVersion: data.ExpectedVersion,
+	})
+}
+
+// Get data by owner ID
+func (c *mycontentClient) Get(ctx context.Context, namespace string, refIDs []string, ID string) ([]content.Data, error) {
+	chanResp, err := c.query(ctx, namespace, refIDs, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// for get, we store them all into memory
+	result := make([]content.Data, 0)
+	for data := range chanResp {
+		result = append(result, data)
+	}
+
+	return result, nil
+}
+
+// Delete specific ID data. If no data, MUST return error
+func (c *mycontentClient) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (content.Data, error) {
+	return c.propose(ctx, CommandDelete, DataWrapper{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+
+		ExpectedVersion: content.GetExpectedVersion(ctx),
+	})
+}
+
+// Stream Get data
+func (c *mycontentClient) Stream(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan content.Data, error) {
+	return c.query(ctx, namespace, refIDs, ID)
+}
+
+// List data, filtered & paginated by the replica
+func (c *mycontentClient) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (content.Page, error) {
+	res, err := raft_runner.ConsistentRead(ctx, c.DHost, c.Sess.ShardID, c.ReplicaID, c.consistency, ListMyContent{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		Query:     q,
+	})
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	page, ok := res.(content.Page)
+	if !ok {
+		return content.Page{}, fmt.Errorf("server error: not a page: %T %v", res, res)
+	}
+
+	return page, nil
+}
+
+func (c *mycontentClient) propose(ctx context.Context, command raft.Command, payload DataWrapper) (content.Data, error) {
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return content.Data{}, err
+	}
+
+	res, err := c.proposeRaw(ctx, command, value)
+	if err != nil {
+		return content.Data{}, fmt.Errorf("failed to propose %v to raft: %w (%w)", command, err, ErrNotReady)
+	}
+
+	if res.Value > 0 {
+		return content.Data{}, parseRaftError(res.Data)
+	}
+
+	var parsedRaft DataWrapper
+	if err := json.Unmarshal(res.Data, &parsedRaft); err != nil {
+		return content.Data{}, fmt.Errorf("failed to parse raft response: %w '%v'", err, string(res.Data))
+	}
+
+	return toContentData(parsedRaft), nil
+}
+
+func (c *mycontentClient) proposeRaw(ctx context.Context, command raft.Command, value json.RawMessage) (statemachine.Result, error) {
+	if c.batcher != nil {
+		return c.batcher.Propose(ctx, command, value)
+	}
+	if c.sessions != nil {
+		return c.sessions.Propose(ctx, command, value)
+	}
+
+	data, err := raft_runner.EncodeCommand(c.encoding, raft_runner.Command{
+		Command:   command,
+		Value:     value,
+		ReplicaID: &c.ReplicaID,
+	})
+	if err != nil {
+		return statemachine.Result{}, fmt.Errorf("failed to marshal msg to raft: %w", err)
+	}
+
+	// not retried, the proposal might be applied twice
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	return c.DHost.SyncPropose(ctx, c.Sess, data)
+}
+
+func (c *mycontentClient) query(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan content.Data, error) {
+	q := QueryMyContent{
+		Table:     c.tableName,
+		Namespace: namespace,
+		RefIDs:    refIDs,
+		ID:        ID,
+	}
+
+	res, err := raft_runner.ConsistentRead(ctx, c.DHost, c.Sess.ShardID, c.ReplicaID, c.consistency, q)
+	if err != nil {
+		return nil, err
+	}
+
+	chanResp, ok := res.(QueryMyContentResponse)
+	if !ok {
+		return nil, fmt.Errorf("server error: not query my content response: %T %v", res, res)
+	}
+
+	return chanResp, nil
+}
+
+// parseRaftError brings back the sentinel errors, since only the message goes through raft
+func parseRaftError(msg []byte) error {
+	if conflict, ok := content.ParseConflictError(string(msg)); ok {
+		return fmt.Errorf("got error from raft: %w", conflict)
+	}
+
+	for _, sentinel := range []error{content.ErrNotFound, content.ErrInvalidKey} {
+		if strings.Contains(string(msg), sentinel.Error()) {
+			return fmt.Errorf("got error from raft: %w", sentinel)
+		}
+	}
+
+	return fmt.Errorf("got error from raft: '%v'", string(msg))
+}
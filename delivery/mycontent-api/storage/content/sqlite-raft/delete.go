@@ -5,64 +5,88 @@ import (
 	"fmt"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
 
-func (r *repository) delete(
-	ctx context.Context,
-	namespace string,
-	refIDs []string,
-	ID string,
-) (content.Data, error) {
+func (a *ContentApp) delete(ctx context.Context, payload DataWrapper) (*DataWrapper, error) {
+	table, ok := a.tableConfig[payload.Table]
+	if !ok {
+		return nil, fmt.Errorf("table %v not found", payload.Table)
+	}
 
-	if err := r.app.validateKey(namespace, refIDs); err != nil {
-		return content.Data{}, err
+	if err := table.validateKey(payload.Namespace, payload.RefIDs); err != nil {
+		return nil, err
 	}
 
-	if ID == "" {
-		return content.Data{}, content.ErrInvalidKey
+	if payload.ID == "" {
+		return nil, content.ErrInvalidKey
 	}
 
-	items, err := r.get(
+	items, err := a.get(
 		ctx,
-		namespace,
-		refIDs,
-		ID,
+		table,
+		payload.Namespace,
+		payload.RefIDs,
+		payload.ID,
 	)
 	if err != nil {
-		return content.Data{}, err
+		return nil, err
 	}
 
 	if len(items) == 0 {
-		return content.Data{}, content.ErrNotFound
+		return nil, content.ErrNotFound
+	}
+
+	if err := content.CheckVersion(&items[0], content.Data{ExpectedVersion: payload.ExpectedVersion}); err != nil {
+		return nil, err
 	}
 
 	query := fmt.Sprintf(`
 DELETE FROM %s
 WHERE %s
 `,
-		r.app.tableConfig.TableName,
-		r.app.primaryWhere(),
+		table.TableName,
+		table.primaryWhere(),
 	)
 
-	args := r.app.primaryArgs(namespace, refIDs, ID)
+	args := table.primaryArgs(payload.Namespace, payload.RefIDs, payload.ID)
 
-	result, err := r.app.db.ExecContext(
+	result, err := raft_runner.GetSQLiteConnection(ctx).ExecContext(
 		ctx,
 		query,
 		args...,
 	)
 	if err != nil {
-		return content.Data{}, err
+		return nil, err
 	}
 
 	affected, err := result.RowsAffected()
 	if err != nil {
-		return content.Data{}, err
+		return nil, err
 	}
 
 	if affected == 0 {
-		return content.Data{}, content.ErrNotFound
+		return nil, content.ErrNotFound
 	}
 
-	return items[0], nil
+	// deletion is an event too
+	eventIdx, ok := a.state.EventIndexes[table.TableName]
+	if !ok {
+		var index uint64
+		a.state.EventIndexes[table.TableName] = &index
+		eventIdx = &index
+	}
+	*eventIdx++
+
+	deleted := items[0]
+
+	return &DataWrapper{
+		Table:     table.TableName,
+		Namespace: deleted.Namespace,
+		RefIDs:    deleted.RefIDs,
+		ID:        deleted.ID,
+		EventID:   deleted.EventID,
+		Data:      deleted.Data,
+		Meta:      deleted.Meta,
+	}, nil
 }
@@ -5,46 +5,72 @@ import (
 	"fmt"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
 
-func (r *repository) get(
+func (a *ContentApp) get(
 	ctx context.Context,
+	table TableConfig,
 	namespace string,
 	refIDs []string,
 	ID string,
 ) ([]content.Data, error) {
 
-	if err := r.app.validateKey(namespace, refIDs); err != nil {
+	query, args, err := prepareGet(table, namespace, refIDs, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := raft_runner.GetSQLiteConnection(ctx).QueryContext(
+		ctx,
+		query,
+		args...,
+	)
+	if err != nil {
 		return nil, err
 	}
 
+	return scanRows(table, rows)
+}
+
+func prepareGet(
+	table TableConfig,
+	namespace string,
+	refIDs []string,
+	ID string,
+) (string, []any, error) {
+
+	if err := table.validateKey(namespace, refIDs); err != nil {
+		return "", nil, err
+	}
+
 	query := fmt.Sprintf(`
 SELECT
 	%s
 FROM %s
 WHERE %s`,
-		r.app.selectColumns(),
-		r.app.tableConfig.TableName,
-		r.app.ownerWhere(),
+		table.selectColumns(),
+		table.TableName,
+		table.ownerWhere(),
 	)
 
-	args := r.app.ownerArgs(namespace, refIDs)
+	args := table.ownerArgs(namespace, refIDs)
 
 	if ID != "" {
 		query += "\nAND id=?"
 		args = append(args, ID)
 	}
 
-	query += "\nORDER BY event_id ASC"
-
-	rows, err := r.app.db.QueryContext(
-		ctx,
-		query,
-		args...,
-	)
-	if err != nil {
-		return nil, err
+	// same as clickhouse-raft, versioned table returns the latest versions first
+	if table.Versioned {
+		limit := 20
+		if table.VersionedGetLimit > 0 {
+			limit = int(table.VersionedGetLimit)
+		}
+		query += fmt.Sprintf("\nORDER BY event_id DESC\nLIMIT %d", limit)
+	} else {
+		query += "\nORDER BY event_id ASC"
 	}
 
-	return r.scanRows(rows)
+	return query, args, nil
 }
@@ -0,0 +1,148 @@
+package sqliteraft
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+// ListMyContent is the Lookup query of List
+type ListMyContent struct {
+	Table     string        `json:"table"`
+	Namespace string        `json:"namespace"`
+	RefIDs    []string      `json:"ref_ids"`
+	Query     content.Query `json:"query"`
+}
+
+func (a *ContentApp) list(
+	ctx context.Context,
+	table TableConfig,
+	namespace string,
+	refIDs []string,
+	q content.Query,
+) (content.Page, error) {
+
+	query, args, err := prepareList(table, namespace, refIDs, q)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	rows, err := raft_runner.GetSQLiteConnection(ctx).QueryContext(
+		ctx,
+		query,
+		args...,
+	)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	data, err := scanRows(table, rows)
+	if err != nil {
+		return content.Page{}, err
+	}
+
+	return q.NewPage(data), nil
+}
+
+// prepareList filters, orders & paginates in SQLite, with the cursor as the lower (or upper) bound
+func prepareList(
+	table TableConfig,
+	namespace string,
+	refIDs []string,
+	q content.Query,
+) (string, []any, error) {
+
+	cursor, err := q.Validate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if namespace == "" || len(refIDs) > table.RefSize {
+		return "", nil, fmt.Errorf("%w: namespace must be specified, with at most %v ref IDs", content.ErrInvalidKey, table.RefSize)
+	}
+
+	where := []string{"1=1"}
+	var args []any
+
+	if namespace != "*" {
+		where = append(where, "namespace=?")
+		args = append(args, namespace)
+	}
+
+	for i, ref := range refIDs {
+		where = append(where, fmt.Sprintf("ref%d=?", i))
+		args = append(args, ref)
+	}
+
+	// compares the JSON representation, so "5" does not match 5
+	for _, f := range q.Filters {
+		where = append(where, "(CAST(data AS TEXT) -> ?) = ?")
+		args = append(args, `$."`+f.Field+`"`, string(f.Value))
+	}
+
+	orderCols := table.primaryColumns()
+	switch q.OrderBy {
+	case content.OrderByCreatedAt:
+		orderCols = append([]string{"COALESCE(json_extract(CAST(meta AS TEXT), '$.created_at'), '')"}, orderCols...)
+	case content.OrderByEventID:
+		orderCols = append([]string{"event_id"}, orderCols...)
+	}
+
+	if cursor != nil {
+		if len(cursor.Key) != len(table.primaryColumns()) {
+			return "", nil, fmt.Errorf("%w: cursor is for a different table", content.ErrInvalidKey)
+		}
+
+		op := ">"
+		if q.Desc {
+			op = "<"
+		}
+
+		var bound []any
+		switch q.OrderBy {
+		case content.OrderByCreatedAt:
+			bound = append(bound, cursor.Sort)
+		case content.OrderByEventID:
+			eventID, _ := strconv.ParseUint(cursor.Sort, 10, 64)
+			bound = append(bound, eventID)
+		}
+		for _, key := range cursor.Key {
+			bound = append(bound, key)
+		}
+
+		where = append(where, fmt.Sprintf("(%s) %s (%s)", strings.Join(orderCols, ", "), op, placeholders(len(bound))))
+		args = append(args, bound...)
+	}
+
+	direction := " ASC"
+	if q.Desc {
+		direction = " DESC"
+	}
+
+	order := make([]string, len(orderCols))
+	for i, col := range orderCols {
+		order[i] = col + direction
+	}
+
+	query := fmt.Sprintf(`
+SELECT
+	%s
+FROM %s
+WHERE %s
+ORDER BY %s`,
+		table.selectColumns(),
+		table.TableName,
+		strings.Join(where, "\nAND "),
+		strings.Join(order, ", "),
+	)
+
+	if q.Limit > 0 {
+		query += fmt.Sprintf("\nLIMIT %d", q.Limit+1)
+	}
+
+	return query, args, nil
+}
@@ -4,42 +4,115 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
 
-func (r *repository) post(
-	ctx context.Context,
-	namespace string,
-	refIDs []string,
-	ID string,
-	data content.Data,
-) (content.Data, error) {
-
-	if err := r.app.validateKey(namespace, refIDs); err != nil {
-		return content.Data{}, err
+func (a *ContentApp) post(ctx context.Context, payload DataWrapper) (*DataWrapper, error) {
+	table, ok := a.tableConfig[payload.Table]
+	if !ok {
+		return nil, fmt.Errorf("table %v not found", payload.Table)
 	}
 
-	if ID == "" {
-		return content.Data{}, content.ErrInvalidKey
+	if err := table.validateKey(payload.Namespace, payload.RefIDs); err != nil {
+		return nil, err
 	}
 
 	// Keep behavior compatible with clickhouse-raft.
 	var v any
 
-	if len(data.Data) > 0 {
-		if err := json.Unmarshal(data.Data, &v); err != nil {
-			return content.Data{}, err
+	if len(payload.Data) > 0 {
+		if err := json.Unmarshal(payload.Data, &v); err != nil {
+			return nil, fmt.Errorf("expected json mycontent data payload: %v", string(payload.Data))
 		}
 	}
 
-	if len(data.Meta) > 0 {
-		if err := json.Unmarshal(data.Meta, &v); err != nil {
-			return content.Data{}, err
+	var meta mycontent.Meta
+	if len(payload.Meta) > 0 {
+		if err := json.Unmarshal(payload.Meta, &meta); err != nil {
+			return nil, fmt.Errorf("expected json mycontent meta payload: %v", string(payload.Meta))
 		}
 	}
 
+	versionKey := strings.Join(append([]string{table.TableName, payload.Namespace}, payload.RefIDs...), separator)
+	versionIdx, ok := a.state.VersionIndexes[versionKey]
+	if !ok && table.Versioned {
+		var index uint64
+		a.state.VersionIndexes[versionKey] = &index
+		versionIdx = &index
+	}
+
+	eventIdx, ok := a.state.EventIndexes[table.TableName]
+	if !ok {
+		var index uint64
+		a.state.EventIndexes[table.TableName] = &index
+		eventIdx = &index
+	}
+
+	// For "new" version (my content data specified without ID) of versioned data, we support optimistic lock
+	newData := table.Versioned && payload.ID == ""
+	if newData && table.VersionedUseOptimisticLock {
+		if meta.OptimisticLockVersion == nil {
+			if *eventIdx > 0 {
+				return nil, fmt.Errorf("no optimistic lock version specified, and there is already data inside")
+			}
+			lockVer := uint64(0)
+			meta.OptimisticLockVersion = &lockVer
+		}
+
+		if *versionIdx != *meta.OptimisticLockVersion {
+			return nil, &content.ConflictError{Expected: *meta.OptimisticLockVersion, Current: *versionIdx, NextVersion: true}
+		}
+	}
+
+	// For the existing data, compare-and-set with the version of the current data
+	if payload.ExpectedVersion != nil {
+		var current *content.Data
+		if payload.ID != "" {
+			items, err := a.get(ctx, table, payload.Namespace, payload.RefIDs, payload.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(items) > 0 {
+				current = &items[0]
+			}
+		}
+
+		if err := content.CheckVersion(current, content.Data{ExpectedVersion: payload.ExpectedVersion}); err != nil {
+			return nil, err
+		}
+	}
+
+	id := payload.ID
+	var increment bool
+	if table.Versioned {
+		if id == "" {
+			id = strconv.FormatUint(*versionIdx, 10)
+			increment = true
+		} else if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid ID value for versioned table (%v): %v", content.ErrInvalidKey, table.TableName, id)
+		}
+	} else if id == "" {
+		// must be the same in every replica, so it is derived from the replicated event index
+		id = uuid.NewSHA1(uuid.NameSpaceOID, []byte(table.TableName+separator+strconv.FormatUint(*eventIdx, 10))).String()
+	}
+
+	data := payload.Data
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+
+	metaPayload := payload.Meta
+	if len(metaPayload) == 0 {
+		metaPayload = json.RawMessage("{}")
+	}
+
 	query := fmt.Sprintf(`
 INSERT INTO %s
 (
@@ -51,44 +124,40 @@ VALUES
 )
 ON CONFLICT (%s)
 DO UPDATE SET
+	event_id=excluded.event_id,
 	data=excluded.data,
 	meta=excluded.meta;
 `,
-		r.app.tableConfig.TableName,
-		r.app.insertColumns(),
-		r.app.insertPlaceholders(),
-		strings.Join(r.app.primaryColumns(), ", "),
+		table.TableName,
+		table.insertColumns(),
+		table.insertPlaceholders(),
+		strings.Join(table.primaryColumns(), ", "),
 	)
 
-	args := r.app.primaryArgs(namespace, refIDs, ID)
-
-	args = append(
-		args,
-		data.Data,
-		data.Meta,
-	)
+	args := []any{*eventIdx}
+	args = append(args, table.primaryArgs(payload.Namespace, payload.RefIDs, id)...)
+	args = append(args, []byte(data), []byte(metaPayload))
 
-	if _, err := r.app.db.ExecContext(
+	if _, err := raft_runner.GetSQLiteConnection(ctx).ExecContext(
 		ctx,
 		query,
 		args...,
 	); err != nil {
-		return content.Data{}, err
+		return nil, fmt.Errorf("error writing to table %v: %w", table.TableName, err)
 	}
 
-	result, err := r.get(
-		ctx,
-		namespace,
-		refIDs,
-		ID,
-	)
-	if err != nil {
-		return content.Data{}, err
-	}
+	result := payload
+	result.ID = id
+	result.EventID = *eventIdx
+	result.Data = data
+	result.Meta = metaPayload
+	result.ExpectedVersion = nil
+
+	*eventIdx++
 
-	if len(result) == 0 {
-		return content.Data{}, content.ErrNotFound
+	if increment {
+		*versionIdx++
 	}
 
-	return result[0], nil
+	return &result, nil
 }
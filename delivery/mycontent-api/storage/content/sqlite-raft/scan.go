@@ -10,18 +10,18 @@ type scanner interface {
 	Scan(dest ...any) error
 }
 
-func (r *repository) scanRow(s scanner) (content.Data, error) {
+func scanRow(table TableConfig, s scanner) (content.Data, error) {
 
 	var d content.Data
 
-	refs := make([]string, r.app.tableConfig.RefSize)
+	refs := make([]string, table.RefSize)
 
 	dest := []any{
 		&d.EventID,
 		&d.Namespace,
 	}
 
-	for i := 0; i < r.app.tableConfig.RefSize; i++ {
+	for i := 0; i < table.RefSize; i++ {
 		dest = append(dest, &refs[i])
 	}
 
@@ -43,13 +43,13 @@ func (r *repository) scanRow(s scanner) (content.Data, error) {
 	return d, nil
 }
 
-func (r *repository) scanRows(rows *sql.Rows) ([]content.Data, error) {
+func scanRows(table TableConfig, rows *sql.Rows) ([]content.Data, error) {
 	defer rows.Close()
 
 	result := make([]content.Data, 0)
 
 	for rows.Next() {
-		item, err := r.scanRow(rows)
+		item, err := scanRow(table, rows)
 		if err != nil {
 			return nil, err
 		}
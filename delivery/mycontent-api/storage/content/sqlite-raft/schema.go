@@ -1,32 +1,36 @@
 package sqliteraft
 
 import (
+	"context"
 	"fmt"
 	"strings"
+
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
 
-func (a *ContentApp) initSchema() error {
-	if err := a.createContentTable(); err != nil {
+func initSchema(ctx context.Context, conn raft_runner.SQLiteConn, table TableConfig) error {
+	if err := createContentTable(ctx, conn, table); err != nil {
 		return err
 	}
 
-	if err := a.createIndexes(); err != nil {
+	if err := createIndexes(ctx, conn, table); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (a *ContentApp) createContentTable() error {
+// event_id is assigned by the state machine, so it is the same in every replica
+func createContentTable(ctx context.Context, conn raft_runner.SQLiteConn, table TableConfig) error {
 	var sb strings.Builder
 
 	fmt.Fprintf(&sb, `
 CREATE TABLE IF NOT EXISTS %s (
-	event_id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_id INTEGER PRIMARY KEY,
 	namespace TEXT NOT NULL,
-`, a.tableConfig.TableName)
+`, table.TableName)
 
-	for i := 0; i < a.tableConfig.RefSize; i++ {
+	for i := 0; i < table.RefSize; i++ {
 		fmt.Fprintf(&sb, "	ref%d TEXT NOT NULL,\n", i)
 	}
 
@@ -38,15 +42,15 @@ CREATE TABLE IF NOT EXISTS %s (
 );
 `)
 
-	_, err := a.db.Exec(sb.String())
+	_, err := conn.ExecContext(ctx, sb.String())
 	return err
 }
 
-func (a *ContentApp) createIndexes() error {
+func createIndexes(ctx context.Context, conn raft_runner.SQLiteConn, table TableConfig) error {
 
 	cols := []string{"namespace"}
 
-	for i := 0; i < a.tableConfig.RefSize; i++ {
+	for i := 0; i < table.RefSize; i++ {
 		cols = append(cols, fmt.Sprintf("ref%d", i))
 	}
 
@@ -59,8 +63,8 @@ func (a *ContentApp) createIndexes() error {
 CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_primary
 ON %s (%s);
 `,
-			a.tableConfig.TableName,
-			a.tableConfig.TableName,
+			table.TableName,
+			table.TableName,
 			primaryCols,
 		),
 
@@ -68,14 +72,14 @@ ON %s (%s);
 CREATE INDEX IF NOT EXISTS idx_%s_owner
 ON %s (%s);
 `,
-			a.tableConfig.TableName,
-			a.tableConfig.TableName,
+			table.TableName,
+			table.TableName,
 			ownerCols,
 		),
 	}
 
 	for _, stmt := range stmts {
-		if _, err := a.db.Exec(stmt); err != nil {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
 			return err
 		}
 	}
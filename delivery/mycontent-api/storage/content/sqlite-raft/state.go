@@ -0,0 +1,26 @@
+package sqliteraft
+
+type state struct {
+	EventIndexes   map[string]*uint64 `json:"event_indexes"`
+	VersionIndexes map[string]*uint64 `json:"version_indexes"`
+}
+
+// clone to restore the indexes of a failed batch
+func (s *state) clone() *state {
+	c := &state{
+		EventIndexes:   make(map[string]*uint64, len(s.EventIndexes)),
+		VersionIndexes: make(map[string]*uint64, len(s.VersionIndexes)),
+	}
+
+	for k, v := range s.EventIndexes {
+		index := *v
+		c.EventIndexes[k] = &index
+	}
+
+	for k, v := range s.VersionIndexes {
+		index := *v
+		c.VersionIndexes[k] = &index
+	}
+
+	return c
+}
@@ -2,42 +2,25 @@ package sqliteraft
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
 )
 
-func (r *repository) stream(
+func (a *ContentApp) stream(
 	ctx context.Context,
+	table TableConfig,
 	namespace string,
 	refIDs []string,
 	ID string,
 ) (<-chan content.Data, error) {
 
-	if err := r.app.validateKey(namespace, refIDs); err != nil {
+	query, args, err := prepareGet(table, namespace, refIDs, ID)
+	if err != nil {
 		return nil, err
 	}
 
-	query := fmt.Sprintf(`
-SELECT
-	%s
-FROM %s
-WHERE %s`,
-		r.app.selectColumns(),
-		r.app.tableConfig.TableName,
-		r.app.ownerWhere(),
-	)
-
-	args := r.app.ownerArgs(namespace, refIDs)
-
-	if ID != "" {
-		query += "\nAND id=?"
-		args = append(args, ID)
-	}
-
-	query += "\nORDER BY event_id ASC"
-
-	rows, err := r.app.db.QueryContext(
+	rows, err := raft_runner.GetSQLiteConnection(ctx).QueryContext(
 		ctx,
 		query,
 		args...,
@@ -54,7 +37,7 @@ WHERE %s`,
 
 		for rows.Next() {
 
-			item, err := r.scanRow(rows)
+			item, err := scanRow(table, rows)
 			if err != nil {
 				return
 			}
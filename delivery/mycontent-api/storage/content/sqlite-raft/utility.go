@@ -7,7 +7,7 @@ import (
 	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 )
 
-func (a *ContentApp) validateKey(
+func (t TableConfig) validateKey(
 	namespace string,
 	refIDs []string,
 ) error {
@@ -16,7 +16,7 @@ func (a *ContentApp) validateKey(
 		return content.ErrInvalidKey
 	}
 
-	if len(refIDs) != a.tableConfig.RefSize {
+	if len(refIDs) != t.RefSize {
 		return content.ErrInvalidKey
 	}
 
@@ -29,8 +29,8 @@ func (a *ContentApp) validateKey(
 	return nil
 }
 
-func (a *ContentApp) refColumns() []string {
-	cols := make([]string, a.tableConfig.RefSize)
+func (t TableConfig) refColumns() []string {
+	cols := make([]string, t.RefSize)
 
 	for i := range cols {
 		cols[i] = fmt.Sprintf("ref%d", i)
@@ -39,14 +39,14 @@ func (a *ContentApp) refColumns() []string {
 	return cols
 }
 
-func (a *ContentApp) ownerColumns() []string {
+func (t TableConfig) ownerColumns() []string {
 	cols := []string{"namespace"}
-	cols = append(cols, a.refColumns()...)
+	cols = append(cols, t.refColumns()...)
 	return cols
 }
 
-func (a *ContentApp) primaryColumns() []string {
-	cols := a.ownerColumns()
+func (t TableConfig) primaryColumns() []string {
+	cols := t.ownerColumns()
 	cols = append(cols, "id")
 	return cols
 }
@@ -59,8 +59,9 @@ func placeholders(n int) string {
 	return strings.Join(p, ", ")
 }
 
-func (a *ContentApp) insertColumns() string {
-	cols := a.primaryColumns()
+func (t TableConfig) insertColumns() string {
+	cols := []string{"event_id"}
+	cols = append(cols, t.primaryColumns()...)
 	cols = append(cols,
 		"data",
 		"meta",
@@ -68,17 +69,17 @@ func (a *ContentApp) insertColumns() string {
 	return strings.Join(cols, ", ")
 }
 
-func (a *ContentApp) insertPlaceholders() string {
-	return placeholders(len(a.primaryColumns()) + 2)
+func (t TableConfig) insertPlaceholders() string {
+	return placeholders(1 + len(t.primaryColumns()) + 2)
 }
 
-func (a *ContentApp) ownerWhere() string {
+func (t TableConfig) ownerWhere() string {
 
 	var where []string
 
 	where = append(where, "namespace=?")
 
-	for i := 0; i < a.tableConfig.RefSize; i++ {
+	for i := 0; i < t.RefSize; i++ {
 		where = append(where,
 			fmt.Sprintf("ref%d=?", i),
 		)
@@ -87,11 +88,11 @@ func (a *ContentApp) ownerWhere() string {
 	return strings.Join(where, " AND ")
 }
 
-func (a *ContentApp) primaryWhere() string {
-	return a.ownerWhere() + " AND id=?"
+func (t TableConfig) primaryWhere() string {
+	return t.ownerWhere() + " AND id=?"
 }
 
-func (a *ContentApp) ownerArgs(
+func (t TableConfig) ownerArgs(
 	namespace string,
 	refIDs []string,
 ) []any {
@@ -107,26 +108,26 @@ func (a *ContentApp) ownerArgs(
 	return args
 }
 
-func (a *ContentApp) primaryArgs(
+func (t TableConfig) primaryArgs(
 	namespace string,
 	refIDs []string,
 	id string,
 ) []any {
 
-	args := a.ownerArgs(namespace, refIDs)
+	args := t.ownerArgs(namespace, refIDs)
 	args = append(args, id)
 
 	return args
 }
 
-func (a *ContentApp) selectColumns() string {
+func (t TableConfig) selectColumns() string {
 
 	cols := []string{
 		"event_id",
 		"namespace",
 	}
 
-	cols = append(cols, a.refColumns()...)
+	cols = append(cols, t.refColumns()...)
 
 	cols = append(cols,
 		"id",
@@ -11,17 +11,24 @@ var (
 )
 
 type Repository interface {
+	// Post (create or overwrite) the data. If the data has ExpectedVersion, it is a compare-and-set:
+	// ErrConflict (as *ConflictError) if the current version of the data is not the expected one,
+	// or ErrCompareAndSetNotSupported if the storage cannot do it atomically.
 	Post(ctx context.Context, namespace string, refIDs []string, ID string, data Data) (Data, error)
 
 	// Get daya by owner ID
 	Get(ctx context.Context, namespace string, refIDs []string, ID string) ([]Data, error)
 
 	// Delete specific ID data. If no data, MUST return error
+	// If the context has GetExpectedVersion, it is a compare-and-set the same as Post.
 	Delete(ctx context.Context, namespace string, refIDs []string, ID string) (Data, error)
 
 	// Stream Get data
 	Stream(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan Data, error)
 
+	// List data under the namespace & the (partial) ref IDs, page by page
+	List(ctx context.Context, namespace string, refIDs []string, q Query) (Page, error)
+
 	// TODO: add ref size
 	// RefSize() int // Map key value for param / metadata
 }
@@ -44,4 +51,8 @@ type Data struct {
 	// The actual data
 	Data []byte
 	Meta []byte
+
+	// ExpectedVersion for the compare-and-set of Post; 0 expects the data to not exist yet.
+	// Nil to always write.
+	ExpectedVersion *uint64
 }
@@ -0,0 +1,129 @@
+package content
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+type expectedVersionKey struct{}
+
+var (
+	// ErrConflict when the current version of the data is not the expected version of the compare-and-set
+	ErrConflict = errors.New("version conflict")
+
+	// ErrCompareAndSetNotSupported by the storage that cannot compare-and-set atomically
+	ErrCompareAndSetNotSupported = errors.New("compare-and-set is not supported")
+)
+
+const nextVersionConflict = " of the next version"
+
+// ConflictError has the current version, so the caller can retry with it
+type ConflictError struct {
+	Expected uint64
+	Current  uint64
+
+	// NextVersion is the conflict of the optimistic lock of versioned data: the versions count, rather than Data.Version
+	NextVersion bool
+}
+
+func (e *ConflictError) Error() string {
+	if e.NextVersion {
+		return fmt.Sprintf("%v%v (expected %v, current %v)", ErrConflict, nextVersionConflict, e.Expected, e.Current)
+	}
+	return fmt.Sprintf("%v (expected %v, current %v)", ErrConflict, e.Expected, e.Current)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ParseConflictError from the message of a ConflictError, eg. when only the message goes through raft
+func ParseConflictError(msg string) (*ConflictError, bool) {
+	idx := strings.Index(msg, ErrConflict.Error())
+	if idx < 0 {
+		return nil, false
+	}
+
+	var e ConflictError
+	rest := msg[idx+len(ErrConflict.Error()):]
+	rest, e.NextVersion = strings.CutPrefix(rest, nextVersionConflict)
+	_, err := fmt.Sscanf(rest, " (expected %d, current %d)", &e.Expected, &e.Current)
+	if err != nil {
+		return nil, false
+	}
+
+	return &e, true
+}
+
+// Version of the stored data, changed whenever the data or the meta is written.
+// It is hashed from the canonical JSON, so it does not depend on how the storage formats it.
+// It is never 0, which is the version of data that does not exist.
+func (d Data) Version() uint64 {
+	h := fnv.New64a()
+	h.Write(canonicalJSON(d.Data))
+	h.Write([]byte{0})
+	h.Write(canonicalJSON(d.Meta))
+
+	version := h.Sum64()
+	if version == 0 {
+		version = 1
+	}
+
+	return version
+}
+
+// CheckVersion of the current data (nil if it does not exist) against the expected version of the written data
+func CheckVersion(current *Data, written Data) error {
+	if written.ExpectedVersion == nil {
+		return nil
+	}
+
+	var version uint64
+	if current != nil {
+		version = current.Version()
+	}
+
+	if version != *written.ExpectedVersion {
+		return &ConflictError{Expected: *written.ExpectedVersion, Current: version}
+	}
+
+	return nil
+}
+
+// WithExpectedVersion makes the Delete of the request a compare-and-set, the same as Data.ExpectedVersion of Post
+func WithExpectedVersion(ctx context.Context, version uint64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// WithoutExpectedVersion for the other Delete of the same request
+func WithoutExpectedVersion(ctx context.Context) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, nil)
+}
+
+// GetExpectedVersion of the Delete of the request; nil if not specified
+func GetExpectedVersion(ctx context.Context) *uint64 {
+	version, ok := ctx.Value(expectedVersionKey{}).(uint64)
+	if !ok {
+		return nil
+	}
+	return &version
+}
+
+func canonicalJSON(in []byte) []byte {
+	var v any
+	if err := json.Unmarshal(in, &v); err != nil {
+		return in
+	}
+
+	// map keys are sorted
+	out, err := json.Marshal(v)
+	if err != nil {
+		return in
+	}
+
+	return out
+}
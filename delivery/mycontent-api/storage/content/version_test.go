@@ -0,0 +1,29 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseConflictError(t *testing.T) {
+	for _, want := range []*ConflictError{
+		{Expected: 1, Current: 2},
+		{Expected: 3, Current: 4, NextVersion: true},
+	} {
+		// only the message goes through raft
+		msg := fmt.Errorf("failed to post: %w", want).Error()
+
+		got, ok := ParseConflictError(msg)
+		if !ok || *got != *want {
+			t.Fatalf("%q: got %+v, want %+v", msg, got, want)
+		}
+		if !errors.Is(got, ErrConflict) {
+			t.Fatalf("%q: expected ErrConflict", msg)
+		}
+	}
+
+	if _, ok := ParseConflictError("version conflict, somehow"); ok {
+		t.Fatal("expected no conflict error")
+	}
+}
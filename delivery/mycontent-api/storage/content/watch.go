@@ -0,0 +1,55 @@
+package content
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrWatchNotSupported = errors.New("watch is not supported")
+
+	// ErrWatchExpired if the events since the event ID to resume from are no longer kept
+	ErrWatchExpired = errors.New("watch cannot resume from the event")
+)
+
+type EventType string
+
+const (
+	EventPost   EventType = "post"
+	EventDelete EventType = "delete"
+)
+
+// Event of a change in a table. The EventID of the data orders the events of the table.
+// For EventDelete, only the EventID and the key of the data are set.
+type Event struct {
+	Table string
+	Type  EventType
+	Data  Data
+}
+
+// Watcher is implemented by the repository that publishes its changes
+type Watcher interface {
+	// Watch the events under the namespace & the (partial) ref IDs until the context is done.
+	// If fromEventID is specified, every event since then is backfilled from the storage first,
+	// or ErrWatchExpired if the storage no longer has them.
+	Watch(ctx context.Context, namespace string, refIDs []string, fromEventID *uint64) (<-chan Event, error)
+}
+
+// Match the event to the namespace ("*" for all) & the (partial) ref IDs
+func (e Event) Match(namespace string, refIDs []string) bool {
+	if namespace != "*" && namespace != e.Data.Namespace {
+		return false
+	}
+
+	if len(refIDs) > len(e.Data.RefIDs) {
+		return false
+	}
+
+	for i, ref := range refIDs {
+		if e.Data.RefIDs[i] != ref {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,144 @@
+package mycontentapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	types "github.com/desain-gratis/common/types/http"
+)
+
+const (
+	ContentTypeNDJSON = "application/x-ndjson"
+	ContentTypeSSE    = "text/event-stream"
+
+	streamFlushInterval = 100 * time.Millisecond
+)
+
+// streamWriter writes the Stream response as frames.
+//
+// NDJSON (the default) writes one types.CommonResponse per line: {"success": <data>} for each data,
+// and a terminal {"error": ...} if the stream ends early.
+// SSE (Accept: text/event-stream) writes a "data" event for each data, and a terminal "error" event.
+type streamWriter struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	sse   bool
+	dirty bool
+}
+
+func newStreamWriter(w http.ResponseWriter, accept string) *streamWriter {
+	return &streamWriter{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		sse: acceptSSE(accept),
+	}
+}
+
+// acceptSSE if the client prefers SSE over NDJSON
+func acceptSSE(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case ContentTypeSSE:
+			return true
+		case ContentTypeNDJSON:
+			return false
+		}
+	}
+	return false
+}
+
+func (s *streamWriter) start() {
+	if s.sse {
+		s.w.Header().Set("Content-Type", ContentTypeSSE)
+	} else {
+		s.w.Header().Set("Content-Type", ContentTypeNDJSON)
+	}
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("X-Accel-Buffering", "no")
+
+	s.w.WriteHeader(http.StatusOK)
+	s.dirty = true
+	s.flush()
+}
+
+func (s *streamWriter) write(data any) error {
+	var payload []byte
+	var err error
+	if s.sse {
+		payload, err = json.Marshal(data)
+	} else {
+		payload, err = json.Marshal(&types.CommonResponse{Success: data})
+	}
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	return s.frame("", "data", payload)
+}
+
+// writeError as the terminal frame. The status code is already sent, so it only goes to the body.
+func (s *streamWriter) writeError(err error) {
+	code, msg, httpStatus := "SERVER_ERROR", "stream ended early: server error", http.StatusInternalServerError
+	if errors.Is(err, context.DeadlineExceeded) {
+		code, msg, httpStatus = "TIMEOUT", "stream ended early: timeout", http.StatusGatewayTimeout
+	}
+	slog.Error("stream ended early", slog.String("error", err.Error()))
+
+	commonErr := &types.CommonError{
+		Errors: []types.Error{
+			{Message: msg, Code: code, HTTPCode: httpStatus},
+		},
+	}
+
+	var payload []byte
+	if s.sse {
+		payload, _ = json.Marshal(commonErr)
+	} else {
+		payload = serializeError(commonErr)
+	}
+
+	if err := s.frame("", "error", payload); err != nil {
+		return
+	}
+	s.flush()
+}
+
+// frame of the payload; the event & the ID (if any) are only for SSE
+func (s *streamWriter) frame(id string, event string, payload []byte) (err error) {
+	if s.sse && id != "" {
+		_, err = fmt.Fprintf(s.w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload)
+	} else if s.sse {
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s\n", payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.dirty = true
+	return nil
+}
+
+// flush the buffered frames to the client, if any
+func (s *streamWriter) flush() {
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.Warn("failed to flush stream", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,136 @@
+package mycontentapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	"github.com/desain-gratis/common/types/entity"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// streamRepo streams the items without looking at the context, the same as the raft lookup
+type streamRepo struct {
+	content.Repository
+	items []content.Data
+	done  chan struct{}
+}
+
+func (s *streamRepo) Stream(ctx context.Context, namespace string, refIDs []string, ID string) (<-chan content.Data, error) {
+	ch := make(chan content.Data)
+	go func() {
+		defer close(s.done)
+		defer close(ch)
+		for _, item := range s.items {
+			ch <- item
+		}
+	}()
+	return ch, nil
+}
+
+func newStreamAPI(items ...string) (*streamRepo, http.Handler) {
+	repo := &streamRepo{done: make(chan struct{})}
+	for idx, item := range items {
+		repo.items = append(repo.items, content.Data{ID: fmt.Sprint(idx), Namespace: "ns", Data: []byte(item)})
+	}
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/doc", mycontentapi.New[*entity.Document](base.New[*entity.Document](repo), "http://localhost/doc", nil))
+
+	return repo, router
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	_, router := newStreamAPI(`{"id":"0","namespace":"ns"}`, `{"id":"1","namespace":"ns"}`, `not json`)
+
+	rec := serve(t, router, http.MethodGet, "/doc/stream", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != mycontentapi.ContentTypeNDJSON {
+		t.Fatalf("expected NDJSON stream, got %v %v", rec.Code, rec.Header())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 data & 1 error line, got %q", rec.Body.String())
+	}
+
+	for idx, line := range lines[:2] {
+		var frame types.CommonResponseTyped[entity.Document]
+		if err := json.Unmarshal([]byte(line), &frame); err != nil || frame.Success["id"] != fmt.Sprint(idx) {
+			t.Fatalf("line %v: expected the data, got %q %v", idx, line, err)
+		}
+	}
+
+	// the data that cannot be parsed ends the stream with the terminal error
+	var last types.CommonResponse
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil || last.Error == nil || last.Error.Errors[0].Code != "SERVER_ERROR" {
+		t.Fatalf("expected the terminal error line, got %q %v", lines[2], err)
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	_, router := newStreamAPI(`{"id":"0","namespace":"ns"}`, `not json`)
+
+	rec := serve(t, router, http.MethodGet, "/doc/stream", "", "Accept", "application/json, text/event-stream")
+	if rec.Header().Get("Content-Type") != mycontentapi.ContentTypeSSE {
+		t.Fatalf("expected SSE stream, got %v", rec.Header())
+	}
+
+	events := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 2 {
+		t.Fatalf("expected 1 data & 1 error event, got %q", rec.Body.String())
+	}
+	if !strings.HasPrefix(events[0], "event: data\ndata: {") || !strings.Contains(events[0], `"id":"0"`) {
+		t.Fatalf("expected the data event, got %q", events[0])
+	}
+	if !strings.HasPrefix(events[1], "event: error\ndata: {\"errors\":[") {
+		t.Fatalf("expected the terminal error event, got %q", events[1])
+	}
+}
+
+func TestStreamDisconnect(t *testing.T) {
+	// more than the connection can buffer
+	padding := strings.Repeat("x", 1<<10)
+	items := make([]string, 10000)
+	for idx := range items {
+		items[idx] = fmt.Sprintf(`{"id":"%v","namespace":"ns","padding":"%v"}`, idx, padding)
+	}
+	repo, router := newStreamAPI(items...)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/doc/stream", nil)
+	req.Header.Set("X-Namespace", "ns")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !bufio.NewScanner(resp.Body).Scan() {
+		t.Fatal("expected the first line of the stream")
+	}
+
+	// the client is gone after the first data; the storage must not be left blocked on sending the rest
+	cancel()
+
+	select {
+	case <-repo.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the storage stream is still blocked after the client disconnected")
+	}
+}
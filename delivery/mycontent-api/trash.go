@@ -0,0 +1,147 @@
+package mycontentapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	types "github.com/desain-gratis/common/types/http"
+)
+
+// Trash lists the soft deleted resources under the (partial) ref params, latest deleted first.
+// It is paginated the same as Get, with "limit" & "cursor".
+func (i *service[T]) Trash(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistTrashParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	trashable, ok := i.uc.(mycontent.Trashable[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "soft delete is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	q, err := parsePageQuery(r.URL.Query())
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	ctx, err := withReadConsistency(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	page, err := trashable.Trash(ctx, namespace, refIDs, q)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	if page.Next != "" {
+		w.Header().Set("DG-Next-Cursor", page.Next)
+	}
+
+	for _, pp := range i.postProcess {
+		for idx := range page.Data {
+			pp(page.Data[idx])
+		}
+	}
+
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: page.Data,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
+
+// Undelete the resource located by the URL parameters (the same as Delete) from the trash.
+// Conflict if the resource has been written again since it is deleted.
+func (i *service[T]) Undelete(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionWrite, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	trashable, ok := i.uc.(mycontent.Trashable[T])
+	if !ok {
+		handleError(w, "NOT_IMPLEMENTED", "soft delete is not supported", http.StatusNotImplemented, nil)
+		return
+	}
+
+	ID := r.URL.Query().Get("id")
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	result, err := trashable.Undelete(r.Context(), namespace, refIDs, ID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	for _, pp := range i.postProcess {
+		pp(result)
+	}
+
+	payload, err := json.Marshal(&types.CommonResponse{
+		Success: &result,
+	})
+	if err != nil {
+		handleError(
+			w, "SERVER_ERROR", "server encounter an error",
+			http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(payload)
+}
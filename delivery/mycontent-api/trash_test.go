@@ -0,0 +1,152 @@
+package mycontentapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+// failingTrash fails the delete of the tombstone of the ID
+type failingTrash struct {
+	content.Repository
+	failID string
+}
+
+func (f *failingTrash) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (content.Data, error) {
+	if ID == f.failID {
+		return content.Data{}, errors.New("injected failure")
+	}
+	return f.Repository.Delete(ctx, namespace, refIDs, ID)
+}
+
+func newTrashAPI(t *testing.T, retention time.Duration) (*base.Handler[*entity.Document], *failingTrash, http.Handler) {
+	t.Helper()
+
+	storage := newStorage(t,
+		sqliteraft.TableConfig{TableName: "doc", RefSize: 1},
+		sqliteraft.TableConfig{TableName: "doc_trash", RefSize: 1},
+	)
+	trash := &failingTrash{Repository: storage["doc_trash"]}
+	uc := base.New[*entity.Document](storage["doc"]).WithSoftDelete(trash, retention)
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/doc", mycontentapi.New[*entity.Document](uc, "http://localhost/doc", []string{"owner"}))
+
+	return uc, trash, router
+}
+
+func documentIDs(docs []entity.Document) []string {
+	result := make([]string, 0, len(docs))
+	for _, d := range docs {
+		result = append(result, d.ID())
+	}
+	return result
+}
+
+func TestSoftDeleteAndUndelete(t *testing.T) {
+	_, _, router := newTrashAPI(t, time.Hour)
+
+	for _, id := range []string{"d1", "d2", "d3"} {
+		decode[entity.Document](t, serve(t, router, http.MethodPost, "/doc", `{"id":"`+id+`","namespace":"ns","ref_ids":["o"],"title":"`+id+`"}`))
+	}
+	for _, id := range []string{"d1", "d2"} {
+		decode[entity.Document](t, serve(t, router, http.MethodDelete, "/doc?owner=o&id="+id, ""))
+	}
+
+	if status, code := errorCode(t, serve(t, router, http.MethodGet, "/doc?owner=o&id=d1", "")); status != http.StatusNotFound || code != "NOT_FOUND" {
+		t.Fatalf("expected the deleted resource not found, got %v %v", status, code)
+	}
+
+	// latest deleted first, page by page
+	rec := serve(t, router, http.MethodGet, "/doc/trash?owner=o&limit=1", "")
+	if got := documentIDs(decode[[]entity.Document](t, rec)); len(got) != 1 || got[0] != "d2" {
+		t.Fatalf("expected the latest deleted first, got %v", got)
+	}
+	rec = serve(t, router, http.MethodGet, "/doc/trash?owner=o&limit=1&cursor="+rec.Header().Get("DG-Next-Cursor"), "")
+	if got := documentIDs(decode[[]entity.Document](t, rec)); len(got) != 1 || got[0] != "d1" {
+		t.Fatalf("expected the first deleted in the next page, got %v", got)
+	}
+
+	rec = serve(t, router, http.MethodPost, "/doc/undelete?owner=o&id=d1", "")
+	if got := decode[entity.Document](t, rec); got.ID() != "d1" || got["title"] != "d1" {
+		t.Fatalf("expected the undeleted resource, got %+v", got)
+	}
+	if got := decode[[]entity.Document](t, serve(t, router, http.MethodGet, "/doc?owner=o&id=d1", "")); len(got) != 1 || got[0]["title"] != "d1" {
+		t.Fatalf("expected the resource back in its place, got %+v", got)
+	}
+	if got := documentIDs(decode[[]entity.Document](t, serve(t, router, http.MethodGet, "/doc/trash?owner=o", ""))); len(got) != 1 || got[0] != "d2" {
+		t.Fatalf("expected only the other one in the trash, got %v", got)
+	}
+
+	if status, code := errorCode(t, serve(t, router, http.MethodPost, "/doc/undelete?owner=o&id=d1", "")); status != http.StatusNotFound || code != "NOT_FOUND" {
+		t.Fatalf("expected not found in the trash, got %v %v", status, code)
+	}
+
+	// the place is written since the delete
+	decode[entity.Document](t, serve(t, router, http.MethodPost, "/doc", `{"id":"d2","namespace":"ns","ref_ids":["o"],"title":"new"}`))
+	if status, code := errorCode(t, serve(t, router, http.MethodPost, "/doc/undelete?owner=o&id=d2", "")); status != http.StatusConflict || code != "CONFLICT" {
+		t.Fatalf("expected conflict, got %v %v", status, code)
+	}
+
+	// a failed precondition is not moved to the trash
+	rec = serve(t, router, http.MethodDelete, "/doc?owner=o&id=d3", "", "If-Match", `"1"`)
+	if status, code := errorCode(t, rec); status != http.StatusPreconditionFailed || code != "PRECONDITION_FAILED" {
+		t.Fatalf("expected precondition failed, got %v %v", status, code)
+	}
+	decode[[]entity.Document](t, serve(t, router, http.MethodGet, "/doc?owner=o&id=d3", ""))
+}
+
+func TestPurge(t *testing.T) {
+	uc, trash, router := newTrashAPI(t, time.Hour)
+	ctx := context.Background()
+
+	// more than a page of the purge
+	tombstone := func(id string, deletedAt time.Time) {
+		t.Helper()
+		_, err := trash.Post(ctx, "ns", []string{"o"}, id, content.Data{
+			Namespace: "ns",
+			RefIDs:    []string{"o"},
+			ID:        id,
+			Data:      []byte(`{"id":"` + id + `","namespace":"ns","ref_ids":["o"]}`),
+			Meta:      []byte(`{"created_at":"` + deletedAt.Format(time.RFC3339Nano) + `"}`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for idx := range 150 {
+		tombstone(fmt.Sprintf("e%03d", idx), time.Now().Add(-2*time.Hour))
+	}
+	tombstone("recent", time.Now())
+	trash.failID = "e042"
+
+	purged, err := uc.Purge(ctx)
+	if purged != 149 || err == nil {
+		t.Fatalf("expected every expired tombstone purged but the failing one, with its error: %v %v", purged, err)
+	}
+
+	got := documentIDs(decode[[]entity.Document](t, serve(t, router, http.MethodGet, "/doc/trash?owner=o", "")))
+	if len(got) != 2 {
+		t.Fatalf("expected the failing & the recent tombstones kept, got %v", got)
+	}
+
+	// the failing one is purged in the next run
+	trash.failID = ""
+	if purged, err := uc.Purge(ctx); purged != 1 || err != nil {
+		t.Fatalf("expected the rest purged, got %v %v", purged, err)
+	}
+	if got := documentIDs(decode[[]entity.Document](t, serve(t, router, http.MethodGet, "/doc/trash?owner=o", ""))); len(got) != 1 || got[0] != "recent" {
+		t.Fatalf("expected only the recent tombstone kept, got %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package mycontentapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/julienschmidt/httprouter"
+	"github.com/rs/zerolog/log"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
+)
+
+const watchHeartbeatInterval = 15 * time.Second
+
+// WithWebsocketOrigins allows cross origin websocket for Watch, eg. "https://*.desain.gratis"
+func (i *service[T]) WithWebsocketOrigins(originPatterns ...string) *service[T] {
+	i.websocketOrigins = originPatterns
+	return i
+}
+
+// Watch the changes of the content over SSE, or websocket if it is an upgrade request.
+// The "from" parameter (or the Last-Event-ID header of SSE reconnection) resumes from the event ID,
+// backfilled from the storage; without it, only the live changes are sent.
+//
+// Only clickhouse-raft with the event log of the table can resume; it also sends the changes of every replica.
+// The other storages send the live changes written through this instance only, and answer 410 to resume.
+func (i *service[T]) Watch(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	namespace := r.Header.Get("X-Namespace")
+	if namespace == "" {
+		handleError(
+			w, "BAD_REQUEST", "'X-Namespace' header is empty",
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	if !i.authorize(w, r, mycontent.ActionRead, namespace) {
+		return
+	}
+
+	invalidParams := validateParams(i.whitelistWatchParams, r.URL.Query())
+	if len(invalidParams) > 0 {
+		handleError(
+			w, "BAD_REQUEST", "invalid parameter(s): "+strings.Join(invalidParams, ","),
+			http.StatusBadRequest, nil)
+		return
+	}
+
+	refIDs := make([]string, 0, len(i.refParams))
+	for _, param := range i.refParams {
+		refIDs = append(refIDs, r.URL.Query().Get(param))
+	}
+
+	fromEventID, err := parseFromEventID(r)
+	if err != nil {
+		handleError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest, nil)
+		return
+	}
+
+	watchable, ok := i.uc.(mycontent.Watchable[T])
+	if !ok {
+		handleUsecaseError(w, content.ErrWatchNotSupported)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events, err := watchable.Watch(ctx, namespace, refIDs, fromEventID)
+	if err != nil {
+		handleUsecaseError(w, err)
+		return
+	}
+
+	if isWebsocketUpgrade(r) {
+		i.watchWebsocket(ctx, cancel, w, r, events)
+		return
+	}
+
+	i.watchSSE(ctx, w, events)
+}
+
+func (i *service[T]) watchSSE(ctx context.Context, w http.ResponseWriter, events <-chan mycontent.Event[T]) {
+	sw := newStreamWriter(w, ContentTypeSSE)
+	sw.start()
+
+	flush := time.NewTicker(streamFlushInterval)
+	defer flush.Stop()
+
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			sw.flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			sw.dirty = true
+		case event, ok := <-events:
+			if !ok {
+				sw.flush()
+				return
+			}
+
+			payload, err := json.Marshal(i.postProcessEvent(event))
+			if err != nil {
+				sw.writeError(err)
+				return
+			}
+
+			err = sw.frame(strconv.FormatUint(event.EventID, 10), string(event.Type), payload)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (i *service[T]) watchWebsocket(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	w http.ResponseWriter,
+	r *http.Request,
+	events <-chan mycontent.Event[T],
+) {
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: i.websocketOrigins,
+	})
+	if err != nil {
+		log.Error().Msgf("error accept %v", err)
+		return
+	}
+	defer c.CloseNow()
+
+	// only server sends; stop watching once the client closes
+	readCtx := c.CloseRead(ctx)
+	go func() {
+		<-readCtx.Done()
+		cancel()
+	}()
+
+	for event := range events {
+		payload, err := json.Marshal(i.postProcessEvent(event))
+		if err != nil {
+			log.Err(err).Msgf("failed to marshal event %v", event.EventID)
+			c.Close(websocket.StatusInternalError, "server error")
+			return
+		}
+
+		err = c.Write(ctx, websocket.MessageText, payload)
+		if err != nil {
+			return
+		}
+	}
+
+	c.Close(websocket.StatusNormalClosure, "")
+}
+
+func (i *service[T]) postProcessEvent(event mycontent.Event[T]) mycontent.Event[T] {
+	if event.Type != content.EventPost {
+		return event
+	}
+
+	for _, pp := range i.postProcess {
+		pp(event.Data)
+	}
+
+	return event
+}
+
+func parseFromEventID(r *http.Request) (*uint64, error) {
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		last, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Last-Event-ID header: %v", lastEventID)
+		}
+		from := last + 1
+		return &from, nil
+	}
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		from, err := strconv.ParseUint(fromStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'from' parameter: %v", fromStr)
+		}
+		return &from, nil
+	}
+
+	return nil, nil
+}
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
@@ -0,0 +1,98 @@
+package mycontentapi_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	sqliteraft "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/sqlite-raft"
+	"github.com/desain-gratis/common/types/entity"
+)
+
+// sseEvent reads the next event of the SSE stream, as its type & data
+func sseEvent(t *testing.T, scanner *bufio.Scanner) (string, string) {
+	t.Helper()
+
+	var eventType, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && eventType != "":
+			return eventType, data
+		}
+	}
+
+	t.Fatalf("the stream ended before the event: %v", scanner.Err())
+	return "", ""
+}
+
+// sqlite-raft is not a content.Watcher; the writes through the handler are watched instead
+func TestWatchPublished(t *testing.T) {
+	storage := newStorage(t, sqliteraft.TableConfig{TableName: "doc", RefSize: 1})
+
+	router := httprouter.New()
+	mycontentapi.NewRegistrar(router).Resource("/doc", mycontentapi.New[*entity.Document](
+		base.New[*entity.Document](storage["doc"]), "http://localhost/doc", []string{"owner"}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/doc/watch?owner=o1", nil)
+	req.Header.Set("X-Namespace", "ns")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != mycontentapi.ContentTypeSSE {
+		t.Fatalf("expected the SSE stream, got %v %v", resp.StatusCode, resp.Header)
+	}
+
+	// of another owner, not watched
+	rec := serve(t, router, http.MethodPost, "/doc", `{"id":"d0","namespace":"ns","ref_ids":["o2"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to post: %v %s", rec.Code, rec.Body.String())
+	}
+	rec = serve(t, router, http.MethodPost, "/doc", `{"id":"d1","namespace":"ns","ref_ids":["o1"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to post: %v %s", rec.Code, rec.Body.String())
+	}
+	rec = serve(t, router, http.MethodDelete, "/doc?owner=o1&id=d1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to delete: %v %s", rec.Code, rec.Body.String())
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	eventType, data := sseEvent(t, scanner)
+	if eventType != "post" || !strings.Contains(data, `"id":"d1"`) || !strings.Contains(data, `"data":{`) {
+		t.Fatalf("expected the post of d1, got %v %v", eventType, data)
+	}
+
+	eventType, data = sseEvent(t, scanner)
+	if eventType != "delete" || !strings.Contains(data, `"id":"d1"`) {
+		t.Fatalf("expected the delete of d1, got %v %v", eventType, data)
+	}
+
+	// nothing is kept to resume from
+	rec = serve(t, router, http.MethodGet, "/doc/watch?owner=o1&from=1", "")
+	if status, code := errorCode(t, rec); status != http.StatusGone || code != "GONE" {
+		t.Fatalf("expected 410 to resume, got %v %v", status, code)
+	}
+}
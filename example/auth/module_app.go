@@ -9,6 +9,7 @@ import (
 	idtokenverifier "github.com/desain-gratis/common/delivery/auth-api/idtoken-verifier"
 	mycontentapi "github.com/desain-gratis/common/delivery/mycontent-api"
 	mycontent_base "github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	mycontent_policy "github.com/desain-gratis/common/delivery/mycontent-api/policy"
 	blob_gcs "github.com/desain-gratis/common/delivery/mycontent-api/storage/blob/gcs"
 	content_postgres "github.com/desain-gratis/common/delivery/mycontent-api/storage/content/postgres"
 	"github.com/desain-gratis/common/example/auth/entity"
@@ -100,12 +101,12 @@ func enableApplicationAPI(
 		"",
 	)
 
-	// Sample API
+	// Sample API, authorized by the grant to the namespace
 	projectService := mycontentapi.New(
 		projectUsecase,
 		baseURL+"/project",
 		[]string{},
-	)
+	).WithPolicy(mycontent_policy.NewSession(plugin.AuthCtxKey, mycontent_policy.Table{}))
 
 	// Http router
 
@@ -125,22 +126,26 @@ func enableApplicationAPI(
 	router.GET("/auth/keys", appAuth(tokenAPI.Keys))
 
 	// Mycontent authorized user (admin only) endpoint
-	router.OPTIONS("/auth/user", Empty)
-	router.GET("/auth/user", appAuth(plugin.AdminOnly(userAuthService.Get)))
-	router.POST("/auth/user", appAuth(userAuthService.Post))
-	router.DELETE("/auth/user", appAuth(userAuthService.Delete))
+	registrar := mycontentapi.NewRegistrar(router).Use(appAuth)
+	registrar.Resource("/auth/user", userAuthService, adminOnlyGet)
 
 	// Mycontent Authorized user thumbnail (admin only) endpoint
-	router.OPTIONS("/auth/user/thumbnail", Empty)
-	router.GET("/auth/user/thumbnail", appAuth(userAuthThumbnailService.Get))
-	router.POST("/auth/user/thumbnail", appAuth(userAuthThumbnailService.Upload))
-	router.DELETE("/auth/user/thumbnail", appAuth(userAuthThumbnailService.Delete))
+	registrar.Resource("/auth/user/thumbnail", userAuthThumbnailService)
 
 	// Mycontent sample entity
-	router.OPTIONS("/project", Empty)
-	router.GET("/project", appAuth(projectService.Get))
-	router.POST("/project", appAuth(projectService.Post))
-	router.DELETE("/project", appAuth(projectService.Delete))
+	registrar.Resource("/project", projectService)
+}
+
+// adminOnlyGet since listing every authorized user is admin only
+func adminOnlyGet(handle httprouter.Handle) httprouter.Handle {
+	adminOnly := plugin.AdminOnly(handle)
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if r.Method == http.MethodGet {
+			adminOnly(w, r, p)
+			return
+		}
+		handle(w, r, p)
+	}
 }
 
 func Empty(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	common_entity "github.com/desain-gratis/common/types/entity"
 	types "github.com/desain-gratis/common/types/http"
 
@@ -75,6 +76,30 @@ func (a *mcAttachAuth) Get(ctx context.Context, namespace string, refIDs []strin
 	// you can also filter result based on each get result afterward based on "permission"
 }
 
+// List your resource page by page, only in the namespace you are granted; "*" is only for super admin
+func (a *mcAttachAuth) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[*common_entity.Attachment], error) {
+	auth := getAuth(ctx)
+	if auth == nil {
+		return mycontent.Page[*common_entity.Attachment]{}, &types.CommonError{
+			Errors: []types.Error{
+				{
+					HTTPCode: http.StatusInternalServerError,
+					Code:     "EMPTY_AUTHORIZATION",
+					Message:  "authorization is configured by the server, but it's empty. Contact server owner.",
+				},
+			},
+		}
+	}
+
+	// verify namespace
+	err := verifyNamespace(auth, namespace)
+	if err != nil {
+		return mycontent.Page[*common_entity.Attachment]{}, err
+	}
+
+	return a.Handler.List(ctx, namespace, refIDs, q)
+}
+
 // Delete your resource here
 // the implementation can check whether there are linked resource or not
 func (a *mcAttachAuth) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (*common_entity.Attachment, error) {
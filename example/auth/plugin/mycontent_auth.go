@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	types "github.com/desain-gratis/common/types/http"
 )
 
@@ -71,6 +72,30 @@ func (a *mcAuth[T]) Get(ctx context.Context, namespace string, refIDs []string,
 	// you can also filter result based on each get result afterward based on "permission"
 }
 
+// List your resource page by page, only in the namespace you are granted; "*" is only for super admin
+func (a *mcAuth[T]) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	auth := getAuth(ctx)
+	if auth == nil {
+		return mycontent.Page[T]{}, &types.CommonError{
+			Errors: []types.Error{
+				{
+					HTTPCode: http.StatusInternalServerError,
+					Code:     "EMPTY_AUTHORIZATION",
+					Message:  "authorization is configured by the server, but it's empty. Contact server owner.",
+				},
+			},
+		}
+	}
+
+	// verify namespace
+	err := verifyNamespace(auth, namespace)
+	if err != nil {
+		return mycontent.Page[T]{}, err
+	}
+
+	return a.Usecase.List(ctx, namespace, refIDs, q)
+}
+
 // Delete your resource here
 // the implementation can check whether there are linked resource or not
 func (a *mcAuth[T]) Delete(ctx context.Context, namespace string, refIDs []string, ID string) (T, error) {
@@ -74,6 +74,13 @@ func main() {
 		return nil
 	})
 
+	// shard membership management, for operators
+	membershipAPI := raft_runner.NewMembershipAPI()
+	router.GET("/raft/:id/members", membershipAPI.Members)
+	router.POST("/raft/:id/members", membershipAPI.AddReplica)
+	router.DELETE("/raft/:id/members/:replica_id", membershipAPI.RemoveReplica)
+	router.POST("/raft/:id/leader", membershipAPI.TransferLeader)
+
 	// router.PanicHandler = func(w http.ResponseWriter, r *http.Request, i interface{}) {
 	// 	w.WriteHeader(http.StatusInternalServerError)
 	// 	w.Write([]byte("oh no"))
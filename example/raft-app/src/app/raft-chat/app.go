@@ -23,6 +23,7 @@ const (
 )
 
 var _ raft.Application = &chatWriterApp{}
+var _ raft.TableOwner = &chatWriterApp{}
 
 // happySM to isolate all business logic from the state machine technicality
 // because this is an OLAP usecase,  writing to DB, choosing the appropriate DB & indexes are tightly coupled.
@@ -59,6 +60,11 @@ func (s *chatWriterApp) Lookup(ctx context.Context, query interface{}) (interfac
 	return nil, errors.New("unsupported query")
 }
 
+// Tables owned by the app, to be included in the raft snapshot
+func (s *chatWriterApp) Tables(_ context.Context) []string {
+	return []string{"chat_log"}
+}
+
 func (s *chatWriterApp) Init(ctx context.Context) error {
 	conn := raft_runner.GetClickhouseConnection(ctx)
 
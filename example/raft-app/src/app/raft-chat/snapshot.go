@@ -0,0 +1,63 @@
+package raftchat
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+var _ raft.Snapshotter = &chatWriterApp{}
+
+type chatSnapshot struct {
+	chatIndex uint64
+	tables    raft_runner.FrozenTables
+}
+
+func (s *chatWriterApp) PrepareSnapshot(ctx context.Context) (any, error) {
+	tables, err := raft_runner.FreezeTables(ctx, s.Tables(ctx)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chatSnapshot{
+		chatIndex: *s.state.ChatIndex,
+		tables:    tables,
+	}, nil
+}
+
+func (s *chatWriterApp) SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error {
+	snap, ok := snapshot.(*chatSnapshot)
+	if !ok {
+		return fmt.Errorf("unexpected snapshot context: %T", snapshot)
+	}
+	defer snap.tables.Drop(ctx)
+
+	if err := raft_runner.WriteValue(w, state{ChatIndex: &snap.chatIndex}); err != nil {
+		return err
+	}
+
+	return raft_runner.WriteTables(ctx, w, snap.tables, done)
+}
+
+func (s *chatWriterApp) RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error {
+	recovered := &state{}
+	if err := raft_runner.ReadValue(r, recovered); err != nil {
+		return err
+	}
+
+	if recovered.ChatIndex == nil {
+		var idx uint64
+		recovered.ChatIndex = &idx
+	}
+
+	if _, err := raft_runner.ReadTables(ctx, r, done); err != nil {
+		return err
+	}
+
+	s.state = recovered
+
+	return nil
+}
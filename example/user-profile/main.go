@@ -155,34 +155,21 @@ func enableApplicationAPI(
 		"",
 	)
 
-	// Organization
-	router.OPTIONS("/org", Empty)
-	router.GET("/org", organizationHandler.Get)
-	router.POST("/org", organizationHandler.Post)
-	router.DELETE("/org", organizationHandler.Delete)
-
-	// User profile
-	router.OPTIONS("/org/user", Empty)
-	router.GET("/org/user", userProfileHandler.Get)
-	router.POST("/org/user", userProfileHandler.Post)
-	router.DELETE("/org/user", userProfileHandler.Delete)
+	registrar := mycontentapi.NewRegistrar(router).Use(mycontentapi.Logging)
+
+	registrar.
+		Resource("/org", organizationHandler).
+		Resource("/org/user", userProfileHandler).
+		Resource("/org/user/thumbnail", userThumbnailHandler)
 
 	// TODO: since the usage is common, we can just ship it to default mycontentapi
 	topicapi := notifier_api.NewTopicAPI(broker, func(v any) any {
 		data, _ := json.Marshal(v)
 		return string(data)
 	})
-	router.GET("/org/user/tail", topicapi.Tail)
-
-	// User thumbnail
-	router.OPTIONS("/org/user/thumbnail", Empty)
-	router.GET("/org/user/thumbnail", userThumbnailHandler.Get)
-	router.POST("/org/user/thumbnail", userThumbnailHandler.Upload)
-	router.DELETE("/org/user/thumbnail", userThumbnailHandler.Delete)
+	registrar.Handle(http.MethodGet, "/org/user/tail", topicapi.Tail)
 
-}
-
-func Empty(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(""))
+	for _, route := range registrar.Routes() {
+		log.Info().Msgf("mounted %v", route)
+	}
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
 	mycontent_base "github.com/desain-gratis/common/delivery/mycontent-api/mycontent/base"
+	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 	"github.com/desain-gratis/common/lib/notifier"
 )
 
@@ -25,3 +26,8 @@ func (w *withNotifier[T]) Post(ctx context.Context, data T, meta any) (T, error)
 	w.notifier.Broadcast(ctx, v)
 	return v, nil
 }
+
+// List is not a change, so there is nothing to broadcast
+func (w *withNotifier[T]) List(ctx context.Context, namespace string, refIDs []string, q content.Query) (mycontent.Page[T], error) {
+	return w.Handler.List(ctx, namespace, refIDs, q)
+}
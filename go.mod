@@ -20,6 +20,7 @@ require (
 	github.com/lni/dragonboat/v4 v4.0.0-20250723143628-076c7f6497dc
 	github.com/minio/minio-go/v7 v7.0.97
 	github.com/rs/zerolog v1.34.0
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.2
 	github.com/spf13/viper v1.21.0
 	golang.org/x/crypto v0.53.0
 	golang.org/x/image v0.18.0
@@ -3,6 +3,7 @@ package raft
 import (
 	"context"
 	"errors"
+	"io"
 
 	"github.com/lni/dragonboat/v4/raftio"
 	sm "github.com/lni/dragonboat/v4/statemachine"
@@ -50,4 +51,34 @@ type Application interface {
 	Lookup(ctx context.Context, key interface{}) (interface{}, error)
 }
 
+// TableOwner is an optional interface for Application.
+// It lists the tables written by the application so the runner can include them in the raft snapshot.
+type TableOwner interface {
+	Tables(ctx context.Context) []string
+}
+
+// Snapshotter is an optional interface for Application to export & import its own state (tables, indexes, etc.)
+// during raft snapshot. The runner only takes care of the framing of the snapshot stream.
+// If implemented, it takes precedence over TableOwner.
+type Snapshotter interface {
+	// PrepareSnapshot is called while there is no update running.
+	// Capture a consistent point in time view of the app here, and return it as the snapshot context
+	PrepareSnapshot(ctx context.Context) (any, error)
+
+	// SaveSnapshot writes the snapshot context returned by PrepareSnapshot to w.
+	// It might run concurrently with update.
+	SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error
+
+	// RecoverFromSnapshot replaces the app state with the one written by SaveSnapshot
+	RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error
+}
+
+// Savepointer is an optional interface for Application that keeps state in memory during the update.
+// A batch of commands is applied all or nothing: the runner takes a savepoint before the batch, and if a command
+// of the batch fails, the storage writes are rolled back and rollback restores the app state as of the savepoint.
+// Application without it must keep the state of the update only in the storage transaction.
+type Savepointer interface {
+	Savepoint(ctx context.Context) (rollback func(), err error)
+}
+
 type EventLeaderUpdate raftio.LeaderInfo
@@ -0,0 +1,41 @@
+package raft
+
+import (
+	"context"
+	"errors"
+)
+
+type readConsistencyKey struct{}
+
+// ReadConsistency of the query to the replica
+type ReadConsistency string
+
+const (
+	// ReadDefault uses the default of the client
+	ReadDefault ReadConsistency = ""
+
+	// ReadStale reads the local replica as it is; might not see the latest writes
+	ReadStale ReadConsistency = "stale"
+
+	// ReadLinearizable sees every write completed before the read started, in any replica
+	ReadLinearizable ReadConsistency = "linearizable"
+
+	// ReadLeader is linearizable, but only served by the leader. Fails with ErrNotLeader otherwise.
+	ReadLeader ReadConsistency = "leader"
+)
+
+var ErrNotLeader = errors.New("not leader")
+
+// WithReadConsistency overrides the read consistency of the client for the request
+func WithReadConsistency(ctx context.Context, c ReadConsistency) context.Context {
+	return context.WithValue(ctx, readConsistencyKey{}, c)
+}
+
+// GetReadConsistency returns the read consistency of the request, or def if not specified
+func GetReadConsistency(ctx context.Context, def ReadConsistency) ReadConsistency {
+	c, _ := ctx.Value(readConsistencyKey{}).(ReadConsistency)
+	if c == ReadDefault {
+		return def
+	}
+	return c
+}
@@ -0,0 +1,531 @@
+// Package raftest runs a raft.Application in a multi node cluster inside a single process, for testing.
+// Every node has its own NodeHost on a loopback port, with WAL & storage in a temp dir.
+package raftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lni/dragonboat/v4"
+	"github.com/lni/dragonboat/v4/config"
+	"github.com/lni/dragonboat/v4/logger"
+
+	"github.com/desain-gratis/common/lib/raft"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const defaultShardID = 1
+
+var quietOnce sync.Once
+
+type options struct {
+	id                 string
+	shardID            uint64
+	rttMillisecond     uint64
+	snapshotEntries    uint64
+	compactionOverhead uint64
+	timeout            time.Duration
+	storage            func(dir string) raft_runner.StorageEngine
+	encoding           raft_runner.CommandEncoding
+}
+
+type Option func(*options)
+
+// WithCommandEncoding of the commands proposed by every node, default JSON
+func WithCommandEncoding(encoding raft_runner.CommandEncoding) Option {
+	return func(o *options) { o.encoding = encoding }
+}
+
+// WithID sets the replica ID (RaftContext.ID) used by the app, default "raftest"
+func WithID(id string) Option {
+	return func(o *options) { o.id = id }
+}
+
+// WithSnapshotEntries takes a snapshot every n applied entries, keeping only overhead entries in the log.
+// Use it to make a restarted node catch up from a snapshot.
+func WithSnapshotEntries(n, overhead uint64) Option {
+	return func(o *options) {
+		o.snapshotEntries = n
+		o.compactionOverhead = overhead
+	}
+}
+
+// WithTimeout sets how long the helpers wait for the cluster, default 10s
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithStorage sets the storage engine of each node. Default is SQLite in the node directory.
+func WithStorage(storage func(dir string) raft_runner.StorageEngine) Option {
+	return func(o *options) { o.storage = storage }
+}
+
+// Cluster of nodes running the same raft.Application
+type Cluster struct {
+	t      testing.TB
+	opts   options
+	newApp func(replicaID uint64) raft.Application
+
+	deploymentID uint64
+	dir          string
+	members      map[uint64]dragonboat.Target
+	nodes        map[uint64]*Node
+}
+
+// Node is a single replica of the cluster
+type Node struct {
+	ReplicaID uint64
+	Address   string
+
+	// Host and App are replaced on Restart; Host is nil while the node is stopped
+	Host *dragonboat.NodeHost
+	App  raft.Application
+
+	dir  string
+	ctx  context.Context
+	join bool
+}
+
+// Context returns the raft context of the node, to create the app clients (eg. NewStorageClient)
+func (n *Node) Context() context.Context {
+	return n.ctx
+}
+
+func (n *Node) Running() bool {
+	return n.Host != nil
+}
+
+// NewCluster starts size nodes, and waits until there is a leader.
+// newApp is called every time a node (re)starts, like a new process would.
+// The cluster is closed when the test ends.
+func NewCluster(t testing.TB, size int, newApp func(replicaID uint64) raft.Application, opts ...Option) *Cluster {
+	t.Helper()
+
+	quietOnce.Do(func() {
+		for _, name := range []string{"dragonboat", "raft", "rsm", "transport", "logdb", "raftpb", "config", "settings", "grpc"} {
+			logger.GetLogger(name).SetLevel(logger.WARNING)
+		}
+	})
+
+	o := options{
+		id:                 "raftest",
+		shardID:            defaultShardID,
+		rttMillisecond:     10,
+		compactionOverhead: 5,
+		timeout:            10 * time.Second,
+		storage: func(dir string) raft_runner.StorageEngine {
+			return raft_runner.NewSQLiteStorage(dir)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Cluster{
+		t:            t,
+		opts:         o,
+		newApp:       newApp,
+		deploymentID: rand.Uint64(),
+		members:      make(map[uint64]dragonboat.Target),
+		nodes:        make(map[uint64]*Node),
+	}
+
+	// cleanups run last in first out; close the nodes before their directory is removed
+	c.dir = t.TempDir()
+	t.Cleanup(c.Close)
+
+	for i := 1; i <= size; i++ {
+		replicaID := uint64(i)
+		node := &Node{
+			ReplicaID: replicaID,
+			Address:   freeAddress(t),
+			dir:       filepath.Join(c.dir, fmt.Sprintf("node-%d", replicaID)),
+		}
+		c.nodes[replicaID] = node
+		c.members[replicaID] = node.Address
+	}
+
+	// every node must listen before any replica starts, otherwise the early messages to
+	// the late nodes trip the transport circuit breaker and slow down the cluster
+	for _, node := range c.Nodes() {
+		if err := c.startHost(node); err != nil {
+			t.Fatalf("failed to start node %v: %v", node.ReplicaID, err)
+		}
+	}
+
+	for _, node := range c.Nodes() {
+		if err := c.startReplica(node); err != nil {
+			t.Fatalf("failed to start replica %v: %v", node.ReplicaID, err)
+		}
+	}
+
+	c.WaitLeader()
+
+	return c
+}
+
+// Node returns the node by replica ID
+func (c *Cluster) Node(replicaID uint64) *Node {
+	c.t.Helper()
+
+	node, ok := c.nodes[replicaID]
+	if !ok {
+		c.t.Fatalf("node %v not found", replicaID)
+	}
+	return node
+}
+
+// Nodes returns all the nodes, ordered by replica ID
+func (c *Cluster) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ReplicaID < nodes[j].ReplicaID })
+	return nodes
+}
+
+// WaitLeader waits until the running nodes agree on a running leader, and returns its replica ID
+func (c *Cluster) WaitLeader() uint64 {
+	c.t.Helper()
+
+	var leaderID uint64
+	c.eventually("leader election", func() error {
+		leaderID = 0
+		for _, node := range c.Nodes() {
+			if !node.Running() {
+				continue
+			}
+			id, _, ok, err := node.Host.GetLeaderID(c.opts.shardID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("node %v has no leader", node.ReplicaID)
+			}
+			if leaderID != 0 && id != leaderID {
+				return fmt.Errorf("node %v sees leader %v, others see %v", node.ReplicaID, id, leaderID)
+			}
+			leaderID = id
+		}
+		if leaderID == 0 {
+			return fmt.Errorf("no running node")
+		}
+		if !c.nodes[leaderID].Running() {
+			return fmt.Errorf("leader %v is stopped", leaderID)
+		}
+		return nil
+	})
+
+	return leaderID
+}
+
+// Propose a command through the node, and returns the result of the app once applied.
+// value is JSON encoded, unless it is already []byte or json.RawMessage.
+func (c *Cluster) Propose(replicaID uint64, command raft.Command, value any) (raft.Result, error) {
+	c.t.Helper()
+
+	node := c.running(replicaID)
+
+	var raw json.RawMessage
+	switch v := value.(type) {
+	case json.RawMessage:
+		raw = v
+	case []byte:
+		raw = v
+	default:
+		var err error
+		raw, err = json.Marshal(value)
+		if err != nil {
+			return raft.Result{}, err
+		}
+	}
+
+	cmd, err := raft_runner.EncodeCommand(c.opts.encoding, raft_runner.Command{
+		Command:   command,
+		Value:     raw,
+		ReplicaID: &node.ReplicaID,
+	})
+	if err != nil {
+		return raft.Result{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+	defer cancel()
+
+	res, err := node.Host.SyncPropose(ctx, node.Host.GetNoOPSession(c.opts.shardID), cmd)
+	return raft.Result(res), err
+}
+
+// Read does a linearizable read of the app in the node
+func (c *Cluster) Read(replicaID uint64, query any) (any, error) {
+	c.t.Helper()
+
+	return c.read(c.running(replicaID), query, c.opts.timeout)
+}
+
+// WaitApplied waits until every running node applied the same index, which covers
+// everything committed before the call. It returns the applied index.
+func (c *Cluster) WaitApplied() uint64 {
+	c.t.Helper()
+
+	var applied uint64
+	c.eventually("apply", func() error {
+		var seen bool
+		for _, node := range c.Nodes() {
+			if !node.Running() {
+				continue
+			}
+
+			// a node that just restarted may drop the read until it hears from the leader, retry instead
+			res, err := c.read(node, raft_runner.AppliedIndexQuery{}, time.Second)
+			if err != nil {
+				return fmt.Errorf("node %v: %w", node.ReplicaID, err)
+			}
+
+			index := res.(uint64)
+			if seen && index != applied {
+				return fmt.Errorf("node %v applied %v, others applied %v", node.ReplicaID, index, applied)
+			}
+			applied, seen = index, true
+		}
+		return nil
+	})
+
+	return applied
+}
+
+// RequireConverged waits for apply, then fails the test if read does not return
+// the same result (reflect.DeepEqual) in every running node.
+// read is called with the node App, so it can also look at the app internal state.
+func (c *Cluster) RequireConverged(read func(node *Node) (any, error)) {
+	c.t.Helper()
+
+	c.WaitApplied()
+
+	var first *Node
+	var expected any
+	for _, node := range c.Nodes() {
+		if !node.Running() {
+			continue
+		}
+
+		got, err := read(node)
+		if err != nil {
+			c.t.Fatalf("failed to read node %v: %v", node.ReplicaID, err)
+		}
+
+		if first == nil {
+			first, expected = node, got
+			continue
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			c.t.Fatalf("node %v diverged from node %v:\n got: %+v\nwant: %+v", node.ReplicaID, first.ReplicaID, got, expected)
+		}
+	}
+}
+
+// Stop the node, as if the process is killed. Its data stays.
+// If the rest of the cluster still has a quorum, it waits until they elect a leader.
+func (c *Cluster) Stop(replicaID uint64) {
+	c.t.Helper()
+
+	node := c.running(replicaID)
+	node.Host.Close()
+	node.Host = nil
+
+	// proposals to a stopped leader are dropped, so wait for a new one while there is a quorum
+	if c.hasQuorum() {
+		c.WaitLeader()
+	}
+}
+
+// Restart a stopped node with a new app instance
+func (c *Cluster) Restart(replicaID uint64) {
+	c.t.Helper()
+
+	node := c.Node(replicaID)
+	if node.Running() {
+		c.t.Fatalf("node %v is still running", replicaID)
+	}
+
+	if err := c.startHost(node); err != nil {
+		c.t.Fatalf("failed to restart node %v: %v", replicaID, err)
+	}
+
+	if err := c.startReplica(node); err != nil {
+		c.t.Fatalf("failed to restart replica %v: %v", replicaID, err)
+	}
+
+	if c.hasQuorum() {
+		c.WaitLeader()
+	}
+}
+
+// AddNode adds a stopped node that joins the cluster instead of bootstrapping it.
+// Add it to the shard membership first (eg. raft_runner.AddReplica with the node Address),
+// then start it with Restart.
+func (c *Cluster) AddNode(replicaID uint64) *Node {
+	c.t.Helper()
+
+	if _, ok := c.nodes[replicaID]; ok {
+		c.t.Fatalf("node %v already exists", replicaID)
+	}
+
+	node := &Node{
+		ReplicaID: replicaID,
+		Address:   freeAddress(c.t),
+		dir:       filepath.Join(c.dir, fmt.Sprintf("node-%d", replicaID)),
+		join:      true,
+	}
+	c.nodes[replicaID] = node
+
+	return node
+}
+
+// RequestSnapshot makes the node take a snapshot, and returns its index
+func (c *Cluster) RequestSnapshot(replicaID uint64) (uint64, error) {
+	c.t.Helper()
+
+	node := c.running(replicaID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+	defer cancel()
+
+	return node.Host.SyncRequestSnapshot(ctx, c.opts.shardID, dragonboat.SnapshotOption{
+		OverrideCompactionOverhead: true,
+		CompactionOverhead:         c.opts.compactionOverhead,
+	})
+}
+
+// Close stops all the nodes
+func (c *Cluster) Close() {
+	for _, node := range c.nodes {
+		if node.Running() {
+			node.Host.Close()
+			node.Host = nil
+		}
+	}
+}
+
+func (c *Cluster) startHost(node *Node) error {
+	nh, err := dragonboat.NewNodeHost(config.NodeHostConfig{
+		RaftAddress:    node.Address,
+		WALDir:         filepath.Join(node.dir, "wal"),
+		NodeHostDir:    filepath.Join(node.dir, "nodehost"),
+		RTTMillisecond: c.opts.rttMillisecond,
+		DeploymentID:   c.deploymentID,
+	})
+	if err != nil {
+		return err
+	}
+
+	node.Host = nh
+
+	return nil
+}
+
+func (c *Cluster) startReplica(node *Node) error {
+	app := c.newApp(node.ReplicaID)
+
+	ctx := raft_runner.WithRaftContext(context.Background(), raft_runner.RaftContext{
+		ID:        c.opts.id,
+		ShardID:   c.opts.shardID,
+		ReplicaID: node.ReplicaID,
+		DHost:     node.Host,
+		Storage:   c.opts.storage(filepath.Join(node.dir, "storage")),
+
+		CommandEncoding: c.opts.encoding,
+	})
+
+	// election timeout (1s) must be longer than the transport circuit breaker backoff,
+	// otherwise a restarted node keeps disrupting the cluster
+	members := c.members
+	if node.join {
+		members = nil
+	}
+
+	err := raft_runner.StartReplica(ctx, app, members, node.join, config.Config{
+		HeartbeatRTT:       2,
+		CheckQuorum:        true,
+		PreVote:            true,
+		ElectionRTT:        100,
+		SnapshotEntries:    c.opts.snapshotEntries,
+		CompactionOverhead: c.opts.compactionOverhead,
+	})
+	if err != nil {
+		node.Host.Close()
+		node.Host = nil
+		return err
+	}
+
+	node.App = app
+	node.ctx = ctx
+
+	return nil
+}
+
+func (c *Cluster) read(node *Node, query any, timeout time.Duration) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return node.Host.SyncRead(ctx, c.opts.shardID, query)
+}
+
+func (c *Cluster) hasQuorum() bool {
+	var running int
+	for _, node := range c.nodes {
+		if node.Running() {
+			running++
+		}
+	}
+	return running*2 > len(c.nodes)
+}
+
+func (c *Cluster) running(replicaID uint64) *Node {
+	c.t.Helper()
+
+	node := c.Node(replicaID)
+	if !node.Running() {
+		c.t.Fatalf("node %v is not running", replicaID)
+	}
+	return node
+}
+
+func (c *Cluster) eventually(what string, fn func() error) {
+	c.t.Helper()
+
+	deadline := time.Now().Add(c.opts.timeout)
+	for {
+		err := fn()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			c.t.Fatalf("timeout waiting for %v: %v", what, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func freeAddress(t testing.TB) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
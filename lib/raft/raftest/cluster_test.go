@@ -0,0 +1,161 @@
+package raftest_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const commandAppend raft.Command = "append"
+
+// appendApp keeps the appended values in the raft metadata, so a restarted node starts from them
+type appendApp struct {
+	values []string
+}
+
+func (a *appendApp) Init(ctx context.Context) error {
+	payload, err := raft_runner.GetMetadata(ctx, "values")
+	if err != nil || len(payload) == 0 {
+		return err
+	}
+	return json.Unmarshal(payload, &a.values)
+}
+
+func (a *appendApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *appendApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandAppend {
+		return nil, raft.ErrUnsupported
+	}
+	a.values = append(a.values, string(e.Value))
+	return func() (raft.Result, error) { return raft.Result{Value: uint64(len(a.values))}, nil }, nil
+}
+
+func (a *appendApp) Apply(ctx context.Context) error {
+	payload, err := json.Marshal(a.values)
+	if err != nil {
+		return err
+	}
+	return raft_runner.SetMetadata(ctx, "values", payload)
+}
+
+func (a *appendApp) Lookup(ctx context.Context, key any) (any, error) {
+	return slices.Clone(a.values), nil
+}
+
+func newAppendCluster(t *testing.T) *raftest.Cluster {
+	return raftest.NewCluster(t, 3, func(uint64) raft.Application { return &appendApp{} })
+}
+
+func propose(t *testing.T, c *raftest.Cluster, replicaID uint64, value string) {
+	t.Helper()
+
+	if _, err := c.Propose(replicaID, commandAppend, json.RawMessage(value)); err != nil {
+		t.Fatalf("failed to propose %v through node %v: %v", value, replicaID, err)
+	}
+}
+
+func values(c *raftest.Cluster) func(node *raftest.Node) (any, error) {
+	return func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	}
+}
+
+func TestClusterStart(t *testing.T) {
+	c := newAppendCluster(t)
+
+	nodes := c.Nodes()
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %v", len(nodes))
+	}
+	for idx, node := range nodes {
+		if node.ReplicaID != uint64(idx+1) || !node.Running() || node.App == nil || node.Context() == nil {
+			t.Fatalf("expected node %v running with its app, got %+v", idx+1, node)
+		}
+	}
+
+	leaderID := c.WaitLeader()
+	if leaderID < 1 || leaderID > 3 {
+		t.Fatalf("expected a leader among the nodes, got %v", leaderID)
+	}
+
+	// through the leader & a follower
+	follower := leaderID%3 + 1
+	propose(t, c, leaderID, "1")
+	propose(t, c, follower, "2")
+
+	applied := c.WaitApplied()
+	if applied == 0 {
+		t.Fatal("expected the applied index")
+	}
+
+	c.RequireConverged(values(c))
+	for _, node := range c.Nodes() {
+		if got := node.App.(*appendApp).values; !slices.Equal(got, []string{"1", "2"}) {
+			t.Fatalf("expected node %v to apply every proposal, got %v", node.ReplicaID, got)
+		}
+	}
+}
+
+func TestClusterStopLeader(t *testing.T) {
+	c := newAppendCluster(t)
+
+	propose(t, c, 1, "1")
+
+	leaderID := c.WaitLeader()
+	c.Stop(leaderID)
+	if c.Node(leaderID).Running() {
+		t.Fatalf("expected node %v stopped", leaderID)
+	}
+
+	// the rest still has the quorum, and a new leader
+	newLeaderID := c.WaitLeader()
+	if newLeaderID == leaderID {
+		t.Fatalf("expected a new leader, got the stopped %v", leaderID)
+	}
+	propose(t, c, newLeaderID, "2")
+
+	// the restarted node has a new app, which catches up from its metadata & the log
+	stopped := c.Node(leaderID).App
+	c.Restart(leaderID)
+	if !c.Node(leaderID).Running() || c.Node(leaderID).App == stopped {
+		t.Fatalf("expected node %v running with a new app", leaderID)
+	}
+
+	c.RequireConverged(values(c))
+	if got := c.Node(leaderID).App.(*appendApp).values; !slices.Equal(got, []string{"1", "2"}) {
+		t.Fatalf("expected the restarted node to catch up, got %v", got)
+	}
+}
+
+func TestClusterQuorumLoss(t *testing.T) {
+	c := newAppendCluster(t)
+
+	propose(t, c, 1, "1")
+
+	// without the quorum, Stop does not wait for a leader
+	c.Stop(2)
+	c.Stop(3)
+
+	c.Restart(2)
+	propose(t, c, 2, "2")
+
+	c.Restart(3)
+	c.RequireConverged(values(c))
+
+	got, err := c.Read(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.([]string), []string{"1", "2"}) {
+		t.Fatalf("expected every node converged after the quorum is back, got %v", got)
+	}
+}
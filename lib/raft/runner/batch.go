@@ -0,0 +1,282 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lni/dragonboat/v4"
+	dclient "github.com/lni/dragonboat/v4/client"
+	"github.com/lni/dragonboat/v4/statemachine"
+
+	"github.com/desain-gratis/common/lib/raft"
+)
+
+const (
+	defaultBatchMaxItems = 256
+	defaultBatchMaxBytes = 1 << 20
+	defaultBatchMaxDelay = 2 * time.Millisecond
+
+	batchProposeTimeout = 60 * time.Second
+)
+
+var (
+	ErrBatcherClosed     = errors.New("batcher closed")
+	ErrBatchNotSupported = errors.New("the storage engine cannot apply a batch all or nothing")
+)
+
+// BatchConfig is the window of a batch. Zero value uses the default.
+type BatchConfig struct {
+	// MaxItems proposed in one batch
+	MaxItems int
+
+	// MaxBytes of the values; the batch is proposed as soon as it is reached
+	MaxBytes int
+
+	// MaxDelay to wait for other proposals after the first one
+	MaxDelay time.Duration
+
+	// Sessions is optional, to propose the batch exactly once
+	Sessions *SessionPool
+}
+
+// Batcher groups concurrent proposals into a single raft entry (CommandBatch),
+// and responds each proposal with its own result. The batch is applied all or nothing, so a proposal that fails
+// is responded with its error, and the rest is proposed again without it.
+// The storage engine must be a TransactionalStorage.
+// The proposal is not tied to the context of the caller; it might be applied even if the caller gives up.
+type Batcher struct {
+	dHost     *dragonboat.NodeHost
+	noop      *dclient.Session
+	replicaID uint64
+	encoding  CommandEncoding
+	cfg       BatchConfig
+
+	lock   sync.RWMutex
+	closed bool
+	queue  chan *batchRequest
+	wg     sync.WaitGroup
+}
+
+type batchRequest struct {
+	command raft.Command
+	value   json.RawMessage
+	result  chan batchResult
+}
+
+type batchResult struct {
+	res statemachine.Result
+	err error
+}
+
+func NewBatcher(ctx context.Context, cfg BatchConfig) (*Batcher, error) {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return nil, ErrRaftContextNotFound
+	}
+
+	storage := raftCtx.Storage
+	if storage == nil {
+		storage = globalStorage
+	}
+	if _, ok := storage.(TransactionalStorage); !ok {
+		return nil, ErrBatchNotSupported
+	}
+
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = defaultBatchMaxItems
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultBatchMaxBytes
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultBatchMaxDelay
+	}
+
+	b := &Batcher{
+		dHost:     raftCtx.DHost,
+		noop:      raftCtx.DHost.GetNoOPSession(raftCtx.ShardID),
+		replicaID: raftCtx.ReplicaID,
+		encoding:  raftCtx.CommandEncoding,
+		cfg:       cfg,
+		queue:     make(chan *batchRequest, cfg.MaxItems),
+	}
+
+	b.wg.Add(1)
+	go b.run()
+
+	return b, nil
+}
+
+// Propose the command in the next batch. Result with non zero Value is an app error.
+func (b *Batcher) Propose(ctx context.Context, command raft.Command, value json.RawMessage) (statemachine.Result, error) {
+	req := &batchRequest{
+		command: command,
+		value:   value,
+		result:  make(chan batchResult, 1),
+	}
+
+	b.lock.RLock()
+	if b.closed {
+		b.lock.RUnlock()
+		return statemachine.Result{}, ErrBatcherClosed
+	}
+	select {
+	case b.queue <- req:
+	case <-ctx.Done():
+		b.lock.RUnlock()
+		return statemachine.Result{}, ctx.Err()
+	}
+	b.lock.RUnlock()
+
+	select {
+	case r := <-req.result:
+		return r.res, r.err
+	case <-ctx.Done():
+		return statemachine.Result{}, ctx.Err()
+	}
+}
+
+// Close proposes the queued proposals, and waits until all of them are responded
+func (b *Batcher) Close() {
+	b.lock.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.lock.Unlock()
+
+	b.wg.Wait()
+}
+
+func (b *Batcher) run() {
+	defer b.wg.Done()
+
+	for first := range b.queue {
+		batch := []*batchRequest{first}
+		size := len(first.value)
+
+		timer := time.NewTimer(b.cfg.MaxDelay)
+	collect:
+		for len(batch) < b.cfg.MaxItems && size < b.cfg.MaxBytes {
+			select {
+			case req, ok := <-b.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+				size += len(req.value)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		// the next batch is collected while this one is proposed
+		b.wg.Add(1)
+		go b.flush(batch)
+	}
+}
+
+func (b *Batcher) flush(batch []*batchRequest) {
+	defer b.wg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchProposeTimeout)
+	defer cancel()
+
+	// the batch is applied all or nothing; the proposal failing it is responded with its error,
+	// and the proposals of the other callers are proposed again without it
+	for len(batch) > 1 {
+		results, failure, err := b.proposeBatch(ctx, batch)
+		if err != nil {
+			for _, req := range batch {
+				req.result <- batchResult{err: err}
+			}
+			return
+		}
+
+		if failure == nil {
+			for i, req := range batch {
+				req.result <- batchResult{res: results[i]}
+			}
+			return
+		}
+
+		batch[failure.Index].result <- batchResult{res: statemachine.Result{Value: 1, Data: []byte(failure.Error)}}
+		batch = slices.Delete(batch, failure.Index, failure.Index+1)
+	}
+
+	if len(batch) == 1 {
+		res, err := b.propose(ctx, batch[0].command, batch[0].value)
+		batch[0].result <- batchResult{res: res, err: err}
+	}
+}
+
+// proposeBatch returns the result of each proposal, or the failure of the proposal that rejected the batch
+func (b *Batcher) proposeBatch(ctx context.Context, batch []*batchRequest) ([]statemachine.Result, *batchFailure, error) {
+	cmds := make([]Command, len(batch))
+	for i, req := range batch {
+		cmds[i] = Command{Command: req.command, Value: req.value}
+	}
+
+	value, err := encodeBatch(b.encoding, cmds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal batch to raft: %w", err)
+	}
+
+	res, err := b.propose(ctx, CommandBatch, value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if res.Value > 0 {
+		var failure batchFailure
+		if err := json.Unmarshal(res.Data, &failure); err == nil && failure.Error != "" && failure.Index >= 0 && failure.Index < len(batch) {
+			return nil, &failure, nil
+		}
+
+		// the batch itself is rejected
+		results := make([]statemachine.Result, len(batch))
+		for i := range results {
+			results[i] = res
+		}
+		return results, nil, nil
+	}
+
+	results, err := decodeBatchResult(res.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(results) != len(batch) {
+		return nil, nil, fmt.Errorf("invalid batch result: got %v results for %v commands", len(results), len(batch))
+	}
+
+	return results, nil, nil
+}
+
+func (b *Batcher) propose(ctx context.Context, command raft.Command, value json.RawMessage) (statemachine.Result, error) {
+	if b.cfg.Sessions != nil {
+		return b.cfg.Sessions.Propose(ctx, command, value)
+	}
+
+	data, err := EncodeCommand(b.encoding, Command{
+		Command:   command,
+		Value:     value,
+		ReplicaID: &b.replicaID,
+	})
+	if err != nil {
+		return statemachine.Result{}, fmt.Errorf("failed to marshal msg to raft: %w", err)
+	}
+
+	// not retried, the proposal might be applied twice
+	res, err := b.dHost.SyncPropose(ctx, b.noop, data)
+	if err != nil {
+		return statemachine.Result{}, fmt.Errorf("%w: %w", ErrRaft, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,256 @@
+package runner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+func TestBatcher(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} })
+	before := c.WaitApplied()
+
+	batcher, err := raft_runner.NewBatcher(c.Node(2).Context(), raft_runner.BatchConfig{MaxDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batcher.Close()
+
+	const n = 50
+	counts := make([]int, n)
+	var unsupported raft.Result
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := batcher.Propose(context.Background(), commandIncrement, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			counts[i], _ = strconv.Atoi(string(res.Data))
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, err := batcher.Propose(context.Background(), "unsupported", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		unsupported = raft.Result(res)
+	}()
+	wg.Wait()
+
+	// every proposal got its own result
+	slices.Sort(counts)
+	for i, count := range counts {
+		if count != i+1 {
+			t.Fatalf("expected the counts to be 1..%v, got %v", n, counts)
+		}
+	}
+	if unsupported.Value == 0 {
+		t.Fatalf("expected error for the unsupported command, got %+v", unsupported)
+	}
+
+	if entries := c.WaitApplied() - before; entries >= n {
+		t.Fatalf("expected the proposals to be batched, got %v entries", entries)
+	}
+
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return node.App.(*counterApp).count, nil
+	})
+
+	batcher.Close()
+	if _, err := batcher.Propose(context.Background(), commandIncrement, nil); err == nil {
+		t.Fatal("expected error proposing with a closed batcher")
+	}
+}
+
+const (
+	commandAdd  raft.Command = "add"
+	ledgerTable              = "raftest_ledger"
+)
+
+// ledgerApp writes every amount to a SQLite table, and keeps the total in memory.
+// A negative amount is written, then fails, so the write must be rolled back.
+type ledgerApp struct {
+	total int
+}
+
+type ledger struct {
+	Total   int
+	Amounts []int
+}
+
+func (a *ledgerApp) Init(ctx context.Context) error {
+	conn := raft_runner.GetSQLiteConnection(ctx)
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+ledgerTable+" (id INTEGER PRIMARY KEY AUTOINCREMENT, amount INTEGER)"); err != nil {
+		return err
+	}
+	return conn.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM "+ledgerTable).Scan(&a.total)
+}
+
+func (a *ledgerApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *ledgerApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandAdd {
+		return nil, raft.ErrUnsupported
+	}
+
+	var amount int
+	if err := json.Unmarshal(e.Value, &amount); err != nil {
+		return nil, err
+	}
+
+	a.total += amount
+	if _, err := raft_runner.GetSQLiteConnection(ctx).ExecContext(ctx, "INSERT INTO "+ledgerTable+" (amount) VALUES (?)", amount); err != nil {
+		return nil, err
+	}
+	if amount < 0 {
+		return nil, errors.New("negative amount")
+	}
+
+	total := a.total
+	return func() (raft.Result, error) { return raft.Result{Value: 0, Data: []byte(strconv.Itoa(total))}, nil }, nil
+}
+
+func (a *ledgerApp) Apply(ctx context.Context) error {
+	return nil
+}
+
+func (a *ledgerApp) Lookup(ctx context.Context, key any) (any, error) {
+	rows, err := raft_runner.GetSQLiteConnection(ctx).QueryContext(ctx, "SELECT amount FROM "+ledgerTable+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := ledger{Total: a.total}
+	for rows.Next() {
+		var amount int
+		if err := rows.Scan(&amount); err != nil {
+			return nil, err
+		}
+		result.Amounts = append(result.Amounts, amount)
+	}
+	return result, rows.Err()
+}
+
+func (a *ledgerApp) Savepoint(ctx context.Context) (func(), error) {
+	total := a.total
+	return func() { a.total = total }, nil
+}
+
+func TestBatchAtomic(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &ledgerApp{} })
+
+	batch := func(amounts ...int) json.RawMessage {
+		cmds := make([]raft_runner.Command, 0, len(amounts))
+		for _, amount := range amounts {
+			cmds = append(cmds, raft_runner.Command{Command: commandAdd, Value: json.RawMessage(strconv.Itoa(amount))})
+		}
+		value, err := json.Marshal(cmds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	if res, err := c.Propose(1, raft_runner.CommandBatch, batch(1, 2)); err != nil || res.Value != 0 {
+		t.Fatalf("expected the batch applied, got %+v %v", res, err)
+	}
+
+	// the second command fails, the writes of the first one & the app state are rolled back
+	res, err := c.Propose(1, raft_runner.CommandBatch, batch(10, -1, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failure struct {
+		Index int    `json:"index"`
+		Error string `json:"error"`
+	}
+	if res.Value == 0 || json.Unmarshal(res.Data, &failure) != nil || failure.Index != 1 || failure.Error != "negative amount" {
+		t.Fatalf("expected the batch rejected by its second command, got %v %s", res.Value, res.Data)
+	}
+
+	want := ledger{Total: 3, Amounts: []int{1, 2}}
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	})
+	if got, err := c.Read(3, nil); err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected only the applied batch, got %+v %v", got, err)
+	}
+}
+
+func TestBatcherWithoutFailedProposal(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &ledgerApp{} })
+
+	batcher, err := raft_runner.NewBatcher(c.Node(1).Context(), raft_runner.BatchConfig{MaxDelay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batcher.Close()
+
+	amounts := []int{1, 2, -1, 3, -2, 4}
+	results := make([]raft.Result, len(amounts))
+
+	var wg sync.WaitGroup
+	for i, amount := range amounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := batcher.Propose(context.Background(), commandAdd, json.RawMessage(strconv.Itoa(amount)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = raft.Result(res)
+		}()
+	}
+	wg.Wait()
+
+	// the failed proposals got their error, the others are applied
+	for i, amount := range amounts {
+		if failed := results[i].Value > 0; failed != (amount < 0) {
+			t.Fatalf("proposal of %v: unexpected result %v %s", amount, results[i].Value, results[i].Data)
+		}
+	}
+
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	})
+	got, err := c.Read(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := got.(ledger)
+	slices.Sort(applied.Amounts)
+	if applied.Total != 10 || !slices.Equal(applied.Amounts, []int{1, 2, 3, 4}) {
+		t.Fatalf("expected only the positive amounts applied, got %+v", applied)
+	}
+}
+
+func TestBatcherNotSupported(t *testing.T) {
+	ctx := raft_runner.WithRaftContext(context.Background(), raft_runner.RaftContext{Storage: raft_runner.NewClickhouseStorage(nil)})
+
+	if _, err := raft_runner.NewBatcher(ctx, raft_runner.BatchConfig{}); !errors.Is(err, raft_runner.ErrBatchNotSupported) {
+		t.Fatalf("expected batch not supported without transaction, got %v", err)
+	}
+}
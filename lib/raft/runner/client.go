@@ -11,8 +11,6 @@ import (
 	"github.com/lni/dragonboat/v4"
 	dclient "github.com/lni/dragonboat/v4/client"
 	"github.com/lni/dragonboat/v4/statemachine"
-
-	"github.com/desain-gratis/common/delivery/mycontent-api/storage/content"
 )
 
 var (
@@ -24,6 +22,10 @@ type Client struct {
 	dHost     *dragonboat.NodeHost
 	sess      *dclient.Session
 	replicaID uint64
+	encoding  CommandEncoding
+
+	// optional, for exactly once Publish
+	sessions *SessionPool
 }
 
 func NewClient(ctx context.Context) (*Client, error) {
@@ -35,22 +37,58 @@ func NewClient(ctx context.Context) (*Client, error) {
 		dHost:     raftCtx.DHost,
 		sess:      raftCtx.DHost.GetNoOPSession(raftCtx.ShardID),
 		replicaID: raftCtx.ReplicaID,
+		encoding:  raftCtx.CommandEncoding,
 	}, nil
 }
 
+// NewSessionClient publishes with client sessions, so a retried Publish is applied once.
+// Close it when done.
+func NewSessionClient(ctx context.Context) (*Client, error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sessions, err = NewSessionPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Close unregisters the client sessions, if any
+func (c *Client) Close(ctx context.Context) error {
+	if c.sessions == nil {
+		return nil
+	}
+	return c.sessions.Close(ctx)
+}
+
 func (c *Client) Publish(ctx context.Context, command raft.Command, msg any) ([]byte, uint64, error) {
 	value, err := json.Marshal(msg)
 	if err != nil {
 		return nil, 1, fmt.Errorf("failed to marshal msg to raft: %w (%v)", err, msg)
 	}
 
+	if c.sessions != nil {
+		res, err := c.sessions.Propose(ctx, command, value)
+		if err != nil {
+			return nil, 1, err
+		}
+		if res.Value > 0 {
+			return nil, res.Value, fmt.Errorf("raft app: '%v'", string(res.Data))
+		}
+		return res.Data, 0, nil
+	}
+
 	cmd := Command{
 		Command:   command,
 		ReplicaID: &c.replicaID,
 		Value:     value,
 	}
 
-	data, err := json.Marshal(cmd)
+	data, err := EncodeCommand(c.encoding, cmd)
 	if err != nil {
 		return nil, 1, fmt.Errorf("failed to marshal msg to raft: %w (%v)", err, string(data))
 	}
@@ -81,12 +119,33 @@ func (c *Client) Publish(ctx context.Context, command raft.Command, msg any) ([]
 	return res.Data, 0, nil
 }
 
+// Query the local replica. Linearizable, unless overridden with raft.WithReadConsistency
 func (c *Client) Query(ctx context.Context, msg any) (any, error) {
+	return ConsistentRead(ctx, c.dHost, c.sess.ShardID, c.replicaID, raft.ReadLinearizable, msg)
+}
+
+// ConsistentRead queries the replica with the read consistency of ctx, or def if ctx has none.
+// Linearizable read is retried on temporary raft error; the error of the app is returned as it is.
+func ConsistentRead(ctx context.Context, dHost *dragonboat.NodeHost, shardID, replicaID uint64, def raft.ReadConsistency, query any) (any, error) {
+	switch raft.GetReadConsistency(ctx, def) {
+	case raft.ReadStale:
+		return dHost.StaleRead(shardID, query)
+	case raft.ReadLeader:
+		// no forwarding; the caller should ask the leader instead
+		leaderID, _, ok, err := dHost.GetLeaderID(shardID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || leaderID != replicaID {
+			return nil, fmt.Errorf("%w: replica %v (leader: %v)", raft.ErrNotLeader, replicaID, leaderID)
+		}
+	}
+
 	var res any
 	var errg error
 	for range 3 {
 		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		tmpRes, err := c.dHost.SyncRead(ctx, c.sess.ShardID, msg)
+		tmpRes, err := dHost.SyncRead(ctx, shardID, query)
 		if err == nil {
 			res = tmpRes
 			errg = nil
@@ -95,7 +154,7 @@ func (c *Client) Query(ctx context.Context, msg any) (any, error) {
 		}
 		errg = err
 		cancel()
-		if errors.Is(err, content.ErrInvalidKey) || errors.Is(err, content.ErrNotFound) {
+		if !dragonboat.IsTempError(err) {
 			return nil, err
 		}
 
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/desain-gratis/common/lib/raft"
+	raftpb "github.com/desain-gratis/common/types/protobuf/raft"
+)
+
+// Binary command is the protobuf Command prefixed by the magic byte & the version.
+// JSON command never starts with a zero byte, so both can be decoded.
+const (
+	commandMagic     byte = 0
+	commandVersionV1 byte = 1
+)
+
+var ErrInvalidCommand = errors.New("invalid command")
+
+// CommandEncoding of the proposed command; every runner decodes both
+type CommandEncoding uint8
+
+const (
+	// CommandJSON is the legacy command, decoded by every version of the runner. The default.
+	CommandJSON CommandEncoding = iota
+
+	// CommandBinary is the smaller protobuf command. A replica that cannot decode it stops applying the log,
+	// so enable it (ReplicaConfig.BinaryCommand) only after every replica of the shard runs a runner that decodes it.
+	CommandBinary
+)
+
+// EncodeCommand encodes the command for SyncPropose; use the encoding of the replica (RaftContext.CommandEncoding)
+func EncodeCommand(encoding CommandEncoding, cmd Command) ([]byte, error) {
+	if encoding == CommandBinary {
+		return encodeBinary(toProtoCommand(cmd))
+	}
+
+	return json.Marshal(cmd)
+}
+
+func encodeBinary(msg proto.Message) ([]byte, error) {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{commandMagic, commandVersionV1}, payload...), nil
+}
+
+// DecodeCommand decodes both the binary and the JSON command
+func DecodeCommand(data []byte) (Command, error) {
+	if len(data) == 0 || data[0] != commandMagic {
+		var cmd Command
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			return Command{}, fmt.Errorf("%w: not a valid JSON", ErrInvalidCommand)
+		}
+		return cmd, nil
+	}
+
+	var msg raftpb.Command
+	if err := decodeBinary(data, &msg); err != nil {
+		return Command{}, err
+	}
+
+	return fromProtoCommand(&msg), nil
+}
+
+func decodeBinary(data []byte, msg proto.Message) error {
+	if len(data) < 2 || data[1] != commandVersionV1 {
+		return fmt.Errorf("%w: unsupported binary command version", ErrInvalidCommand)
+	}
+
+	if err := proto.Unmarshal(data[2:], msg); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCommand, err)
+	}
+
+	return nil
+}
+
+func toProtoCommand(cmd Command) *raftpb.Command {
+	msg := &raftpb.Command{
+		Command:   string(cmd.Command),
+		Value:     cmd.Value,
+		ReplicaId: cmd.ReplicaID,
+	}
+	if cmd.Session != nil {
+		msg.Session = &raftpb.CommandSession{
+			ClientId:    cmd.Session.ClientID,
+			SeriesId:    cmd.Session.SeriesID,
+			RespondedTo: cmd.Session.RespondedTo,
+		}
+	}
+
+	return msg
+}
+
+func fromProtoCommand(msg *raftpb.Command) Command {
+	cmd := Command{
+		Command:   raft.Command(msg.Command),
+		Value:     msg.Value,
+		ReplicaID: msg.ReplicaId,
+	}
+	if msg.Session != nil {
+		cmd.Session = &CommandSession{
+			ClientID:    msg.Session.ClientId,
+			SeriesID:    msg.Session.SeriesId,
+			RespondedTo: msg.Session.RespondedTo,
+		}
+	}
+
+	return cmd
+}
@@ -0,0 +1,108 @@
+package runner_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+func TestCommandEncoding(t *testing.T) {
+	replicaID := uint64(2)
+	cmd := raft_runner.Command{
+		Command:   "gratis.desain.mycontent.post",
+		Value:     json.RawMessage(`{"id":"a"}`),
+		ReplicaID: &replicaID,
+		Session:   &raft_runner.CommandSession{ClientID: 42, SeriesID: 3, RespondedTo: 2},
+	}
+
+	binary, err := raft_runner.EncodeCommand(raft_runner.CommandBinary, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the zero value is the legacy JSON, decoded by every replica
+	var encoding raft_runner.CommandEncoding
+	legacy, err := raft_runner.EncodeCommand(encoding, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := json.Marshal(cmd); string(legacy) != string(want) {
+		t.Fatalf("expected JSON by default, got %q", legacy)
+	}
+
+	if len(binary) >= len(legacy) {
+		t.Errorf("expected binary command (%v bytes) to be smaller than JSON (%v bytes)", len(binary), len(legacy))
+	}
+
+	for name, data := range map[string][]byte{"binary": binary, "json": legacy} {
+		got, err := raft_runner.DecodeCommand(data)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, cmd) {
+			t.Fatalf("%v: got %+v, want %+v", name, got, cmd)
+		}
+	}
+
+	if _, err := raft_runner.DecodeCommand([]byte{0, 99}); !errors.Is(err, raft_runner.ErrInvalidCommand) {
+		t.Fatalf("expected invalid command for unknown version, got %v", err)
+	}
+}
+
+func TestMixedCommandEncoding(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} })
+
+	// log written before the upgrade
+	increment(t, c, 1, 2)
+
+	// every replica is upgraded, then the binary command is enabled
+	node := c.Node(2)
+	for range 2 {
+		data, err := raft_runner.EncodeCommand(raft_runner.CommandBinary, raft_runner.Command{Command: commandIncrement, ReplicaID: &node.ReplicaID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = node.Host.SyncPropose(ctx, node.Host.GetNoOPSession(1), data)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return node.App.(*counterApp).count, nil
+	})
+	if got := c.Node(3).App.(*counterApp).count; got != 4 {
+		t.Fatalf("expected count 4, got %v", got)
+	}
+}
+
+func TestBinaryCommandCluster(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} },
+		raftest.WithCommandEncoding(raft_runner.CommandBinary))
+
+	// the clients of the replica propose in the encoding of its raft context
+	client, err := raft_runner.NewClient(c.Node(1).Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.Publish(context.Background(), commandIncrement, nil); err != nil {
+		t.Fatal(err)
+	}
+	increment(t, c, 2, 1)
+
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return node.App.(*counterApp).count, nil
+	})
+	if got := c.Node(3).App.(*counterApp).count; got != 2 {
+		t.Fatalf("expected count 2, got %v", got)
+	}
+}
@@ -2,13 +2,12 @@ package runner
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"sync/atomic"
 
-	"github.com/ClickHouse/clickhouse-go/v2"
-	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/desain-gratis/common/lib/raft"
 	"github.com/rs/zerolog/log"
 
@@ -17,23 +16,24 @@ import (
 
 type baseDiskSM struct {
 	lastApplied    uint64
-	conn           driver.Conn
+	storage        StorageEngine
 	closed         bool
 	smMetadata     *Metadata
+	sessions       *sessions
 	initialApplied uint64
 	app            raft.Application
 	raftContext    RaftContext
 	config         DragonboatConfig2
 }
 
-func newBaseDiskClickhouseSM(cfg DragonboatConfig2, raftCtx RaftContext, app raft.Application) func(shardID uint64, replicaID uint64) sm.IOnDiskStateMachine {
+func newBaseDiskSM(cfg DragonboatConfig2, raftCtx RaftContext, app raft.Application) func(shardID uint64, replicaID uint64) sm.IOnDiskStateMachine {
 	return func(shardID uint64, replicaID uint64) sm.IOnDiskStateMachine {
 		raftCtx.ShardID = shardID
 		raftCtx.ReplicaID = replicaID
 		return &baseDiskSM{
 			config:      cfg,
 			app:         app,
-			conn:        raftCtx.ClickhouseConn,
+			storage:     raftCtx.Storage,
 			raftContext: raftCtx,
 		}
 	}
@@ -42,16 +42,15 @@ func newBaseDiskClickhouseSM(cfg DragonboatConfig2, raftCtx RaftContext, app raf
 // Open opens the state machine and return the index of the last Raft Log entry
 // already updated into the state machine.
 func (d *baseDiskSM) Open(stopc <-chan struct{}) (uint64, error) {
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), contextKey, d.raftContext)
 
-	ctx = context.WithValue(ctx, chConnKey, d.conn)
-	ctx = context.WithValue(ctx, contextKey, d.raftContext)
-
-	err := prepareSchema(ctx, d.conn)
+	err := d.storage.Prepare(ctx)
 	if err != nil {
-		log.Fatal().Msgf("failed to prepare raft database schema err: %v", err)
+		log.Fatal().Msgf("failed to prepare raft storage err: %v", err)
 	}
 
+	ctx = d.storage.WithConnection(ctx)
+
 	metadata, err := d.loadMetadata(ctx)
 	if err != nil {
 		log.Fatal().Msgf("failed to load metadata to raft replica err: %v", err)
@@ -59,25 +58,34 @@ func (d *baseDiskSM) Open(stopc <-chan struct{}) (uint64, error) {
 
 	d.smMetadata = metadata
 	d.initialApplied = *metadata.AppliedIndex
-	d.lastApplied = *metadata.AppliedIndex
+	atomic.StoreUint64(&d.lastApplied, *metadata.AppliedIndex)
+
+	d.sessions, err = loadSessions(ctx, d.storage)
+	if err != nil {
+		log.Fatal().Msgf("failed to load client sessions err: %v", err)
+	}
 
 	err = d.app.Init(ctx)
 	if err != nil {
 		log.Fatal().Msgf("failed to init app err: %v", err)
 	}
 
-	return d.lastApplied, nil
+	return *metadata.AppliedIndex, nil
 }
 
 // Lookup queries the state machine.
 func (d *baseDiskSM) Lookup(key interface{}) (interface{}, error) {
-	// Inject with context
-	ctx := context.WithValue(context.Background(), chConnKey, d.conn)
-	ctx = context.WithValue(ctx, contextKey, d.raftContext)
+	if _, ok := key.(AppliedIndexQuery); ok {
+		return atomic.LoadUint64(&d.lastApplied), nil
+	}
 
-	return d.app.Lookup(ctx, key)
+	return d.app.Lookup(d.newContext(), key)
 }
 
+// AppliedIndexQuery returns the last applied index of the replica, instead of querying the app.
+// With SyncRead, it waits until the replica applied everything committed before the read.
+type AppliedIndexQuery struct{}
+
 // Raft Command
 type Command struct {
 	Command raft.Command    `json:"command"`
@@ -85,12 +93,18 @@ type Command struct {
 
 	// ReplicaID of the requester
 	ReplicaID *uint64 `json:"replica_id,omitempty"`
+
+	// Session deduplicates retried proposal, see SessionPool
+	Session *CommandSession `json:"session,omitempty"`
 }
 
 func (d *baseDiskSM) Update(ents []sm.Entry) ([]sm.Entry, error) {
-	ctx := context.WithValue(context.Background(), chConnKey, d.conn)
-	ctx = context.WithValue(ctx, contextKey, d.raftContext)
-	ctx = context.WithValue(ctx, metadataKey, make(map[string][]byte)) // since map is ref type, other can modify
+	ctx := context.WithValue(d.newContext(), metadataKey, make(map[string][]byte)) // since map is ref type, other can modify
+
+	ctx, commit, err := d.storage.Begin(ctx)
+	if err != nil {
+		log.Panic().Msgf("failed to begin update: %v", err)
+	}
 
 	ctx, cleanup, err := d.app.PrepareUpdate(ctx)
 	if err != nil {
@@ -100,38 +114,49 @@ func (d *baseDiskSM) Update(ents []sm.Entry) ([]sm.Entry, error) {
 
 	// Process message one-by-one
 	afterApplys := make([]raft.OnAfterApply, len(ents))
+	pendings := make(map[int]pendingResponse)
+	duplicates := make(map[int]int)
+	inBatch := make(map[CommandSession]int)
 	for idx := range ents {
 		if ents[idx].Index <= d.initialApplied {
 			log.Panic().Msgf("oh no initial")
 		}
-		var msg Command
-		err := json.Unmarshal(ents[idx].Cmd, &msg)
+		msg, err := DecodeCommand(ents[idx].Cmd)
 		if err != nil {
-			afterApplys[idx] = func() (raft.Result, error) {
-				return raft.Result{Value: 1, Data: []byte("invalid message: not a valid JSON")}, nil
-			}
+			afterApplys[idx] = resultOf(raft.Result{Value: 1, Data: []byte("invalid message: " + err.Error())})
 			continue
 		}
 
-		afterApplys[idx], err = d.app.OnUpdate(ctx, raft.Entry{
-			Entry:     &ents[idx],
-			Index:     ents[idx].Index,
-			Command:   raft.Command(msg.Command),
-			Value:     []byte(msg.Value),
-			ReplicaID: msg.ReplicaID,
-		})
-		if err != nil && errors.Is(err, raft.ErrUnsupported) {
-			afterApplys[idx] = func() (raft.Result, error) {
-				return raft.Result{Value: 1, Data: []byte(err.Error())}, nil
+		if msg.Session != nil {
+			switch msg.Command {
+			case CommandRegisterSession:
+				afterApplys[idx] = resultOf(d.sessions.register(msg.Session.ClientID, ents[idx].Index))
+				continue
+			case CommandCloseSession:
+				afterApplys[idx] = resultOf(d.sessions.close(msg.Session.ClientID))
+				continue
 			}
-			continue
-		}
-		if err != nil {
-			afterApplys[idx] = func() (raft.Result, error) {
-				return raft.Result{Value: 1, Data: []byte(err.Error())}, nil
+
+			// retried in the same batch; responds with the result of the first one
+			key := CommandSession{ClientID: msg.Session.ClientID, SeriesID: msg.Session.SeriesID}
+			if first, ok := inBatch[key]; ok {
+				duplicates[idx] = first
+				continue
 			}
-			continue
+
+			res, session := d.sessions.check(msg.Session, ents[idx].Index)
+			if res != nil {
+				afterApplys[idx] = resultOf(*res)
+				continue
+			}
+
+			// marked as applied in the same transaction as the update
+			session.Responses[msg.Session.SeriesID] = nil
+			pendings[idx] = pendingResponse{clientID: msg.Session.ClientID, session: session, seriesID: msg.Session.SeriesID}
+			inBatch[key] = idx
 		}
+
+		afterApplys[idx] = d.apply(ctx, &ents[idx], msg)
 	}
 
 	// Apply update to disk
@@ -141,31 +166,39 @@ func (d *baseDiskSM) Update(ents []sm.Entry) ([]sm.Entry, error) {
 	}
 
 	*d.smMetadata.AppliedIndex = ents[len(ents)-1].Index
+	atomic.StoreUint64(&d.lastApplied, ents[len(ents)-1].Index)
 
-	metadataCtx := clickhouse.Context(ctx, clickhouse.WithStdAsync(true))
-
-	// Update our own metadata asynchronously
+	// Update our own metadata together with the other namespaces
 	smeta, err := serializeMetadata(d.smMetadata)
 	if err != nil {
 		log.Panic().Msgf("failed to serialize metadata: %v", err)
 	}
 
-	err = d.conn.AsyncInsert(metadataCtx, DMLWriteRaftMetadataAsync(ctx), true, "default", string(smeta))
+	metas, _ := ctx.Value(metadataKey).(map[string][]byte)
+	metas["default"] = smeta
+
+	sessionMetas, err := d.sessions.changed()
 	if err != nil {
-		log.Panic().Msgf("base save metadata failed: %v", err)
+		log.Panic().Msgf("failed to serialize client sessions: %v", err)
 	}
+	maps.Copy(metas, sessionMetas)
 
-	// Update other metadata asynchronously
-	metas, _ := ctx.Value(metadataKey).(map[string][]byte)
-	for ns, meta := range metas {
-		err = d.conn.AsyncInsert(metadataCtx, DMLWriteRaftMetadataAsync(ctx), true, ns, string(meta))
-		if err != nil {
-			log.Panic().Msgf("save other metadata failed (ns: :%v): %v", ns, err)
-		}
+	err = d.storage.SaveMetadata(ctx, metas)
+	if err != nil {
+		log.Panic().Msgf("save metadata failed: %v", err)
 	}
 
+	err = commit()
+	if err != nil {
+		log.Panic().Msgf("failed to commit update: %v", err)
+	}
+	d.sessions.saved()
+
 	// Execute function after successful apply
 	for idx := range ents {
+		if afterApplys[idx] == nil {
+			continue
+		}
 		res, err := afterApplys[idx]()
 		if err != nil {
 			continue
@@ -173,6 +206,19 @@ func (d *baseDiskSM) Update(ents []sm.Entry) ([]sm.Entry, error) {
 		ents[idx].Result = sm.Result(res)
 	}
 
+	for idx, first := range duplicates {
+		ents[idx].Result = ents[first].Result
+	}
+
+	if len(pendings) > 0 {
+		for idx, pending := range pendings {
+			res := raft.Result(ents[idx].Result)
+			pending.session.Responses[pending.seriesID] = &res
+			d.sessions.touch(pending.clientID)
+		}
+		d.saveSessions()
+	}
+
 	return ents, nil
 }
 
@@ -188,63 +234,129 @@ func (d *baseDiskSM) PrepareSnapshot() (interface{}, error) {
 		panic("prepare snapshot called after Close()")
 	}
 
-	// if it's KV, can freeze all table.. & freeze all metadata up to the current applied index (for other node to join)
-	//    ALTER TABLE <database_name>.<table_name> FREEZE [PARTITION partition_expr] [WITH NAME 'backup_name']
+	lastApplied := atomic.LoadUint64(&d.lastApplied)
+	ctx := context.WithValue(d.newContext(), snapshotIndexKey, lastApplied)
+
+	snapshot := &snapshotContext{
+		appliedIndex: lastApplied,
+		storage:      d.storage,
+	}
+
+	// raft metadata always goes first
+	tables := []string{RaftMetadataTable(ctx)}
+
+	// app that can snapshot itself takes precedence
+	snapshotter, isSnapshotter := d.app.(raft.Snapshotter)
+	if owner, ok := d.app.(raft.TableOwner); ok && !isSnapshotter {
+		tables = append(tables, owner.Tables(ctx)...)
+	}
+
+	// Update is not running concurrently with PrepareSnapshot, so it is the place to copy the storage
+	storageCtx, err := d.storage.PrepareSnapshot(ctx, tables)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.storageCtx = storageCtx
 
-	return nil, nil
+	if isSnapshotter {
+		appCtx, err := snapshotter.PrepareSnapshot(ctx)
+		if err != nil {
+			d.storage.ReleaseSnapshot(ctx, storageCtx)
+			return nil, fmt.Errorf("failed to prepare app snapshot: %w", err)
+		}
+		snapshot.app = snapshotter
+		snapshot.appCtx = appCtx
+	}
+
+	return snapshot, nil
 }
 
 // SaveSnapshot saves the state machine state identified by the state
 // identifier provided by the input ctx parameter. Note that SaveSnapshot
 // is not suppose to save the latest state.
-func (d *baseDiskSM) SaveSnapshot(ctx interface{},
+func (d *baseDiskSM) SaveSnapshot(snapshotCtx interface{},
 	w io.Writer, done <-chan struct{}) error {
 	if d.closed {
 		panic("prepare snapshot called after Close()")
 	}
 
-	// TODO: read from context all the table that is frozen; and write them to a single stream (maybe use simple framing)
+	snapshot, ok := snapshotCtx.(*snapshotContext)
+	if !ok {
+		return fmt.Errorf("%w: unexpected snapshot context %T", ErrInvalidSnapshot, snapshotCtx)
+	}
 
-	return nil
+	ctx := d.newContext()
+
+	// the storage copy is only needed during the lifetime of this snapshot
+	defer d.storage.ReleaseSnapshot(ctx, snapshot.storageCtx)
+
+	return writeSnapshot(ctx, w, snapshot, done)
 }
 
-// RecoverFromSnapshot
+// RecoverFromSnapshot replace all the state machine tables with the content of the snapshot
 func (d *baseDiskSM) RecoverFromSnapshot(r io.Reader,
 	done <-chan struct{}) error {
 	if d.closed {
 		panic("recover from snapshot called after Close()")
 	}
 
-	// read from the stream, frame as
-	// write to a file as clickhouse file
-	// load to clickhouse
-	// restart clickhouse
+	ctx := d.newContext()
+
+	header, appRecovered, err := readSnapshot(ctx, r, d.storage, d.app, done)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := d.loadMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata after recovering from snapshot: %w", err)
+	}
+
+	if *metadata.AppliedIndex != header.AppliedIndex {
+		return fmt.Errorf("%w: applied index in metadata (%v) does not match snapshot (%v)",
+			ErrInvalidSnapshot, *metadata.AppliedIndex, header.AppliedIndex)
+	}
+
+	d.smMetadata = metadata
+	d.initialApplied = *metadata.AppliedIndex
+	atomic.StoreUint64(&d.lastApplied, *metadata.AppliedIndex)
+
+	d.sessions, err = loadSessions(ctx, d.storage)
+	if err != nil {
+		return fmt.Errorf("failed to load client sessions after recovering from snapshot: %w", err)
+	}
+
+	// app without snapshot hook reload its state from the recovered tables & metadata
+	if !appRecovered {
+		err = d.app.Init(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to re-init app after recovering from snapshot: %w", err)
+		}
+	}
+
+	log.Info().Msgf("recovered from snapshot at index %v", header.AppliedIndex)
 
 	return nil
 }
 
 // Close closes the state machine.
 func (d *baseDiskSM) Close() error {
-	return nil
+	return d.storage.Close(context.WithValue(context.Background(), contextKey, d.raftContext))
 }
 
-func prepareSchema(ctx context.Context, conn driver.Conn) error {
-	// prepare raft metadata table
-	if err := conn.Exec(ctx, DDLRaftMetadata(ctx)); err != nil {
-		return err
-	}
-
-	return nil
+// newContext injects the raft context and the storage connection for the app
+func (d *baseDiskSM) newContext() context.Context {
+	ctx := context.WithValue(context.Background(), contextKey, d.raftContext)
+	return d.storage.WithConnection(ctx)
 }
 
 func (s *baseDiskSM) loadMetadata(ctx context.Context) (*Metadata, error) {
-	var payload string
-	if err := s.conn.QueryRow(ctx, DQLReadRaftMetadata(ctx), "default").
-		Scan(&payload); err != nil && err != sql.ErrNoRows {
+	payload, err := s.storage.LoadMetadata(ctx, "default")
+	if err != nil {
 		return nil, err
 	}
 
-	metadata, err := deserializeMetadata([]byte(payload))
+	metadata, err := deserializeMetadata(payload)
 	if err != nil {
 		return nil, err
 	}
@@ -252,11 +364,26 @@ func (s *baseDiskSM) loadMetadata(ctx context.Context) (*Metadata, error) {
 	return metadata, nil
 }
 
-func (s *baseDiskSM) saveMetadata(batch driver.Batch) error {
-	payload, err := serializeMetadata(s.smMetadata)
+// saveSessions saves the responses, known only after the update is committed.
+// If it fails, the responses are saved with the next update; until then a retried proposal
+// gets ErrSessionResponseLost instead of being applied again.
+func (d *baseDiskSM) saveSessions() {
+	metas, err := d.sessions.changed()
 	if err != nil {
-		return err
+		log.Warn().Msgf("failed to serialize client sessions: %v", err)
+		return
 	}
 
-	return batch.Append("default", string(payload))
+	err = d.storage.SaveMetadata(d.newContext(), metas)
+	if err != nil {
+		log.Warn().Msgf("failed to save client sessions: %v", err)
+		return
+	}
+	d.sessions.saved()
+}
+
+func resultOf(res raft.Result) raft.OnAfterApply {
+	return func() (raft.Result, error) {
+		return res, nil
+	}
 }
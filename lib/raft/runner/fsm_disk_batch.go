@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sm "github.com/lni/dragonboat/v4/statemachine"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/desain-gratis/common/lib/raft"
+	raftpb "github.com/desain-gratis/common/types/protobuf/raft"
+)
+
+// CommandBatch proposes several commands as a single raft entry, see Batcher.
+// The app gets each command of the batch as an ordinary entry (with the index of the batch),
+// all of them in the same update transaction. The batch is applied all or nothing: if the app fails a command,
+// the writes of the batch are rolled back to a savepoint of the storage (TransactionalStorage) and the app state
+// is restored (raft.Savepointer). The result of the rejected batch has a non zero Value, and the index & the error
+// of the failed command in its Data (batchFailure).
+// The storage engine without transaction (eg. ClickHouse) rejects the batch.
+const CommandBatch raft.Command = "gratis.desain.raft.batch"
+
+// batchFailure is the result data of the batch rejected because of one of its commands
+type batchFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// apply passes the command to the app, unpacking the batch command
+func (d *baseDiskSM) apply(ctx context.Context, ent *sm.Entry, msg Command) raft.OnAfterApply {
+	if msg.Command != CommandBatch {
+		afterApply, err := d.onUpdate(ctx, ent, msg)
+		if err != nil {
+			return resultOf(raft.Result{Value: 1, Data: []byte(err.Error())})
+		}
+		return afterApply
+	}
+
+	cmds, err := decodeBatch(msg.Value)
+	if err != nil {
+		return resultOf(raft.Result{Value: 1, Data: []byte("invalid batch: " + err.Error())})
+	}
+
+	storage, ok := d.storage.(TransactionalStorage)
+	if !ok {
+		return resultOf(raft.Result{Value: 1, Data: []byte(fmt.Sprintf("%v storage engine cannot apply a batch", d.storage.Name()))})
+	}
+
+	rollbackStorage, release, err := storage.Savepoint(ctx)
+	if err != nil {
+		log.Panic().Msgf("failed to start batch: %v", err)
+	}
+
+	rollbackApp := func() {}
+	if savepointer, ok := d.app.(raft.Savepointer); ok {
+		rollbackApp, err = savepointer.Savepoint(ctx)
+		if err != nil {
+			log.Panic().Msgf("failed to save the app state before batch: %v", err)
+		}
+	}
+
+	afterApplys := make([]raft.OnAfterApply, len(cmds))
+	for i, cmd := range cmds {
+		if cmd.ReplicaID == nil {
+			cmd.ReplicaID = msg.ReplicaID
+		}
+
+		afterApplys[i], err = d.onUpdate(ctx, ent, cmd)
+		if err != nil {
+			rollbackApp()
+			if err := rollbackStorage(); err != nil {
+				log.Panic().Msgf("failed to rollback batch: %v", err)
+			}
+
+			failure, _ := json.Marshal(batchFailure{Index: i, Error: err.Error()})
+			return resultOf(raft.Result{Value: 1, Data: failure})
+		}
+	}
+
+	if err := release(); err != nil {
+		log.Panic().Msgf("failed to release batch: %v", err)
+	}
+
+	return func() (raft.Result, error) {
+		results := make([]raft.Result, len(afterApplys))
+		for i, afterApply := range afterApplys {
+			if afterApply == nil {
+				continue
+			}
+			res, err := afterApply()
+			if err != nil {
+				// same as a single command, no result
+				continue
+			}
+			results[i] = res
+		}
+
+		data, err := encodeBatchResult(results)
+		if err != nil {
+			return raft.Result{Value: 1, Data: []byte("failed to encode batch result: " + err.Error())}, nil
+		}
+
+		return raft.Result{Data: data}, nil
+	}
+}
+
+func (d *baseDiskSM) onUpdate(ctx context.Context, ent *sm.Entry, msg Command) (raft.OnAfterApply, error) {
+	return d.app.OnUpdate(ctx, raft.Entry{
+		Entry:     ent,
+		Index:     ent.Index,
+		Command:   msg.Command,
+		Value:     []byte(msg.Value),
+		ReplicaID: msg.ReplicaID,
+	})
+}
+
+// encodeBatch encodes the value of the batch command, in the same encoding as EncodeCommand
+func encodeBatch(encoding CommandEncoding, cmds []Command) ([]byte, error) {
+	if encoding != CommandBinary {
+		return json.Marshal(cmds)
+	}
+
+	msg := &raftpb.CommandBatch{Commands: make([]*raftpb.Command, len(cmds))}
+	for i, cmd := range cmds {
+		msg.Commands[i] = toProtoCommand(cmd)
+	}
+
+	return encodeBinary(msg)
+}
+
+func decodeBatch(data []byte) ([]Command, error) {
+	if len(data) == 0 || data[0] != commandMagic {
+		var cmds []Command
+		if err := json.Unmarshal(data, &cmds); err != nil {
+			return nil, fmt.Errorf("%w: not a valid JSON", ErrInvalidCommand)
+		}
+		return cmds, nil
+	}
+
+	var msg raftpb.CommandBatch
+	if err := decodeBinary(data, &msg); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]Command, len(msg.Commands))
+	for i, cmd := range msg.Commands {
+		cmds[i] = fromProtoCommand(cmd)
+	}
+
+	return cmds, nil
+}
+
+// the result is not part of the raft log, so it is always binary
+func encodeBatchResult(results []raft.Result) ([]byte, error) {
+	msg := &raftpb.ResultBatch{Results: make([]*raftpb.Result, len(results))}
+	for i, res := range results {
+		msg.Results[i] = &raftpb.Result{Value: res.Value, Data: res.Data}
+	}
+
+	return proto.Marshal(msg)
+}
+
+func decodeBatchResult(data []byte) ([]sm.Result, error) {
+	var msg raftpb.ResultBatch
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("invalid batch result: %w", err)
+	}
+
+	results := make([]sm.Result, len(msg.Results))
+	for i, res := range msg.Results {
+		results[i] = sm.Result{Value: res.Value, Data: res.Data}
+	}
+
+	return results, nil
+}
@@ -2,7 +2,6 @@ package runner
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
@@ -26,7 +25,11 @@ type RaftContext struct {
 	AppConfig any
 	DHost     *dragonboat.NodeHost
 
-	ClickhouseConn driver.Conn
+	// Storage of the replica state
+	Storage StorageEngine
+
+	// CommandEncoding of the commands proposed by the clients of the replica; JSON by default
+	CommandEncoding CommandEncoding
 
 	// internal state
 	isBootstrap bool
@@ -38,13 +41,12 @@ func GetClickhouseConnection(ctx context.Context) driver.Conn {
 }
 
 func GetMetadata(ctx context.Context, namespace string) ([]byte, error) {
-	conn := GetClickhouseConnection(ctx)
-	var payload string
-	if err := conn.QueryRow(ctx, DQLReadRaftMetadata(ctx), namespace).Scan(&payload); err != nil && err != sql.ErrNoRows {
-		return nil, err
+	storage := getStorage(ctx)
+	if storage == nil {
+		return nil, ErrRaftContextNotFound
 	}
 
-	return []byte(payload), nil
+	return storage.LoadMetadata(ctx, namespace)
 }
 
 func SetMetadata(ctx context.Context, namespace string, data []byte) error {
@@ -58,6 +60,11 @@ func SetMetadata(ctx context.Context, namespace string, data []byte) error {
 	return nil
 }
 
+// WithRaftContext injects the raft context, for replica started without RunReplica (eg. in tests)
+func WithRaftContext(ctx context.Context, raftCtx RaftContext) context.Context {
+	return context.WithValue(ctx, contextKey, raftCtx)
+}
+
 func GetRaftContext(ctx context.Context) (RaftContext, error) {
 	raftCtx, ok := ctx.Value(contextKey).(RaftContext)
 	if !ok {
@@ -27,6 +27,12 @@ SELECT data FROM %s__metadata FINAL WHERE namespace=?;
 	`, strings.ReplaceAll(raftCtx.ID, "-", "_"))
 }
 
+// RaftMetadataTable is the table name where the raft metadata of the replica stored
+func RaftMetadataTable(ctx context.Context) string {
+	raftCtx, _ := GetRaftContext(ctx)
+	return strings.ReplaceAll(raftCtx.ID, "-", "_") + "__metadata"
+}
+
 func DMLWriteRaftMetadataAsync(ctx context.Context) string {
 	raftCtx, _ := GetRaftContext(ctx)
 	return fmt.Sprintf(
@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/desain-gratis/common/lib/raft"
+)
+
+// dragonboat client session is not supported for on disk state machine,
+// so the runner deduplicates the proposals of a client session by itself, in the same way.
+const (
+	CommandRegisterSession raft.Command = "gratis.desain.raft.session.register"
+	CommandCloseSession    raft.Command = "gratis.desain.raft.session.close"
+
+	// sessions are saved in buckets by client ID, so an update saves only the buckets it changed
+	sessionNamespace = "session"
+	sessionBuckets   = 256
+
+	// least recently used session is evicted after this
+	maxSessions = 4096
+)
+
+// Result values of the session errors. They are reserved for the runner; the app must not
+// return them, and a client ID is never one of them.
+const (
+	ResultSessionNotFound uint64 = math.MaxUint64 - iota
+	ResultSessionResponded
+	ResultSessionResponseLost
+
+	minReservedResult = ResultSessionResponseLost
+)
+
+var (
+	// ErrSessionNotFound the session is closed or evicted; register a new one
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrSessionResponded the client already acknowledged the response of the proposal
+	ErrSessionResponded = errors.New("proposal already responded")
+
+	// ErrSessionResponseLost the proposal is applied, but the response is lost (eg. crash right after apply)
+	ErrSessionResponseLost = errors.New("proposal already applied, response is lost")
+)
+
+// SessionError returns the session error of the result, or nil if it is not a session error
+func SessionError(res raft.Result) error {
+	switch res.Value {
+	case ResultSessionNotFound:
+		return ErrSessionNotFound
+	case ResultSessionResponded:
+		return ErrSessionResponded
+	case ResultSessionResponseLost:
+		return ErrSessionResponseLost
+	}
+	return nil
+}
+
+func sessionErrorResult(value uint64) *raft.Result {
+	return &raft.Result{Value: value, Data: []byte(SessionError(raft.Result{Value: value}).Error())}
+}
+
+// CommandSession identifies a proposal of a client session
+type CommandSession struct {
+	ClientID uint64 `json:"client_id"`
+	SeriesID uint64 `json:"series_id"`
+
+	// RespondedTo is the last series ID the client got the response of
+	RespondedTo uint64 `json:"responded_to"`
+}
+
+type sessionState struct {
+	RespondedTo uint64 `json:"responded_to"`
+
+	// LastIndex is the raft index of the last proposal, for eviction
+	LastIndex uint64 `json:"last_index"`
+
+	// Responses by series ID. Nil until the response of an applied proposal is known.
+	Responses map[uint64]*raft.Result `json:"responses"`
+}
+
+type sessions struct {
+	clients map[uint64]*sessionState
+
+	// buckets changed since they are last saved
+	dirty map[uint64]struct{}
+}
+
+// pendingResponse is an applied proposal waiting for its response
+type pendingResponse struct {
+	clientID uint64
+	session  *sessionState
+	seriesID uint64
+}
+
+func newSessions() *sessions {
+	return &sessions{
+		clients: make(map[uint64]*sessionState),
+		dirty:   make(map[uint64]struct{}),
+	}
+}
+
+func loadSessions(ctx context.Context, storage StorageEngine) (*sessions, error) {
+	result := newSessions()
+
+	for bucket := range uint64(sessionBuckets) {
+		payload, err := storage.LoadMetadata(ctx, sessionBucketNamespace(bucket))
+		if err != nil {
+			return nil, err
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		var clients map[uint64]*sessionState
+		if err := json.Unmarshal(payload, &clients); err != nil {
+			return nil, fmt.Errorf("failed to parse sessions of bucket %v: %w", bucket, err)
+		}
+		maps.Copy(result.clients, clients)
+	}
+
+	return result, nil
+}
+
+func sessionBucketNamespace(bucket uint64) string {
+	return fmt.Sprintf("%s/%03d", sessionNamespace, bucket)
+}
+
+func (s *sessions) touch(clientID uint64) {
+	s.dirty[clientID%sessionBuckets] = struct{}{}
+}
+
+// changed returns the metadata of the changed buckets, to be saved
+func (s *sessions) changed() (map[string][]byte, error) {
+	if len(s.dirty) == 0 {
+		return nil, nil
+	}
+
+	buckets := make(map[uint64]map[uint64]*sessionState, len(s.dirty))
+	for bucket := range s.dirty {
+		buckets[bucket] = make(map[uint64]*sessionState)
+	}
+	for clientID, session := range s.clients {
+		if bucket, ok := buckets[clientID%sessionBuckets]; ok {
+			bucket[clientID] = session
+		}
+	}
+
+	result := make(map[string][]byte, len(buckets))
+	for bucket, clients := range buckets {
+		payload, err := json.Marshal(clients)
+		if err != nil {
+			return nil, err
+		}
+		result[sessionBucketNamespace(bucket)] = payload
+	}
+
+	return result, nil
+}
+
+// saved marks every bucket as saved
+func (s *sessions) saved() {
+	clear(s.dirty)
+}
+
+func (s *sessions) register(clientID uint64, index uint64) raft.Result {
+	if _, ok := s.clients[clientID]; !ok && len(s.clients) >= maxSessions {
+		s.evict()
+	}
+
+	s.clients[clientID] = &sessionState{
+		LastIndex: index,
+		Responses: make(map[uint64]*raft.Result),
+	}
+	s.touch(clientID)
+
+	return raft.Result{Value: clientID}
+}
+
+func (s *sessions) close(clientID uint64) raft.Result {
+	if _, ok := s.clients[clientID]; !ok {
+		return *sessionErrorResult(ResultSessionNotFound)
+	}
+
+	delete(s.clients, clientID)
+	s.touch(clientID)
+
+	return raft.Result{Value: clientID}
+}
+
+// check returns the result to respond with if the proposal must not be applied (again)
+func (s *sessions) check(cmd *CommandSession, index uint64) (*raft.Result, *sessionState) {
+	session, ok := s.clients[cmd.ClientID]
+	if !ok {
+		return sessionErrorResult(ResultSessionNotFound), nil
+	}
+	session.LastIndex = index
+	s.touch(cmd.ClientID)
+
+	if cmd.RespondedTo > session.RespondedTo {
+		for seriesID := range session.Responses {
+			if seriesID <= cmd.RespondedTo {
+				delete(session.Responses, seriesID)
+			}
+		}
+		session.RespondedTo = cmd.RespondedTo
+	}
+
+	if cmd.SeriesID <= session.RespondedTo {
+		return sessionErrorResult(ResultSessionResponded), session
+	}
+
+	res, applied := session.Responses[cmd.SeriesID]
+	if !applied {
+		return nil, session
+	}
+	if res == nil {
+		return sessionErrorResult(ResultSessionResponseLost), session
+	}
+
+	return res, session
+}
+
+func (s *sessions) evict() {
+	ids := slices.Collect(maps.Keys(s.clients))
+
+	oldest := slices.MinFunc(ids, func(a, b uint64) int {
+		return cmp.Compare(s.clients[a].LastIndex, s.clients[b].LastIndex)
+	})
+
+	delete(s.clients, oldest)
+	s.touch(oldest)
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestSessionsChangedBuckets(t *testing.T) {
+	s := newSessions()
+	for clientID := uint64(2); clientID < 2+3*sessionBuckets; clientID++ {
+		s.register(clientID, clientID)
+	}
+
+	all, err := s.changed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != sessionBuckets {
+		t.Fatalf("expected every bucket to be changed, got %v", len(all))
+	}
+	s.saved()
+
+	// only the bucket of the session is saved again
+	if res, _ := s.check(&CommandSession{ClientID: 5, SeriesID: 1}, 1000); res != nil {
+		t.Fatalf("unexpected check result %+v", res)
+	}
+	changed, err := s.changed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Collect(maps.Keys(changed)); !slices.Equal(got, []string{sessionBucketNamespace(5)}) {
+		t.Fatalf("expected only the bucket of client 5 to be changed, got %v", got)
+	}
+	s.saved()
+
+	s.close(5 + sessionBuckets)
+	if changed, _ := s.changed(); len(changed) != 1 || len(changed[sessionBucketNamespace(5)]) == 0 {
+		t.Fatalf("expected the closed session bucket to be changed, got %v", changed)
+	}
+}
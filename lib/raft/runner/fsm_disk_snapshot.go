@@ -0,0 +1,317 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/desain-gratis/common/lib/raft"
+)
+
+// Snapshot stream layout:
+//
+//	header frame (json snapshotHeader)
+//	storage section, written by the StorageEngine:
+//	  clickhouse (WriteTables):
+//	    for each table:
+//	      table frame (table name)
+//	      rows frame... (gob encoded rows, see encodeRow; at most snapshotRowsPerFrame each)
+//	    end frame
+//	  sqlite:
+//	    chunk frame... (the database file)
+//	    end frame
+//	app section, only if header.App is true:
+//	  app frame... (opaque chunk of the stream written by raft.Snapshotter)
+//	  end frame
+//	end frame
+//
+// Every frame is [1 byte frame type][4 byte big endian payload length][payload]
+
+type frameType uint8
+
+const (
+	frameHeader frameType = iota + 1
+	frameTable
+	frameRows
+	frameEnd
+	frameApp
+	frameValue
+	frameChunk
+)
+
+const (
+	snapshotVersion      = 1
+	snapshotRowsPerFrame = 1000
+	snapshotAppChunkSize = 64 << 10
+
+	// 256MB, just to protect ourselves from corrupted stream
+	maxFrameSize = 256 << 20
+)
+
+const snapshotIndexKey ContextKey = "snapshot-index"
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+type snapshotHeader struct {
+	Version      int    `json:"version"`
+	AppliedIndex uint64 `json:"applied_index"`
+	App          bool   `json:"app"`
+
+	// Storage engine name; empty for snapshots taken before the engine was pluggable (clickhouse)
+	Storage string `json:"storage,omitempty"`
+}
+
+// snapshotContext is created by PrepareSnapshot and consumed by SaveSnapshot
+type snapshotContext struct {
+	appliedIndex uint64
+
+	storage    StorageEngine
+	storageCtx any
+
+	// set if the app implements raft.Snapshotter
+	app    raft.Snapshotter
+	appCtx any
+}
+
+// WriteValue writes a single JSON serializable value (eg. app state) to the snapshot
+func WriteValue(w io.Writer, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return writeFrame(w, frameValue, payload)
+}
+
+// ReadValue reads value written by WriteValue
+func ReadValue(r io.Reader, v any) error {
+	ft, payload, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+
+	if ft != frameValue {
+		return fmt.Errorf("%w: expected value frame, got %v", ErrInvalidSnapshot, ft)
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+func writeSnapshot(ctx context.Context, w io.Writer, snapshot *snapshotContext, done <-chan struct{}) error {
+	bw := bufio.NewWriter(w)
+
+	header, err := json.Marshal(snapshotHeader{
+		Version:      snapshotVersion,
+		AppliedIndex: snapshot.appliedIndex,
+		App:          snapshot.app != nil,
+		Storage:      snapshot.storage.Name(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeFrame(bw, frameHeader, header); err != nil {
+		return err
+	}
+
+	if err := snapshot.storage.SaveSnapshot(ctx, snapshot.storageCtx, bw, done); err != nil {
+		return err
+	}
+
+	if snapshot.app != nil {
+		// whatever the app writes, goes into app frames
+		aw := bufio.NewWriterSize(&frameWriter{w: bw, ft: frameApp}, snapshotAppChunkSize)
+
+		if err := snapshot.app.SaveSnapshot(ctx, snapshot.appCtx, aw, done); err != nil {
+			return fmt.Errorf("failed to save app snapshot: %w", err)
+		}
+
+		if err := aw.Flush(); err != nil {
+			return err
+		}
+
+		if err := writeFrame(bw, frameEnd, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFrame(bw, frameEnd, nil); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// readSnapshot restores the storage section and, if any, the app section.
+// It returns true if the app state has been recovered via raft.Snapshotter
+func readSnapshot(ctx context.Context, r io.Reader, storage StorageEngine, app raft.Application, done <-chan struct{}) (*snapshotHeader, bool, error) {
+	br := bufio.NewReader(r)
+
+	ft, payload, err := readFrame(br)
+	if err != nil {
+		return nil, false, err
+	}
+	if ft != frameHeader {
+		return nil, false, fmt.Errorf("%w: expected header frame, got %v", ErrInvalidSnapshot, ft)
+	}
+
+	var header snapshotHeader
+	if err := json.Unmarshal(payload, &header); err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	if header.Version != snapshotVersion {
+		return nil, false, fmt.Errorf("%w: unsupported version %v", ErrInvalidSnapshot, header.Version)
+	}
+
+	if header.Storage == "" {
+		header.Storage = storageClickhouse
+	}
+	if header.Storage != storage.Name() {
+		return nil, false, fmt.Errorf("%w: snapshot is taken from %v storage, but replica uses %v",
+			ErrInvalidSnapshot, header.Storage, storage.Name())
+	}
+
+	if err := storage.RecoverFromSnapshot(ctx, br, done); err != nil {
+		return nil, false, err
+	}
+
+	var appRecovered bool
+	if header.App {
+		snapshotter, ok := app.(raft.Snapshotter)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: snapshot contains app state, but app %T does not implement raft.Snapshotter",
+				ErrInvalidSnapshot, app)
+		}
+
+		ar := &frameReader{r: br, ft: frameApp}
+		if err := snapshotter.RecoverFromSnapshot(ctx, ar, done); err != nil {
+			return nil, false, fmt.Errorf("failed to recover app snapshot: %w", err)
+		}
+
+		// in case the app does not read until the end
+		if _, err := io.Copy(io.Discard, ar); err != nil {
+			return nil, false, err
+		}
+
+		appRecovered = true
+	}
+
+	ft, _, err = readFrame(br)
+	if err != nil {
+		return nil, false, err
+	}
+	if ft != frameEnd {
+		return nil, false, fmt.Errorf("%w: expected end frame, got %v", ErrInvalidSnapshot, ft)
+	}
+
+	return &header, appRecovered, nil
+}
+
+// frameWriter wraps each write as a frame of type ft
+type frameWriter struct {
+	w  io.Writer
+	ft frameType
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := writeFrame(f.w, f.ft, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// frameReader reads the payload of frames of type ft until the end frame of the section
+type frameReader struct {
+	r   io.Reader
+	ft  frameType
+	buf []byte
+	eof bool
+}
+
+func (f *frameReader) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.eof {
+			return 0, io.EOF
+		}
+
+		ft, payload, err := readFrame(f.r)
+		if err != nil {
+			return 0, err
+		}
+
+		switch ft {
+		case f.ft:
+			f.buf = payload
+		case frameEnd:
+			f.eof = true
+		default:
+			return 0, fmt.Errorf("%w: unexpected frame type %v in %v section", ErrInvalidSnapshot, ft, f.ft)
+		}
+	}
+
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+func writeFrame(w io.Writer, ft frameType, payload []byte) error {
+	var header [5]byte
+	header[0] = byte(ft)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(r io.Reader) (frameType, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to read frame header: %w", ErrInvalidSnapshot, err)
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("%w: frame too large (%v bytes)", ErrInvalidSnapshot, size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to read frame payload: %w", ErrInvalidSnapshot, err)
+	}
+
+	return frameType(header[0]), payload, nil
+}
+
+func (f frameType) String() string {
+	switch f {
+	case frameHeader:
+		return "header"
+	case frameTable:
+		return "table"
+	case frameRows:
+		return "rows"
+	case frameEnd:
+		return "end"
+	case frameApp:
+		return "app"
+	case frameValue:
+		return "value"
+	case frameChunk:
+		return "chunk"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(f))
+}
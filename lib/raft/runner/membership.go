@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const membershipTimeout = 10 * time.Second
+
+var (
+	ErrReplicaNotFound = errors.New("replica not found")
+	ErrInvalidMember   = errors.New("invalid member")
+)
+
+// Membership of the shard of a replica
+type Membership struct {
+	ShardID uint64 `json:"shard_id"`
+
+	// ConfigChangeID is the index of the last applied membership change
+	ConfigChangeID uint64 `json:"config_change_id"`
+	LeaderID       uint64 `json:"leader_id"`
+	Term           uint64 `json:"term"`
+
+	// replica ID to raft address
+	Nodes      map[uint64]string `json:"nodes"`
+	NonVotings map[uint64]string `json:"non_votings"`
+	Witnesses  map[uint64]string `json:"witnesses"`
+
+	// Removed replica ID can not join the shard again
+	Removed []uint64 `json:"removed"`
+}
+
+// ReplicaContext returns the raft context of a configured replica by its ID,
+// to manage a replica without running it in this process (eg. from an admin API).
+func ReplicaContext(ctx context.Context, id string) (context.Context, error) {
+	sc, ok := GetConfig().ReplicaByID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrReplicaNotFound, id)
+	}
+
+	return context.WithValue(ctx, contextKey, RaftContext{
+		ID:        sc.ID,
+		ShardID:   sc.ShardID,
+		ReplicaID: sc.ReplicaID,
+		Type:      sc.Type,
+		DHost:     DHost(),
+
+		isBootstrap: sc.Bootstrap,
+		Storage:     globalStorage,
+		namespace:   namespace,
+	}), nil
+}
+
+// GetMembership does a linearizable read of the shard membership
+func GetMembership(ctx context.Context) (Membership, error) {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return Membership{}, ErrRaftContextNotFound
+	}
+
+	ctx, cancel := withMembershipTimeout(ctx)
+	defer cancel()
+
+	m, err := raftCtx.DHost.SyncGetShardMembership(ctx, raftCtx.ShardID)
+	if err != nil {
+		return Membership{}, fmt.Errorf("failed to get membership of shard %v: %w", raftCtx.ShardID, err)
+	}
+
+	result := Membership{
+		ShardID:        raftCtx.ShardID,
+		ConfigChangeID: m.ConfigChangeID,
+		Nodes:          m.Nodes,
+		NonVotings:     m.NonVotings,
+		Witnesses:      m.Witnesses,
+		Removed:        make([]uint64, 0, len(m.Removed)),
+	}
+	for replicaID := range m.Removed {
+		result.Removed = append(result.Removed, replicaID)
+	}
+	slices.Sort(result.Removed)
+
+	leaderID, term, ok, err := raftCtx.DHost.GetLeaderID(raftCtx.ShardID)
+	if err == nil && ok {
+		result.LeaderID = leaderID
+		result.Term = term
+	}
+
+	return result, nil
+}
+
+// AddReplica adds a new voting replica to the shard. The new replica must then be
+// started at target (its raft address) with join enabled, ie. not bootstrapped.
+func AddReplica(ctx context.Context, replicaID uint64, target string) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return ErrRaftContextNotFound
+	}
+
+	if replicaID == 0 || target == "" {
+		return fmt.Errorf("%w: replica ID and target are required", ErrInvalidMember)
+	}
+
+	m, err := GetMembership(ctx)
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(m.Removed, replicaID) {
+		return fmt.Errorf("%w: replica %v was removed from the shard, use a new replica ID", ErrInvalidMember, replicaID)
+	}
+
+	ctx, cancel := withMembershipTimeout(ctx)
+	defer cancel()
+
+	// config change ID makes the request fail if the membership changed in between
+	err = raftCtx.DHost.SyncRequestAddReplica(ctx, raftCtx.ShardID, replicaID, target, m.ConfigChangeID)
+	if err != nil {
+		return fmt.Errorf("failed to add replica %v (%v) to shard %v: %w", replicaID, target, raftCtx.ShardID, err)
+	}
+
+	return nil
+}
+
+// RemoveReplica removes the replica from the shard. A removed replica ID can not be reused.
+func RemoveReplica(ctx context.Context, replicaID uint64) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return ErrRaftContextNotFound
+	}
+
+	m, err := GetMembership(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, node := m.Nodes[replicaID]
+	_, nonVoting := m.NonVotings[replicaID]
+	_, witness := m.Witnesses[replicaID]
+	if !node && !nonVoting && !witness {
+		return fmt.Errorf("%w: replica %v is not a member of shard %v", ErrInvalidMember, replicaID, raftCtx.ShardID)
+	}
+
+	ctx, cancel := withMembershipTimeout(ctx)
+	defer cancel()
+
+	err = raftCtx.DHost.SyncRequestDeleteReplica(ctx, raftCtx.ShardID, replicaID, m.ConfigChangeID)
+	if err != nil {
+		return fmt.Errorf("failed to remove replica %v from shard %v: %w", replicaID, raftCtx.ShardID, err)
+	}
+
+	return nil
+}
+
+// TransferLeader asks the current leader to hand over leadership to the target replica.
+// It returns once the request is sent; check GetMembership for the outcome.
+func TransferLeader(ctx context.Context, targetReplicaID uint64) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return ErrRaftContextNotFound
+	}
+
+	m, err := GetMembership(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.Nodes[targetReplicaID]; !ok {
+		return fmt.Errorf("%w: replica %v is not a voting member of shard %v", ErrInvalidMember, targetReplicaID, raftCtx.ShardID)
+	}
+
+	err = raftCtx.DHost.RequestLeaderTransfer(raftCtx.ShardID, targetReplicaID)
+	if err != nil {
+		return fmt.Errorf("failed to transfer leader of shard %v to %v: %w", raftCtx.ShardID, targetReplicaID, err)
+	}
+
+	return nil
+}
+
+// dragonboat sync requests require a deadline
+func withMembershipTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, membershipTimeout)
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lni/dragonboat/v4"
+	"github.com/rs/zerolog/log"
+)
+
+type membershipAPI struct{}
+
+// NewMembershipAPI manages the shard membership of the configured replicas.
+// The replica is selected with the :id param (the replica ID in the config), eg.
+//
+//	router.GET("/raft/:id/members", api.Members)
+//	router.POST("/raft/:id/members", api.AddReplica)
+//	router.DELETE("/raft/:id/members/:replica_id", api.RemoveReplica)
+//	router.POST("/raft/:id/leader", api.TransferLeader)
+//
+// Protect the routes, they are meant for operators only.
+func NewMembershipAPI() *membershipAPI {
+	return &membershipAPI{}
+}
+
+// MemberRequest is the body to add a replica or to transfer the leadership
+type MemberRequest struct {
+	ReplicaID uint64 `json:"replica_id"`
+
+	// Target is the raft address of the new replica (add only)
+	Target string `json:"target,omitempty"`
+}
+
+func (a *membershipAPI) Members(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ctx, err := ReplicaContext(r.Context(), p.ByName("id"))
+	if err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	m, err := GetMembership(ctx)
+	if err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	payload, _ := json.Marshal(m)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+func (a *membershipAPI) AddReplica(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ctx, err := ReplicaContext(r.Context(), p.ByName("id"))
+	if err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid member request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := AddReplica(ctx, req.ReplicaID, req.Target); err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	log.Info().Msgf("replica %v (%v) added to %v", req.ReplicaID, req.Target, p.ByName("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *membershipAPI) RemoveReplica(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ctx, err := ReplicaContext(r.Context(), p.ByName("id"))
+	if err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	replicaID, err := strconv.ParseUint(p.ByName("replica_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid replica ID: "+p.ByName("replica_id"), http.StatusBadRequest)
+		return
+	}
+
+	if err := RemoveReplica(ctx, replicaID); err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	log.Info().Msgf("replica %v removed from %v", replicaID, p.ByName("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *membershipAPI) TransferLeader(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ctx, err := ReplicaContext(r.Context(), p.ByName("id"))
+	if err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	var req MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid member request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := TransferLeader(ctx, req.ReplicaID); err != nil {
+		writeMembershipError(w, err)
+		return
+	}
+
+	// the transfer itself is asynchronous
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeMembershipError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrReplicaNotFound), errors.Is(err, dragonboat.ErrShardNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidMember), errors.Is(err, dragonboat.ErrInvalidOperation):
+		status = http.StatusBadRequest
+	case errors.Is(err, dragonboat.ErrRejected):
+		// eg. the membership changed in between; get the members and try again
+		status = http.StatusConflict
+	case errors.Is(err, dragonboat.ErrTimeout),
+		errors.Is(err, dragonboat.ErrShardNotReady),
+		errors.Is(err, dragonboat.ErrSystemBusy),
+		errors.Is(err, dragonboat.ErrAborted):
+		status = http.StatusServiceUnavailable
+	}
+
+	http.Error(w, err.Error(), status)
+}
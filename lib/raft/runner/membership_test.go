@@ -0,0 +1,115 @@
+package runner_test
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const commandIncrement raft.Command = "increment"
+
+// counterApp counts the increments, persisted in the raft metadata
+type counterApp struct {
+	count int
+}
+
+func (a *counterApp) Init(ctx context.Context) error {
+	payload, err := raft_runner.GetMetadata(ctx, "counter")
+	if err != nil || len(payload) == 0 {
+		return err
+	}
+	a.count, err = strconv.Atoi(string(payload))
+	return err
+}
+
+func (a *counterApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *counterApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandIncrement {
+		return nil, raft.ErrUnsupported
+	}
+	a.count++
+	count := a.count
+	return func() (raft.Result, error) { return raft.Result{Data: []byte(strconv.Itoa(count))}, nil }, nil
+}
+
+func (a *counterApp) Apply(ctx context.Context) error {
+	return raft_runner.SetMetadata(ctx, "counter", []byte(strconv.Itoa(a.count)))
+}
+
+func (a *counterApp) Lookup(ctx context.Context, key any) (any, error) {
+	return a.count, nil
+}
+
+func increment(t *testing.T, c *raftest.Cluster, replicaID uint64, n int) {
+	t.Helper()
+	for range n {
+		if _, err := c.Propose(replicaID, commandIncrement, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplaceReplica(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} })
+	increment(t, c, 1, 3)
+
+	// move the leadership away from the replica to be replaced
+	leaderID := c.WaitLeader()
+	ctx := c.Node(leaderID).Context()
+	if leaderID == 1 {
+		if err := raft_runner.TransferLeader(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+		for range 100 {
+			if leaderID = c.WaitLeader(); leaderID != 1 {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if leaderID == 1 {
+			t.Fatal("leadership is not transferred")
+		}
+		ctx = c.Node(leaderID).Context()
+	}
+
+	node := c.AddNode(4)
+	if err := raft_runner.AddReplica(ctx, 4, node.Address); err != nil {
+		t.Fatal(err)
+	}
+	c.Restart(4)
+
+	if err := raft_runner.RemoveReplica(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	c.Stop(1)
+
+	increment(t, c, 4, 2)
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return node.App.(*counterApp).count, nil
+	})
+	if got := c.Node(4).App.(*counterApp).count; got != 5 {
+		t.Fatalf("expected the new replica to count 5, got %v", got)
+	}
+
+	m, err := raft_runner.GetMembership(c.Node(4).Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Nodes) != 3 || m.Nodes[4] != node.Address || !slices.Contains(m.Removed, 1) {
+		t.Fatalf("unexpected membership: %+v", m)
+	}
+
+	if err := raft_runner.AddReplica(ctx, 1, c.Node(1).Address); err == nil {
+		t.Fatal("expected error adding back a removed replica")
+	}
+}
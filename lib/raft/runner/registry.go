@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"strconv"
 
-	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/desain-gratis/common/lib/notifier"
 	noifier_impl "github.com/desain-gratis/common/lib/notifier/impl"
 	"github.com/lni/dragonboat/v4"
@@ -33,7 +32,7 @@ var cfg3 config3
 
 var topic notifier.Topic = noifier_impl.NewStandardTopic()
 
-var globalClickhouse driver.Conn
+var globalStorage StorageEngine
 var namespace string
 
 type config3 struct {
@@ -114,7 +113,18 @@ func WithClickhouseStorage(
 		log.Fatal().Msgf("failed to create replica DB in clickhouse err: %v", err)
 	}
 
-	globalClickhouse = Connect(address, username, password, database)
+	globalStorage = NewClickhouseStorage(Connect(address, username, password, database))
+}
+
+// WithSQLiteStorage stores the replicas in SQLite database files inside dir,
+// so a replica can run without external database.
+func WithSQLiteStorage(dir string) {
+	globalStorage = NewSQLiteStorage(dir)
+}
+
+// WithStorage uses a custom storage engine for the replicas
+func WithStorage(storage StorageEngine) {
+	globalStorage = storage
 }
 
 func ConfigureReplica(replica map[uint64]ReplicaConfig) {
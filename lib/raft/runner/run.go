@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/desain-gratis/common/lib/raft"
 	"github.com/lni/dragonboat/v4"
 	"github.com/lni/dragonboat/v4/config"
@@ -21,9 +20,20 @@ type ReplicaConfig struct {
 	Alias     string `yaml:"alias"`
 	Type      string `yaml:"type"`
 	Config    string `yaml:"config"`
+
+	// BinaryCommand proposes the smaller binary command instead of JSON.
+	// Enable it only after every replica of the shard runs a runner that decodes it (see CommandBinary).
+	BinaryCommand bool `yaml:"binary_command" mapstructure:"binary_command"`
+}
+
+func (sc ReplicaConfig) commandEncoding() CommandEncoding {
+	if sc.BinaryCommand {
+		return CommandBinary
+	}
+	return CommandJSON
 }
 
-func withContext[T any](ctx context.Context, conn driver.Conn, appID string) (context.Context, error) {
+func withContext[T any](ctx context.Context, storage StorageEngine, appID string) (context.Context, error) {
 	cfg := GetConfig()
 
 	sc, ok := cfg.ReplicaByID[appID]
@@ -47,9 +57,11 @@ func withContext[T any](ctx context.Context, conn driver.Conn, appID string) (co
 		DHost:     DHost(),
 
 		// internal state
-		isBootstrap:    sc.Bootstrap,
-		ClickhouseConn: conn,
-		namespace:      namespace,
+		isBootstrap: sc.Bootstrap,
+		Storage:     storage,
+		namespace:   namespace,
+
+		CommandEncoding: sc.commandEncoding(),
 
 		// can add more as required
 	})
@@ -58,11 +70,11 @@ func withContext[T any](ctx context.Context, conn driver.Conn, appID string) (co
 }
 
 func RunReplica[T any](ctx context.Context, appID string, app raft.Application) (context.Context, error) {
-	if globalClickhouse == nil {
-		log.Fatal().Msgf("please call WithClickhouseStorage() first to configure replica store")
+	if globalStorage == nil {
+		log.Fatal().Msgf("please call WithClickhouseStorage() or WithSQLiteStorage() first to configure replica store")
 	}
 
-	ctx, err := withContext[T](ctx, globalClickhouse, appID)
+	ctx, err := withContext[T](ctx, globalStorage, appID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build raft replica context: %v", err)
 	}
@@ -71,8 +83,8 @@ func RunReplica[T any](ctx context.Context, appID string, app raft.Application)
 }
 
 func ForEachReplica[T any](appType string, f func(ctx context.Context) error) {
-	if globalClickhouse == nil {
-		log.Fatal().Msgf("please call WithClickhouseStorage() first to configure replica store")
+	if globalStorage == nil {
+		log.Fatal().Msgf("please call WithClickhouseStorage() or WithSQLiteStorage() first to configure replica store")
 	}
 
 	cfg := GetConfig()
@@ -101,8 +113,10 @@ func ForEachReplica[T any](appType string, f func(ctx context.Context) error) {
 			// internal state
 			isBootstrap: sc.Bootstrap,
 
-			ClickhouseConn: globalClickhouse,
-			namespace:      namespace,
+			Storage:   globalStorage,
+			namespace: namespace,
+
+			CommandEncoding: sc.commandEncoding(),
 
 			// can add more as required
 		})
@@ -115,20 +129,12 @@ func ForEachReplica[T any](appType string, f func(ctx context.Context) error) {
 }
 
 func Run(ctx context.Context, app raft.Application) error {
-	dhost := DHost()
-
 	cfg := GetConfig()
 	raftCtx, err := GetRaftContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	fn := newBaseDiskClickhouseSM(
-		cfg,
-		raftCtx,
-		app,
-	)
-
 	var target map[uint64]dragonboat.Target
 	if raftCtx.isBootstrap {
 		target = getPeer(cfg.Host.Peer)
@@ -136,9 +142,7 @@ func Run(ctx context.Context, app raft.Application) error {
 
 	join := len(target) == 0
 
-	err = dhost.StartOnDiskReplica(target, join, fn, config.Config{
-		ShardID:            raftCtx.ShardID,
-		ReplicaID:          raftCtx.ReplicaID,
+	err = StartReplica(ctx, app, target, join, config.Config{
 		HeartbeatRTT:       1,
 		CheckQuorum:        true,
 		ElectionRTT:        10,
@@ -153,6 +157,36 @@ func Run(ctx context.Context, app raft.Application) error {
 	return nil
 }
 
+// StartReplica starts app as an on disk replica in the NodeHost of the raft context.
+// ShardID and ReplicaID of rc are taken from the raft context.
+func StartReplica(ctx context.Context, app raft.Application, initialMembers map[uint64]dragonboat.Target, join bool, rc config.Config) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if raftCtx.DHost == nil {
+		raftCtx.DHost = DHost()
+	}
+	if raftCtx.Storage == nil {
+		raftCtx.Storage = globalStorage
+	}
+	if raftCtx.Storage == nil {
+		return fmt.Errorf("no storage engine configured for replica %v", raftCtx.ID)
+	}
+
+	rc.ShardID = raftCtx.ShardID
+	rc.ReplicaID = raftCtx.ReplicaID
+
+	fn := newBaseDiskSM(
+		GetConfig(),
+		raftCtx,
+		app,
+	)
+
+	return raftCtx.DHost.StartOnDiskReplica(initialMembers, join, fn, rc)
+}
+
 func getPeer(x map[int]string) map[uint64]dragonboat.Target {
 	y := make(map[uint64]dragonboat.Target)
 	for replicaID, peerRaftAddress := range x {
@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/lni/dragonboat/v4"
+	dclient "github.com/lni/dragonboat/v4/client"
+	"github.com/lni/dragonboat/v4/statemachine"
+
+	"github.com/desain-gratis/common/lib/raft"
+)
+
+const (
+	sessionProposeTimeout  = 5 * time.Second
+	sessionProposeAttempts = 3
+)
+
+var ErrSessionPoolClosed = errors.New("session pool closed")
+
+// Session is a client session; a retried proposal with the same series ID is applied once.
+// Not goroutine safe: one proposal at a time.
+type Session struct {
+	ClientID    uint64
+	SeriesID    uint64
+	RespondedTo uint64
+}
+
+// ProposalCompleted must be called once the response of the proposal is received,
+// before the next proposal
+func (s *Session) ProposalCompleted() {
+	s.RespondedTo = s.SeriesID
+	s.SeriesID++
+}
+
+// SessionPool proposes with client sessions, so proposals retried on timeout are applied exactly once.
+// A session is registered for each concurrent proposal, and reused afterward.
+// Close the pool to unregister the sessions.
+type SessionPool struct {
+	dHost     *dragonboat.NodeHost
+	noop      *dclient.Session
+	replicaID uint64
+	encoding  CommandEncoding
+
+	lock   sync.Mutex
+	idle   []*Session
+	closed bool
+}
+
+func NewSessionPool(ctx context.Context) (*SessionPool, error) {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return nil, ErrRaftContextNotFound
+	}
+
+	return &SessionPool{
+		dHost:     raftCtx.DHost,
+		noop:      raftCtx.DHost.GetNoOPSession(raftCtx.ShardID),
+		replicaID: raftCtx.ReplicaID,
+		encoding:  raftCtx.CommandEncoding,
+	}, nil
+}
+
+// Propose the command, retrying on timeout. Result with non zero Value is an app error;
+// the session errors (see SessionError) are returned as error.
+func (p *SessionPool) Propose(ctx context.Context, command raft.Command, value json.RawMessage) (statemachine.Result, error) {
+	session, err := p.get(ctx)
+	if err != nil {
+		return statemachine.Result{}, err
+	}
+
+	res, err := p.propose(ctx, Command{
+		Command:   command,
+		Value:     value,
+		ReplicaID: &p.replicaID,
+		Session: &CommandSession{
+			ClientID:    session.ClientID,
+			SeriesID:    session.SeriesID,
+			RespondedTo: session.RespondedTo,
+		},
+	})
+	if err != nil {
+		// the outcome is unknown; the proposal might still be applied later,
+		// so the session can not be used for another proposal
+		go p.discard(session)
+		return statemachine.Result{}, err
+	}
+
+	switch err := SessionError(raft.Result(res)); {
+	case errors.Is(err, ErrSessionNotFound):
+		// evicted; the proposal was not applied
+		return statemachine.Result{}, fmt.Errorf("%w: client %v", err, session.ClientID)
+	case err != nil:
+		// applied, but the result is unknown
+		session.ProposalCompleted()
+		p.put(session)
+		return statemachine.Result{}, fmt.Errorf("%w: client %v", err, session.ClientID)
+	}
+
+	session.ProposalCompleted()
+	p.put(session)
+
+	return res, nil
+}
+
+// Close unregisters the idle sessions; sessions still in use are unregistered when returned
+func (p *SessionPool) Close(ctx context.Context) error {
+	p.lock.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.lock.Unlock()
+
+	var errs []error
+	for _, session := range idle {
+		if err := p.closeSession(ctx, session); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *SessionPool) get(ctx context.Context) (*Session, error) {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, ErrSessionPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		session := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.lock.Unlock()
+		return session, nil
+	}
+	p.lock.Unlock()
+
+	// same as dragonboat, series ID starts after the register proposal
+	session := &Session{ClientID: rand.Uint64()}
+	for session.ClientID <= 1 || session.ClientID >= minReservedResult {
+		// 1 is the error result value, the others are the session errors
+		session.ClientID = rand.Uint64()
+	}
+	res, err := p.propose(ctx, Command{
+		Command: CommandRegisterSession,
+		Session: &CommandSession{ClientID: session.ClientID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register session: %w", err)
+	}
+	if res.Value != session.ClientID {
+		return nil, fmt.Errorf("failed to register session: '%v'", string(res.Data))
+	}
+	session.ProposalCompleted()
+
+	return session, nil
+}
+
+func (p *SessionPool) put(session *Session) {
+	p.lock.Lock()
+
+	closed := p.closed
+	if !closed {
+		p.idle = append(p.idle, session)
+	}
+	p.lock.Unlock()
+
+	if closed {
+		p.discard(session)
+	}
+}
+
+func (p *SessionPool) discard(session *Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionProposeTimeout)
+	defer cancel()
+
+	// best effort; otherwise it is evicted eventually
+	p.closeSession(ctx, session)
+}
+
+func (p *SessionPool) closeSession(ctx context.Context, session *Session) error {
+	res, err := p.propose(ctx, Command{
+		Command: CommandCloseSession,
+		Session: &CommandSession{ClientID: session.ClientID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close session %v: %w", session.ClientID, err)
+	}
+	if res.Value != session.ClientID {
+		return fmt.Errorf("failed to close session %v: '%v'", session.ClientID, string(res.Data))
+	}
+
+	return nil
+}
+
+// propose retries the exact same command on retryable raft error
+func (p *SessionPool) propose(ctx context.Context, cmd Command) (statemachine.Result, error) {
+	data, err := EncodeCommand(p.encoding, cmd)
+	if err != nil {
+		return statemachine.Result{}, fmt.Errorf("failed to marshal msg to raft: %w", err)
+	}
+
+	var res statemachine.Result
+	for attempt := range sessionProposeAttempts {
+		if attempt > 0 {
+			time.Sleep(100 * time.Millisecond * time.Duration(1<<attempt))
+		}
+
+		pctx, cancel := context.WithTimeout(ctx, sessionProposeTimeout)
+		res, err = p.dHost.SyncPropose(pctx, p.noop, data)
+		cancel()
+		if err == nil || !isRetryable(err) || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return statemachine.Result{}, fmt.Errorf("%w: %w", ErrRaft, err)
+	}
+
+	return res, nil
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, dragonboat.ErrTimeout) ||
+		errors.Is(err, dragonboat.ErrSystemBusy) ||
+		errors.Is(err, dragonboat.ErrShardNotReady) ||
+		errors.Is(err, dragonboat.ErrAborted)
+}
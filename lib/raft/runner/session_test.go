@@ -0,0 +1,142 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+func proposeRaw(t *testing.T, node *raftest.Node, command raft.Command, session raft_runner.CommandSession) raft.Result {
+	t.Helper()
+
+	data, err := raft_runner.EncodeCommand(raft_runner.CommandJSON, raft_runner.Command{Command: command, Session: &session})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := node.Host.SyncPropose(ctx, node.Host.GetNoOPSession(1), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raft.Result(res)
+}
+
+func TestSessionPool(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} })
+
+	pool, err := raft_runner.NewSessionPool(c.Node(1).Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		res, err := pool.Propose(context.Background(), commandIncrement, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Data) != strconv.Itoa(i) {
+			t.Fatalf("expected count %v, got %s", i, res.Data)
+		}
+	}
+
+	if err := pool.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Propose(context.Background(), commandIncrement, nil); err == nil {
+		t.Fatal("expected error proposing with a closed pool")
+	}
+}
+
+func TestSessionDeduplication(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &counterApp{} })
+	node := c.Node(1)
+
+	if res := proposeRaw(t, node, raft_runner.CommandRegisterSession, raft_runner.CommandSession{ClientID: 42}); res.Value != 42 {
+		t.Fatalf("failed to register session: %+v", res)
+	}
+
+	first := proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 1})
+	retried := proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 1})
+	if string(first.Data) != "1" || string(retried.Data) != "1" {
+		t.Fatalf("expected the retry to get the first response, got %s and %s", first.Data, retried.Data)
+	}
+
+	// the sessions survive restart
+	c.Stop(1)
+	c.Restart(1)
+	node = c.Node(1)
+
+	retried = proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 1})
+	if string(retried.Data) != "1" {
+		t.Fatalf("expected the retry after restart to get the first response, got %+v", retried)
+	}
+
+	next := proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 2, RespondedTo: 1})
+	if string(next.Data) != "2" {
+		t.Fatalf("expected count 2, got %+v", next)
+	}
+
+	if res := proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 1, RespondedTo: 2}); res.Value == 0 {
+		t.Fatalf("expected error for a responded proposal, got %+v", res)
+	}
+
+	if res := proposeRaw(t, node, raft_runner.CommandCloseSession, raft_runner.CommandSession{ClientID: 42}); res.Value != 42 {
+		t.Fatalf("failed to close session: %+v", res)
+	}
+	if res := proposeRaw(t, node, commandIncrement, raft_runner.CommandSession{ClientID: 42, SeriesID: 3, RespondedTo: 2}); res.Value == 0 {
+		t.Fatalf("expected error for a closed session, got %+v", res)
+	}
+
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return node.App.(*counterApp).count, nil
+	})
+	if got := c.Node(1).App.(*counterApp).count; got != 2 {
+		t.Fatalf("expected the counter to be incremented twice, got %v", got)
+	}
+}
+
+const commandEcho raft.Command = "echo"
+
+// echoApp responds with an app error of the proposed value
+type echoApp struct{ counterApp }
+
+func (a *echoApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandEcho {
+		return nil, raft.ErrUnsupported
+	}
+	return func() (raft.Result, error) { return raft.Result{Value: 1, Data: e.Value}, nil }, nil
+}
+
+func TestSessionPoolAppResult(t *testing.T) {
+	c := raftest.NewCluster(t, 1, func(uint64) raft.Application { return &echoApp{} })
+
+	pool, err := raft_runner.NewSessionPool(c.Node(1).Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close(context.Background())
+
+	// the app result is not mistaken for a session error, whatever its content
+	res, err := pool.Propose(context.Background(), commandEcho, []byte(`"session not found"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != 1 || string(res.Data) != `"session not found"` {
+		t.Fatalf("expected the app result, got %+v", res)
+	}
+
+	// the session error is still the typed result code
+	closed := proposeRaw(t, c.Node(1), commandEcho, raft_runner.CommandSession{ClientID: 7, SeriesID: 1})
+	if !errors.Is(raft_runner.SessionError(closed), raft_runner.ErrSessionNotFound) {
+		t.Fatalf("expected session not found, got %+v", closed)
+	}
+}
@@ -0,0 +1,404 @@
+package runner_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/desain-gratis/common/lib/raft"
+	"github.com/desain-gratis/common/lib/raft/raftest"
+	raft_runner "github.com/desain-gratis/common/lib/raft/runner"
+)
+
+const (
+	commandInsert raft.Command = "insert"
+
+	nullableTable = "raftest_nullable"
+)
+
+// nullableApp keeps every inserted value, which can be NULL, in a ClickHouse table
+type nullableApp struct{}
+
+type nullableRow struct {
+	ID    uint64
+	Value *string
+}
+
+func (a *nullableApp) Init(ctx context.Context) error {
+	return raft_runner.GetClickhouseConnection(ctx).Exec(ctx,
+		"CREATE TABLE IF NOT EXISTS "+nullableTable+" (id UInt64, value Nullable(String), tags Array(Nullable(String))) ENGINE = MergeTree PARTITION BY id % 4 ORDER BY id")
+}
+
+func (a *nullableApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *nullableApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandInsert {
+		return nil, raft.ErrUnsupported
+	}
+
+	var value *string
+	if err := json.Unmarshal(e.Value, &value); err != nil {
+		return nil, err
+	}
+
+	err := raft_runner.GetClickhouseConnection(ctx).Exec(ctx,
+		"INSERT INTO "+nullableTable+" (id, value, tags) VALUES (?, ?, ?)", e.Index, value, []*string{value, nil})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (raft.Result, error) { return raft.Result{Value: e.Index}, nil }, nil
+}
+
+func (a *nullableApp) Apply(ctx context.Context) error {
+	return nil
+}
+
+func (a *nullableApp) Lookup(ctx context.Context, key any) (any, error) {
+	rows, err := raft_runner.GetClickhouseConnection(ctx).Query(ctx, "SELECT id, value FROM "+nullableTable+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []nullableRow
+	for rows.Next() {
+		var row nullableRow
+		if err := rows.Scan(&row.ID, &row.Value); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (a *nullableApp) Tables(ctx context.Context) []string {
+	return []string{nullableTable}
+}
+
+// clickhouseAddr of the ClickHouse server of RAFTEST_CLICKHOUSE_ADDR, otherwise of a new container if docker is available
+func clickhouseAddr(t *testing.T) string {
+	if addr := os.Getenv("RAFTEST_CLICKHOUSE_ADDR"); addr != "" {
+		return addr
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("RAFTEST_CLICKHOUSE_ADDR is not set, and docker is not available")
+	}
+
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::9000",
+		"-e", "CLICKHOUSE_SKIP_USER_SETUP=1", "clickhouse/clickhouse-server").Output()
+	if err != nil {
+		t.Skipf("failed to start the ClickHouse container: %v", err)
+	}
+	container := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		exec.Command("docker", "rm", "-f", container).Run()
+	})
+
+	out, err = exec.Command("docker", "port", container, "9000/tcp").Output()
+	if err != nil {
+		t.Fatalf("failed to get the port of the ClickHouse container: %v", err)
+	}
+	addr := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+
+	conn, err := clickhouse.Open(&clickhouse.Options{Addr: []string{addr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for deadline := time.Now().Add(time.Minute); ; time.Sleep(time.Second) {
+		err := conn.Ping(context.Background())
+		if err == nil {
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ClickHouse container is not ready: %v", err)
+		}
+	}
+}
+
+// clickhouseStorage gives each node its own database in the ClickHouse server of clickhouseAddr
+func clickhouseStorage(t *testing.T) func(dir string) raft_runner.StorageEngine {
+	addr := clickhouseAddr(t)
+
+	open := func(database string) driver.Conn {
+		conn, err := clickhouse.Open(&clickhouse.Options{
+			Addr: []string{addr},
+			Auth: clickhouse.Auth{Database: database, Username: os.Getenv("RAFTEST_CLICKHOUSE_USER"), Password: os.Getenv("RAFTEST_CLICKHOUSE_PASSWORD")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	admin := open("default")
+	prefix := fmt.Sprintf("raftest_%d_", rand.Uint32())
+	t.Cleanup(func() {
+		rows, err := admin.Query(context.Background(), "SELECT name FROM system.databases WHERE startsWith(name, ?)", prefix)
+		if err == nil {
+			for rows.Next() {
+				var name string
+				if rows.Scan(&name) == nil {
+					admin.Exec(context.Background(), "DROP DATABASE IF EXISTS "+name)
+				}
+			}
+			rows.Close()
+		}
+		admin.Close()
+	})
+
+	return func(dir string) raft_runner.StorageEngine {
+		// dir is <cluster>/node-N/storage
+		database := prefix + strings.ReplaceAll(filepath.Base(filepath.Dir(dir)), "-", "_")
+		if err := admin.Exec(context.Background(), "CREATE DATABASE IF NOT EXISTS "+database); err != nil {
+			t.Fatal(err)
+		}
+		return raft_runner.NewClickhouseStorage(open(database))
+	}
+}
+
+func TestClickhouseSnapshot(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &nullableApp{} },
+		raftest.WithStorage(clickhouseStorage(t)), raftest.WithSnapshotEntries(5, 1))
+
+	insert := func(from, to int) {
+		t.Helper()
+		for i := from; i < to; i++ {
+			var value *string
+			if i%2 == 0 {
+				v := fmt.Sprint(i)
+				value = &v
+			}
+			if _, err := c.Propose(1, commandInsert, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	insert(0, 3)
+	c.WaitApplied()
+
+	c.Stop(3)
+
+	// the log is compacted, node 3 can only catch up from a snapshot with NULL values, of every partition
+	insert(3, 30)
+
+	c.Restart(3)
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	})
+
+	got, err := c.Read(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := got.([]nullableRow)
+	if len(rows) != 30 || rows[0].Value == nil || rows[1].Value != nil {
+		t.Fatalf("expected 30 rows, every other one NULL, got %+v", rows)
+	}
+}
+
+const commandAppend raft.Command = "append"
+
+// hookApp keeps its values in memory & in the raft metadata, and snapshots them itself
+type hookApp struct {
+	values []string
+
+	// set when the state comes from RecoverFromSnapshot
+	recovered bool
+}
+
+func (a *hookApp) Init(ctx context.Context) error {
+	payload, err := raft_runner.GetMetadata(ctx, "values")
+	if err != nil || len(payload) == 0 {
+		return err
+	}
+	return json.Unmarshal(payload, &a.values)
+}
+
+func (a *hookApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *hookApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandAppend {
+		return nil, raft.ErrUnsupported
+	}
+	a.values = append(a.values, string(e.Value))
+	return func() (raft.Result, error) { return raft.Result{Value: uint64(len(a.values))}, nil }, nil
+}
+
+func (a *hookApp) Apply(ctx context.Context) error {
+	payload, err := json.Marshal(a.values)
+	if err != nil {
+		return err
+	}
+	return raft_runner.SetMetadata(ctx, "values", payload)
+}
+
+func (a *hookApp) Lookup(ctx context.Context, key any) (any, error) {
+	return slices.Clone(a.values), nil
+}
+
+func (a *hookApp) PrepareSnapshot(ctx context.Context) (any, error) {
+	return slices.Clone(a.values), nil
+}
+
+func (a *hookApp) SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error {
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+func (a *hookApp) RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error {
+	var values []string
+	if err := json.NewDecoder(r).Decode(&values); err != nil {
+		return err
+	}
+	a.values, a.recovered = values, true
+	return nil
+}
+
+func TestSnapshotHooks(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &hookApp{} }, raftest.WithSnapshotEntries(5, 1))
+
+	appendN := func(from, to int) {
+		t.Helper()
+		for i := from; i < to; i++ {
+			if _, err := c.Propose(1, commandAppend, json.RawMessage(strconv.Itoa(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	appendN(0, 3)
+	c.WaitApplied()
+
+	c.Stop(3)
+	appendN(3, 30)
+
+	c.Restart(3)
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	})
+
+	app := c.Node(3).App.(*hookApp)
+	if !app.recovered {
+		t.Fatal("expected node 3 to recover its state with the snapshot hook")
+	}
+	if len(app.values) != 30 || app.values[29] != "29" {
+		t.Fatalf("expected 30 values, got %v", app.values)
+	}
+}
+
+// sqliteApp keeps every inserted value, which can be NULL, in a SQLite table
+type sqliteApp struct{}
+
+func (a *sqliteApp) Init(ctx context.Context) error {
+	_, err := raft_runner.GetSQLiteConnection(ctx).ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS "+nullableTable+" (id INTEGER PRIMARY KEY, value TEXT)")
+	return err
+}
+
+func (a *sqliteApp) PrepareUpdate(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func (a *sqliteApp) OnUpdate(ctx context.Context, e raft.Entry) (raft.OnAfterApply, error) {
+	if e.Command != commandInsert {
+		return nil, raft.ErrUnsupported
+	}
+
+	var value *string
+	if err := json.Unmarshal(e.Value, &value); err != nil {
+		return nil, err
+	}
+
+	_, err := raft_runner.GetSQLiteConnection(ctx).ExecContext(ctx,
+		"INSERT INTO "+nullableTable+" (id, value) VALUES (?, ?)", e.Index, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (raft.Result, error) { return raft.Result{Value: e.Index}, nil }, nil
+}
+
+func (a *sqliteApp) Apply(ctx context.Context) error {
+	return nil
+}
+
+func (a *sqliteApp) Lookup(ctx context.Context, key any) (any, error) {
+	rows, err := raft_runner.GetSQLiteConnection(ctx).QueryContext(ctx, "SELECT id, value FROM "+nullableTable+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []nullableRow
+	for rows.Next() {
+		var row nullableRow
+		if err := rows.Scan(&row.ID, &row.Value); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func TestSQLiteSnapshot(t *testing.T) {
+	c := raftest.NewCluster(t, 3, func(uint64) raft.Application { return &sqliteApp{} }, raftest.WithSnapshotEntries(5, 1))
+
+	insertN := func(from, to int) {
+		t.Helper()
+		for i := from; i < to; i++ {
+			var value *string
+			if i%2 == 0 {
+				v := strconv.Itoa(i)
+				value = &v
+			}
+			if _, err := c.Propose(1, commandInsert, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	insertN(0, 3)
+	c.WaitApplied()
+
+	c.Stop(3)
+
+	// the log is compacted, node 3 can only catch up from a snapshot of the database file
+	insertN(3, 30)
+
+	c.Restart(3)
+	c.RequireConverged(func(node *raftest.Node) (any, error) {
+		return c.Read(node.ReplicaID, nil)
+	})
+
+	got, err := c.Read(3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := got.([]nullableRow); len(rows) != 30 || rows[0].Value == nil || rows[1].Value != nil {
+		t.Fatalf("expected 30 rows, every other one NULL, got %+v", rows)
+	}
+}
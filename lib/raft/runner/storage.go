@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"context"
+	"io"
+)
+
+// StorageEngine is where a replica keeps its state: the raft metadata and the app tables.
+// One engine is shared by all replicas in the host; replica specific resources are
+// looked up from the RaftContext in ctx.
+type StorageEngine interface {
+	// Name of the engine, recorded in the snapshot header
+	Name() string
+
+	// Prepare the replica storage (eg. open the database, create the metadata table).
+	// Called once when the state machine opens.
+	Prepare(ctx context.Context) error
+
+	// WithConnection injects the engine connection to ctx for the app to use
+	// (eg. via GetClickhouseConnection or GetSQLiteConnection)
+	WithConnection(ctx context.Context) context.Context
+
+	// Begin an update. App writes and metadata done with the returned context
+	// are committed together by calling commit, if the engine supports it.
+	Begin(ctx context.Context) (_ context.Context, commit func() error, _ error)
+
+	// LoadMetadata returns empty payload if there is no metadata for the namespace yet
+	LoadMetadata(ctx context.Context, namespace string) ([]byte, error)
+	SaveMetadata(ctx context.Context, metadata map[string][]byte) error
+
+	// PrepareSnapshot captures a point in time copy of the replica storage. It is called while
+	// Update is blocked. tables is the list of tables known to the runner; engines that can
+	// snapshot the whole replica storage at once may ignore it.
+	PrepareSnapshot(ctx context.Context, tables []string) (any, error)
+	SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error
+	ReleaseSnapshot(ctx context.Context, snapshot any)
+	RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error
+
+	// Close the replica storage
+	Close(ctx context.Context) error
+}
+
+// TransactionalStorage is the optional capability of the StorageEngine to undo part of an update,
+// needed to apply CommandBatch all or nothing
+type TransactionalStorage interface {
+	// Savepoint in the update of ctx; rollback undoes the writes after it, release keeps them.
+	// Either of them ends the savepoint.
+	Savepoint(ctx context.Context) (rollback func() error, release func() error, err error)
+}
+
+func getStorage(ctx context.Context) StorageEngine {
+	raftCtx, _ := GetRaftContext(ctx)
+	return raftCtx.Storage
+}
@@ -0,0 +1,495 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/rs/zerolog/log"
+
+	sm "github.com/lni/dragonboat/v4/statemachine"
+)
+
+const storageClickhouse = "clickhouse"
+
+var _ StorageEngine = &clickhouseStorage{}
+
+type clickhouseStorage struct {
+	conn driver.Conn
+}
+
+// NewClickhouseStorage stores all the replicas in the database of conn.
+// Tables are prefixed by the replica ID.
+func NewClickhouseStorage(conn driver.Conn) StorageEngine {
+	return &clickhouseStorage{conn: conn}
+}
+
+func (c *clickhouseStorage) Name() string {
+	return storageClickhouse
+}
+
+func (c *clickhouseStorage) Prepare(ctx context.Context) error {
+	// prepare raft metadata table
+	return c.conn.Exec(ctx, DDLRaftMetadata(ctx))
+}
+
+func (c *clickhouseStorage) WithConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, chConnKey, c.conn)
+}
+
+// Begin is a no-op; ClickHouse has no transaction
+func (c *clickhouseStorage) Begin(ctx context.Context) (context.Context, func() error, error) {
+	return ctx, func() error { return nil }, nil
+}
+
+func (c *clickhouseStorage) LoadMetadata(ctx context.Context, namespace string) ([]byte, error) {
+	var payload string
+	if err := c.conn.QueryRow(ctx, DQLReadRaftMetadata(ctx), namespace).
+		Scan(&payload); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return []byte(payload), nil
+}
+
+// SaveMetadata writes metadata asynchronously
+func (c *clickhouseStorage) SaveMetadata(ctx context.Context, metadata map[string][]byte) error {
+	metadataCtx := clickhouse.Context(ctx, clickhouse.WithStdAsync(true))
+
+	for ns, meta := range metadata {
+		err := c.conn.AsyncInsert(metadataCtx, DMLWriteRaftMetadataAsync(ctx), true, ns, string(meta))
+		if err != nil {
+			return fmt.Errorf("save metadata failed (ns: %v): %w", ns, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *clickhouseStorage) PrepareSnapshot(ctx context.Context, tables []string) (any, error) {
+	return FreezeTables(ctx, tables...)
+}
+
+func (c *clickhouseStorage) SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error {
+	tables, ok := snapshot.(FrozenTables)
+	if !ok {
+		return fmt.Errorf("%w: unexpected clickhouse snapshot %T", ErrInvalidSnapshot, snapshot)
+	}
+
+	return WriteTables(ctx, w, tables, done)
+}
+
+func (c *clickhouseStorage) ReleaseSnapshot(ctx context.Context, snapshot any) {
+	if tables, ok := snapshot.(FrozenTables); ok {
+		tables.Drop(ctx)
+	}
+}
+
+func (c *clickhouseStorage) RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error {
+	_, err := ReadTables(ctx, r, done)
+	return err
+}
+
+// Close is a no-op; the connection is shared by all replicas
+func (c *clickhouseStorage) Close(ctx context.Context) error {
+	return nil
+}
+
+// FrozenTables is a point in time copy of tables, to be written to the snapshot with WriteTables
+type FrozenTables []frozenTable
+
+type frozenTable struct {
+	name   string
+	frozen string
+}
+
+// FreezeTables copies the current parts of each table to a dedicated snapshot table.
+// ATTACH PARTITION FROM only hard-links the parts, so it is cheap. Call it from PrepareSnapshot,
+// where Update is blocked, so the copy is consistent with the applied index.
+// Every active partition of the table is attached, so the table can be partitioned.
+func FreezeTables(ctx context.Context, tables ...string) (FrozenTables, error) {
+	conn := GetClickhouseConnection(ctx)
+	index, _ := ctx.Value(snapshotIndexKey).(uint64)
+
+	result := make(FrozenTables, 0, len(tables))
+	for _, table := range tables {
+		frozen := fmt.Sprintf("%s__snapshot_%d", table, index)
+
+		if err := conn.Exec(ctx, "DROP TABLE IF EXISTS "+frozen); err != nil {
+			result.Drop(ctx)
+			return nil, fmt.Errorf("failed to drop stale snapshot table %v: %w", frozen, err)
+		}
+
+		if err := conn.Exec(ctx, "CREATE TABLE "+frozen+" AS "+table); err != nil {
+			result.Drop(ctx)
+			return nil, fmt.Errorf("failed to create snapshot table %v: %w", frozen, err)
+		}
+
+		result = append(result, frozenTable{name: table, frozen: frozen})
+
+		partitions, err := getPartitions(ctx, conn, table)
+		if err != nil {
+			result.Drop(ctx)
+			return nil, fmt.Errorf("failed to list the partitions of table %v: %w", table, err)
+		}
+
+		for _, partition := range partitions {
+			if err := conn.Exec(ctx, "ALTER TABLE "+frozen+" ATTACH PARTITION ID ? FROM "+table, partition); err != nil {
+				result.Drop(ctx)
+				return nil, fmt.Errorf("failed to freeze partition %v of table %v: %w", partition, table, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// getPartitions ID of the active parts of the table; the unpartitioned table has the single partition "all"
+func getPartitions(ctx context.Context, conn driver.Conn, table string) ([]string, error) {
+	rows, err := conn.Query(ctx,
+		"SELECT DISTINCT partition_id FROM system.parts WHERE database = currentDatabase() AND table = ? AND active ORDER BY partition_id", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			return nil, err
+		}
+		result = append(result, partition)
+	}
+
+	return result, rows.Err()
+}
+
+// Drop the frozen copy of the tables. Call it after the snapshot is written.
+func (f FrozenTables) Drop(ctx context.Context) {
+	conn := GetClickhouseConnection(ctx)
+	for _, table := range f {
+		if err := conn.Exec(ctx, "DROP TABLE IF EXISTS "+table.frozen); err != nil {
+			log.Warn().Msgf("failed to drop snapshot table %v: %v", table.frozen, err)
+		}
+	}
+}
+
+// WriteTables writes all rows of the frozen tables to w
+func WriteTables(ctx context.Context, w io.Writer, tables FrozenTables, done <-chan struct{}) error {
+	conn := GetClickhouseConnection(ctx)
+
+	for _, table := range tables {
+		if err := writeFrame(w, frameTable, []byte(table.name)); err != nil {
+			return err
+		}
+
+		if err := writeTableRows(ctx, conn, w, table.frozen, done); err != nil {
+			return fmt.Errorf("failed to write table %v to snapshot: %w", table.name, err)
+		}
+	}
+
+	return writeFrame(w, frameEnd, nil)
+}
+
+// ReadTables replaces the content of the tables written by WriteTables.
+// The tables must already exist.
+func ReadTables(ctx context.Context, r io.Reader, done <-chan struct{}) ([]string, error) {
+	conn := GetClickhouseConnection(ctx)
+
+	var tables []string
+	var table string
+	var columnTypes []driver.ColumnType
+
+	for {
+		select {
+		case <-done:
+			return nil, sm.ErrSnapshotStopped
+		default:
+		}
+
+		ft, payload, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch ft {
+		case frameTable:
+			table = string(payload)
+			if err := conn.Exec(ctx, "TRUNCATE TABLE IF EXISTS "+table); err != nil {
+				return nil, fmt.Errorf("failed to truncate table %v: %w", table, err)
+			}
+
+			columnTypes, err = getColumnTypes(ctx, conn, table)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get column types for table %v: %w", table, err)
+			}
+
+			tables = append(tables, table)
+		case frameRows:
+			if table == "" {
+				return nil, fmt.Errorf("%w: rows without table", ErrInvalidSnapshot)
+			}
+			if err := loadTableRows(ctx, conn, table, columnTypes, payload); err != nil {
+				return nil, fmt.Errorf("failed to load table %v from snapshot: %w", table, err)
+			}
+		case frameEnd:
+			return tables, nil
+		default:
+			return nil, fmt.Errorf("%w: unexpected frame type %v in tables section", ErrInvalidSnapshot, ft)
+		}
+	}
+}
+
+func writeTableRows(ctx context.Context, conn driver.Conn, w io.Writer, table string, done <-chan struct{}) error {
+	rows, err := conn.Query(ctx, "SELECT * FROM "+table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columnTypes := rows.ColumnTypes()
+
+	buf := bytes.NewBuffer(make([]byte, 0, 1<<16))
+	enc := gob.NewEncoder(buf)
+	var count int
+
+	flush := func() error {
+		if count == 0 {
+			return nil
+		}
+		if err := writeFrame(w, frameRows, buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+		// gob stream carries type information once per encoder; each frame must be self contained
+		enc = gob.NewEncoder(buf)
+		count = 0
+		return nil
+	}
+
+	for rows.Next() {
+		select {
+		case <-done:
+			return sm.ErrSnapshotStopped
+		default:
+		}
+
+		dst := newScanDestination(columnTypes)
+		if err := rows.Scan(dst...); err != nil {
+			return err
+		}
+
+		if err := encodeRow(enc, dst); err != nil {
+			return err
+		}
+
+		count++
+		if count >= snapshotRowsPerFrame {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func loadTableRows(ctx context.Context, conn driver.Conn, table string, columnTypes []driver.ColumnType, payload []byte) error {
+	batch, err := conn.PrepareBatch(ctx, "INSERT INTO "+table)
+	if err != nil {
+		return err
+	}
+	defer batch.Close()
+
+	dec := gob.NewDecoder(bytes.NewReader(payload))
+	for {
+		dst := newScanDestination(columnTypes)
+
+		err := decodeRow(dec, dst)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		values := make([]any, len(dst))
+		for idx, d := range dst {
+			values[idx] = reflect.ValueOf(d).Elem().Interface()
+		}
+
+		if err := batch.Append(values...); err != nil {
+			return err
+		}
+	}
+
+	return batch.Send()
+}
+
+func getColumnTypes(ctx context.Context, conn driver.Conn, table string) ([]driver.ColumnType, error) {
+	rows, err := conn.Query(ctx, "SELECT * FROM "+table+" LIMIT 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return rows.ColumnTypes(), nil
+}
+
+func newScanDestination(columnTypes []driver.ColumnType) []any {
+	dst := make([]any, len(columnTypes))
+	for idx, ct := range columnTypes {
+		dst[idx] = reflect.New(ct.ScanType()).Interface()
+	}
+	return dst
+}
+
+// encodeRow of the scanned values. gob can not encode nil pointer, so every pointer
+// (Nullable column, also inside Array & Map) is prefixed by whether it is set.
+func encodeRow(enc *gob.Encoder, dst []any) error {
+	for _, d := range dst {
+		if err := encodeValue(enc, reflect.ValueOf(d).Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeRow to the scan destination; returns io.EOF if there is no more row
+func decodeRow(dec *gob.Decoder, dst []any) error {
+	for idx, d := range dst {
+		err := decodeValue(dec, reflect.ValueOf(d).Elem())
+		if err == io.EOF && idx > 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeValue(enc *gob.Encoder, v reflect.Value) error {
+	if !hasPointer(v.Type()) {
+		return enc.EncodeValue(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if err := enc.Encode(!v.IsNil()); err != nil || v.IsNil() {
+			return err
+		}
+		return encodeValue(enc, v.Elem())
+	case reflect.Slice, reflect.Array:
+		if err := enc.Encode(v.Len()); err != nil {
+			return err
+		}
+		for i := range v.Len() {
+			if err := encodeValue(enc, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if err := enc.Encode(v.Len()); err != nil {
+			return err
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := encodeValue(enc, iter.Key()); err != nil {
+				return err
+			}
+			if err := encodeValue(enc, iter.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported column type %v", v.Type())
+}
+
+func decodeValue(dec *gob.Decoder, v reflect.Value) error {
+	if !hasPointer(v.Type()) {
+		return dec.DecodeValue(v)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		var set bool
+		if err := dec.Decode(&set); err != nil {
+			return err
+		}
+		if !set {
+			v.SetZero()
+			return nil
+		}
+		ptr := reflect.New(v.Type().Elem())
+		if err := decodeValue(dec, ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	case reflect.Slice, reflect.Array:
+		var n int
+		if err := dec.Decode(&n); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		} else if n != v.Len() {
+			return fmt.Errorf("%w: expected %v elements of %v, got %v", ErrInvalidSnapshot, v.Len(), v.Type(), n)
+		}
+		for i := range n {
+			if err := decodeValue(dec, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		var n int
+		if err := dec.Decode(&n); err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		for range n {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := decodeValue(dec, key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(dec, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported column type %v", v.Type())
+}
+
+// hasPointer if a value of t may hold a nil pointer
+func hasPointer(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return true
+	case reflect.Slice, reflect.Array:
+		return hasPointer(t.Elem())
+	case reflect.Map:
+		return hasPointer(t.Key()) || hasPointer(t.Elem())
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestRowCodec(t *testing.T) {
+	// scan destination of: String, Nullable(String), Nullable(DateTime), Array(Nullable(Int64)), Map(String, Nullable(String))
+	newRow := func() []any {
+		return []any{new(string), new(*string), new(*time.Time), new([]*int64), new(map[string]*string)}
+	}
+
+	now := time.Date(2026, 10, 18, 1, 2, 3, 0, time.UTC)
+	rows := [][]any{
+		{ptr("a"), ptr(ptr("x")), ptr(&now), ptr([]*int64{ptr(int64(1)), nil}), ptr(map[string]*string{"k": ptr("v"), "nil": nil})},
+		{ptr("b"), new(*string), new(*time.Time), new([]*int64), new(map[string]*string)},
+		{ptr(""), ptr(ptr("")), new(*time.Time), ptr([]*int64{nil, nil}), ptr(map[string]*string{})},
+	}
+
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := encodeRow(enc, row); err != nil {
+			t.Fatalf("encode %v: %v", row, err)
+		}
+	}
+
+	dec := gob.NewDecoder(&buf)
+	for idx, want := range rows {
+		got := newRow()
+		if err := decodeRow(dec, got); err != nil {
+			t.Fatalf("decode row %v: %v", idx, err)
+		}
+		for col := range want {
+			w, g := reflect.ValueOf(want[col]).Elem(), reflect.ValueOf(got[col]).Elem()
+			// nil and empty slice or map are the same column value
+			if w.Kind() != reflect.Pointer && w.Len() == 0 && g.Len() == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(w.Interface(), g.Interface()) {
+				t.Fatalf("row %v column %v: got %#v, want %#v", idx, col, g.Interface(), w.Interface())
+			}
+		}
+	}
+
+	if err := decodeRow(dec, newRow()); err != io.EOF {
+		t.Fatalf("expected EOF after the last row, got %v", err)
+	}
+}
+
+func TestRowCodecTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeRow(gob.NewEncoder(&buf), []any{ptr("a"), new(*string)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a row with more columns than written
+	err := decodeRow(gob.NewDecoder(&buf), []any{new(string), new(*string), new(string)})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,397 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	sm "github.com/lni/dragonboat/v4/statemachine"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	storageSQLite = "sqlite"
+
+	sqliteConnKey ContextKey = "sqlite-conn"
+)
+
+var (
+	_ StorageEngine        = &sqliteStorage{}
+	_ TransactionalStorage = &sqliteStorage{}
+)
+
+// SQLiteConn is implemented by both *sql.DB and *sql.Tx.
+// Inside Update it is the transaction of the update.
+type SQLiteConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func GetSQLiteConnection(ctx context.Context) SQLiteConn {
+	return ctx.Value(sqliteConnKey).(SQLiteConn)
+}
+
+type sqliteStorage struct {
+	dir string
+
+	lock sync.Mutex
+	dbs  map[string]*sql.DB
+}
+
+// NewSQLiteStorage stores each replica in its own SQLite database file inside dir.
+// Updates, including the raft metadata, are committed in a single transaction.
+func NewSQLiteStorage(dir string) StorageEngine {
+	return &sqliteStorage{
+		dir: dir,
+		dbs: make(map[string]*sql.DB),
+	}
+}
+
+func (s *sqliteStorage) Name() string {
+	return storageSQLite
+}
+
+func (s *sqliteStorage) Prepare(ctx context.Context) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	db, ok := s.dbs[raftCtx.ID]
+	if !ok {
+		if err := os.MkdirAll(s.dir, 0o755); err != nil {
+			return err
+		}
+
+		// WAL, so Lookup can read while Update is writing
+		dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate",
+			s.path(raftCtx.ID))
+		db, err = sql.Open("sqlite", dsn)
+		if err != nil {
+			return err
+		}
+		s.dbs[raftCtx.ID] = db
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+quoteIdent(RaftMetadataTable(ctx))+` (
+	namespace TEXT PRIMARY KEY,
+	data BLOB
+)`)
+
+	return err
+}
+
+func (s *sqliteStorage) WithConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sqliteConnKey, SQLiteConn(s.getDB(ctx)))
+}
+
+func (s *sqliteStorage) Begin(ctx context.Context) (context.Context, func() error, error) {
+	tx, err := s.getDB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return context.WithValue(ctx, sqliteConnKey, SQLiteConn(tx)), tx.Commit, nil
+}
+
+// Savepoint in the update transaction
+func (s *sqliteStorage) Savepoint(ctx context.Context) (func() error, func() error, error) {
+	conn := GetSQLiteConnection(ctx)
+	if _, err := conn.ExecContext(ctx, `SAVEPOINT raft_batch`); err != nil {
+		return nil, nil, err
+	}
+
+	release := func() error {
+		_, err := conn.ExecContext(ctx, `RELEASE raft_batch`)
+		return err
+	}
+	rollback := func() error {
+		if _, err := conn.ExecContext(ctx, `ROLLBACK TO raft_batch`); err != nil {
+			return err
+		}
+		return release()
+	}
+
+	return rollback, release, nil
+}
+
+func (s *sqliteStorage) LoadMetadata(ctx context.Context, namespace string) ([]byte, error) {
+	var payload []byte
+	err := GetSQLiteConnection(ctx).QueryRowContext(ctx,
+		`SELECT data FROM `+quoteIdent(RaftMetadataTable(ctx))+` WHERE namespace = ?`, namespace).
+		Scan(&payload)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+func (s *sqliteStorage) SaveMetadata(ctx context.Context, metadata map[string][]byte) error {
+	conn := GetSQLiteConnection(ctx)
+	query := `INSERT INTO ` + quoteIdent(RaftMetadataTable(ctx)) + ` (namespace, data) VALUES (?, ?)
+ON CONFLICT(namespace) DO UPDATE SET data = excluded.data`
+
+	for ns, meta := range metadata {
+		if _, err := conn.ExecContext(ctx, query, ns, meta); err != nil {
+			return fmt.Errorf("save metadata failed (ns: %v): %w", ns, err)
+		}
+	}
+
+	return nil
+}
+
+// PrepareSnapshot copies the whole replica database to a snapshot file
+func (s *sqliteStorage) PrepareSnapshot(ctx context.Context, _ []string) (any, error) {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index, _ := ctx.Value(snapshotIndexKey).(uint64)
+
+	file := fmt.Sprintf("%s.snapshot_%d", s.path(raftCtx.ID), index)
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if _, err := s.getDB(ctx).ExecContext(ctx, `VACUUM INTO ?`, file); err != nil {
+		return nil, fmt.Errorf("failed to copy database to %v: %w", file, err)
+	}
+
+	return file, nil
+}
+
+func (s *sqliteStorage) SaveSnapshot(ctx context.Context, snapshot any, w io.Writer, done <-chan struct{}) error {
+	file, ok := snapshot.(string)
+	if !ok {
+		return fmt.Errorf("%w: unexpected sqlite snapshot %T", ErrInvalidSnapshot, snapshot)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := copyWithDone(&frameWriter{w: w, ft: frameChunk}, f, done); err != nil {
+		return err
+	}
+
+	return writeFrame(w, frameEnd, nil)
+}
+
+func (s *sqliteStorage) ReleaseSnapshot(ctx context.Context, snapshot any) {
+	if file, ok := snapshot.(string); ok {
+		os.Remove(file)
+	}
+}
+
+// RecoverFromSnapshot replaces every table of the replica database with the one in the snapshot
+func (s *sqliteStorage) RecoverFromSnapshot(ctx context.Context, r io.Reader, done <-chan struct{}) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	file := s.path(raftCtx.ID) + ".recover"
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	err = copyWithDone(f, &frameReader{r: r, ft: frameChunk}, done)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	// ATTACH is per connection
+	conn, err := s.getDB(ctx).Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS snapshot`, file); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `DETACH DATABASE snapshot`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := restoreSQLiteTables(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStorage) Close(ctx context.Context) error {
+	raftCtx, err := GetRaftContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	db, ok := s.dbs[raftCtx.ID]
+	if !ok {
+		return nil
+	}
+	delete(s.dbs, raftCtx.ID)
+
+	return db.Close()
+}
+
+func (s *sqliteStorage) path(id string) string {
+	return filepath.Join(s.dir, id+".db")
+}
+
+func (s *sqliteStorage) getDB(ctx context.Context) *sql.DB {
+	raftCtx, _ := GetRaftContext(ctx)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.dbs[raftCtx.ID]
+}
+
+func restoreSQLiteTables(ctx context.Context, tx *sql.Tx) error {
+	current, err := sqliteSchema(ctx, tx, "main")
+	if err != nil {
+		return err
+	}
+
+	recovered, err := sqliteSchema(ctx, tx, "snapshot")
+	if err != nil {
+		return err
+	}
+
+	// tables created after the snapshot was taken
+	for name, obj := range current {
+		if obj.kind != "table" {
+			continue
+		}
+		if _, ok := recovered[name]; ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.`+quoteIdent(name)); err != nil {
+			return err
+		}
+	}
+
+	// create the missing tables first, then the indexes
+	for _, kind := range []string{"table", "index"} {
+		for name, obj := range recovered {
+			if _, ok := current[name]; ok || obj.kind != kind {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, obj.sql); err != nil {
+				return fmt.Errorf("failed to create %v %v: %w", kind, name, err)
+			}
+		}
+	}
+
+	for name, obj := range recovered {
+		if obj.kind != "table" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.`+quoteIdent(name)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO main.`+quoteIdent(name)+` SELECT * FROM snapshot.`+quoteIdent(name)); err != nil {
+			return fmt.Errorf("failed to restore table %v: %w", name, err)
+		}
+	}
+
+	// keep AUTOINCREMENT counters in sync with the restored rows
+	if _, ok := recovered["sqlite_sequence"]; ok {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.sqlite_sequence`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO main.sqlite_sequence SELECT * FROM snapshot.sqlite_sequence`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type sqliteObject struct {
+	kind string
+	sql  string
+}
+
+// sqliteSchema lists the user tables & indexes of the schema.
+// sqlite_sequence is included as it is (only) present if there is an AUTOINCREMENT table.
+func sqliteSchema(ctx context.Context, tx *sql.Tx, schema string) (map[string]sqliteObject, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT type, name, sql FROM `+schema+`.sqlite_master
+WHERE type IN ('table', 'index') AND sql IS NOT NULL AND (name NOT LIKE 'sqlite_%' OR name = 'sqlite_sequence')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]sqliteObject)
+	for rows.Next() {
+		var name string
+		var obj sqliteObject
+		if err := rows.Scan(&obj.kind, &name, &obj.sql); err != nil {
+			return nil, err
+		}
+		if name == "sqlite_sequence" {
+			// managed by sqlite itself
+			obj.kind = "sequence"
+		}
+		result[name] = obj
+	}
+
+	return result, rows.Err()
+}
+
+func copyWithDone(w io.Writer, r io.Reader, done <-chan struct{}) error {
+	buf := make([]byte, snapshotAppChunkSize)
+	for {
+		select {
+		case <-done:
+			return sm.ErrSnapshotStopped
+		default:
+		}
+
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
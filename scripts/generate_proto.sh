@@ -6,7 +6,8 @@ protoc \
     --go_out=types/protobuf \
     --go_opt=paths=source_relative \
     session/claims.proto \
-    session/session.proto
+    session/session.proto \
+    raft/command.proto
 
 echo "Done."
     
@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/desain-gratis/common/delivery/mycontent-api/mycontent"
+)
+
+// Document is a content without Go type, eg. for a table defined only by its JSON Schema.
+// The location of the content is in the "id", "namespace", "ref_ids", "url" & "created_at" fields.
+type Document map[string]any
+
+func (c *Document) WithID(id string) mycontent.Data {
+	c.set("id", id)
+	return c
+}
+
+func (c *Document) ID() string {
+	return c.str("id")
+}
+
+func (c *Document) WithNamespace(id string) mycontent.Data {
+	c.set("namespace", id)
+	return c
+}
+
+func (c *Document) Namespace() string {
+	return c.str("namespace")
+}
+
+func (c *Document) URL() string {
+	return c.str("url")
+}
+
+func (c *Document) WithURL(url string) mycontent.Data {
+	c.set("url", url)
+	return c
+}
+
+func (c *Document) WithCreatedTime(t time.Time) mycontent.Data {
+	c.set("created_at", t.Format(time.RFC3339))
+	return c
+}
+
+func (c *Document) CreatedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, c.str("created_at"))
+	return t
+}
+
+func (c *Document) RefIDs() []string {
+	refIDs, _ := (*c)["ref_ids"].([]any)
+	result := make([]string, 0, len(refIDs))
+	for _, refID := range refIDs {
+		s, _ := refID.(string)
+		result = append(result, s)
+	}
+	return result
+}
+
+// Validate nothing; the document is validated by the schema of the handler
+func (c *Document) Validate() error {
+	return nil
+}
+
+func (c *Document) set(key string, value string) {
+	if *c == nil {
+		*c = make(Document)
+	}
+	(*c)[key] = value
+}
+
+func (c *Document) str(key string) string {
+	s, _ := (*c)[key].(string)
+	return s
+}
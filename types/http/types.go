@@ -45,6 +45,7 @@ type Error struct {
 	HTTPCode int    `json:"http_code,omitempty"`
 	Code     string `json:"code,omitempty"`
 	Message  string `json:"message,omitempty"`
+	Field    string `json:"field,omitempty"` // JSON pointer of the invalid field, if any
 	URL      string `json:"url,omitempty"`
 	IconURL  string `json:"icon_url,omitempty"`
 	ImageURL string `json:"image_url,omitempty"`
@@ -67,3 +68,25 @@ func SerializeError(err error) []byte {
 	}
 	return d
 }
+
+// Problem details of RFC 7807 (application/problem+json); the errors are its extension
+type Problem struct {
+	Type   string  `json:"type,omitempty"`
+	Title  string  `json:"title,omitempty"`
+	Status int     `json:"status,omitempty"`
+	Detail string  `json:"detail,omitempty"`
+	Code   string  `json:"code,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// CommonError of the problem, the same as if it is not written as problem details
+func (p *Problem) CommonError() *CommonError {
+	if len(p.Errors) > 0 {
+		return &CommonError{Errors: p.Errors}
+	}
+	return &CommonError{
+		Errors: []Error{
+			{HTTPCode: p.Status, Code: p.Code, Message: p.Detail},
+		},
+	}
+}